@@ -2301,6 +2301,38 @@ public:
     return Status::OK;
   }
 
+  grpc::Status Capabilities(ServerContext* context, const backend::HealthMessage* request, backend::CapabilitiesResponse* response) {
+    response->set_chat(true);
+    response->set_streaming(true);
+    response->set_grammar(true);
+    response->set_tokenize(true);
+    response->set_embeddings(llama.params.embedding);
+    response->set_vision(llama.multimodal);
+    return Status::OK;
+  }
+
+  grpc::Status TokenizeString(ServerContext* context, const backend::PredictOptions* request, backend::TokenizationResponse* response) {
+    // tokenize the prompt as it is evaluated by the completions
+    const std::vector<llama_token> tokens = llama.tokenize(request->prompt(), llama.add_bos_token);
+    for (const llama_token token : tokens) {
+        response->add_tokens(token);
+    }
+    response->set_length(tokens.size());
+    return Status::OK;
+  }
+
+  grpc::Status Detokenize(ServerContext* context, const backend::DetokenizeRequest* request, backend::Reply* reply) {
+    const std::vector<llama_token> tokens(request->tokens().begin(), request->tokens().end());
+    const int n_vocab = llama_n_vocab(llama.model);
+    for (const llama_token token : tokens) {
+        if (token < 0 || token >= n_vocab) {
+            return grpc::Status(grpc::StatusCode::INVALID_ARGUMENT, "token id " + std::to_string(token) + " is out of the vocabulary");
+        }
+    }
+    reply->set_message(tokens_to_str(llama.ctx, tokens.cbegin(), tokens.cend()));
+    return Status::OK;
+  }
+
   grpc::Status LoadModel(ServerContext* context, const backend::ModelOptions* request, backend::Result* result) {
     // Implement LoadModel RPC
     gpt_params params;
@@ -31,3 +31,7 @@ func (image *Image) GenerateImage(opts *pb.GenerateImageRequest) error {
 		opts.NegativePrompt,
 		opts.Dst)
 }
+
+func (image *Image) Capabilities() (*pb.CapabilitiesResponse, error) {
+	return &pb.CapabilitiesResponse{Images: true}, nil
+}
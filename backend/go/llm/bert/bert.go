@@ -32,3 +32,7 @@ func (llm *Embeddings) Embeddings(opts *pb.PredictOptions) ([]float32, error) {
 
 	return llm.bert.Embeddings(opts.Embeddings, bert.SetThreads(int(opts.Threads)))
 }
+
+func (llm *Embeddings) Capabilities() (*pb.CapabilitiesResponse, error) {
+	return &pb.CapabilitiesResponse{Embeddings: true}, nil
+}
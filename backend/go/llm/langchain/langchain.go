@@ -62,3 +62,7 @@ func (llm *LLM) PredictStream(opts *pb.PredictOptions, results chan string) erro
 
 	return nil
 }
+
+func (llm *LLM) Capabilities() (*pb.CapabilitiesResponse, error) {
+	return &pb.CapabilitiesResponse{Chat: true, Streaming: true}, nil
+}
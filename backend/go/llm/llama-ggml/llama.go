@@ -202,3 +202,7 @@ func (llm *LLM) Embeddings(opts *pb.PredictOptions) ([]float32, error) {
 
 	return llm.llama.Embeddings(opts.Embeddings, predictOptions...)
 }
+
+func (llm *LLM) Capabilities() (*pb.CapabilitiesResponse, error) {
+	return &pb.CapabilitiesResponse{Chat: true, Embeddings: true, Grammar: true, Streaming: true}, nil
+}
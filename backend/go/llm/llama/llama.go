@@ -255,3 +255,7 @@ func (llm *LLM) TokenizeString(opts *pb.PredictOptions) (pb.TokenizationResponse
 		Tokens: tokens,
 	}, nil
 }
+
+func (llm *LLM) Capabilities() (*pb.CapabilitiesResponse, error) {
+	return &pb.CapabilitiesResponse{Chat: true, Embeddings: true, Tokenize: true, Grammar: true, Streaming: true}, nil
+}
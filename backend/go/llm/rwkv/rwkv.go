@@ -93,3 +93,7 @@ func (llm *LLM) TokenizeString(opts *pb.PredictOptions) (pb.TokenizationResponse
 		Tokens: i32Tokens,
 	}, nil
 }
+
+func (llm *LLM) Capabilities() (*pb.CapabilitiesResponse, error) {
+	return &pb.CapabilitiesResponse{Chat: true, Tokenize: true, Streaming: true}, nil
+}
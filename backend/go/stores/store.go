@@ -505,3 +505,7 @@ func (s *Store) StoresFind(opts *pb.StoresFindOptions) (pb.StoresFindResult, err
 		return s.StoresFindFallback(opts)
 	}
 }
+
+func (s *Store) Capabilities() (*pb.CapabilitiesResponse, error) {
+	return &pb.CapabilitiesResponse{Stores: true}, nil
+}
@@ -24,3 +24,7 @@ func (sd *Whisper) Load(opts *pb.ModelOptions) error {
 func (sd *Whisper) AudioTranscription(opts *pb.TranscriptRequest) (schema.TranscriptionResult, error) {
 	return Transcript(sd.whisper, opts.Dst, opts.Language, opts.Translate, uint(opts.Threads))
 }
+
+func (sd *Whisper) Capabilities() (*pb.CapabilitiesResponse, error) {
+	return &pb.CapabilitiesResponse{Transcription: true}, nil
+}
@@ -47,3 +47,7 @@ func New(assetDir string) (*PiperB, error) {
 func (s *PiperB) TTS(text, model, dst string) error {
 	return piper.TextToWav(text, model, s.assetDir, "", dst)
 }
+
+func (sd *Piper) Capabilities() (*pb.CapabilitiesResponse, error) {
+	return &pb.CapabilitiesResponse{Tts: true}, nil
+}
@@ -35,6 +35,10 @@ func ModelEmbedding(s string, tokens []int, loader *model.ModelLoader, backendCo
 		return nil, err
 	}
 
+	if err := loader.CheckCapabilities(modelFile, model.CapabilityEmbeddings); err != nil {
+		return nil, err
+	}
+
 	var fn func() ([]float32, error)
 	switch model := inferenceModel.(type) {
 	case grpc.Backend:
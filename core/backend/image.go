@@ -29,6 +29,10 @@ func ImageGeneration(height, width, mode, step, seed int, positive_prompt, negat
 		return nil, err
 	}
 
+	if err := loader.CheckCapabilities(backendConfig.Model, model.CapabilityImages); err != nil {
+		return nil, err
+	}
+
 	fn := func() error {
 		_, err := inferenceModel.GenerateImage(
 			appConfig.Context,
@@ -17,6 +17,7 @@ import (
 	"github.com/mudler/LocalAI/pkg/grpc/proto"
 	model "github.com/mudler/LocalAI/pkg/model"
 	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
 )
 
 type LLMResponse struct {
@@ -57,7 +58,7 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 		if _, err := os.Stat(modelFile); os.IsNotExist(err) {
 			utils.ResetDownloadTimers()
 			// if we failed to load the model, we try to download it
-			err := gallery.InstallModelFromGallery(o.Galleries, modelFile, loader.ModelPath, gallery.GalleryModel{}, utils.DisplayDownloadFunction, o.EnforcePredownloadScans)
+			err := gallery.InstallModelFromGallery(o.Context, o.Galleries, modelFile, loader.ModelPath, gallery.GalleryModel{}, utils.DisplayDownloadFunction, o.EnforcePredownloadScans)
 			if err != nil {
 				return nil, err
 			}
@@ -74,6 +75,23 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 		return nil, err
 	}
 
+	capabilities := []string{model.CapabilityChat}
+	if tokenCallback != nil {
+		capabilities = append(capabilities, model.CapabilityStreaming)
+	}
+	if len(images) > 0 {
+		capabilities = append(capabilities, model.CapabilityVision)
+	}
+	if err := loader.CheckCapabilities(modelFile, capabilities...); err != nil {
+		return nil, err
+	}
+	// the backends without grammars ignored them before advertising their capabilities, as the function calls
+	// and the JSON replies can still be parsed from their output: the grammar is dropped instead of failing
+	if c.Grammar != "" && loader.CheckCapabilities(modelFile, model.CapabilityGrammar) != nil {
+		log.Warn().Str("model", modelFile).Msg("the backend of the model does not support grammars, the grammar is ignored")
+		c.Grammar = ""
+	}
+
 	var protoMessages []*proto.Message
 	// if we are using the tokenizer template, we need to convert the messages to proto messages
 	// unless the prompt has already been tokenized (non-chat endpoints + functions)
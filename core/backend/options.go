@@ -6,9 +6,11 @@ import (
 	"path/filepath"
 
 	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/p2p"
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
 	"github.com/mudler/LocalAI/pkg/model"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/proto"
 )
 
 func modelOpts(c config.BackendConfig, so *config.ApplicationConfig, opts []model.Option) []model.Option {
@@ -28,6 +30,12 @@ func modelOpts(c config.BackendConfig, so *config.ApplicationConfig, opts []mode
 		opts = append(opts, model.WithGRPCAttemptsDelay(c.GRPC.AttemptsSleepTime))
 	}
 
+	// Backends discovered from p2p workers come first, so
+	// explicitly configured external backends take precedence
+	for k, v := range p2p.GetAvailableBackends() {
+		opts = append(opts, model.WithExternalBackend(k, v))
+	}
+
 	for k, v := range so.ExternalGRPCBackends {
 		opts = append(opts, model.WithExternalBackend(k, v))
 	}
@@ -108,6 +116,20 @@ func gRPCModelOpts(c config.BackendConfig) *pb.ModelOptions {
 	}
 }
 
+// LoadOptionsChanged returns true if the options used to load the model differ between two configurations,
+// so that the model has to be loaded again to apply the new one
+func LoadOptionsChanged(old, new config.BackendConfig) bool {
+	if old.Backend != new.Backend || old.Model != new.Model {
+		return true
+	}
+	o, n := gRPCModelOpts(old), gRPCModelOpts(new)
+	// the random seeds are not a change
+	if *old.Seed == config.RAND_SEED && *new.Seed == config.RAND_SEED {
+		o.Seed, n.Seed = 0, 0
+	}
+	return !proto.Equal(o, n)
+}
+
 func gRPCPredictOpts(c config.BackendConfig, modelPath string) *pb.PredictOptions {
 	promptCachePath := ""
 	if c.PromptCachePath != "" {
@@ -0,0 +1,31 @@
+package backend
+
+import (
+	"github.com/mudler/LocalAI/core/config"
+	model "github.com/mudler/LocalAI/pkg/model"
+)
+
+// PreloadModel loads the model of the given config in memory without running any inference
+func PreloadModel(c config.BackendConfig, loader *model.ModelLoader, o *config.ApplicationConfig) error {
+	threads := c.Threads
+	if threads == nil || (*threads == 0 && o.Threads != 0) {
+		threads = &o.Threads
+	}
+
+	opts := modelOpts(c, o, []model.Option{
+		model.WithLoadGRPCLoadModelOpts(gRPCModelOpts(c)),
+		model.WithThreads(uint32(*threads)),
+		model.WithAssetDir(o.AssetsDestination),
+		model.WithModel(c.Model),
+		model.WithContext(o.Context),
+	})
+
+	var err error
+	if c.Backend == "" {
+		_, err = loader.GreedyLoader(opts...)
+	} else {
+		opts = append(opts, model.WithBackendString(c.Backend))
+		_, err = loader.BackendLoader(opts...)
+	}
+	return err
+}
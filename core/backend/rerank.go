@@ -33,6 +33,10 @@ func Rerank(backend, modelFile string, request *proto.RerankRequest, loader *mod
 		return nil, fmt.Errorf("could not load rerank model")
 	}
 
+	if err := loader.CheckCapabilities(modelFile, model.CapabilityRerank); err != nil {
+		return nil, err
+	}
+
 	res, err := rerankModel.Rerank(context.Background(), request)
 
 	return res, err
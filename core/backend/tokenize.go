@@ -0,0 +1,69 @@
+package backend
+
+import (
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/mudler/LocalAI/pkg/grpc/proto"
+	model "github.com/mudler/LocalAI/pkg/model"
+)
+
+// ModelTokenize returns the tokens of a string, as the backend of the model tokenizes the prompts
+func ModelTokenize(s string, loader *model.ModelLoader, backendConfig config.BackendConfig, appConfig *config.ApplicationConfig) ([]int32, error) {
+	inferenceModel, err := loadTokenizer(loader, backendConfig, appConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	predictOptions := gRPCPredictOpts(backendConfig, loader.ModelPath)
+	predictOptions.Prompt = s
+
+	res, err := inferenceModel.TokenizeString(appConfig.Context, predictOptions)
+	if err != nil {
+		return nil, err
+	}
+	if res.Tokens == nil {
+		return []int32{}, nil
+	}
+	return res.Tokens, nil
+}
+
+// ModelDetokenize returns the text of a list of tokens of a model
+func ModelDetokenize(tokens []int32, loader *model.ModelLoader, backendConfig config.BackendConfig, appConfig *config.ApplicationConfig) (string, error) {
+	inferenceModel, err := loadTokenizer(loader, backendConfig, appConfig)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := inferenceModel.Detokenize(appConfig.Context, &proto.DetokenizeRequest{Tokens: tokens})
+	if err != nil {
+		return "", err
+	}
+	return string(res.Message), nil
+}
+
+func loadTokenizer(loader *model.ModelLoader, backendConfig config.BackendConfig, appConfig *config.ApplicationConfig) (grpc.Backend, error) {
+	opts := modelOpts(backendConfig, appConfig, []model.Option{
+		model.WithLoadGRPCLoadModelOpts(gRPCModelOpts(backendConfig)),
+		model.WithThreads(uint32(*backendConfig.Threads)),
+		model.WithAssetDir(appConfig.AssetsDestination),
+		model.WithModel(backendConfig.Model),
+		model.WithContext(appConfig.Context),
+	})
+
+	var inferenceModel grpc.Backend
+	var err error
+	if backendConfig.Backend == "" {
+		inferenceModel, err = loader.GreedyLoader(opts...)
+	} else {
+		opts = append(opts, model.WithBackendString(backendConfig.Backend))
+		inferenceModel, err = loader.BackendLoader(opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loader.CheckCapabilities(backendConfig.Model, model.CapabilityTokenize); err != nil {
+		return nil, err
+	}
+	return inferenceModel, nil
+}
@@ -30,6 +30,10 @@ func ModelTranscription(audio, language string, translate bool, ml *model.ModelL
 		return nil, fmt.Errorf("could not load whisper model")
 	}
 
+	if err := ml.CheckCapabilities(backendConfig.Model, model.CapabilityTranscription); err != nil {
+		return nil, err
+	}
+
 	return whisperModel.AudioTranscription(context.Background(), &proto.TranscriptRequest{
 		Dst:       audio,
 		Language:  language,
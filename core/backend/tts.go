@@ -62,6 +62,10 @@ func ModelTTS(
 		return "", nil, fmt.Errorf("could not load piper model")
 	}
 
+	if err := loader.CheckCapabilities(modelFile, model.CapabilityTTS); err != nil {
+		return "", nil, err
+	}
+
 	if err := os.MkdirAll(appConfig.AudioDir, 0750); err != nil {
 		return "", nil, fmt.Errorf("failed creating audio directory: %s", err)
 	}
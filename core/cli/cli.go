@@ -13,6 +13,6 @@ var CLI struct {
 	Models     ModelsCMD     `cmd:"" help:"Manage LocalAI models and definitions"`
 	TTS        TTSCMD        `cmd:"" help:"Convert text to speech"`
 	Transcript TranscriptCMD `cmd:"" help:"Convert audio to text"`
-	Worker     worker.Worker `cmd:"" help:"Run workers to distribute workload (llama.cpp-only)"`
+	Worker     worker.Worker `cmd:"" help:"Run workers to distribute workload"`
 	Util       UtilCMD       `cmd:"" help:"Utility commands"`
 }
@@ -1,16 +1,24 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"strings"
 
 	cliContext "github.com/mudler/LocalAI/core/cli/context"
 	"github.com/mudler/LocalAI/core/config"
 
 	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/assets"
 	"github.com/mudler/LocalAI/pkg/downloader"
+	"github.com/mudler/LocalAI/pkg/oci"
 	"github.com/mudler/LocalAI/pkg/startup"
+	"github.com/mudler/LocalAI/pkg/utils"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/rs/zerolog/log"
 	"github.com/schollz/progressbar/v3"
 )
@@ -27,13 +35,106 @@ type ModelsList struct {
 type ModelsInstall struct {
 	DisablePredownloadScan bool     `env:"LOCALAI_DISABLE_PREDOWNLOAD_SCAN" help:"If true, disables the best-effort security scanner before downloading any files." group:"hardening" default:"false"`
 	ModelArgs              []string `arg:"" optional:"" name:"models" help:"Model configuration URLs to load"`
+	DownloadConnections    int      `env:"LOCALAI_DOWNLOAD_CONNECTIONS,DOWNLOAD_CONNECTIONS" default:"1" help:"Number of parallel connections used to download a model file, when the server supports it" group:"models"`
+	DownloadRetries        int      `env:"LOCALAI_DOWNLOAD_RETRIES,DOWNLOAD_RETRIES" default:"3" help:"Number of times a download is retried after a transient error" group:"models"`
+	BlobsPath              string   `env:"LOCALAI_BLOBS_PATH,BLOBS_PATH" type:"path" help:"Path of the content-addressed store of the model files (disabled if empty)" group:"storage"`
+	HuggingFaceToken       string   `env:"LOCALAI_HF_TOKEN,HF_TOKEN" name:"huggingface-token" help:"Token used to download models from gated or private HuggingFace repositories" group:"models"`
+	OCIRegistries          string   `env:"LOCALAI_OCI_REGISTRIES,OCI_REGISTRIES" help:"JSON list of the credentials of the OCI registries" group:"models"`
 
 	ModelsCMDFlags `embed:""`
 }
 
+type ModelsGC struct {
+	BlobsPath  string `env:"LOCALAI_BLOBS_PATH,BLOBS_PATH" type:"path" required:"" help:"Path of the content-addressed store of the model files" group:"storage"`
+	ModelsPath string `env:"LOCALAI_MODELS_PATH,MODELS_PATH" type:"path" default:"${basepath}/models" help:"Path containing models used for inferencing" group:"storage"`
+}
+
+type ModelsPush struct {
+	Model         string `arg:"" help:"Name of the model to push"`
+	Image         string `arg:"" help:"Destination image, e.g. oci://registry.example.com/models/phi-2:latest"`
+	OCIRegistries string `env:"LOCALAI_OCI_REGISTRIES,OCI_REGISTRIES" help:"JSON list of the credentials of the OCI registries" group:"models"`
+	ModelsPath    string `env:"LOCALAI_MODELS_PATH,MODELS_PATH" type:"path" default:"${basepath}/models" help:"Path containing models used for inferencing" group:"storage"`
+}
+
+type ModelsOutdated struct {
+	ModelsCMDFlags `embed:""`
+}
+
+type ModelsUpgrade struct {
+	DisablePredownloadScan bool     `env:"LOCALAI_DISABLE_PREDOWNLOAD_SCAN" help:"If true, disables the best-effort security scanner before downloading any files." group:"hardening" default:"false"`
+	ModelArgs              []string `arg:"" name:"models" help:"Names of the models to upgrade"`
+
+	ModelsCMDFlags `embed:""`
+}
+
+type ModelsRollback struct {
+	ModelArgs []string `arg:"" name:"models" help:"Names of the models to restore to the version preceding their last upgrade"`
+
+	ModelsCMDFlags `embed:""`
+}
+
+type ModelsExport struct {
+	ModelArgs []string `arg:"" name:"models" help:"Names of the models to export"`
+	Output    string   `short:"o" required:"" type:"path" help:"Path of the bundle to write"`
+
+	ModelsCMDFlags `embed:""`
+}
+
+type ModelsImport struct {
+	Bundle string `arg:"" type:"existingfile" help:"Path of the bundle to import"`
+	Force  bool   `help:"Replace the existing files which differ from the ones of the bundle"`
+
+	ModelsCMDFlags `embed:""`
+}
+
+type ModelsReconcile struct {
+	DisablePredownloadScan bool   `env:"LOCALAI_DISABLE_PREDOWNLOAD_SCAN" help:"If true, disables the best-effort security scanner before downloading any files." group:"hardening" default:"false"`
+	Manifest               string `arg:"" type:"existingfile" help:"Path of the models manifest"`
+	DryRun                 bool   `help:"Only print the changes which would be applied"`
+
+	ModelsCMDFlags `embed:""`
+}
+
+type ModelsShow struct {
+	Model      string `arg:"" help:"Name of the model"`
+	ModelsPath string `env:"LOCALAI_MODELS_PATH,MODELS_PATH" type:"path" default:"${basepath}/models" help:"Path containing models used for inferencing" group:"storage"`
+}
+
+type ModelsValidate struct {
+	ModelArgs            []string `arg:"" optional:"" name:"models" help:"Names of the models to check (all the models by default)"`
+	SkipChecksums        bool     `help:"Do not verify the SHA256 of the files of the models"`
+	Strict               bool     `help:"Fail on the warnings too"`
+	Format               string   `enum:"text,json" default:"text" help:"Format of the report [${enum}]"`
+	BackendAssetsPath    string   `env:"LOCALAI_BACKEND_ASSETS_PATH,BACKEND_ASSETS_PATH" type:"path" default:"/tmp/localai/backend_data" help:"Path used to extract libraries that are required by some of the backends in runtime" group:"storage"`
+	ExternalGRPCBackends []string `env:"LOCALAI_EXTERNAL_GRPC_BACKENDS,EXTERNAL_GRPC_BACKENDS" help:"A list of external grpc backends (name:uri, where uri is a file, host:port or grpc(s)://host:port?token=...)" group:"backends"`
+	ModelsPath           string   `env:"LOCALAI_MODELS_PATH,MODELS_PATH" type:"path" default:"${basepath}/models" help:"Path containing models used for inferencing" group:"storage"`
+}
+
 type ModelsCMD struct {
-	List    ModelsList    `cmd:"" help:"List the models available in your galleries" default:"withargs"`
-	Install ModelsInstall `cmd:"" help:"Install a model from the gallery"`
+	List      ModelsList      `cmd:"" help:"List the models available in your galleries" default:"withargs"`
+	Install   ModelsInstall   `cmd:"" help:"Install a model from the gallery"`
+	GC        ModelsGC        `cmd:"" name:"gc" help:"Remove the model files of the blob store which are not used by any model anymore"`
+	Push      ModelsPush      `cmd:"" help:"Push a model (configuration, templates and weights) to an OCI registry"`
+	Outdated  ModelsOutdated  `cmd:"" help:"List the installed models which changed in your galleries"`
+	Upgrade   ModelsUpgrade   `cmd:"" help:"Upgrade installed models to their version in your galleries"`
+	Rollback  ModelsRollback  `cmd:"" help:"Restore the version of the models preceding their last upgrade"`
+	Export    ModelsExport    `cmd:"" help:"Export models (configuration, gallery metadata, templates and weights) to a bundle"`
+	Import    ModelsImport    `cmd:"" help:"Verify and install the models of a bundle"`
+	Reconcile ModelsReconcile `cmd:"" help:"Install, apply again or delete models to match a models manifest"`
+	Show      ModelsShow      `cmd:"" help:"Show the configuration of a model, with the profiles and the models it extends merged"`
+	Validate  ModelsValidate  `cmd:"" help:"Check the configurations, templates, files and backends of the models"`
+}
+
+func setRegistryCredentials(registries string) error {
+	if registries == "" {
+		return nil
+	}
+	credentials, err := oci.ParseRegistryCredentials(registries)
+	if err != nil {
+		return err
+	}
+	oci.SetRegistryCredentials(credentials)
+	return nil
 }
 
 func (ml *ModelsList) Run(ctx *cliContext.Context) error {
@@ -62,6 +163,16 @@ func (mi *ModelsInstall) Run(ctx *cliContext.Context) error {
 		log.Error().Err(err).Msg("unable to load galleries")
 	}
 
+	if err := setRegistryCredentials(mi.OCIRegistries); err != nil {
+		return err
+	}
+	downloader.SetDefaultOptions(
+		downloader.WithConnections(mi.DownloadConnections),
+		downloader.WithRetries(mi.DownloadRetries),
+		downloader.WithBlobStore(mi.BlobsPath),
+		downloader.WithHuggingFaceToken(mi.HuggingFaceToken),
+	)
+
 	for _, modelName := range mi.ModelArgs {
 
 		progressBar := progressbar.NewOptions(
@@ -98,10 +209,221 @@ func (mi *ModelsInstall) Run(ctx *cliContext.Context) error {
 			log.Info().Str("model", modelName).Str("license", model.License).Msg("installing model")
 		}
 
-		err = startup.InstallModels(galleries, "", mi.ModelsPath, !mi.DisablePredownloadScan, progressCallback, modelName)
+		err = startup.InstallModels(context.Background(), galleries, "", mi.ModelsPath, !mi.DisablePredownloadScan, progressCallback, modelName)
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+func (mg *ModelsGC) Run(ctx *cliContext.Context) error {
+	removed, err := downloader.NewBlobStore(mg.BlobsPath).GC(mg.ModelsPath)
+	if err != nil {
+		return err
+	}
+	for _, sha := range removed {
+		fmt.Printf(" - %s\n", sha)
+	}
+	log.Info().Int("removed", len(removed)).Msg("blob store garbage collected")
+	return nil
+}
+
+func (mp *ModelsPush) Run(ctx *cliContext.Context) error {
+	if !strings.HasPrefix(mp.Image, downloader.OCIPrefix) {
+		return fmt.Errorf("invalid image %q, expected %sregistry/repository:tag", mp.Image, downloader.OCIPrefix)
+	}
+	image := strings.TrimPrefix(mp.Image, downloader.OCIPrefix)
+
+	bcl := config.NewBackendConfigLoader(mp.ModelsPath)
+	if err := bcl.LoadBackendConfigsFromPath(mp.ModelsPath); err != nil {
+		return err
+	}
+	cfg, exists := bcl.GetBackendConfig(mp.Model)
+	if !exists {
+		return fmt.Errorf("model %q not found in %s", mp.Model, mp.ModelsPath)
+	}
+
+	files := cfg.ModelFiles(mp.ModelsPath)
+	for _, f := range files {
+		log.Info().Str("model", mp.Model).Msgf("packaging %s", f)
+	}
+
+	if err := setRegistryCredentials(mp.OCIRegistries); err != nil {
+		return err
+	}
+	digest, err := oci.PushFiles(image, mp.ModelsPath, files, map[string]string{
+		ocispec.AnnotationTitle:       cfg.Name,
+		ocispec.AnnotationDescription: cfg.Description,
+	}, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed pushing %q to %s: %w", mp.Model, image, err)
+	}
+
+	log.Info().Str("model", mp.Model).Str("image", image).Str("digest", digest).Msg("model pushed")
+	return nil
+}
+
+func (ms *ModelsShow) Run(ctx *cliContext.Context) error {
+	file, err := config.FindBackendConfigFile(ms.ModelsPath, ms.Model)
+	if err != nil {
+		return err
+	}
+	resolved, err := config.ResolveBackendConfigFile(file)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(resolved))
+	return nil
+}
+
+func (mv *ModelsValidate) Run(ctx *cliContext.Context) error {
+	if err := assets.ExtractFiles(ctx.BackendAssets, mv.BackendAssetsPath); err != nil {
+		log.Warn().Err(err).Msg("failed extracting the backend assets, the backends might be reported as missing")
+	}
+
+	opts := []config.AppOption{
+		config.WithModelPath(mv.ModelsPath),
+		config.WithBackendAssetsOutput(mv.BackendAssetsPath),
+	}
+	for _, v := range mv.ExternalGRPCBackends {
+		backend, uri, _ := strings.Cut(v, ":")
+		opts = append(opts, config.WithExternalBackend(backend, uri))
+	}
+
+	report, err := services.Doctor(config.NewApplicationConfig(opts...), !mv.SkipChecksums, mv.ModelArgs...)
+	if err != nil {
+		return err
+	}
+
+	if mv.Format == "json" {
+		dat, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(dat))
+	} else {
+		for _, p := range report.Problems {
+			fmt.Printf(" * [%s] %s (%s) %s: %s\n", p.Severity, p.Model, p.File, p.Check, p.Message)
+		}
+		fmt.Printf("%d models checked, %d errors, %d warnings\n", report.Models, report.Errors, report.Warnings)
+	}
+
+	if report.Errors > 0 || (mv.Strict && report.Warnings > 0) {
+		return fmt.Errorf("%d errors and %d warnings found", report.Errors, report.Warnings)
+	}
+	return nil
+}
+
+func (mo *ModelsOutdated) Run(ctx *cliContext.Context) error {
+	var galleries []config.Gallery
+	if err := json.Unmarshal([]byte(mo.Galleries), &galleries); err != nil {
+		log.Error().Err(err).Msg("unable to load galleries")
+	}
+
+	updates, err := gallery.CheckModelUpdates(galleries, mo.ModelsPath)
+	if err != nil {
+		return err
+	}
+	for _, u := range updates {
+		changes := []string{}
+		if u.ConfigChanged {
+			changes = append(changes, "config")
+		}
+		for _, f := range u.ChangedFiles {
+			changes = append(changes, "~"+f)
+		}
+		for _, f := range u.AddedFiles {
+			changes = append(changes, "+"+f)
+		}
+		for _, f := range u.RemovedFiles {
+			changes = append(changes, "-"+f)
+		}
+		for _, t := range u.ChangedTemplates {
+			changes = append(changes, "template "+t)
+		}
+		fmt.Printf(" * %s (%s@%s): %s\n", u.Name, u.Gallery, u.GalleryModel, strings.Join(changes, ", "))
+	}
+	return nil
+}
+
+func (mu *ModelsUpgrade) Run(ctx *cliContext.Context) error {
+	var galleries []config.Gallery
+	if err := json.Unmarshal([]byte(mu.Galleries), &galleries); err != nil {
+		log.Error().Err(err).Msg("unable to load galleries")
+	}
+
+	for _, modelName := range mu.ModelArgs {
+		if err := gallery.UpgradeModel(context.Background(), galleries, mu.ModelsPath, modelName, utils.DisplayDownloadFunction, !mu.DisablePredownloadScan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mr *ModelsRollback) Run(ctx *cliContext.Context) error {
+	for _, modelName := range mr.ModelArgs {
+		if err := gallery.RollbackModel(mr.ModelsPath, modelName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (me *ModelsExport) Run(ctx *cliContext.Context) error {
+	f, err := os.Create(me.Output)
+	if err != nil {
+		return err
+	}
+	if err := gallery.ExportModels(me.ModelsPath, me.ModelArgs, f); err != nil {
+		f.Close()
+		os.Remove(me.Output)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	log.Info().Strs("models", me.ModelArgs).Str("bundle", me.Output).Msg("models exported")
+	return nil
+}
+
+func (mi *ModelsImport) Run(ctx *cliContext.Context) error {
+	f, err := os.Open(mi.Bundle)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	names, err := gallery.ImportModels(mi.ModelsPath, f, mi.Force, utils.DisplayDownloadFunction)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Printf(" * %s\n", name)
+	}
+	return nil
+}
+
+func (mr *ModelsReconcile) Run(ctx *cliContext.Context) error {
+	var galleries []config.Gallery
+	if err := json.Unmarshal([]byte(mr.Galleries), &galleries); err != nil {
+		log.Error().Err(err).Msg("unable to load galleries")
+	}
+
+	dat, err := os.ReadFile(mr.Manifest)
+	if err != nil {
+		return err
+	}
+	manifest, err := gallery.ReadManifest(dat)
+	if err != nil {
+		return err
+	}
+
+	actions, err := gallery.ReconcileManifest(context.Background(), galleries, mr.ModelsPath, manifest, mr.DryRun, utils.DisplayDownloadFunction, !mr.DisablePredownloadScan)
+	if mr.DryRun {
+		for _, a := range actions {
+			fmt.Println(a)
+		}
+	}
+	return err
+}
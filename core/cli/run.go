@@ -26,43 +26,62 @@ type RunCMD struct {
 	AudioPath                    string        `env:"LOCALAI_AUDIO_PATH,AUDIO_PATH" type:"path" default:"/tmp/generated/audio" help:"Location for audio generated by backends (e.g. piper)" group:"storage"`
 	UploadPath                   string        `env:"LOCALAI_UPLOAD_PATH,UPLOAD_PATH" type:"path" default:"/tmp/localai/upload" help:"Path to store uploads from files api" group:"storage"`
 	ConfigPath                   string        `env:"LOCALAI_CONFIG_PATH,CONFIG_PATH" default:"/tmp/localai/config" group:"storage"`
-	LocalaiConfigDir             string        `env:"LOCALAI_CONFIG_DIR" type:"path" default:"${basepath}/configuration" help:"Directory for dynamic loading of certain configuration files (currently api_keys.json and external_backends.json)" group:"storage"`
+	SecretsPath                  string        `env:"LOCALAI_SECRETS_PATH" type:"path" default:"/run/secrets" help:"Directory of the secret files the configuration files can reference (file: variables)" group:"storage"`
+	ConversationsTTL             time.Duration `env:"LOCALAI_CONVERSATIONS_TTL" default:"24h" help:"Time after its last message after which a conversation stored on the server is deleted (0 to keep them)" group:"storage"`
+	BlobsPath                    string        `env:"LOCALAI_BLOBS_PATH,BLOBS_PATH" type:"path" help:"Path of the content-addressed store of the model files, which allows to download and store only once the files shared between models (disabled if empty)" group:"storage"`
+	BackendLogsPath              string        `env:"LOCALAI_BACKEND_LOGS_PATH,BACKEND_LOGS_PATH" type:"path" help:"Path to store the output of the backends, one rotated file per model (disabled if empty)" group:"storage"`
+	LocalaiConfigDir             string        `env:"LOCALAI_CONFIG_DIR" type:"path" default:"${basepath}/configuration" help:"Directory for dynamic loading of certain configuration files (currently api_keys.json, external_backends.json and models_manifest.yaml)" group:"storage"`
 	LocalaiConfigDirPollInterval time.Duration `env:"LOCALAI_CONFIG_DIR_POLL_INTERVAL" help:"Typically the config path picks up changes automatically, but if your system has broken fsnotify events, set this to an interval to poll the LocalAI Config Dir (example: 1m)" group:"storage"`
+	ModelsPathPollInterval       time.Duration `env:"LOCALAI_MODELS_PATH_POLL_INTERVAL" help:"The changes of the model configuration files in the models path are applied automatically, but if your system has broken fsnotify events, set this to an interval to poll the models path (example: 30s)" group:"storage"`
+	DisableModelsWatcher         bool          `env:"LOCALAI_DISABLE_MODELS_WATCHER" help:"Do not apply the changes of the model configuration files in the models path until restarted" group:"storage"`
 	// The alias on this option is there to preserve functionality with the old `--config-file` parameter
 	ModelsConfigFile string `env:"LOCALAI_MODELS_CONFIG_FILE,CONFIG_FILE" aliases:"config-file" help:"YAML file containing a list of model backend configs" group:"storage"`
 
-	Galleries           string   `env:"LOCALAI_GALLERIES,GALLERIES" help:"JSON list of galleries" group:"models" default:"${galleries}"`
-	AutoloadGalleries   bool     `env:"LOCALAI_AUTOLOAD_GALLERIES,AUTOLOAD_GALLERIES" group:"models"`
-	RemoteLibrary       string   `env:"LOCALAI_REMOTE_LIBRARY,REMOTE_LIBRARY" default:"${remoteLibraryURL}" help:"A LocalAI remote library URL" group:"models"`
-	PreloadModels       string   `env:"LOCALAI_PRELOAD_MODELS,PRELOAD_MODELS" help:"A List of models to apply in JSON at start" group:"models"`
-	Models              []string `env:"LOCALAI_MODELS,MODELS" help:"A List of model configuration URLs to load" group:"models"`
-	PreloadModelsConfig string   `env:"LOCALAI_PRELOAD_MODELS_CONFIG,PRELOAD_MODELS_CONFIG" help:"A List of models to apply at startup. Path to a YAML config file" group:"models"`
+	Galleries              string        `env:"LOCALAI_GALLERIES,GALLERIES" help:"JSON list of galleries" group:"models" default:"${galleries}"`
+	AutoloadGalleries      bool          `env:"LOCALAI_AUTOLOAD_GALLERIES,AUTOLOAD_GALLERIES" group:"models"`
+	GalleryRefreshInterval time.Duration `env:"LOCALAI_GALLERY_REFRESH_INTERVAL" default:"1h" help:"Interval after which the gallery indexes cached in memory are downloaded again (0 to download them on every request)" group:"models"`
+	GalleryWebhooks        []string      `env:"LOCALAI_GALLERY_WEBHOOKS" help:"URLs notified with a POST request when a model gallery job completes, fails or is cancelled" group:"models"`
+	ModelsManifestDryRun   bool          `env:"LOCALAI_MODELS_MANIFEST_DRY_RUN" help:"Only log the changes the models manifest (models_manifest.yaml in the configuration directory) would apply" group:"models"`
+	RemoteLibrary          string        `env:"LOCALAI_REMOTE_LIBRARY,REMOTE_LIBRARY" default:"${remoteLibraryURL}" help:"A LocalAI remote library URL" group:"models"`
+	PreloadModels          string        `env:"LOCALAI_PRELOAD_MODELS,PRELOAD_MODELS" help:"A List of models to apply in JSON at start" group:"models"`
+	Models                 []string      `env:"LOCALAI_MODELS,MODELS" help:"A List of model configuration URLs to load" group:"models"`
+	PreloadModelsConfig    string        `env:"LOCALAI_PRELOAD_MODELS_CONFIG,PRELOAD_MODELS_CONFIG" help:"A List of models to apply at startup. Path to a YAML config file" group:"models"`
+	DownloadConnections    int           `env:"LOCALAI_DOWNLOAD_CONNECTIONS,DOWNLOAD_CONNECTIONS" default:"1" help:"Number of parallel connections used to download a model file, when the server supports it" group:"models"`
+	DownloadRetries        int           `env:"LOCALAI_DOWNLOAD_RETRIES,DOWNLOAD_RETRIES" default:"3" help:"Number of times a download is retried after a transient error" group:"models"`
+	OCIRegistries          string        `env:"LOCALAI_OCI_REGISTRIES,OCI_REGISTRIES" help:"JSON list of the credentials of the OCI registries, e.g. [{\"registry\":\"registry.example.com\",\"username\":\"user\",\"password\":\"secret\"}]. The docker config.json is used for the other registries" group:"models"`
+	HuggingFaceToken       string        `env:"LOCALAI_HF_TOKEN,HF_TOKEN" name:"huggingface-token" help:"Token used to download models from gated or private HuggingFace repositories" group:"models"`
 
 	F16         bool `name:"f16" env:"LOCALAI_F16,F16" help:"Enable GPU acceleration" group:"performance"`
 	Threads     int  `env:"LOCALAI_THREADS,THREADS" short:"t" help:"Number of threads used for parallel computation. Usage of the number of physical cores in the system is suggested" group:"performance"`
 	ContextSize int  `env:"LOCALAI_CONTEXT_SIZE,CONTEXT_SIZE" default:"512" help:"Default context size for models" group:"performance"`
 
-	Address                string   `env:"LOCALAI_ADDRESS,ADDRESS" default:":8080" help:"Bind address for the API server" group:"api"`
-	CORS                   bool     `env:"LOCALAI_CORS,CORS" help:"" group:"api"`
-	CORSAllowOrigins       string   `env:"LOCALAI_CORS_ALLOW_ORIGINS,CORS_ALLOW_ORIGINS" group:"api"`
-	LibraryPath            string   `env:"LOCALAI_LIBRARY_PATH,LIBRARY_PATH" help:"Path to the library directory (for e.g. external libraries used by backends)" default:"/usr/share/local-ai/libs" group:"backends"`
-	CSRF                   bool     `env:"LOCALAI_CSRF" help:"Enables fiber CSRF middleware" group:"api"`
-	UploadLimit            int      `env:"LOCALAI_UPLOAD_LIMIT,UPLOAD_LIMIT" default:"15" help:"Default upload-limit in MB" group:"api"`
-	APIKeys                []string `env:"LOCALAI_API_KEY,API_KEY" help:"List of API Keys to enable API authentication. When this is set, all the requests must be authenticated with one of these API keys" group:"api"`
-	DisableWebUI           bool     `env:"LOCALAI_DISABLE_WEBUI,DISABLE_WEBUI" default:"false" help:"Disable webui" group:"api"`
-	DisablePredownloadScan bool     `env:"LOCALAI_DISABLE_PREDOWNLOAD_SCAN" help:"If true, disables the best-effort security scanner before downloading any files." group:"hardening" default:"false"`
-	OpaqueErrors           bool     `env:"LOCALAI_OPAQUE_ERRORS" default:"false" help:"If true, all error responses are replaced with blank 500 errors. This is intended only for hardening against information leaks and is normally not recommended." group:"hardening"`
-	Peer2Peer              bool     `env:"LOCALAI_P2P,P2P" name:"p2p" default:"false" help:"Enable P2P mode" group:"p2p"`
-	Peer2PeerToken         string   `env:"LOCALAI_P2P_TOKEN,P2P_TOKEN,TOKEN" name:"p2ptoken" help:"Token for P2P mode (optional)" group:"p2p"`
-	ParallelRequests       bool     `env:"LOCALAI_PARALLEL_REQUESTS,PARALLEL_REQUESTS" help:"Enable backends to handle multiple requests in parallel if they support it (e.g.: llama.cpp or vllm)" group:"backends"`
-	SingleActiveBackend    bool     `env:"LOCALAI_SINGLE_ACTIVE_BACKEND,SINGLE_ACTIVE_BACKEND" help:"Allow only one backend to be run at a time" group:"backends"`
-	PreloadBackendOnly     bool     `env:"LOCALAI_PRELOAD_BACKEND_ONLY,PRELOAD_BACKEND_ONLY" default:"false" help:"Do not launch the API services, only the preloaded models / backends are started (useful for multi-node setups)" group:"backends"`
-	ExternalGRPCBackends   []string `env:"LOCALAI_EXTERNAL_GRPC_BACKENDS,EXTERNAL_GRPC_BACKENDS" help:"A list of external grpc backends" group:"backends"`
-	EnableWatchdogIdle     bool     `env:"LOCALAI_WATCHDOG_IDLE,WATCHDOG_IDLE" default:"false" help:"Enable watchdog for stopping backends that are idle longer than the watchdog-idle-timeout" group:"backends"`
-	WatchdogIdleTimeout    string   `env:"LOCALAI_WATCHDOG_IDLE_TIMEOUT,WATCHDOG_IDLE_TIMEOUT" default:"15m" help:"Threshold beyond which an idle backend should be stopped" group:"backends"`
-	EnableWatchdogBusy     bool     `env:"LOCALAI_WATCHDOG_BUSY,WATCHDOG_BUSY" default:"false" help:"Enable watchdog for stopping backends that are busy longer than the watchdog-busy-timeout" group:"backends"`
-	WatchdogBusyTimeout    string   `env:"LOCALAI_WATCHDOG_BUSY_TIMEOUT,WATCHDOG_BUSY_TIMEOUT" default:"5m" help:"Threshold beyond which a busy backend should be stopped" group:"backends"`
-	Federated              bool     `env:"LOCALAI_FEDERATED,FEDERATED" help:"Enable federated instance" group:"federated"`
+	Address                      string   `env:"LOCALAI_ADDRESS,ADDRESS" default:":8080" help:"Bind address for the API server" group:"api"`
+	CORS                         bool     `env:"LOCALAI_CORS,CORS" help:"" group:"api"`
+	CORSAllowOrigins             string   `env:"LOCALAI_CORS_ALLOW_ORIGINS,CORS_ALLOW_ORIGINS" group:"api"`
+	LibraryPath                  string   `env:"LOCALAI_LIBRARY_PATH,LIBRARY_PATH" help:"Path to the library directory (for e.g. external libraries used by backends)" default:"/usr/share/local-ai/libs" group:"backends"`
+	CSRF                         bool     `env:"LOCALAI_CSRF" help:"Enables fiber CSRF middleware" group:"api"`
+	UploadLimit                  int      `env:"LOCALAI_UPLOAD_LIMIT,UPLOAD_LIMIT" default:"15" help:"Default upload-limit in MB" group:"api"`
+	BundleUploadLimit            int      `env:"LOCALAI_BUNDLE_UPLOAD_LIMIT,BUNDLE_UPLOAD_LIMIT" default:"102400" help:"Upload-limit in MB of the bundles of models imported with /models/import" group:"api"`
+	APIKeys                      []string `env:"LOCALAI_API_KEY,API_KEY" help:"List of API Keys to enable API authentication. When this is set, all the requests must be authenticated with one of these API keys" group:"api"`
+	DisableWebUI                 bool     `env:"LOCALAI_DISABLE_WEBUI,DISABLE_WEBUI" default:"false" help:"Disable webui" group:"api"`
+	DisablePredownloadScan       bool     `env:"LOCALAI_DISABLE_PREDOWNLOAD_SCAN" help:"If true, disables the best-effort security scanner before downloading any files." group:"hardening" default:"false"`
+	OpaqueErrors                 bool     `env:"LOCALAI_OPAQUE_ERRORS" default:"false" help:"If true, all error responses are replaced with blank 500 errors. This is intended only for hardening against information leaks and is normally not recommended." group:"hardening"`
+	Peer2Peer                    bool     `env:"LOCALAI_P2P,P2P" name:"p2p" default:"false" help:"Enable P2P mode" group:"p2p"`
+	Peer2PeerToken               string   `env:"LOCALAI_P2P_TOKEN,P2P_TOKEN,TOKEN" name:"p2ptoken" help:"Token for P2P mode (optional)" group:"p2p"`
+	ParallelRequests             bool     `env:"LOCALAI_PARALLEL_REQUESTS,PARALLEL_REQUESTS" help:"Enable backends to handle multiple requests in parallel if they support it (e.g.: llama.cpp or vllm)" group:"backends"`
+	SingleActiveBackend          bool     `env:"LOCALAI_SINGLE_ACTIVE_BACKEND,SINGLE_ACTIVE_BACKEND" help:"Allow only one backend to be run at a time" group:"backends"`
+	PreloadBackendOnly           bool     `env:"LOCALAI_PRELOAD_BACKEND_ONLY,PRELOAD_BACKEND_ONLY" default:"false" help:"Do not launch the API services, only the preloaded models / backends are started (useful for multi-node setups)" group:"backends"`
+	ExternalGRPCBackends         []string `env:"LOCALAI_EXTERNAL_GRPC_BACKENDS,EXTERNAL_GRPC_BACKENDS" help:"A list of external grpc backends (name:uri, where uri is a file, host:port or grpc(s)://host:port?token=...)" group:"backends"`
+	EnableWatchdogIdle           bool     `env:"LOCALAI_WATCHDOG_IDLE,WATCHDOG_IDLE" default:"false" help:"Enable watchdog for stopping backends that are idle longer than the watchdog-idle-timeout" group:"backends"`
+	WatchdogIdleTimeout          string   `env:"LOCALAI_WATCHDOG_IDLE_TIMEOUT,WATCHDOG_IDLE_TIMEOUT" default:"15m" help:"Threshold beyond which an idle backend should be stopped" group:"backends"`
+	EnableWatchdogBusy           bool     `env:"LOCALAI_WATCHDOG_BUSY,WATCHDOG_BUSY" default:"false" help:"Enable watchdog for stopping backends that are busy longer than the watchdog-busy-timeout" group:"backends"`
+	WatchdogBusyTimeout          string   `env:"LOCALAI_WATCHDOG_BUSY_TIMEOUT,WATCHDOG_BUSY_TIMEOUT" default:"5m" help:"Threshold beyond which a busy backend should be stopped" group:"backends"`
+	EnableBackendSupervisor      bool     `env:"LOCALAI_BACKEND_SUPERVISOR,BACKEND_SUPERVISOR" default:"false" help:"Enable the supervisor for health-checking the backends in the background and restarting the crashed ones" group:"backends"`
+	BackendSupervisorInterval    string   `env:"LOCALAI_BACKEND_SUPERVISOR_INTERVAL,BACKEND_SUPERVISOR_INTERVAL" default:"10s" help:"Interval between the supervisor health checks" group:"backends"`
+	BackendSupervisorMaxFailures int      `env:"LOCALAI_BACKEND_SUPERVISOR_MAX_FAILURES,BACKEND_SUPERVISOR_MAX_FAILURES" default:"5" help:"Number of consecutive crashes after which a model is marked as unhealthy" group:"backends"`
+	BackendSupervisorCooldown    string   `env:"LOCALAI_BACKEND_SUPERVISOR_COOLDOWN,BACKEND_SUPERVISOR_COOLDOWN" default:"5m" help:"Time during which requests to an unhealthy model fail fast before trying to load it again" group:"backends"`
+	BackendLogLines              int      `env:"LOCALAI_BACKEND_LOG_LINES,BACKEND_LOG_LINES" default:"1000" help:"Number of lines of the backends output kept in memory for each model" group:"backends"`
+	Federated                    bool     `env:"LOCALAI_FEDERATED,FEDERATED" help:"Enable federated instance" group:"federated"`
 }
 
 func (r *RunCMD) Run(ctx *cliContext.Context) error {
@@ -77,10 +96,23 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		config.WithAudioDir(r.AudioPath),
 		config.WithUploadDir(r.UploadPath),
 		config.WithConfigsDir(r.ConfigPath),
+		config.WithSecretsDir(r.SecretsPath),
+		config.WithConversationsTTL(r.ConversationsTTL),
+		config.WithBackendLogsDir(r.BackendLogsPath),
+		config.WithBackendLogLines(r.BackendLogLines),
+		config.WithDownloadConnections(r.DownloadConnections),
+		config.WithDownloadRetries(r.DownloadRetries),
+		config.WithBlobsPath(r.BlobsPath),
+		config.WithHuggingFaceToken(r.HuggingFaceToken),
+		config.WithStringOCIRegistries(r.OCIRegistries),
 		config.WithDynamicConfigDir(r.LocalaiConfigDir),
 		config.WithDynamicConfigDirPollInterval(r.LocalaiConfigDirPollInterval),
+		config.WithModelsPathPollInterval(r.ModelsPathPollInterval),
 		config.WithF16(r.F16),
 		config.WithStringGalleries(r.Galleries),
+		config.WithGalleryRefreshInterval(r.GalleryRefreshInterval),
+		config.WithGalleryWebhooks(r.GalleryWebhooks),
+		config.WithModelsManifestDryRun(r.ModelsManifestDryRun),
 		config.WithModelLibraryURL(r.RemoteLibrary),
 		config.WithCors(r.CORS),
 		config.WithCorsAllowOrigins(r.CORSAllowOrigins),
@@ -90,6 +122,7 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		config.WithBackendAssets(ctx.BackendAssets),
 		config.WithBackendAssetsOutput(r.BackendAssetsPath),
 		config.WithUploadLimitMB(r.UploadLimit),
+		config.WithBundleUploadLimitMB(r.BundleUploadLimit),
 		config.WithApiKeys(r.APIKeys),
 		config.WithModelsURL(append(r.Models, r.ModelArgs...)...),
 		config.WithOpaqueErrors(r.OpaqueErrors),
@@ -135,6 +168,18 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		}); err != nil {
 			return err
 		}
+
+		// Discover the workers exposing gRPC backends (e.g. whisper, piper):
+		// these are registered as external backends when loading models
+		backendNode, err := p2p.NewNode(token)
+		if err != nil {
+			return err
+		}
+		if err := p2p.ServiceDiscoverer(context.Background(), backendNode, token, p2p.BackendWorkerID, func(serviceID string, node p2p.NodeData) {
+			log.Debug().Msgf("Discovered backend worker %s serving '%s' at %s", node.ID, node.Backend, node.TunnelAddress)
+		}); err != nil {
+			return err
+		}
 	}
 
 	if r.Federated {
@@ -161,6 +206,10 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		opts = append(opts, config.DisableWebUI)
 	}
 
+	if r.DisableModelsWatcher {
+		opts = append(opts, config.DisableModelsWatcher)
+	}
+
 	if idleWatchDog || busyWatchDog {
 		opts = append(opts, config.EnableWatchDog)
 		if idleWatchDog {
@@ -180,6 +229,19 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 			opts = append(opts, config.SetWatchDogBusyTimeout(dur))
 		}
 	}
+	if r.EnableBackendSupervisor {
+		opts = append(opts, config.EnableBackendSupervisor, config.SetBackendSupervisorMaxFailures(r.BackendSupervisorMaxFailures))
+		dur, err := time.ParseDuration(r.BackendSupervisorInterval)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.SetBackendSupervisorInterval(dur))
+		dur, err = time.ParseDuration(r.BackendSupervisorCooldown)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.SetBackendSupervisorCooldown(dur))
+	}
 	if r.ParallelRequests {
 		opts = append(opts, config.EnableParallelBackendRequests)
 	}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -11,12 +14,16 @@ import (
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/gallery"
 	"github.com/mudler/LocalAI/pkg/downloader"
+	"github.com/mudler/LocalAI/pkg/signature"
 	gguf "github.com/thxcode/gguf-parser-go"
 )
 
 type UtilCMD struct {
 	GGUFInfo GGUFInfoCMD `cmd:"" name:"gguf-info" help:"Get information about a GGUF file"`
 	HFScan   HFScanCMD   `cmd:"" name:"hf-scan" help:"Checks installed models for known security issues. WARNING: this is a best-effort feature and may not catch everything!"`
+
+	GalleryKeygen GalleryKeygenCMD `cmd:"" name:"gallery-keygen" help:"Generate a key pair to sign galleries"`
+	SignGallery   SignGalleryCMD   `cmd:"" name:"sign-gallery" help:"Sign the index and the model configurations of a gallery"`
 }
 
 type GGUFInfoCMD struct {
@@ -30,6 +37,15 @@ type HFScanCMD struct {
 	ToScan     []string `arg:""`
 }
 
+type GalleryKeygenCMD struct {
+	Output string `short:"o" type:"path" default:"gallery.key" help:"Path of the private key to write. The public key is written next to it, with the .pub extension"`
+}
+
+type SignGalleryCMD struct {
+	Key   string   `env:"LOCALAI_GALLERY_SIGNING_KEY" required:"" type:"existingfile" help:"Path of the private key"`
+	Files []string `arg:"" type:"existingfile" help:"Files of the gallery to sign: the index and the model configurations"`
+}
+
 func (u *GGUFInfoCMD) Run(ctx *cliContext.Context) error {
 	if u.Args == nil || len(u.Args) == 0 {
 		return fmt.Errorf("no GGUF file provided")
@@ -99,3 +115,42 @@ func (hfscmd *HFScanCMD) Run(ctx *cliContext.Context) error {
 		return nil
 	}
 }
+
+func (g *GalleryKeygenCMD) Run(ctx *cliContext.Context) error {
+	pub, priv, err := signature.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(g.Output, []byte(priv.String()), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(g.Output+".pub", []byte(pub.String()), 0644); err != nil {
+		return err
+	}
+	log.Info().Str("key", g.Output).Msgf("key pair generated. Public key to configure in the galleries: %s", pub.Encode())
+	return nil
+}
+
+func (s *SignGalleryCMD) Run(ctx *cliContext.Context) error {
+	dat, err := os.ReadFile(s.Key)
+	if err != nil {
+		return err
+	}
+	key, err := signature.ParsePrivateKey(string(dat))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range s.Files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		sig := key.Sign(content, fmt.Sprintf("timestamp:%d\tfile:%s", time.Now().Unix(), filepath.Base(f)))
+		if err := os.WriteFile(f+signature.Extension, sig, 0644); err != nil {
+			return err
+		}
+		log.Info().Str("file", f).Msg("signed")
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ type WorkerFlags struct {
 }
 
 type Worker struct {
-	P2P      P2P      `cmd:"" name:"p2p-llama-cpp-rpc" help:"Starts a LocalAI llama.cpp worker in P2P mode (requires a token)"`
-	LLamaCPP LLamaCPP `cmd:"" name:"llama-cpp-rpc" help:"Starts a llama.cpp worker in standalone mode"`
+	P2P        P2P        `cmd:"" name:"p2p-llama-cpp-rpc" help:"Starts a LocalAI llama.cpp worker in P2P mode (requires a token)"`
+	P2PBackend P2PBackend `cmd:"" name:"p2p-backend" help:"Starts a LocalAI gRPC backend worker (e.g. whisper, piper) in P2P mode (requires a token)"`
+	LLamaCPP   LLamaCPP   `cmd:"" name:"llama-cpp-rpc" help:"Starts a llama.cpp worker in standalone mode"`
 }
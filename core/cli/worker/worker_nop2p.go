@@ -14,3 +14,9 @@ type P2P struct{}
 func (r *P2P) Run(ctx *cliContext.Context) error {
 	return fmt.Errorf("p2p mode is not enabled in this build")
 }
+
+type P2PBackend struct{}
+
+func (r *P2PBackend) Run(ctx *cliContext.Context) error {
+	return fmt.Errorf("p2p mode is not enabled in this build")
+}
@@ -0,0 +1,118 @@
+//go:build p2p
+// +build p2p
+
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	cliContext "github.com/mudler/LocalAI/core/cli/context"
+	"github.com/mudler/LocalAI/core/p2p"
+	"github.com/mudler/LocalAI/pkg/assets"
+	"github.com/mudler/LocalAI/pkg/library"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/phayes/freeport"
+	"github.com/rs/zerolog/log"
+)
+
+type P2PBackend struct {
+	WorkerFlags      `embed:""`
+	Backend          string   `arg:"" name:"backend" help:"Name of the gRPC backend to expose (e.g. whisper, piper, stablediffusion, bert-embeddings, local-store)"`
+	Token            string   `env:"LOCALAI_TOKEN,LOCALAI_P2P_TOKEN,TOKEN" help:"P2P token to use"`
+	NoRunner         bool     `env:"LOCALAI_NO_RUNNER,NO_RUNNER" help:"Do not start the gRPC backend"`
+	RunnerAddress    string   `env:"LOCALAI_RUNNER_ADDRESS,RUNNER_ADDRESS" help:"Address of the gRPC backend"`
+	RunnerPort       string   `env:"LOCALAI_RUNNER_PORT,RUNNER_PORT" help:"Port of the gRPC backend"`
+	ExtraBackendArgs []string `env:"LOCALAI_EXTRA_BACKEND_ARGS,EXTRA_BACKEND_ARGS" help:"Extra arguments to pass to the gRPC backend"`
+}
+
+func (r *P2PBackend) Run(ctx *cliContext.Context) error {
+	// Extract files from the embedded FS
+	err := assets.ExtractFiles(ctx.BackendAssets, r.BackendAssetsPath)
+	log.Debug().Msgf("Extracting backend assets files to %s", r.BackendAssetsPath)
+	if err != nil {
+		log.Warn().Msgf("Failed extracting backend assets files: %s (might be required for some backends to work properly, like gpt4all)", err)
+	}
+
+	// Check if the token is set
+	// as we always need it.
+	if r.Token == "" {
+		return fmt.Errorf("Token is required")
+	}
+
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		return err
+	}
+
+	address := "127.0.0.1"
+
+	if r.NoRunner {
+		// Let override which port and address to bind if the user
+		// configure the backend service on its own
+		p := fmt.Sprint(port)
+		if r.RunnerAddress != "" {
+			address = r.RunnerAddress
+		}
+		if r.RunnerPort != "" {
+			p = r.RunnerPort
+		}
+
+		err = p2p.ExposeBackendService(context.Background(), address, p, r.Token, r.Backend)
+		if err != nil {
+			return err
+		}
+		log.Info().Msgf("You need to start the '%s' backend on '%s:%s'", r.Backend, address, p)
+
+		return nil
+	}
+
+	backendsDir := filepath.Join(r.BackendAssetsPath, "backend-assets", "grpc")
+	grpcProcess := filepath.Join(backendsDir, r.Backend)
+	if err := utils.VerifyPath(r.Backend, backendsDir); err != nil {
+		return fmt.Errorf("invalid backend name %q: %w", r.Backend, err)
+	}
+	if _, err := os.Stat(grpcProcess); err != nil {
+		return fmt.Errorf("backend %q not found in the asset directory: %w", r.Backend, err)
+	}
+
+	// Start the backend from the version we have pre-packaged, and restart it if it dies
+	go func() {
+		for {
+			log.Info().Msgf("Starting backend '%s' on '%s:%d'", r.Backend, address, port)
+
+			args := append([]string{}, r.ExtraBackendArgs...)
+			args = append(args, "--addr", fmt.Sprintf("%s:%d", address, port))
+			args, process := library.LoadLDSO(r.BackendAssetsPath, args, grpcProcess)
+
+			cmd := exec.Command(
+				process, args...,
+			)
+
+			cmd.Env = os.Environ()
+
+			cmd.Stderr = os.Stdout
+			cmd.Stdout = os.Stdout
+
+			if err := cmd.Start(); err != nil {
+				log.Error().Any("grpcProcess", process).Any("args", args).Err(err).Msg("Failed to start backend")
+			}
+
+			cmd.Wait()
+			time.Sleep(1 * time.Second)
+		}
+	}()
+
+	err = p2p.ExposeBackendService(context.Background(), address, fmt.Sprint(port), r.Token, r.Backend)
+	if err != nil {
+		return err
+	}
+
+	for {
+		time.Sleep(1 * time.Second)
+	}
+}
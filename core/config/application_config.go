@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/mudler/LocalAI/pkg/oci"
 	"github.com/mudler/LocalAI/pkg/xsysinfo"
 	"github.com/rs/zerolog/log"
 )
@@ -16,6 +17,7 @@ type ApplicationConfig struct {
 	ModelPath                           string
 	LibPath                             string
 	UploadLimitMB, Threads, ContextSize int
+	BundleUploadLimitMB                 int
 	DisableWebUI                        bool
 	F16                                 bool
 	Debug                               bool
@@ -23,8 +25,12 @@ type ApplicationConfig struct {
 	AudioDir                            string
 	UploadDir                           string
 	ConfigsDir                          string
+	SecretsDir                          string
+	ConversationsTTL                    time.Duration
 	DynamicConfigsDir                   string
 	DynamicConfigsDirPollInterval       time.Duration
+	DisableModelsWatcher                bool
+	ModelsPathPollInterval              time.Duration
 	CORS                                bool
 	CSRF                                bool
 	PreloadJSONModels                   string
@@ -37,7 +43,10 @@ type ApplicationConfig struct {
 
 	ModelLibraryURL string
 
-	Galleries []Gallery
+	Galleries              []Gallery
+	GalleryRefreshInterval time.Duration
+	GalleryWebhooks        []string
+	ModelsManifestDryRun   bool
 
 	BackendAssets     embed.FS
 	AssetsDestination string
@@ -56,16 +65,31 @@ type ApplicationConfig struct {
 	ModelsURL []string
 
 	WatchDogBusyTimeout, WatchDogIdleTimeout time.Duration
+
+	BackendSupervisor            bool
+	BackendSupervisorInterval    time.Duration
+	BackendSupervisorMaxFailures int
+	BackendSupervisorCooldown    time.Duration
+
+	BackendLogsDir  string
+	BackendLogLines int
+
+	DownloadConnections int
+	DownloadRetries     int
+	BlobsPath           string
+	HuggingFaceToken    string
+	OCIRegistries       []oci.RegistryCredentials
 }
 
 type AppOption func(*ApplicationConfig)
 
 func NewApplicationConfig(o ...AppOption) *ApplicationConfig {
 	opt := &ApplicationConfig{
-		Context:       context.Background(),
-		UploadLimitMB: 15,
-		ContextSize:   512,
-		Debug:         true,
+		Context:             context.Background(),
+		UploadLimitMB:       15,
+		BundleUploadLimitMB: 100 * 1024,
+		ContextSize:         512,
+		Debug:               true,
 	}
 	for _, oo := range o {
 		oo(opt)
@@ -145,6 +169,28 @@ func SetWatchDogIdleTimeout(t time.Duration) AppOption {
 	}
 }
 
+var EnableBackendSupervisor = func(o *ApplicationConfig) {
+	o.BackendSupervisor = true
+}
+
+func SetBackendSupervisorInterval(t time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.BackendSupervisorInterval = t
+	}
+}
+
+func SetBackendSupervisorMaxFailures(n int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.BackendSupervisorMaxFailures = n
+	}
+}
+
+func SetBackendSupervisorCooldown(t time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.BackendSupervisorCooldown = t
+	}
+}
+
 var EnableSingleBackend = func(o *ApplicationConfig) {
 	o.SingleBackend = true
 }
@@ -198,12 +244,47 @@ func WithStringGalleries(galls string) AppOption {
 	}
 }
 
+// WithStringOCIRegistries sets the credentials of the OCI registries from a JSON list
+func WithStringOCIRegistries(registries string) AppOption {
+	return func(o *ApplicationConfig) {
+		credentials, err := oci.ParseRegistryCredentials(registries)
+		if err != nil {
+			log.Error().Err(err).Msg("failed loading OCI registries credentials")
+		}
+		o.OCIRegistries = append(o.OCIRegistries, credentials...)
+	}
+}
+
 func WithGalleries(galleries []Gallery) AppOption {
 	return func(o *ApplicationConfig) {
 		o.Galleries = append(o.Galleries, galleries...)
 	}
 }
 
+func WithConversationsTTL(ttl time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ConversationsTTL = ttl
+	}
+}
+
+func WithGalleryRefreshInterval(interval time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.GalleryRefreshInterval = interval
+	}
+}
+
+func WithGalleryWebhooks(webhooks []string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.GalleryWebhooks = append(o.GalleryWebhooks, webhooks...)
+	}
+}
+
+func WithModelsManifestDryRun(dryRun bool) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ModelsManifestDryRun = dryRun
+	}
+}
+
 func WithContext(ctx context.Context) AppOption {
 	return func(o *ApplicationConfig) {
 		o.Context = ctx
@@ -233,6 +314,13 @@ func WithUploadLimitMB(limit int) AppOption {
 	}
 }
 
+// WithBundleUploadLimitMB sets the limit of the bundles of models uploaded to be imported
+func WithBundleUploadLimitMB(limit int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.BundleUploadLimitMB = limit
+	}
+}
+
 func WithThreads(threads int) AppOption {
 	return func(o *ApplicationConfig) {
 		if threads == 0 { // 0 is not allowed
@@ -284,6 +372,48 @@ func WithConfigsDir(configsDir string) AppOption {
 	}
 }
 
+func WithSecretsDir(dir string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.SecretsDir = dir
+	}
+}
+
+func WithBackendLogsDir(dir string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.BackendLogsDir = dir
+	}
+}
+
+func WithBackendLogLines(lines int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.BackendLogLines = lines
+	}
+}
+
+func WithDownloadConnections(n int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.DownloadConnections = n
+	}
+}
+
+func WithDownloadRetries(n int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.DownloadRetries = n
+	}
+}
+
+func WithHuggingFaceToken(token string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.HuggingFaceToken = token
+	}
+}
+
+func WithBlobsPath(path string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.BlobsPath = path
+	}
+}
+
 func WithDynamicConfigDir(dynamicConfigsDir string) AppOption {
 	return func(o *ApplicationConfig) {
 		o.DynamicConfigsDir = dynamicConfigsDir
@@ -296,6 +426,17 @@ func WithDynamicConfigDirPollInterval(interval time.Duration) AppOption {
 	}
 }
 
+var DisableModelsWatcher = func(o *ApplicationConfig) {
+	o.DisableModelsWatcher = true
+}
+
+// WithModelsPathPollInterval polls the configuration files of the models path, instead of relying on the file system events
+func WithModelsPathPollInterval(interval time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ModelsPathPollInterval = interval
+	}
+}
+
 func WithApiKeys(apiKeys []string) AppOption {
 	return func(o *ApplicationConfig) {
 		o.ApiKeys = apiKeys
@@ -1,14 +1,18 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/pkg/downloader"
 	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/reasoning"
 	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
 )
 
 const (
@@ -27,6 +31,8 @@ type TTSConfig struct {
 type BackendConfig struct {
 	schema.PredictionOptions `yaml:"parameters"`
 	Name                     string `yaml:"name"`
+	// Extends lists the profiles and the models whose configuration is inherited
+	Extends Extends `yaml:"extends"`
 
 	F16            *bool             `yaml:"f16"`
 	Threads        *int              `yaml:"threads"`
@@ -41,9 +47,18 @@ type BackendConfig struct {
 	functionCallString, functionCallNameString string                 `yaml:"-"`
 	ResponseFormat                             string                 `yaml:"-"`
 	ResponseFormatMap                          map[string]interface{} `yaml:"-"`
+	configFile                                 string
+	// configDigest is the digest of the content of the config file, to detect its changes
+	configDigest string
 
 	FunctionsConfig functions.FunctionsConfig `yaml:"function"`
 
+	// ContextWindow sets how the chat messages exceeding the context size are trimmed
+	ContextWindow ContextWindow `yaml:"context_window"`
+
+	// ReasoningConfig sets how the reasoning of the model is separated from the content of its replies
+	ReasoningConfig reasoning.Config `yaml:"reasoning"`
+
 	FeatureFlag FeatureFlag `yaml:"feature_flags"` // Feature Flag registry. We move fast, and features may break on a per model/backend basis. Registry for (usually temporary) flags that indicate aborting something early.
 	// LLM configs (GPT4ALL, Llama.cpp, ...)
 	LLMConfig `yaml:",inline"`
@@ -65,6 +80,10 @@ type BackendConfig struct {
 	// Explicitly enable CUDA or not (some backends might need it)
 	CUDA bool `yaml:"cuda"`
 
+	// Pinned models are loaded at startup and, when the backend supervisor
+	// is enabled, reloaded as soon as they are not running anymore
+	Pinned bool `yaml:"pinned"`
+
 	DownloadFiles []File `yaml:"download_files"`
 
 	Description string `yaml:"description"`
@@ -192,6 +211,24 @@ type TemplateConfig struct {
 	// JoinChatMessagesByCharacter is a string that will be used to join chat messages together.
 	// It defaults to \n
 	JoinChatMessagesByCharacter *string `yaml:"join_chat_messages_by_character"`
+
+	// Jinja is a Jinja2 chat template (or the name of a .jinja file in the model path), as the ones of the HuggingFace models.
+	// When set, it renders the whole conversation and the Chat, ChatMessage and Functions templates are not used
+	Jinja string `yaml:"jinja"`
+
+	// UseGGUFChatTemplate uses the tokenizer.chat_template embedded in the GGUF file as Jinja template,
+	// even if the model family is known
+	UseGGUFChatTemplate bool `yaml:"use_gguf_chat_template"`
+
+	// BOSToken and EOSToken are the bos_token and eos_token variables of the Jinja template
+	BOSToken string `yaml:"bos_token"`
+	EOSToken string `yaml:"eos_token"`
+}
+
+// String formats the configuration for the logs, redacting the secrets expanded in its values
+func (c BackendConfig) String() string {
+	type plain BackendConfig
+	return utils.RedactSecrets(fmt.Sprintf("%+v", plain(c)))
 }
 
 func (c *BackendConfig) SetFunctionCallString(s string) {
@@ -240,6 +277,61 @@ func (c *BackendConfig) ModelFileName() string {
 	return c.Model
 }
 
+// ConfigFile returns the path of the file the configuration was read from, if any
+func (c *BackendConfig) ConfigFile() string {
+	return c.configFile
+}
+
+// ModelFiles returns the files in the models path used by the model (relative to it):
+// its configuration, weights, templates and additional downloaded files
+func (c *BackendConfig) ModelFiles(modelPath string) []string {
+	candidates := []string{}
+	if c.configFile != "" {
+		if rel, err := filepath.Rel(modelPath, c.configFile); err == nil {
+			candidates = append(candidates, rel)
+		}
+		// the profiles and the models the configuration extends
+		extended, err := ExtendedBackendConfigFiles(c.configFile)
+		if err != nil {
+			log.Warn().Err(err).Str("model", c.Name).Msg("cannot resolve the configurations extended by the model")
+		}
+		for _, f := range extended {
+			if rel, err := filepath.Rel(modelPath, f); err == nil {
+				candidates = append(candidates, rel)
+			}
+		}
+	}
+	if c.Model != "" {
+		candidates = append(candidates, downloader.ShardNames(c.ModelFileName())...)
+	}
+	candidates = append(candidates, c.MMProjFileName(), c.DraftModel)
+	for _, t := range []string{c.TemplateConfig.Chat, c.TemplateConfig.ChatMessage, c.TemplateConfig.Completion, c.TemplateConfig.Edit, c.TemplateConfig.Functions} {
+		if t != "" {
+			candidates = append(candidates, t+".tmpl")
+		}
+	}
+	// the jinja template is usually written in the configuration: only the short names can be files
+	if c.TemplateConfig.Jinja != "" && !strings.ContainsAny(c.TemplateConfig.Jinja, "{\n") {
+		candidates = append(candidates, c.TemplateConfig.Jinja+".jinja")
+	}
+	for _, f := range c.DownloadFiles {
+		candidates = append(candidates, f.Filename)
+	}
+
+	files := []string{}
+	seen := map[string]bool{}
+	for _, f := range candidates {
+		if f == "" || seen[f] || utils.VerifyPath(f, modelPath) != nil {
+			continue
+		}
+		seen[f] = true
+		if st, err := os.Stat(filepath.Join(modelPath, f)); err == nil && st.Mode().IsRegular() {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
 func (c *BackendConfig) FunctionToCall() string {
 	if c.functionCallNameString != "" &&
 		c.functionCallNameString != "none" && c.functionCallNameString != "auto" {
@@ -373,6 +465,9 @@ func (cfg *BackendConfig) SetDefaults(opts ...ConfigLoaderOption) {
 		cfg.Debug = &trueV
 	}
 
+	// the reasoning blocks are ignored when parsing the function calls
+	cfg.FunctionsConfig.ReasoningConfig = cfg.ReasoningConfig
+
 	guessDefaultsFromFile(cfg, lo.modelPath)
 }
 
@@ -404,5 +499,5 @@ func (c *BackendConfig) Validate() bool {
 }
 
 func (c *BackendConfig) HasTemplate() bool {
-	return c.TemplateConfig.Completion != "" || c.TemplateConfig.Edit != "" || c.TemplateConfig.Chat != "" || c.TemplateConfig.ChatMessage != ""
+	return c.TemplateConfig.Completion != "" || c.TemplateConfig.Edit != "" || c.TemplateConfig.Chat != "" || c.TemplateConfig.ChatMessage != "" || c.TemplateConfig.Jinja != ""
 }
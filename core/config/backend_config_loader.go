@@ -1,14 +1,17 @@
 package config
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/charmbracelet/glamour"
 	"github.com/mudler/LocalAI/core/schema"
@@ -22,12 +25,69 @@ type BackendConfigLoader struct {
 	configs   map[string]BackendConfig
 	modelPath string
 	sync.Mutex
+
+	subscribersMu sync.Mutex
+	subscribers   map[chan BackendConfigEvent]struct{}
+}
+
+const (
+	BackendConfigAdded   = "added"
+	BackendConfigUpdated = "updated"
+	BackendConfigRemoved = "removed"
+	// BackendConfigReloaded is sent when a loaded model is restarted to apply its new configuration
+	BackendConfigReloaded = "reloaded"
+)
+
+// BackendConfigEvent is sent to the subscribers when the configuration of a model changes
+type BackendConfigEvent struct {
+	Event string    `json:"event"`
+	Model string    `json:"model"`
+	Time  time.Time `json:"time"`
+	Error string    `json:"error,omitempty"`
+}
+
+// BackendConfigChange is a change of the configurations applied by SyncBackendConfigsFromPath.
+// Old is nil for the added configurations, New for the removed ones
+type BackendConfigChange struct {
+	Event string
+	Old   *BackendConfig
+	New   *BackendConfig
 }
 
 func NewBackendConfigLoader(modelPath string) *BackendConfigLoader {
 	return &BackendConfigLoader{
-		configs:   make(map[string]BackendConfig),
-		modelPath: modelPath,
+		configs:     make(map[string]BackendConfig),
+		modelPath:   modelPath,
+		subscribers: make(map[chan BackendConfigEvent]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving the events of the configurations, and a function to unsubscribe.
+// The events are dropped if the subscriber does not keep up
+func (bcl *BackendConfigLoader) Subscribe() (chan BackendConfigEvent, func()) {
+	ch := make(chan BackendConfigEvent, 16)
+	bcl.subscribersMu.Lock()
+	bcl.subscribers[ch] = struct{}{}
+	bcl.subscribersMu.Unlock()
+	return ch, func() {
+		bcl.subscribersMu.Lock()
+		delete(bcl.subscribers, ch)
+		bcl.subscribersMu.Unlock()
+	}
+}
+
+// Notify sends an event to the subscribers
+func (bcl *BackendConfigLoader) Notify(event BackendConfigEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	bcl.subscribersMu.Lock()
+	defer bcl.subscribersMu.Unlock()
+	for ch := range bcl.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
 	}
 }
 
@@ -83,6 +143,10 @@ func readMultipleBackendConfigsFromFile(file string, opts ...ConfigLoaderOption)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read config file: %w", err)
 	}
+	f, err = utils.ExpandYAMLVariables(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal config file: %w", err)
+	}
 	if err := yaml.Unmarshal(f, c); err != nil {
 		return nil, fmt.Errorf("cannot unmarshal config file: %w", err)
 	}
@@ -99,15 +163,21 @@ func readBackendConfigFromFile(file string, opts ...ConfigLoaderOption) (*Backen
 	lo.Apply(opts...)
 
 	c := &BackendConfig{}
-	f, err := os.ReadFile(file)
+	f, err := ResolveBackendConfigFile(file)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read config file: %w", err)
+		return nil, err
 	}
-	if err := yaml.Unmarshal(f, c); err != nil {
+	expanded, err := utils.ExpandYAMLVariables(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal config file: %w", err)
+	}
+	if err := yaml.Unmarshal(expanded, c); err != nil {
 		return nil, fmt.Errorf("cannot unmarshal config file: %w", err)
 	}
 
 	c.SetDefaults(opts...)
+	c.configFile = file
+	c.configDigest = fmt.Sprintf("%x", sha256.Sum256(f))
 	return c, nil
 }
 
@@ -319,7 +389,7 @@ func (bcl *BackendConfigLoader) LoadBackendConfigsFromPath(path string, opts ...
 	for _, file := range files {
 		// Skip templates, YAML and .keep files
 		if !strings.Contains(file.Name(), ".yaml") && !strings.Contains(file.Name(), ".yml") ||
-			strings.HasPrefix(file.Name(), ".") {
+			strings.HasPrefix(file.Name(), ".") || IsProfileFile(file.Name()) {
 			continue
 		}
 		c, err := readBackendConfigFromFile(filepath.Join(path, file.Name()), opts...)
@@ -336,3 +406,84 @@ func (bcl *BackendConfigLoader) LoadBackendConfigsFromPath(path string, opts ...
 
 	return nil
 }
+
+// SyncBackendConfigsFromPath reads again the configurations of the models from a path, returning the changes:
+// new and modified files are loaded, and the configurations of the files which were removed are dropped.
+// The configurations which cannot be read anymore are kept, as the files might be in the middle of a write
+func (bcl *BackendConfigLoader) SyncBackendConfigsFromPath(path string, opts ...ConfigLoaderOption) ([]BackendConfigChange, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read directory '%s': %w", path, err)
+	}
+
+	bcl.Lock()
+	byFile := map[string]BackendConfig{}
+	for _, c := range bcl.configs {
+		if c.configFile != "" {
+			byFile[c.configFile] = c
+		}
+	}
+
+	changes := []BackendConfigChange{}
+	seen := map[string]bool{}
+	unreadable := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || (!strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml")) || strings.HasPrefix(name, ".") || IsProfileFile(name) {
+			continue
+		}
+		file := filepath.Join(path, name)
+
+		// skip the files which did not change, including the profiles they extend: the loaded
+		// configurations might have been modified since, e.g. by the preloading of the models
+		if dat, err := ResolveBackendConfigFile(file); err == nil {
+			if c, exists := byFile[file]; exists && c.configDigest == fmt.Sprintf("%x", sha256.Sum256(dat)) {
+				seen[c.Name] = true
+				continue
+			}
+		}
+
+		c, err := readBackendConfigFromFile(file, opts...)
+		if err != nil || !c.Validate() {
+			log.Debug().Err(err).Str("file", file).Msg("skipping invalid config file")
+			unreadable[file] = true
+			continue
+		}
+		seen[c.Name] = true
+
+		old, exists := bcl.configs[c.Name]
+		switch {
+		case !exists:
+			changes = append(changes, BackendConfigChange{Event: BackendConfigAdded, New: c})
+		case !reflect.DeepEqual(old, *c):
+			changes = append(changes, BackendConfigChange{Event: BackendConfigUpdated, Old: &old, New: c})
+		}
+		bcl.configs[c.Name] = *c
+	}
+
+	for name, c := range bcl.configs {
+		if seen[name] || c.configFile == "" || filepath.Dir(c.configFile) != filepath.Clean(path) || unreadable[c.configFile] {
+			continue
+		}
+		old := c
+		changes = append(changes, BackendConfigChange{Event: BackendConfigRemoved, Old: &old})
+		delete(bcl.configs, name)
+	}
+	bcl.Unlock()
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Name() < changes[j].Name()
+	})
+	for _, c := range changes {
+		bcl.Notify(BackendConfigEvent{Event: c.Event, Model: c.Name()})
+	}
+	return changes, nil
+}
+
+// Name returns the name of the model changed
+func (c BackendConfigChange) Name() string {
+	if c.New != nil {
+		return c.New.Name
+	}
+	return c.Old.Name
+}
@@ -0,0 +1,90 @@
+package config_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BackendConfigLoader", func() {
+	var tempdir string
+	var cl *BackendConfigLoader
+
+	write := func(file, content string) {
+		Expect(os.WriteFile(filepath.Join(tempdir, file), []byte(content), 0600)).To(Succeed())
+	}
+
+	events := func(changes []BackendConfigChange) []string {
+		e := []string{}
+		for _, c := range changes {
+			e = append(e, c.Event+" "+c.Name())
+		}
+		return e
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempdir, err = os.MkdirTemp("", "configs")
+		Expect(err).ToNot(HaveOccurred())
+		cl = NewBackendConfigLoader(tempdir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempdir)
+	})
+
+	It("applies the changes of the config files", func() {
+		write("a.yaml", "name: a\nparameters:\n  model: a.gguf\n")
+		Expect(cl.LoadBackendConfigsFromPath(tempdir)).To(Succeed())
+
+		updates, unsubscribe := cl.Subscribe()
+		defer unsubscribe()
+
+		changes, err := cl.SyncBackendConfigsFromPath(tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+
+		write("a.yaml", "name: a\ncontext_size: 2048\nparameters:\n  model: a.gguf\n")
+		write("b.yaml", "name: b\nparameters:\n  model: b.gguf\n")
+		changes, err = cl.SyncBackendConfigsFromPath(tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(events(changes)).To(Equal([]string{"updated a", "added b"}))
+		Expect(*changes[0].Old.ContextSize).ToNot(Equal(2048))
+		Expect(*changes[0].New.ContextSize).To(Equal(2048))
+		Expect((<-updates).Model).To(Equal("a"))
+		Expect(<-updates).To(HaveField("Event", BackendConfigAdded))
+
+		// the files being written are not removed
+		write("b.yaml", "name: [")
+		Expect(os.Remove(filepath.Join(tempdir, "a.yaml"))).To(Succeed())
+		changes, err = cl.SyncBackendConfigsFromPath(tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(events(changes)).To(Equal([]string{"removed a"}))
+		_, exists := cl.GetBackendConfig("a")
+		Expect(exists).To(BeFalse())
+		_, exists = cl.GetBackendConfig("b")
+		Expect(exists).To(BeTrue())
+	})
+
+	It("expands the variables and redacts the secrets in the logs", func() {
+		write("token", "hf_secret_token_value\n")
+		utils.SetSecretsDir(tempdir)
+		defer utils.SetSecretsDir("")
+		os.Setenv("LOCALAI_TEST_CONTEXT_SIZE", "8192")
+		defer os.Unsetenv("LOCALAI_TEST_CONTEXT_SIZE")
+		write("a.yaml", fmt.Sprintf("name: a\ncontext_size: ${LOCALAI_TEST_CONTEXT_SIZE}\nparameters:\n  model: huggingface://org/repo/a.gguf?token=${file:%s}\n", filepath.Join(tempdir, "token")))
+		Expect(cl.LoadBackendConfigsFromPath(tempdir)).To(Succeed())
+
+		a, exists := cl.GetBackendConfig("a")
+		Expect(exists).To(BeTrue())
+		Expect(*a.ContextSize).To(Equal(8192))
+		Expect(a.Model).To(Equal("huggingface://org/repo/a.gguf?token=hf_secret_token_value"))
+		Expect(fmt.Sprintf("%+v", a)).ToNot(ContainSubstring("hf_secret_token_value"))
+		Expect(fmt.Sprintf("%+v", &a)).To(ContainSubstring("token=[REDACTED]"))
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -60,5 +61,37 @@ parameters:
 			Expect(config.Name).To(Equal("hermes-2-pro-mistral"))
 			Expect(config.Validate()).To(BeTrue())
 		})
+		It("lists the files of the model", func() {
+			dir, err := os.MkdirTemp("", "models")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+			for _, f := range []string{"model-00001-of-00002.gguf", "model-00002-of-00002.gguf", "chat.tmpl", "other.gguf"} {
+				Expect(os.WriteFile(filepath.Join(dir, f), []byte{}, 0600)).To(Succeed())
+			}
+			Expect(os.WriteFile(filepath.Join(dir, "model.yaml"), []byte(`name: model
+parameters:
+  model: model-00001-of-00002.gguf
+mmproj: missing.gguf
+template:
+  chat: chat`), 0600)).To(Succeed())
+
+			config, err := readBackendConfigFromFile(filepath.Join(dir, "model.yaml"))
+			Expect(err).To(BeNil())
+			Expect(config.ConfigFile()).To(Equal(filepath.Join(dir, "model.yaml")))
+			Expect(config.ModelFiles(dir)).To(Equal([]string{"model.yaml", "model-00001-of-00002.gguf", "model-00002-of-00002.gguf", "chat.tmpl"}))
+		})
+		It("lists the jinja template of the model only if it is a file", func() {
+			dir, err := os.MkdirTemp("", "models")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+			Expect(os.WriteFile(filepath.Join(dir, "chat.jinja"), []byte("{{ messages }}"), 0600)).To(Succeed())
+
+			config := &BackendConfig{TemplateConfig: TemplateConfig{Jinja: "chat"}}
+			Expect(config.ModelFiles(dir)).To(Equal([]string{"chat.jinja"}))
+			config.TemplateConfig.Jinja = "{% for m in messages %}{{ m.content }}{% endfor %}"
+			Expect(config.ModelFiles(dir)).To(BeEmpty())
+			config.TemplateConfig.Jinja = "missing"
+			Expect(config.ModelFiles(dir)).To(BeEmpty())
+		})
 	})
 })
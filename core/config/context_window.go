@@ -0,0 +1,63 @@
+package config
+
+import "fmt"
+
+// Strategies to fit the chat messages in the context size of a model
+const (
+	// ContextWindowDropOldest drops the oldest messages, keeping the system messages
+	ContextWindowDropOldest = "drop_oldest"
+	// ContextWindowKeepFirstLast keeps the first and the last messages, dropping the ones in between
+	ContextWindowKeepFirstLast = "keep_first_last"
+	// ContextWindowSummarize replaces the oldest messages with a summary
+	ContextWindowSummarize = "summarize"
+)
+
+// ContextWindow sets how the messages of the chat requests whose prompt exceeds the context size are trimmed
+type ContextWindow struct {
+	// Strategy is one of drop_oldest, keep_first_last and summarize. The messages are not trimmed if empty
+	Strategy string `yaml:"strategy"`
+
+	// KeepFirst and KeepLast are the number of messages kept at the start and at the end of the conversation,
+	// besides the system messages. KeepLast defaults to 1, the last message is always kept
+	KeepFirst int `yaml:"keep_first"`
+	KeepLast  int `yaml:"keep_last"`
+
+	// ReserveTokens is the number of tokens of the context left for the reply.
+	// It defaults to the max_tokens of the request, or to a quarter of the context size
+	ReserveTokens int `yaml:"reserve_tokens"`
+
+	// SummaryModel is the model summarizing the messages trimmed, the model itself if empty
+	SummaryModel string `yaml:"summary_model"`
+	// SummaryPrompt is the instruction given to the summary model
+	SummaryPrompt string `yaml:"summary_prompt"`
+	// SummaryMaxTokens is the maximum length of the summary, 256 tokens by default
+	SummaryMaxTokens int `yaml:"summary_max_tokens"`
+}
+
+func (w ContextWindow) Enabled() bool {
+	return w.Strategy != ""
+}
+
+// Validate checks the strategy and the numbers of messages and tokens
+func (w ContextWindow) Validate() error {
+	switch w.Strategy {
+	case "", ContextWindowDropOldest, ContextWindowKeepFirstLast, ContextWindowSummarize:
+	default:
+		return fmt.Errorf("unknown context_window strategy %q, expected %s, %s or %s", w.Strategy, ContextWindowDropOldest, ContextWindowKeepFirstLast, ContextWindowSummarize)
+	}
+	if w.KeepFirst < 0 || w.KeepLast < 0 || w.ReserveTokens < 0 || w.SummaryMaxTokens < 0 {
+		return fmt.Errorf("the numbers of messages and tokens of context_window must not be negative")
+	}
+	return nil
+}
+
+// Reserve returns the number of tokens of the context left for the reply
+func (w ContextWindow) Reserve(contextSize, maxTokens int) int {
+	switch {
+	case w.ReserveTokens > 0:
+		return w.ReserveTokens
+	case maxTokens > 0:
+		return maxTokens
+	}
+	return contextSize / 4
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"dario.cat/mergo"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Extends lists the profiles (YAML files relative to the models path) and the models whose
+// configuration a model inherits. It accepts a single value or a list
+type Extends []string
+
+func (e *Extends) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var s string
+		if err := value.Decode(&s); err != nil {
+			return err
+		}
+		*e = Extends{s}
+		return nil
+	}
+	var l []string
+	if err := value.Decode(&l); err != nil {
+		return err
+	}
+	*e = l
+	return nil
+}
+
+// IsProfileFile returns true for the files of the profiles, which are not configurations of models
+func IsProfileFile(name string) bool {
+	return strings.HasSuffix(name, ".profile.yaml") || strings.HasSuffix(name, ".profile.yml")
+}
+
+// ResolveBackendConfigFile reads a configuration file and merges into it the configurations it extends.
+// The bases are merged in order, then the fields of the file override the ones inherited, following
+// the same semantics as the overrides of the galleries: the maps are merged and the other values replaced
+func ResolveBackendConfigFile(file string) ([]byte, error) {
+	r := &extendsResolver{basePath: filepath.Dir(file)}
+	doc, err := r.resolve(file, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, extends := doc["extends"]; !extends {
+		// keep the file as-is when there is nothing to merge
+		return os.ReadFile(file)
+	}
+	return yaml.Marshal(doc)
+}
+
+// ExtendedBackendConfigFiles returns the files of the profiles and of the models a configuration file
+// extends, directly or through its bases, in the order they are merged
+func ExtendedBackendConfigFiles(file string) ([]string, error) {
+	r := &extendsResolver{basePath: filepath.Dir(file)}
+	files := []string{}
+	if err := r.walk(file, nil, func(f string) {
+		if f != file && !slices.Contains(files, f) {
+			files = append(files, f)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// FindBackendConfigFile returns the configuration file of a model of the models path
+func FindBackendConfigFile(modelPath, name string) (string, error) {
+	r := &extendsResolver{basePath: modelPath}
+	return r.fileOfModel(name)
+}
+
+type extendsResolver struct {
+	basePath string
+	// models maps the names of the models to their files, read lazily
+	models map[string]string
+}
+
+func (r *extendsResolver) resolve(file string, chain []string) (map[string]interface{}, error) {
+	for _, f := range chain {
+		if f == file {
+			return nil, fmt.Errorf("cyclic extends: %s", strings.Join(relPaths(r.basePath, append(chain, file)), " -> "))
+		}
+	}
+	chain = append(chain, file)
+
+	doc, err := readYAMLDocument(file)
+	if err != nil {
+		return nil, err
+	}
+	extends, err := extendsOf(doc)
+	if err != nil || len(extends) == 0 {
+		return doc, err
+	}
+
+	merged := map[string]interface{}{}
+	for _, ref := range extends {
+		baseFile, err := r.fileOf(ref)
+		if err != nil {
+			return nil, fmt.Errorf("%s: cannot extend %q: %w", filepath.Base(file), ref, err)
+		}
+		base, err := r.resolve(baseFile, chain)
+		if err != nil {
+			return nil, err
+		}
+		// the identity of the bases is not inherited
+		delete(base, "name")
+		delete(base, "extends")
+		if err := mergo.Merge(&merged, base, mergo.WithOverride); err != nil {
+			return nil, err
+		}
+	}
+	if err := mergo.Merge(&merged, doc, mergo.WithOverride); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// walk calls fn with the files a configuration file extends, then with the file itself
+func (r *extendsResolver) walk(file string, chain []string, fn func(string)) error {
+	if slices.Contains(chain, file) {
+		return fmt.Errorf("cyclic extends: %s", strings.Join(relPaths(r.basePath, append(chain, file)), " -> "))
+	}
+	chain = append(chain, file)
+
+	doc, err := readYAMLDocument(file)
+	if err != nil {
+		return err
+	}
+	extends, err := extendsOf(doc)
+	if err != nil {
+		return err
+	}
+	for _, ref := range extends {
+		baseFile, err := r.fileOf(ref)
+		if err != nil {
+			return fmt.Errorf("%s: cannot extend %q: %w", filepath.Base(file), ref, err)
+		}
+		if err := r.walk(baseFile, chain, fn); err != nil {
+			return err
+		}
+	}
+	fn(file)
+	return nil
+}
+
+// fileOf returns the file of a profile, or of a model if the reference is not a YAML file
+func (r *extendsResolver) fileOf(ref string) (string, error) {
+	if !strings.HasSuffix(ref, ".yaml") && !strings.HasSuffix(ref, ".yml") {
+		return r.fileOfModel(ref)
+	}
+	if err := utils.VerifyPath(ref, r.basePath); err != nil {
+		return "", err
+	}
+	return filepath.Join(r.basePath, ref), nil
+}
+
+func (r *extendsResolver) fileOfModel(name string) (string, error) {
+	if r.models == nil {
+		r.models = map[string]string{}
+		entries, err := os.ReadDir(r.basePath)
+		if err != nil {
+			return "", err
+		}
+		for _, entry := range entries {
+			n := entry.Name()
+			if entry.IsDir() || strings.HasPrefix(n, ".") || IsProfileFile(n) ||
+				(!strings.HasSuffix(n, ".yaml") && !strings.HasSuffix(n, ".yml")) {
+				continue
+			}
+			doc, err := readYAMLDocument(filepath.Join(r.basePath, n))
+			if err != nil {
+				continue
+			}
+			if modelName, ok := doc["name"].(string); ok {
+				r.models[modelName] = filepath.Join(r.basePath, n)
+			}
+		}
+	}
+	file, exists := r.models[name]
+	if !exists {
+		return "", fmt.Errorf("no configuration file found for the model %q", name)
+	}
+	return file, nil
+}
+
+func readYAMLDocument(file string) (map[string]interface{}, error) {
+	dat, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %w", err)
+	}
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(dat, &doc); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal config file %s: %w", filepath.Base(file), err)
+	}
+	return doc, nil
+}
+
+func extendsOf(doc map[string]interface{}) ([]string, error) {
+	switch e := doc["extends"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{e}, nil
+	case []interface{}:
+		refs := []string{}
+		for _, ref := range e {
+			s, ok := ref.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid extends: %v is not a string", ref)
+			}
+			refs = append(refs, s)
+		}
+		return refs, nil
+	}
+	return nil, fmt.Errorf("invalid extends: %v", doc["extends"])
+}
+
+func relPaths(basePath string, files []string) []string {
+	rel := []string{}
+	for _, f := range files {
+		if r, err := filepath.Rel(basePath, f); err == nil {
+			f = r
+		}
+		rel = append(rel, f)
+	}
+	return rel
+}
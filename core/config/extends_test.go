@@ -0,0 +1,102 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/LocalAI/core/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Configurations inheritance", func() {
+	var tempdir string
+	var cl *BackendConfigLoader
+
+	write := func(file, content string) {
+		Expect(os.MkdirAll(filepath.Dir(filepath.Join(tempdir, file)), 0750)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(tempdir, file), []byte(content), 0600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempdir, err = os.MkdirTemp("", "configs")
+		Expect(err).ToNot(HaveOccurred())
+		cl = NewBackendConfigLoader(tempdir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempdir)
+	})
+
+	It("merges the profiles and the models extended", func() {
+		write("llama3.profile.yaml", "f16: true\ncontext_size: 8192\nstopwords: ['<|eot_id|>']\ntemplate:\n  chat: llama3-chat\n  completion: llama3-completion\n")
+		write("profiles/gpu.yaml", "gpu_layers: 99\nparameters:\n  temperature: 0.2\n")
+		write("base.yaml", "name: base\nextends: [llama3.profile.yaml, profiles/gpu.yaml]\nparameters:\n  model: base.gguf\n")
+		write("child.yaml", "name: child\nextends: base\nf16: false\ntemplate:\n  chat: custom\nparameters:\n  model: child.gguf\n")
+		Expect(cl.LoadBackendConfigsFromPath(tempdir)).To(Succeed())
+
+		// the profiles are not models
+		Expect(cl.GetAllBackendConfigs()).To(HaveLen(2))
+
+		child, exists := cl.GetBackendConfig("child")
+		Expect(exists).To(BeTrue())
+		Expect(child.Extends).To(Equal(Extends{"base"}))
+		Expect(child.Model).To(Equal("child.gguf"))
+		Expect(*child.F16).To(BeFalse())
+		Expect(*child.ContextSize).To(Equal(8192))
+		Expect(*child.NGPULayers).To(Equal(99))
+		Expect(*child.Temperature).To(Equal(0.2))
+		Expect(child.StopWords).To(Equal([]string{"<|eot_id|>"}))
+		Expect(child.TemplateConfig.Chat).To(Equal("custom"))
+		Expect(child.TemplateConfig.Completion).To(Equal("llama3-completion"))
+
+		base, _ := cl.GetBackendConfig("base")
+		Expect(base.Model).To(Equal("base.gguf"))
+		Expect(*base.F16).To(BeTrue())
+	})
+
+	It("lists the files extended with the files of the model", func() {
+		write("llama3.profile.yaml", "template:\n  completion: llama3-completion\n")
+		write("profiles/gpu.yaml", "gpu_layers: 99\n")
+		write("base.yaml", "name: base\nextends: [llama3.profile.yaml, profiles/gpu.yaml]\n")
+		write("child.yaml", "name: child\nextends: base\nparameters:\n  model: child.gguf\n")
+		write("child.gguf", "")
+		write("llama3-completion.tmpl", "")
+		Expect(cl.LoadBackendConfigsFromPath(tempdir)).To(Succeed())
+
+		child, exists := cl.GetBackendConfig("child")
+		Expect(exists).To(BeTrue())
+		Expect(child.ModelFiles(tempdir)).To(Equal([]string{"child.yaml", "llama3.profile.yaml", filepath.Join("profiles", "gpu.yaml"), "base.yaml", "child.gguf", "llama3-completion.tmpl"}))
+	})
+
+	It("applies the changes of the profiles", func() {
+		write("common.profile.yaml", "context_size: 2048\n")
+		write("a.yaml", "name: a\nextends: common.profile.yaml\nparameters:\n  model: a.gguf\n")
+		Expect(cl.LoadBackendConfigsFromPath(tempdir)).To(Succeed())
+
+		write("common.profile.yaml", "context_size: 4096\n")
+		changes, err := cl.SyncBackendConfigsFromPath(tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changes).To(HaveLen(1))
+		Expect(*changes[0].New.ContextSize).To(Equal(4096))
+	})
+
+	It("detects the cycles", func() {
+		write("a.yaml", "name: a\nextends: b\n")
+		write("b.yaml", "name: b\nextends: [c.profile.yaml]\n")
+		write("c.profile.yaml", "extends: a\n")
+
+		_, err := ResolveBackendConfigFile(filepath.Join(tempdir, "a.yaml"))
+		Expect(err).To(MatchError("cyclic extends: a.yaml -> b.yaml -> c.profile.yaml -> a.yaml"))
+
+		Expect(cl.LoadBackendConfigsFromPath(tempdir)).To(Succeed())
+		Expect(cl.GetAllBackendConfigs()).To(BeEmpty())
+	})
+
+	It("rejects the profiles outside of the models path", func() {
+		write("a.yaml", "name: a\nextends: ../a.profile.yaml\n")
+		_, err := ResolveBackendConfigFile(filepath.Join(tempdir, "a.yaml"))
+		Expect(err).To(HaveOccurred())
+	})
+})
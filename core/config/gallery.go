@@ -3,4 +3,8 @@ package config
 type Gallery struct {
 	URL  string `json:"url" yaml:"url"`
 	Name string `json:"name" yaml:"name"`
+	// PublicKey is the minisign public key the gallery index and model configurations are signed with
+	PublicKey string `json:"public_key,omitempty" yaml:"public_key,omitempty"`
+	// RequireSignature refuses to install the models of the gallery if it has no public key
+	RequireSignature bool `json:"require_signature,omitempty" yaml:"require_signature,omitempty"`
 }
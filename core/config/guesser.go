@@ -152,7 +152,7 @@ func guessDefaultsFromFile(cfg *BackendConfig, modelPath string) {
 		return
 	}
 
-	if cfg.HasTemplate() {
+	if cfg.HasTemplate() && !cfg.TemplateConfig.UseGGUFChatTemplate {
 		// nothing to guess here
 		log.Debug().Any("name", cfg.Name).Msgf("guessDefaultsFromFile: %s", "template already set")
 		return
@@ -179,6 +179,13 @@ func guessDefaultsFromFile(cfg *BackendConfig, modelPath string) {
 
 	family := identifyFamily(f)
 
+	// without a template of its own, the model is prompted with the chat template of the GGUF file
+	if family == Unknown || cfg.TemplateConfig.UseGGUFChatTemplate {
+		if useGGUFChatTemplate(cfg, f) {
+			return
+		}
+	}
+
 	if family == Unknown {
 		log.Debug().Msgf("guessDefaultsFromFile: %s", "family not identified")
 		return
@@ -197,6 +204,43 @@ func guessDefaultsFromFile(cfg *BackendConfig, modelPath string) {
 	}
 }
 
+// useGGUFChatTemplate sets the tokenizer.chat_template of a GGUF file as the Jinja template of the model,
+// returning false if the file has none
+func useGGUFChatTemplate(cfg *BackendConfig, f *gguf.GGUFFile) bool {
+	chatTemplate, found := f.Header.MetadataKV.Get("tokenizer.chat_template")
+	if !found || chatTemplate.ValueString() == "" {
+		log.Debug().Msgf("guessDefaultsFromFile: %s", "no chat template in the GGUF file")
+		return false
+	}
+
+	if cfg.TemplateConfig.Jinja == "" {
+		cfg.TemplateConfig.Jinja = chatTemplate.ValueString()
+	}
+
+	var tokens []string
+	if kv, found := f.Header.MetadataKV.Get("tokenizer.ggml.tokens"); found {
+		tokens = kv.ValueArray().ValuesString()
+	}
+	token := func(id int64) string {
+		if id >= 0 && id < int64(len(tokens)) {
+			return tokens[id]
+		}
+		return ""
+	}
+	if cfg.TemplateConfig.BOSToken == "" {
+		cfg.TemplateConfig.BOSToken = token(f.Tokenizer().BOSTokenID)
+	}
+	if cfg.TemplateConfig.EOSToken == "" {
+		cfg.TemplateConfig.EOSToken = token(f.Tokenizer().EOSTokenID)
+	}
+	if len(cfg.StopWords) == 0 && cfg.TemplateConfig.EOSToken != "" {
+		cfg.StopWords = []string{cfg.TemplateConfig.EOSToken}
+	}
+
+	log.Debug().Str("bos", cfg.TemplateConfig.BOSToken).Str("eos", cfg.TemplateConfig.EOSToken).Msgf("guessDefaultsFromFile: using the chat template of the GGUF file")
+	return true
+}
+
 func identifyFamily(f *gguf.GGUFFile) familyType {
 
 	// identify from well known templates first
@@ -0,0 +1,347 @@
+package gallery
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	importDir          = ".import"
+	bundleManifestName = "manifest.json"
+	bundleVersion      = 1
+)
+
+// BundleManifest describes the content of a bundle of models. It is the first file of the bundle,
+// followed by the files of the models at their path relative to the models path.
+type BundleManifest struct {
+	Version int           `json:"version"`
+	Created time.Time     `json:"created"`
+	Models  []BundleModel `json:"models"`
+	// Checksums are the SHA256 of all the files of the bundle
+	Checksums map[string]string `json:"checksums"`
+}
+
+type BundleModel struct {
+	Name        string   `json:"name"`
+	ConfigFile  string   `json:"config_file"`
+	GalleryFile string   `json:"gallery_file,omitempty"`
+	Templates   []string `json:"templates,omitempty"`
+	Files       []string `json:"files,omitempty"`
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// ExportModels writes a bundle with the configuration, gallery metadata, templates and files of the models
+func ExportModels(basePath string, names []string, w io.Writer) error {
+	bcl := config.NewBackendConfigLoader(basePath)
+	if err := bcl.LoadBackendConfigsFromPath(basePath); err != nil {
+		return err
+	}
+
+	manifest := BundleManifest{Version: bundleVersion, Created: time.Now().UTC(), Checksums: map[string]string{}}
+	files := []string{}
+	for _, name := range names {
+		cfg, exists := bcl.GetBackendConfig(name)
+		if !exists || cfg.ConfigFile() == "" {
+			return fmt.Errorf("model %q not found", name)
+		}
+
+		m := BundleModel{Name: name}
+		for _, f := range cfg.ModelFiles(basePath) {
+			switch {
+			case filepath.Join(basePath, f) == cfg.ConfigFile():
+				m.ConfigFile = f
+			case strings.HasSuffix(f, ".tmpl"):
+				m.Templates = append(m.Templates, f)
+			default:
+				m.Files = append(m.Files, f)
+			}
+			files = append(files, f)
+		}
+		if m.ConfigFile == "" {
+			return fmt.Errorf("the configuration file of %q is not in %s", name, basePath)
+		}
+
+		galleryFile := galleryFileName(strings.ReplaceAll(name, string(os.PathSeparator), "__"))
+		if _, err := os.Stat(filepath.Join(basePath, galleryFile)); err == nil {
+			m.GalleryFile = galleryFile
+			files = append(files, galleryFile)
+		}
+		manifest.Models = append(manifest.Models, m)
+	}
+
+	// files shared between models are stored once
+	unique := []string{}
+	for _, f := range files {
+		if _, exists := manifest.Checksums[f]; exists {
+			continue
+		}
+		log.Debug().Msgf("Computing the checksum of %s", f)
+		sha, err := fileSHA256(filepath.Join(basePath, f))
+		if err != nil {
+			return err
+		}
+		manifest.Checksums[f] = sha
+		unique = append(unique, f)
+	}
+
+	tw := tar.NewWriter(w)
+	dat, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: bundleManifestName, Mode: 0644, Size: int64(len(dat)), ModTime: manifest.Created}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(dat); err != nil {
+		return err
+	}
+
+	for _, f := range unique {
+		if err := addFileToTar(tw, basePath, f); err != nil {
+			return fmt.Errorf("failed adding %q to the bundle: %w", f, err)
+		}
+	}
+	return tw.Close()
+}
+
+func addFileToTar(tw *tar.Writer, basePath, file string) error {
+	f, err := os.Open(filepath.Join(basePath, file))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: filepath.ToSlash(file), Mode: 0644, Size: st.Size(), ModTime: st.ModTime()}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// ImportModels installs the models of a bundle written by ExportModels. The files are verified
+// against the checksums of the manifest, and installed only if all of them are valid.
+// Existing files with a different content are replaced only if force is set.
+// It returns the names of the imported models.
+func ImportModels(basePath string, r io.Reader, force bool, downloadStatus func(string, string, string, float64)) ([]string, error) {
+	staging := filepath.Join(basePath, importDir, uuid.NewString())
+	if err := os.MkdirAll(staging, 0750); err != nil {
+		return nil, err
+	}
+	defer func() {
+		os.RemoveAll(staging)
+		os.Remove(filepath.Dir(staging))
+	}()
+
+	manifest, err := extractBundle(r, staging)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, m := range manifest.Models {
+		name := strings.ReplaceAll(m.Name, string(os.PathSeparator), "__")
+		if err := utils.VerifyPath(name+".yaml", basePath); err != nil {
+			return nil, err
+		}
+
+		configFile, err := readBundleFile(manifest, staging, m.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		var galleryFile []byte
+		if m.GalleryFile != "" {
+			if galleryFile, err = readBundleFile(manifest, staging, m.GalleryFile); err != nil {
+				return nil, err
+			}
+		}
+
+		c := &Config{Name: name, ConfigFile: string(configFile)}
+		for _, t := range m.Templates {
+			content, err := readBundleFile(manifest, staging, t)
+			if err != nil {
+				return nil, err
+			}
+			c.PromptTemplates = append(c.PromptTemplates, PromptTemplate{Name: strings.TrimSuffix(t, ".tmpl"), Content: string(content)})
+		}
+		for _, f := range m.Files {
+			if _, listed := manifest.Checksums[f]; !listed {
+				return nil, fmt.Errorf("invalid bundle: %q has no checksum in the manifest", f)
+			}
+			c.Files = append(c.Files, File{Filename: f, SHA256: manifest.Checksums[f]})
+		}
+
+		// the files are already in place: InstallModel only verifies them
+		if err := InstallModel(context.Background(), staging, name, c, nil, downloadStatus, false); err != nil {
+			return nil, fmt.Errorf("failed installing %q: %w", name, err)
+		}
+		if m.ConfigFile != name+".yaml" {
+			os.Remove(filepath.Join(staging, m.ConfigFile))
+		}
+		// keep the metadata of the gallery the model was installed from
+		if galleryFile != nil {
+			if err := os.WriteFile(filepath.Join(staging, galleryFileName(name)), galleryFile, 0600); err != nil {
+				return nil, err
+			}
+		}
+		names = append(names, name)
+	}
+
+	if err := installStagedFiles(staging, basePath, force); err != nil {
+		return nil, err
+	}
+	log.Info().Strs("models", names).Msg("models imported")
+	return names, nil
+}
+
+// readBundleFile reads a file of the bundle, which must have been verified against the checksums of the manifest
+func readBundleFile(manifest *BundleManifest, staging, file string) ([]byte, error) {
+	if _, listed := manifest.Checksums[file]; !listed {
+		return nil, fmt.Errorf("invalid bundle: %q has no checksum in the manifest", file)
+	}
+	if err := utils.VerifyPath(file, staging); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	dat, err := os.ReadFile(filepath.Join(staging, file))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	return dat, nil
+}
+
+func extractBundle(r io.Reader, staging string) (*BundleManifest, error) {
+	tr := tar.NewReader(r)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	if hdr.Name != bundleManifestName {
+		return nil, fmt.Errorf("invalid bundle: %s is not the first file", bundleManifestName)
+	}
+	manifest := &BundleManifest{}
+	if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+	if manifest.Version != bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", manifest.Version)
+	}
+
+	extracted := map[string]bool{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := filepath.FromSlash(hdr.Name)
+		sha, listed := manifest.Checksums[name]
+		if !listed {
+			return nil, fmt.Errorf("invalid bundle: %q is not listed in the manifest", hdr.Name)
+		}
+		if err := utils.VerifyPath(name, staging); err != nil {
+			return nil, err
+		}
+
+		dst := filepath.Join(staging, name)
+		if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+			return nil, err
+		}
+		f, err := os.Create(dst)
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(io.MultiWriter(f, h), tr)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if calculated := fmt.Sprintf("%x", h.Sum(nil)); calculated != sha {
+			return nil, fmt.Errorf("checksum mismatch for %q ( calculated: %s != manifest: %s )", hdr.Name, calculated, sha)
+		}
+		extracted[name] = true
+	}
+
+	for f := range manifest.Checksums {
+		if !extracted[f] {
+			return nil, fmt.Errorf("invalid bundle: %q is missing", f)
+		}
+	}
+	return manifest, nil
+}
+
+// installStagedFiles moves the files from the staging directory to the models path,
+// after checking that they do not replace different files (unless force is set)
+func installStagedFiles(staging, basePath string, force bool) error {
+	files := []string{}
+	err := filepath.Walk(staging, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(staging, path)
+		if err != nil {
+			return err
+		}
+
+		dst := filepath.Join(basePath, rel)
+		if _, err := os.Stat(dst); err == nil && !force {
+			installed, err := fileSHA256(dst)
+			if err != nil {
+				return err
+			}
+			staged, err := fileSHA256(path)
+			if err != nil {
+				return err
+			}
+			if installed != staged {
+				return fmt.Errorf("%q already exists with a different content", rel)
+			}
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if e := move(filepath.Join(staging, f), filepath.Join(basePath, f)); e != nil {
+			err = errors.Join(err, e)
+		}
+	}
+	return err
+}
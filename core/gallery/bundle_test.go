@@ -0,0 +1,129 @@
+package gallery_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/LocalAI/core/gallery"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Model bundles", func() {
+	var src, dst string
+
+	noStatus := func(string, string, string, float64) {}
+	writeFile := func(dir, name, content string) {
+		Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)).To(Succeed())
+	}
+	readFile := func(dir, name string) string {
+		dat, err := os.ReadFile(filepath.Join(dir, name))
+		Expect(err).ToNot(HaveOccurred())
+		return string(dat)
+	}
+
+	BeforeEach(func() {
+		var err error
+		src, err = os.MkdirTemp("", "bundle-src")
+		Expect(err).ToNot(HaveOccurred())
+		dst, err = os.MkdirTemp("", "bundle-dst")
+		Expect(err).ToNot(HaveOccurred())
+
+		writeFile(src, "test.yaml", "name: test\nbackend: llama-cpp\nparameters:\n  model: weights.bin\ntemplate:\n  chat: chat\n")
+		writeFile(src, "weights.bin", "weights")
+		writeFile(src, "chat.tmpl", "{{.Input}}")
+		writeFile(src, "._gallery_test.yaml", "name: test\ngallery: local\ngallery_model: test\n")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(src)
+		os.RemoveAll(dst)
+	})
+
+	It("exports and imports models", func() {
+		bundle := &bytes.Buffer{}
+		Expect(ExportModels(src, []string{"test"}, bundle)).To(Succeed())
+
+		names, err := ImportModels(dst, bytes.NewReader(bundle.Bytes()), false, noStatus)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(names).To(Equal([]string{"test"}))
+		Expect(readFile(dst, "weights.bin")).To(Equal("weights"))
+		Expect(readFile(dst, "chat.tmpl")).To(Equal("{{.Input}}"))
+		Expect(readFile(dst, "test.yaml")).To(ContainSubstring("model: weights.bin"))
+		Expect(readFile(dst, "._gallery_test.yaml")).To(ContainSubstring("gallery_model: test"))
+
+		// importing the same bundle again is a no-op
+		_, err = ImportModels(dst, bytes.NewReader(bundle.Bytes()), false, noStatus)
+		Expect(err).ToNot(HaveOccurred())
+
+		writeFile(dst, "weights.bin", "modified")
+		_, err = ImportModels(dst, bytes.NewReader(bundle.Bytes()), false, noStatus)
+		Expect(err).To(HaveOccurred())
+		Expect(readFile(dst, "weights.bin")).To(Equal("modified"))
+
+		_, err = ImportModels(dst, bytes.NewReader(bundle.Bytes()), true, noStatus)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(readFile(dst, "weights.bin")).To(Equal("weights"))
+	})
+
+	It("refuses bundles with files not matching the manifest", func() {
+		bundle := &bytes.Buffer{}
+		Expect(ExportModels(src, []string{"test"}, bundle)).To(Succeed())
+
+		tampered := &bytes.Buffer{}
+		tr := tar.NewReader(bundle)
+		tw := tar.NewWriter(tampered)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).ToNot(HaveOccurred())
+			dat, err := io.ReadAll(tr)
+			Expect(err).ToNot(HaveOccurred())
+			if hdr.Name == "weights.bin" {
+				dat = []byte("tampered")
+				hdr.Size = int64(len(dat))
+			}
+			Expect(tw.WriteHeader(hdr)).To(Succeed())
+			_, err = tw.Write(dat)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(tw.Close()).To(Succeed())
+
+		_, err := ImportModels(dst, tampered, false, noStatus)
+		Expect(err).To(MatchError(ContainSubstring("checksum mismatch")))
+		_, err = os.Stat(filepath.Join(dst, "weights.bin"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("refuses bundles referring to files not verified by the manifest", func() {
+		writeFile(dst, "secret.yaml", "name: secret\n")
+		craft := func(configFile string) *bytes.Buffer {
+			manifest := BundleManifest{Version: 1, Models: []BundleModel{{Name: "test", ConfigFile: configFile}}, Checksums: map[string]string{}}
+			dat, err := json.Marshal(manifest)
+			Expect(err).ToNot(HaveOccurred())
+			bundle := &bytes.Buffer{}
+			tw := tar.NewWriter(bundle)
+			Expect(tw.WriteHeader(&tar.Header{Name: "manifest.json", Mode: 0644, Size: int64(len(dat))})).To(Succeed())
+			_, err = tw.Write(dat)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tw.Close()).To(Succeed())
+			return bundle
+		}
+
+		for _, configFile := range []string{"../../secret.yaml", "test.yaml"} {
+			_, err := ImportModels(dst, craft(configFile), false, noStatus)
+			Expect(err).To(MatchError(ContainSubstring("no checksum in the manifest")))
+		}
+		Expect(filepath.Join(dst, "test.yaml")).ToNot(BeAnExistingFile())
+	})
+
+	It("fails exporting unknown models", func() {
+		Expect(ExportModels(src, []string{"unknown"}, io.Discard)).ToNot(Succeed())
+	})
+})
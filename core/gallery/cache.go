@@ -0,0 +1,88 @@
+package gallery
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"golang.org/x/sync/singleflight"
+)
+
+type cachedIndex struct {
+	models  []*GalleryModel
+	fetched time.Time
+}
+
+// IndexCache keeps the indexes of the galleries in memory, and downloads them again
+// once they are older than the refresh interval
+type IndexCache struct {
+	sync.Mutex
+	refreshInterval time.Duration
+	indexes         map[config.Gallery]cachedIndex
+	// generation changes when the cache is invalidated, so that the downloads started before are not cached
+	generation int
+	// fetches downloads each index once at a time, the concurrent calls waiting for the same download
+	fetches singleflight.Group
+}
+
+func NewIndexCache(refreshInterval time.Duration) *IndexCache {
+	return &IndexCache{
+		refreshInterval: refreshInterval,
+		indexes:         map[config.Gallery]cachedIndex{},
+	}
+}
+
+// AvailableGalleryModels is AvailableGalleryModels, using the cached indexes when they are recent enough
+func (c *IndexCache) AvailableGalleryModels(galleries []config.Gallery, basePath string) ([]*GalleryModel, error) {
+	models := []*GalleryModel{}
+	for _, gallery := range galleries {
+		galleryModels, err := c.galleryModels(gallery, basePath)
+		if err != nil {
+			return nil, err
+		}
+		// the models are copied, as the installed state is specific to each call
+		for _, m := range galleryModels {
+			model := *m
+			models = append(models, &model)
+		}
+	}
+	markInstalledModels(models, basePath)
+	return models, nil
+}
+
+func (c *IndexCache) galleryModels(gallery config.Gallery, basePath string) ([]*GalleryModel, error) {
+	c.Lock()
+	if index, exists := c.indexes[gallery]; exists && time.Since(index.fetched) < c.refreshInterval {
+		c.Unlock()
+		return index.models, nil
+	}
+	generation := c.generation
+	c.Unlock()
+
+	// the index is downloaded without holding the lock, so a slow gallery does not block the others
+	models, err, _ := c.fetches.Do(fmt.Sprintf("%d/%+v/%s", generation, gallery, basePath), func() (interface{}, error) {
+		models, err := fetchGalleryModels(gallery, basePath)
+		if err != nil {
+			return nil, err
+		}
+		c.Lock()
+		if c.generation == generation {
+			c.indexes[gallery] = cachedIndex{models: models, fetched: time.Now()}
+		}
+		c.Unlock()
+		return models, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return models.([]*GalleryModel), nil
+}
+
+// Invalidate drops the cached indexes, so they are downloaded again on the next call
+func (c *IndexCache) Invalidate() {
+	c.Lock()
+	defer c.Unlock()
+	c.indexes = map[config.Gallery]cachedIndex{}
+	c.generation++
+}
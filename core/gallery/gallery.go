@@ -1,6 +1,7 @@
 package gallery
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -16,72 +17,85 @@ import (
 )
 
 // Installs a model from the gallery
-func InstallModelFromGallery(galleries []config.Gallery, name string, basePath string, req GalleryModel, downloadStatus func(string, string, string, float64), enforceScan bool) error {
+func InstallModelFromGallery(ctx context.Context, galleries []config.Gallery, name string, basePath string, req GalleryModel, downloadStatus func(string, string, string, float64), enforceScan bool) error {
 
-	applyModel := func(model *GalleryModel) error {
-		name = strings.ReplaceAll(name, string(os.PathSeparator), "__")
+	models, err := AvailableGalleryModels(galleries, basePath)
+	if err != nil {
+		return err
+	}
 
-		var config Config
+	model := FindModel(models, name, basePath)
+	if model == nil {
+		return fmt.Errorf("no model found with name %q", name)
+	}
+	if err := checkGallerySigned(model); err != nil {
+		return err
+	}
 
-		if len(model.URL) > 0 {
-			var err error
-			config, err = GetGalleryConfigFromURL(model.URL, basePath)
-			if err != nil {
-				return err
-			}
-		} else if len(model.ConfigFile) > 0 {
-			// TODO: is this worse than using the override method with a blank cfg yaml?
-			reYamlConfig, err := yaml.Marshal(model.ConfigFile)
-			if err != nil {
-				return err
-			}
-			config = Config{
-				ConfigFile:  string(reYamlConfig),
-				Description: model.Description,
-				License:     model.License,
-				URLs:        model.URLs,
-				Name:        model.Name,
-				Files:       make([]File, 0), // Real values get added below, must be blank
-				// Prompt Template Skipped for now - I expect in this mode that they will be delivered as files.
-			}
-		} else {
-			return fmt.Errorf("invalid gallery model %+v", model)
-		}
+	config, installName, overrides, err := galleryModelConfig(model, basePath, req)
+	if err != nil {
+		return err
+	}
 
-		installName := model.Name
-		if req.Name != "" {
-			installName = req.Name
-		}
+	return InstallModel(ctx, basePath, installName, &config, overrides, downloadStatus, enforceScan)
+}
 
-		// Copy the model configuration from the request schema
-		config.URLs = append(config.URLs, model.URLs...)
-		config.Icon = model.Icon
-		config.Files = append(config.Files, req.AdditionalFiles...)
-		config.Files = append(config.Files, model.AdditionalFiles...)
+// galleryModelConfig returns the configuration to install the gallery model with the
+// name, overrides and additional files of the request
+func galleryModelConfig(model *GalleryModel, basePath string, req GalleryModel) (Config, string, map[string]interface{}, error) {
+	var config Config
 
-		// TODO model.Overrides could be merged with user overrides (not defined yet)
-		if err := mergo.Merge(&model.Overrides, req.Overrides, mergo.WithOverride); err != nil {
-			return err
+	if len(model.URL) > 0 {
+		var err error
+		config, err = getGalleryConfigFromURL(model.URL, basePath, model.Gallery.PublicKey)
+		if err != nil {
+			return config, "", nil, err
 		}
-
-		if err := InstallModel(basePath, installName, &config, model.Overrides, downloadStatus, enforceScan); err != nil {
-			return err
+	} else if len(model.ConfigFile) > 0 {
+		// TODO: is this worse than using the override method with a blank cfg yaml?
+		reYamlConfig, err := yaml.Marshal(model.ConfigFile)
+		if err != nil {
+			return config, "", nil, err
 		}
-
-		return nil
+		config = Config{
+			ConfigFile:  string(reYamlConfig),
+			Description: model.Description,
+			License:     model.License,
+			URLs:        model.URLs,
+			Name:        model.Name,
+			Files:       make([]File, 0), // Real values get added below, must be blank
+			// Prompt Template Skipped for now - I expect in this mode that they will be delivered as files.
+		}
+	} else {
+		return config, "", nil, fmt.Errorf("invalid gallery model %+v", model)
 	}
 
-	models, err := AvailableGalleryModels(galleries, basePath)
-	if err != nil {
-		return err
+	installName := model.Name
+	if req.Name != "" {
+		installName = req.Name
 	}
 
-	model := FindModel(models, name, basePath)
-	if model == nil {
-		return fmt.Errorf("no model found with name %q", name)
+	// Copy the model configuration from the request schema
+	config.URLs = append(config.URLs, model.URLs...)
+	config.Icon = model.Icon
+	config.Files = append(config.Files, req.AdditionalFiles...)
+	config.Files = append(config.Files, model.AdditionalFiles...)
+
+	// Keep track of where the model comes from, and of the user changes
+	config.Gallery = model.Gallery.Name
+	config.GalleryModel = model.Name
+	config.Overrides = req.Overrides
+	config.AdditionalFiles = req.AdditionalFiles
+
+	overrides := map[string]interface{}{}
+	if err := mergo.Merge(&overrides, model.Overrides); err != nil {
+		return config, "", nil, err
+	}
+	if err := mergo.Merge(&overrides, req.Overrides, mergo.WithOverride); err != nil {
+		return config, "", nil, err
 	}
 
-	return applyModel(model)
+	return config, installName, overrides, nil
 }
 
 func FindModel(models []*GalleryModel, name string, basePath string) *GalleryModel {
@@ -144,6 +158,16 @@ func findGalleryURLFromReferenceURL(url string, basePath string) (string, error)
 }
 
 func getGalleryModels(gallery config.Gallery, basePath string) ([]*GalleryModel, error) {
+	models, err := fetchGalleryModels(gallery, basePath)
+	if err != nil {
+		return models, err
+	}
+	markInstalledModels(models, basePath)
+	return models, nil
+}
+
+// fetchGalleryModels downloads the index of the gallery
+func fetchGalleryModels(gallery config.Gallery, basePath string) ([]*GalleryModel, error) {
 	var models []*GalleryModel = []*GalleryModel{}
 
 	if strings.HasSuffix(gallery.URL, ".ref") {
@@ -154,7 +178,7 @@ func getGalleryModels(gallery config.Gallery, basePath string) ([]*GalleryModel,
 		}
 	}
 
-	err := downloader.DownloadAndUnmarshal(gallery.URL, basePath, func(url string, d []byte) error {
+	err := downloadAndUnmarshalSigned(gallery.URL, basePath, gallery.PublicKey, func(url string, d []byte) error {
 		return yaml.Unmarshal(d, &models)
 	})
 	if err != nil {
@@ -167,13 +191,17 @@ func getGalleryModels(gallery config.Gallery, basePath string) ([]*GalleryModel,
 	// Add gallery to models
 	for _, model := range models {
 		model.Gallery = gallery
+	}
+	return models, nil
+}
+
+func markInstalledModels(models []*GalleryModel, basePath string) {
+	for _, model := range models {
 		// we check if the model was already installed by checking if the config file exists
 		// TODO: (what to do if the model doesn't install a config file?)
-		if _, err := os.Stat(filepath.Join(basePath, fmt.Sprintf("%s.yaml", model.Name))); err == nil {
-			model.Installed = true
-		}
+		_, err := os.Stat(filepath.Join(basePath, fmt.Sprintf("%s.yaml", model.Name)))
+		model.Installed = err == nil
 	}
-	return models, nil
 }
 
 func GetLocalModelConfiguration(basePath string, name string) (*Config, error) {
@@ -206,11 +234,24 @@ func DeleteModelFromSystem(basePath string, name string, additionalFiles []strin
 
 	// Remove additional files
 	if galleryconfig != nil {
-		for _, f := range galleryconfig.Files {
-			fullPath := filepath.Join(basePath, f.Filename)
+		for _, f := range installedFiles(galleryconfig) {
+			if filepath.Clean(f) == "." {
+				continue
+			}
+			if e := utils.VerifyPath(f, basePath); e != nil {
+				err = errors.Join(err, fmt.Errorf("failed to verify path %s: %w", f, e))
+				continue
+			}
+			fullPath := filepath.Join(basePath, f)
 			log.Debug().Msgf("Removing file %s", fullPath)
-			if e := os.Remove(fullPath); e != nil {
-				err = errors.Join(err, fmt.Errorf("failed to remove file %s: %w", f.Filename, e))
+			if e := os.RemoveAll(fullPath); e != nil {
+				err = errors.Join(err, fmt.Errorf("failed to remove file %s: %w", f, e))
+			}
+			// the folders of the files of a folder or a glob pattern are removed once empty
+			for dir := filepath.Dir(f); dir != "." && dir != string(os.PathSeparator); dir = filepath.Dir(dir) {
+				if os.Remove(filepath.Join(basePath, dir)) != nil {
+					break
+				}
 			}
 		}
 	}
@@ -233,9 +274,25 @@ func DeleteModelFromSystem(basePath string, name string, additionalFiles []strin
 	// Delete gallery config file
 	os.Remove(galleryFile)
 
+	// Delete the previous version kept by the last upgrade
+	os.RemoveAll(filepath.Join(basePath, rollbackDir, name))
+
 	return err
 }
 
+// installedFiles returns the files installed with a model. The gallery files written before the
+// installed files were recorded only have the files of the configuration, whose shards are added
+func installedFiles(c *Config) []string {
+	if len(c.InstalledFiles) > 0 {
+		return c.InstalledFiles
+	}
+	files := []string{}
+	for _, f := range c.Files {
+		files = append(files, downloader.ShardNames(f.Filename)...)
+	}
+	return files
+}
+
 // This is ***NEVER*** going to be perfect or finished.
 // This is a BEST EFFORT function to surface known-vulnerable models to users.
 func SafetyScanGalleryModels(galleries []config.Gallery, basePath string) error {
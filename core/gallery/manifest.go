@@ -0,0 +1,254 @@
+package gallery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ManifestInstall = "install"
+	ManifestReapply = "reapply"
+	ManifestUpgrade = "upgrade"
+	ManifestDelete  = "delete"
+)
+
+// Manifest is the desired state of the models of an instance
+type Manifest struct {
+	Models []ManifestModel `json:"models" yaml:"models"`
+}
+
+// ManifestModel is a model of the manifest: either a model of the galleries (ID),
+// or a model gallery configuration (URL), installed with the overrides and files of the request
+type ManifestModel struct {
+	ID           string `json:"id,omitempty" yaml:"id,omitempty"`
+	GalleryModel `yaml:",inline"`
+	// Pinned models are not upgraded when they change in the galleries
+	Pinned bool `json:"pinned,omitempty" yaml:"pinned,omitempty"`
+}
+
+// ManifestAction is a change applied to the models path to reconcile it with a manifest
+type ManifestAction struct {
+	Action string `json:"action"`
+	Model  string `json:"model"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func (a ManifestAction) String() string {
+	if a.Reason == "" {
+		return fmt.Sprintf("%s %s", a.Action, a.Model)
+	}
+	return fmt.Sprintf("%s %s (%s)", a.Action, a.Model, a.Reason)
+}
+
+// ReadManifest parses a manifest
+func ReadManifest(dat []byte) (*Manifest, error) {
+	m := &Manifest{}
+	dat, err := utils.ExpandYAMLVariables(dat)
+	if err != nil {
+		return nil, fmt.Errorf("invalid models manifest: %w", err)
+	}
+	if err := yaml.Unmarshal(dat, m); err != nil {
+		return nil, fmt.Errorf("invalid models manifest: %w", err)
+	}
+	names := map[string]bool{}
+	for i, model := range m.Models {
+		if model.ID == "" && model.URL == "" {
+			return nil, fmt.Errorf("invalid models manifest: model %d has neither an id nor a url", i)
+		}
+		if model.Name != "" {
+			if names[model.Name] {
+				return nil, fmt.Errorf("invalid models manifest: %q is listed more than once", model.Name)
+			}
+			names[model.Name] = true
+		}
+	}
+	return m, nil
+}
+
+// desiredModel is a model of the manifest, resolved against the galleries
+type desiredModel struct {
+	name   string
+	entry  ManifestModel
+	config Config
+	// overrides are the ones of the gallery merged with the ones of the manifest
+	overrides map[string]interface{}
+}
+
+func resolveManifestModel(models []*GalleryModel, basePath string, entry ManifestModel) (*desiredModel, error) {
+	if entry.ID != "" {
+		model := FindModel(models, entry.ID, basePath)
+		if model == nil {
+			return nil, fmt.Errorf("no model found with name %q", entry.ID)
+		}
+		if err := checkGallerySigned(model); err != nil {
+			return nil, err
+		}
+		cfg, name, overrides, err := galleryModelConfig(model, basePath, entry.GalleryModel)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Manifest = true
+		return &desiredModel{name: strings.ReplaceAll(name, string(os.PathSeparator), "__"), entry: entry, config: cfg, overrides: overrides}, nil
+	}
+
+	cfg, err := GetGalleryConfigFromURL(entry.URL, basePath)
+	if err != nil {
+		return nil, err
+	}
+	name := cfg.Name
+	if entry.Name != "" {
+		name = entry.Name
+	}
+	if name == "" {
+		return nil, fmt.Errorf("the model at %q has no name", entry.URL)
+	}
+	cfg.Files = append(cfg.Files, entry.AdditionalFiles...)
+	cfg.ConfigURL = entry.URL
+	cfg.Overrides = entry.Overrides
+	cfg.AdditionalFiles = entry.AdditionalFiles
+	cfg.Manifest = true
+	return &desiredModel{name: strings.ReplaceAll(name, string(os.PathSeparator), "__"), entry: entry, config: cfg, overrides: entry.Overrides}, nil
+}
+
+// sameValues compares the values of the manifest and of the gallery file, considering empty values equal
+func sameValues(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Len() == 0 && vb.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// planModel returns the action reconciling an installed model with the manifest, or nil if it is up to date
+func planModel(basePath string, d *desiredModel) *ManifestAction {
+	if _, err := os.Stat(filepath.Join(basePath, d.name+".yaml")); err != nil {
+		return &ManifestAction{Action: ManifestInstall, Model: d.name}
+	}
+	reapply := func(reason string) *ManifestAction {
+		return &ManifestAction{Action: ManifestReapply, Model: d.name, Reason: reason}
+	}
+
+	local, err := GetLocalModelConfiguration(basePath, d.name)
+	if err != nil {
+		return reapply("not installed from the manifest")
+	}
+	switch {
+	case !local.Manifest:
+		return reapply("not installed from the manifest")
+	case local.Gallery != d.config.Gallery || local.GalleryModel != d.config.GalleryModel || local.ConfigURL != d.config.ConfigURL:
+		return reapply("installed from another model")
+	case !sameValues(local.Overrides, d.config.Overrides):
+		return reapply("overrides changed")
+	case !sameValues(local.AdditionalFiles, d.config.AdditionalFiles):
+		return reapply("files changed")
+	}
+	for _, f := range local.Files {
+		if _, err := os.Stat(filepath.Join(basePath, f.Filename)); err != nil {
+			return reapply(fmt.Sprintf("%s is missing", f.Filename))
+		}
+	}
+
+	if !d.entry.Pinned && diffConfigs(d.name, local, &d.config).HasChanges() {
+		if d.entry.ID != "" {
+			return &ManifestAction{Action: ManifestUpgrade, Model: d.name, Reason: "changed in the gallery"}
+		}
+		return reapply("changed upstream")
+	}
+	return nil
+}
+
+// PlanManifest returns the actions reconciling the models path with the manifest
+func PlanManifest(galleries []config.Gallery, basePath string, m *Manifest) ([]ManifestAction, error) {
+	actions, _, err := planManifest(galleries, basePath, m)
+	return actions, err
+}
+
+func planManifest(galleries []config.Gallery, basePath string, m *Manifest) ([]ManifestAction, map[string]*desiredModel, error) {
+	var models []*GalleryModel
+	if slices.ContainsFunc(m.Models, func(e ManifestModel) bool { return e.ID != "" }) {
+		var err error
+		if models, err = AvailableGalleryModels(galleries, basePath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var err error
+	actions := []ManifestAction{}
+	desired := map[string]*desiredModel{}
+	for _, entry := range m.Models {
+		d, e := resolveManifestModel(models, basePath, entry)
+		if e != nil {
+			err = errors.Join(err, fmt.Errorf("failed resolving %q: %w", entry.ID+entry.URL, e))
+			continue
+		}
+		desired[d.name] = d
+		if a := planModel(basePath, d); a != nil {
+			actions = append(actions, *a)
+		}
+	}
+	// an error resolving a model must not delete it
+	if err != nil {
+		return actions, desired, err
+	}
+
+	installed, err := InstalledGalleryModels(basePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range installed {
+		if _, exists := desired[name]; exists {
+			continue
+		}
+		// only the models installed from a manifest are deleted, the others are left untouched
+		if local, err := GetLocalModelConfiguration(basePath, name); err == nil && local.Manifest {
+			actions = append(actions, ManifestAction{Action: ManifestDelete, Model: name, Reason: "removed from the manifest"})
+		}
+	}
+	return actions, desired, nil
+}
+
+// ReconcileManifest installs the models of the manifest which are missing, applies again the ones which
+// differ from it, and deletes the models installed from a manifest which are not part of it anymore.
+// In dry run mode, the actions are only returned.
+func ReconcileManifest(ctx context.Context, galleries []config.Gallery, basePath string, m *Manifest, dryRun bool, downloadStatus func(string, string, string, float64), enforceScan bool) ([]ManifestAction, error) {
+	actions, desired, err := planManifest(galleries, basePath, m)
+	if dryRun {
+		for _, a := range actions {
+			log.Info().Str("model", a.Model).Str("reason", a.Reason).Msgf("models manifest (dry run): %s", a.Action)
+		}
+		return actions, err
+	}
+
+	for _, a := range actions {
+		if ctx.Err() != nil {
+			return actions, errors.Join(err, ctx.Err())
+		}
+		log.Info().Str("model", a.Model).Str("reason", a.Reason).Msgf("models manifest: %s", a.Action)
+
+		var e error
+		switch a.Action {
+		case ManifestInstall, ManifestReapply:
+			d := desired[a.Model]
+			e = InstallModel(ctx, basePath, d.name, &d.config, d.overrides, downloadStatus, enforceScan)
+		case ManifestUpgrade:
+			e = UpgradeModel(ctx, galleries, basePath, a.Model, downloadStatus, enforceScan)
+		case ManifestDelete:
+			e = DeleteModelFromSystem(basePath, a.Model, nil)
+		}
+		if e != nil {
+			err = errors.Join(err, fmt.Errorf("failed to %s %q: %w", a.Action, a.Model, e))
+		}
+	}
+	return actions, err
+}
@@ -0,0 +1,129 @@
+package gallery_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/mudler/LocalAI/core/config"
+	. "github.com/mudler/LocalAI/core/gallery"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Models manifest", func() {
+	var tempdir, galleryDir string
+	var server *httptest.Server
+	var galleries []config.Gallery
+
+	noStatus := func(string, string, string, float64) {}
+
+	writeGalleryModel := func(weights string) {
+		c := Config{
+			Name:       "test",
+			ConfigFile: "backend: llama-cpp\nparameters:\n  model: weights.bin\n",
+			Files: []File{
+				{Filename: "weights.bin", SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte(weights))), URI: server.URL + "/" + weights},
+			},
+		}
+		dat, err := yaml.Marshal(c)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(galleryDir, "test.yaml"), dat, 0600)).To(Succeed())
+	}
+
+	readFile := func(name string) string {
+		dat, err := os.ReadFile(filepath.Join(tempdir, name))
+		Expect(err).ToNot(HaveOccurred())
+		return string(dat)
+	}
+
+	reconcile := func(manifest string, dryRun bool) []ManifestAction {
+		m, err := ReadManifest([]byte(manifest))
+		Expect(err).ToNot(HaveOccurred())
+		actions, err := ReconcileManifest(context.Background(), galleries, tempdir, m, dryRun, noStatus, false)
+		Expect(err).ToNot(HaveOccurred())
+		return actions
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempdir, err = os.MkdirTemp("", "manifest")
+		Expect(err).ToNot(HaveOccurred())
+		galleryDir = filepath.Join(tempdir, "gallery")
+		Expect(os.MkdirAll(galleryDir, 0750)).To(Succeed())
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.URL.Path[1:]))
+		}))
+
+		index := []GalleryModel{{Name: "test", URL: "file://" + filepath.Join(galleryDir, "test.yaml")}}
+		dat, err := yaml.Marshal(index)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(galleryDir, "index.yaml"), dat, 0600)).To(Succeed())
+		galleries = []config.Gallery{{Name: "local", URL: "file://" + filepath.Join(galleryDir, "index.yaml")}}
+		writeGalleryModel("weights-v1")
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(tempdir)
+	})
+
+	It("installs the missing models and applies again the ones which changed", func() {
+		manifest := "models:\n- id: local@test\n  overrides:\n    context_size: 512\n"
+		Expect(reconcile(manifest, true)).To(Equal([]ManifestAction{{Action: ManifestInstall, Model: "test"}}))
+		_, err := os.Stat(filepath.Join(tempdir, "test.yaml"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+
+		Expect(reconcile(manifest, false)).To(HaveLen(1))
+		Expect(readFile("test.yaml")).To(ContainSubstring("context_size: 512"))
+		Expect(readFile("weights.bin")).To(Equal("weights-v1"))
+		Expect(reconcile(manifest, false)).To(BeEmpty())
+
+		manifest = "models:\n- id: local@test\n  overrides:\n    context_size: 1024\n"
+		Expect(reconcile(manifest, false)).To(Equal([]ManifestAction{{Action: ManifestReapply, Model: "test", Reason: "overrides changed"}}))
+		Expect(readFile("test.yaml")).To(ContainSubstring("context_size: 1024"))
+
+		Expect(os.Remove(filepath.Join(tempdir, "weights.bin"))).To(Succeed())
+		Expect(reconcile(manifest, false)).To(Equal([]ManifestAction{{Action: ManifestReapply, Model: "test", Reason: "weights.bin is missing"}}))
+		Expect(readFile("weights.bin")).To(Equal("weights-v1"))
+	})
+
+	It("upgrades the models which are not pinned", func() {
+		Expect(reconcile("models:\n- id: test\n  pinned: true\n", false)).To(HaveLen(1))
+
+		writeGalleryModel("weights-v2")
+		Expect(reconcile("models:\n- id: test\n  pinned: true\n", false)).To(BeEmpty())
+		Expect(readFile("weights.bin")).To(Equal("weights-v1"))
+
+		Expect(reconcile("models:\n- id: test\n", false)).To(Equal([]ManifestAction{{Action: ManifestUpgrade, Model: "test", Reason: "changed in the gallery"}}))
+		Expect(readFile("weights.bin")).To(Equal("weights-v2"))
+		Expect(reconcile("models:\n- id: test\n", false)).To(BeEmpty())
+	})
+
+	It("deletes only the models installed from a manifest", func() {
+		Expect(InstallModelFromGallery(context.Background(), galleries, "test", tempdir, GalleryModel{Name: "manual"}, noStatus, false)).To(Succeed())
+		Expect(reconcile("models:\n- id: test\n  name: managed\n", false)).To(HaveLen(1))
+		Expect(readFile("managed.yaml")).To(ContainSubstring("name: managed"))
+
+		Expect(reconcile("models: []\n", true)).To(Equal([]ManifestAction{{Action: ManifestDelete, Model: "managed", Reason: "removed from the manifest"}}))
+		Expect(readFile("managed.yaml")).To(ContainSubstring("name: managed"))
+
+		Expect(reconcile("models: []\n", false)).To(HaveLen(1))
+		_, err := os.Stat(filepath.Join(tempdir, "managed.yaml"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		Expect(readFile("manual.yaml")).To(ContainSubstring("name: manual"))
+	})
+
+	It("refuses invalid manifests", func() {
+		_, err := ReadManifest([]byte("models:\n- name: test\n"))
+		Expect(err).To(HaveOccurred())
+		_, err = ReadManifest([]byte("models:\n- id: test\n  name: a\n- id: other\n  name: a\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -1,6 +1,8 @@
 package gallery
 
 import (
+	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"os"
@@ -53,6 +55,22 @@ type Config struct {
 	ConfigFile      string           `yaml:"config_file"`
 	Files           []File           `yaml:"files"`
 	PromptTemplates []PromptTemplate `yaml:"prompt_templates"`
+
+	// Installation details, kept in the gallery file of the installed models
+	// to check for updates and to apply them keeping the user overrides
+	Gallery         string                 `yaml:"gallery,omitempty"`
+	GalleryModel    string                 `yaml:"gallery_model,omitempty"`
+	Overrides       map[string]interface{} `yaml:"overrides,omitempty"`
+	AdditionalFiles []File                 `yaml:"additional_files,omitempty"`
+	// ConfigURL is the model gallery configuration the model was installed from, if not part of a gallery
+	ConfigURL string `yaml:"config_url,omitempty"`
+	// Manifest is true if the model was installed from a models manifest
+	Manifest bool `yaml:"manifest,omitempty"`
+	// ConfigSHA256 is the SHA256 of the model configuration file written at the installation, to detect its local edits
+	ConfigSHA256 string `yaml:"config_sha256,omitempty"`
+	// InstalledFiles are the files downloaded at the installation: the files of the folders, of the glob patterns
+	// and of the sharded models of the URIs of Files
+	InstalledFiles []string `yaml:"installed_files,omitempty"`
 }
 
 type File struct {
@@ -67,8 +85,12 @@ type PromptTemplate struct {
 }
 
 func GetGalleryConfigFromURL(url string, basePath string) (Config, error) {
+	return getGalleryConfigFromURL(url, basePath, "")
+}
+
+func getGalleryConfigFromURL(url, basePath, publicKey string) (Config, error) {
 	var config Config
-	err := downloader.DownloadAndUnmarshal(url, basePath, func(url string, d []byte) error {
+	err := downloadAndUnmarshalSigned(url, basePath, publicKey, func(url string, d []byte) error {
 		return yaml.Unmarshal(d, &config)
 	})
 	if err != nil {
@@ -95,7 +117,7 @@ func ReadConfigFile(filePath string) (*Config, error) {
 	return &config, nil
 }
 
-func InstallModel(basePath, nameOverride string, config *Config, configOverrides map[string]interface{}, downloadStatus func(string, string, string, float64), enforceScan bool) error {
+func InstallModel(ctx context.Context, basePath, nameOverride string, config *Config, configOverrides map[string]interface{}, downloadStatus func(string, string, string, float64), enforceScan bool) error {
 	// Create base path if it doesn't exist
 	err := os.MkdirAll(basePath, 0750)
 	if err != nil {
@@ -106,8 +128,34 @@ func InstallModel(basePath, nameOverride string, config *Config, configOverrides
 		log.Debug().Msgf("Config overrides %+v", configOverrides)
 	}
 
+	// Expand the URIs referring to multiple files (folders, glob patterns and sharded models)
+	files := []downloader.RemoteFile{}
+	for _, file := range config.Files {
+		expanded, err := downloader.ExpandURI(downloader.RemoteFile{URI: file.URI, Filename: file.Filename, SHA256: file.SHA256})
+		if err != nil {
+			return fmt.Errorf("failed to expand %q: %w", file.URI, err)
+		}
+		files = append(files, expanded...)
+	}
+
+	if enforceScan {
+		for _, file := range config.Files {
+			scanResults, err := downloader.HuggingFaceScan(file.URI)
+			if err != nil && !errors.Is(err, downloader.ErrNonHuggingFaceFile) {
+				log.Error().Str("model", config.Name).Strs("clamAV", scanResults.ClamAVInfectedFiles).Strs("pickles", scanResults.DangerousPickles).Msg("Contains unsafe file(s)!")
+				return err
+			}
+		}
+	}
+
+	config.InstalledFiles = []string{}
+	for _, file := range files {
+		config.InstalledFiles = append(config.InstalledFiles, file.Filename)
+	}
+
 	// Download files and verify their SHA
-	for i, file := range config.Files {
+	downloaded := []string{}
+	for i, file := range files {
 		log.Debug().Msgf("Checking %q exists and matches SHA", file.Filename)
 
 		if err := utils.VerifyPath(file.Filename, basePath); err != nil {
@@ -116,16 +164,17 @@ func InstallModel(basePath, nameOverride string, config *Config, configOverrides
 
 		// Create file path
 		filePath := filepath.Join(basePath, file.Filename)
-
-		if enforceScan {
-			scanResults, err := downloader.HuggingFaceScan(file.URI)
-			if err != nil && !errors.Is(err, downloader.ErrNonHuggingFaceFile) {
-				log.Error().Str("model", config.Name).Strs("clamAV", scanResults.ClamAVInfectedFiles).Strs("pickles", scanResults.DangerousPickles).Msg("Contains unsafe file(s)!")
-				return err
-			}
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			downloaded = append(downloaded, filePath)
 		}
 
-		if err := downloader.DownloadFile(file.URI, filePath, file.SHA256, i, len(config.Files), downloadStatus); err != nil {
+		if err := downloader.DownloadFileWithContext(ctx, file.URI, filePath, file.SHA256, i, len(files), downloadStatus); err != nil {
+			if ctx.Err() != nil {
+				// do not leave the files of a cancelled installation behind
+				for _, f := range downloaded {
+					os.Remove(f)
+				}
+			}
 			return err
 		}
 	}
@@ -197,6 +246,7 @@ func InstallModel(basePath, nameOverride string, config *Config, configOverrides
 		if err != nil {
 			return fmt.Errorf("failed to write updated config file: %v", err)
 		}
+		config.ConfigSHA256 = fmt.Sprintf("%x", sha256.Sum256(updatedConfigYAML))
 
 		log.Debug().Msgf("Written config file %s", configFilePath)
 	}
@@ -215,6 +265,8 @@ func InstallModel(basePath, nameOverride string, config *Config, configOverrides
 	//return nil
 }
 
+const galleryFilePrefix = "._gallery_"
+
 func galleryFileName(name string) string {
-	return "._gallery_" + name + ".yaml"
+	return galleryFilePrefix + name + ".yaml"
 }
@@ -1,7 +1,11 @@
 package gallery_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 
@@ -21,7 +25,7 @@ var _ = Describe("Model test", func() {
 			defer os.RemoveAll(tempdir)
 			c, err := ReadConfigFile(filepath.Join(os.Getenv("FIXTURES"), "gallery_simple.yaml"))
 			Expect(err).ToNot(HaveOccurred())
-			err = InstallModel(tempdir, "", c, map[string]interface{}{}, func(string, string, string, float64) {}, true)
+			err = InstallModel(context.Background(), tempdir, "", c, map[string]interface{}{}, func(string, string, string, float64) {}, true)
 			Expect(err).ToNot(HaveOccurred())
 
 			for _, f := range []string{"cerebras", "cerebras-completion.tmpl", "cerebras-chat.tmpl", "cerebras.yaml"} {
@@ -69,7 +73,7 @@ var _ = Describe("Model test", func() {
 			Expect(models[0].URL).To(Equal("https://raw.githubusercontent.com/go-skynet/model-gallery/main/bert-embeddings.yaml"))
 			Expect(models[0].Installed).To(BeFalse())
 
-			err = InstallModelFromGallery(galleries, "test@bert", tempdir, GalleryModel{}, func(s1, s2, s3 string, f float64) {}, true)
+			err = InstallModelFromGallery(context.Background(), galleries, "test@bert", tempdir, GalleryModel{}, func(s1, s2, s3 string, f float64) {}, true)
 			Expect(err).ToNot(HaveOccurred())
 
 			dat, err := os.ReadFile(filepath.Join(tempdir, "bert.yaml"))
@@ -106,7 +110,7 @@ var _ = Describe("Model test", func() {
 			c, err := ReadConfigFile(filepath.Join(os.Getenv("FIXTURES"), "gallery_simple.yaml"))
 			Expect(err).ToNot(HaveOccurred())
 
-			err = InstallModel(tempdir, "foo", c, map[string]interface{}{}, func(string, string, string, float64) {}, true)
+			err = InstallModel(context.Background(), tempdir, "foo", c, map[string]interface{}{}, func(string, string, string, float64) {}, true)
 			Expect(err).ToNot(HaveOccurred())
 
 			for _, f := range []string{"cerebras", "cerebras-completion.tmpl", "cerebras-chat.tmpl", "foo.yaml"} {
@@ -122,7 +126,7 @@ var _ = Describe("Model test", func() {
 			c, err := ReadConfigFile(filepath.Join(os.Getenv("FIXTURES"), "gallery_simple.yaml"))
 			Expect(err).ToNot(HaveOccurred())
 
-			err = InstallModel(tempdir, "foo", c, map[string]interface{}{"backend": "foo"}, func(string, string, string, float64) {}, true)
+			err = InstallModel(context.Background(), tempdir, "foo", c, map[string]interface{}{"backend": "foo"}, func(string, string, string, float64) {}, true)
 			Expect(err).ToNot(HaveOccurred())
 
 			for _, f := range []string{"cerebras", "cerebras-completion.tmpl", "cerebras-chat.tmpl", "foo.yaml"} {
@@ -148,8 +152,58 @@ var _ = Describe("Model test", func() {
 			c, err := ReadConfigFile(filepath.Join(os.Getenv("FIXTURES"), "gallery_simple.yaml"))
 			Expect(err).ToNot(HaveOccurred())
 
-			err = InstallModel(tempdir, "../../../foo", c, map[string]interface{}{}, func(string, string, string, float64) {}, true)
+			err = InstallModel(context.Background(), tempdir, "../../../foo", c, map[string]interface{}{}, func(string, string, string, float64) {}, true)
 			Expect(err).To(HaveOccurred())
 		})
 	})
+
+	Context("Deleting", func() {
+		var tempdir string
+
+		BeforeEach(func() {
+			var err error
+			tempdir, err = os.MkdirTemp("", "test")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tempdir)
+		})
+
+		It("deletes all the shards of a sharded model", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("shard"))
+			}))
+			defer server.Close()
+
+			c := &Config{
+				Name:       "sharded",
+				ConfigFile: "backend: llama-cpp\nparameters:\n  model: sharded/model-00001-of-00003.gguf\n",
+				Files:      []File{{Filename: "sharded/model-00001-of-00003.gguf", URI: server.URL + "/model-00001-of-00003.gguf"}},
+			}
+			Expect(InstallModel(context.Background(), tempdir, "", c, nil, func(string, string, string, float64) {}, false)).To(Succeed())
+			for i := 1; i <= 3; i++ {
+				Expect(filepath.Join(tempdir, "sharded", fmt.Sprintf("model-%05d-of-00003.gguf", i))).To(BeAnExistingFile())
+			}
+
+			Expect(DeleteModelFromSystem(tempdir, "sharded", nil)).To(Succeed())
+			Expect(filepath.Join(tempdir, "sharded")).ToNot(BeAnExistingFile())
+			Expect(filepath.Join(tempdir, "sharded.yaml")).ToNot(BeAnExistingFile())
+		})
+
+		It("deletes the shards of the models installed without the list of their files", func() {
+			for i := 1; i <= 2; i++ {
+				Expect(os.WriteFile(filepath.Join(tempdir, fmt.Sprintf("model-%05d-of-00002.gguf", i)), []byte("shard"), 0600)).To(Succeed())
+			}
+			Expect(os.WriteFile(filepath.Join(tempdir, "sharded.yaml"), []byte("name: sharded\n"), 0600)).To(Succeed())
+			dat, err := yaml.Marshal(Config{Name: "sharded", Files: []File{{Filename: "model-00001-of-00002.gguf"}}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(tempdir, "._gallery_sharded.yaml"), dat, 0600)).To(Succeed())
+
+			Expect(DeleteModelFromSystem(tempdir, "sharded", nil)).To(Succeed())
+			entries, err := os.ReadDir(tempdir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
 })
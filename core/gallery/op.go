@@ -7,6 +7,10 @@ type GalleryOp struct {
 	GalleryModelName string
 	ConfigURL        string
 	Delete           bool
+	Upgrade          bool
+	Rollback         bool
+	// Webhook is notified of the end of the operation, in addition to the ones of the configuration
+	Webhook string
 
 	Req       GalleryModel
 	Galleries []config.Gallery
@@ -22,4 +26,18 @@ type GalleryOpStatus struct {
 	TotalFileSize      string  `json:"file_size"`
 	DownloadedFileSize string  `json:"downloaded_size"`
 	GalleryModelName   string  `json:"gallery_model_name"`
+	Cancelled          bool    `json:"cancelled"`
+}
+
+const (
+	GalleryOpCompleted = "completed"
+	GalleryOpFailed    = "failed"
+	GalleryOpCancelled = "cancelled"
+)
+
+// GalleryOpEvent is posted to the webhooks at the end of an operation
+type GalleryOpEvent struct {
+	ID     string           `json:"id"`
+	Event  string           `json:"event"`
+	Status *GalleryOpStatus `json:"status"`
 }
@@ -0,0 +1,132 @@
+package gallery
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ModelQuery selects, sorts and paginates gallery models
+type ModelQuery struct {
+	// Text matches the name, description and tags of the models. All the words must match.
+	Text      string
+	Tags      []string
+	License   string
+	Backend   string
+	Gallery   string
+	Installed *bool
+	// Sort is the field to sort the models by (name, gallery or license), prefixed with "-" for a descending order
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+// Backend returns the backend of the model if it is set in the gallery index
+func (m GalleryModel) Backend() string {
+	for _, c := range []map[string]interface{}{m.Overrides, m.ConfigFile} {
+		if b, ok := c["backend"].(string); ok && b != "" {
+			return b
+		}
+	}
+	return ""
+}
+
+func (m GalleryModel) matchesText(words []string) bool {
+	text := strings.ToLower(strings.Join(append([]string{m.Name, m.Description}, m.Tags...), " "))
+	for _, w := range words {
+		if !strings.Contains(text, w) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q ModelQuery) matches(m *GalleryModel) bool {
+	if !m.matchesText(strings.Fields(strings.ToLower(q.Text))) {
+		return false
+	}
+	for _, t := range q.Tags {
+		if !slices.ContainsFunc(m.Tags, func(tag string) bool { return strings.EqualFold(tag, t) }) {
+			return false
+		}
+	}
+	switch {
+	case q.License != "" && !strings.EqualFold(m.License, q.License),
+		q.Backend != "" && !strings.EqualFold(m.Backend(), q.Backend),
+		q.Gallery != "" && !strings.EqualFold(m.Gallery.Name, q.Gallery),
+		q.Installed != nil && m.Installed != *q.Installed:
+		return false
+	}
+	return true
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	dat, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	offset, err := strconv.Atoi(string(dat))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return offset, nil
+}
+
+// Query returns a page of the models matching q, the cursor of the next page (empty on the last one),
+// and the total number of models matching q
+func (gm GalleryModels) Query(q ModelQuery) (GalleryModels, string, int, error) {
+	var filtered GalleryModels
+	for _, m := range gm {
+		if q.matches(m) {
+			filtered = append(filtered, m)
+		}
+	}
+
+	field, desc := strings.CutPrefix(q.Sort, "-")
+	var key func(m *GalleryModel) string
+	switch field {
+	case "":
+	case "name":
+		key = func(m *GalleryModel) string { return strings.ToLower(m.Name) }
+	case "gallery":
+		key = func(m *GalleryModel) string { return strings.ToLower(m.Gallery.Name) }
+	case "license":
+		key = func(m *GalleryModel) string { return strings.ToLower(m.License) }
+	default:
+		return nil, "", 0, fmt.Errorf("invalid sort field %q", field)
+	}
+	if key != nil {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			if desc {
+				return key(filtered[i]) > key(filtered[j])
+			}
+			return key(filtered[i]) < key(filtered[j])
+		})
+	}
+
+	offset := 0
+	if q.Cursor != "" {
+		var err error
+		if offset, err = decodeCursor(q.Cursor); err != nil {
+			return nil, "", 0, err
+		}
+	}
+	total := len(filtered)
+	if offset >= total {
+		return GalleryModels{}, "", total, nil
+	}
+	page := filtered[offset:]
+	next := ""
+	if q.Limit > 0 && len(page) > q.Limit {
+		page = page[:q.Limit]
+		next = encodeCursor(offset + q.Limit)
+	}
+	return page, next, total, nil
+}
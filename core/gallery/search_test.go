@@ -0,0 +1,167 @@
+package gallery_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	. "github.com/mudler/LocalAI/core/gallery"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Gallery search", func() {
+	models := GalleryModels{
+		{Name: "phi-2", Description: "Small model by Microsoft", License: "mit", Tags: []string{"llm", "gguf"}, Gallery: config.Gallery{Name: "localai"}, Overrides: map[string]interface{}{"backend": "llama-cpp"}},
+		{Name: "whisper-base", Description: "Speech to text", License: "mit", Tags: []string{"stt"}, Gallery: config.Gallery{Name: "localai"}, Installed: true},
+		{Name: "llama3-8b", Description: "Meta Llama 3", License: "llama3", Tags: []string{"llm", "gguf"}, Gallery: config.Gallery{Name: "other"}, ConfigFile: map[string]interface{}{"backend": "llama-cpp"}},
+	}
+	names := func(models GalleryModels) []string {
+		n := []string{}
+		for _, m := range models {
+			n = append(n, m.Name)
+		}
+		return n
+	}
+	query := func(q ModelQuery) []string {
+		page, _, _, err := models.Query(q)
+		Expect(err).ToNot(HaveOccurred())
+		return names(page)
+	}
+	installed := true
+
+	It("searches the name, description and tags", func() {
+		Expect(query(ModelQuery{Text: "microsoft"})).To(Equal([]string{"phi-2"}))
+		Expect(query(ModelQuery{Text: "LLM meta"})).To(Equal([]string{"llama3-8b"}))
+		Expect(query(ModelQuery{Text: "stt"})).To(Equal([]string{"whisper-base"}))
+	})
+
+	It("filters the models", func() {
+		Expect(query(ModelQuery{Tags: []string{"llm", "GGUF"}})).To(Equal([]string{"phi-2", "llama3-8b"}))
+		Expect(query(ModelQuery{License: "mit"})).To(Equal([]string{"phi-2", "whisper-base"}))
+		Expect(query(ModelQuery{Backend: "llama-cpp"})).To(Equal([]string{"phi-2", "llama3-8b"}))
+		Expect(query(ModelQuery{Gallery: "other"})).To(Equal([]string{"llama3-8b"}))
+		Expect(query(ModelQuery{Installed: &installed})).To(Equal([]string{"whisper-base"}))
+	})
+
+	It("sorts the models", func() {
+		Expect(query(ModelQuery{Sort: "name"})).To(Equal([]string{"llama3-8b", "phi-2", "whisper-base"}))
+		Expect(query(ModelQuery{Sort: "-name"})).To(Equal([]string{"whisper-base", "phi-2", "llama3-8b"}))
+		_, _, _, err := models.Query(ModelQuery{Sort: "size"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("paginates the models", func() {
+		page, next, total, err := models.Query(ModelQuery{Sort: "name", Limit: 2})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(total).To(Equal(3))
+		Expect(names(page)).To(Equal([]string{"llama3-8b", "phi-2"}))
+		Expect(next).ToNot(BeEmpty())
+
+		page, next, _, err = models.Query(ModelQuery{Sort: "name", Limit: 2, Cursor: next})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(names(page)).To(Equal([]string{"whisper-base"}))
+		Expect(next).To(BeEmpty())
+
+		_, _, _, err = models.Query(ModelQuery{Cursor: "invalid!"})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Gallery index cache", func() {
+	var tempdir string
+	var galleries []config.Gallery
+
+	writeIndex := func(names ...string) {
+		index := []GalleryModel{}
+		for _, n := range names {
+			index = append(index, GalleryModel{Name: n})
+		}
+		dat, err := yaml.Marshal(index)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(tempdir, "index.yaml"), dat, 0600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempdir, err = os.MkdirTemp("", "cache")
+		Expect(err).ToNot(HaveOccurred())
+		galleries = []config.Gallery{{Name: "local", URL: "file://" + filepath.Join(tempdir, "index.yaml")}}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempdir)
+	})
+
+	It("keeps the indexes until the refresh interval", func() {
+		writeIndex("a")
+		cache := NewIndexCache(time.Hour)
+		models, err := cache.AvailableGalleryModels(galleries, tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(models).To(HaveLen(1))
+		Expect(models[0].Installed).To(BeFalse())
+
+		writeIndex("a", "b")
+		Expect(os.WriteFile(filepath.Join(tempdir, "a.yaml"), []byte("name: a\n"), 0600)).To(Succeed())
+		models, err = cache.AvailableGalleryModels(galleries, tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(models).To(HaveLen(1))
+		Expect(models[0].Installed).To(BeTrue())
+
+		cache.Invalidate()
+		models, err = cache.AvailableGalleryModels(galleries, tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(models).To(HaveLen(2))
+
+		writeIndex("a", "b", "c")
+		models, err = NewIndexCache(0).AvailableGalleryModels(galleries, tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(models).To(HaveLen(3))
+	})
+
+	It("downloads an index once for the concurrent calls", func() {
+		var requests atomic.Int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			<-release
+			w.Write([]byte("- name: a\n"))
+		}))
+		defer server.Close()
+		galleries := []config.Gallery{{Name: "remote", URL: server.URL + "/index.yaml"}}
+
+		cache := NewIndexCache(time.Hour)
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				models, err := cache.AvailableGalleryModels(galleries, tempdir)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(models).To(HaveLen(1))
+			}()
+		}
+		Eventually(requests.Load).Should(Equal(int32(1)))
+		// the other galleries are not blocked by the download
+		writeIndex("b")
+		models, err := cache.AvailableGalleryModels([]config.Gallery{{Name: "local", URL: "file://" + filepath.Join(tempdir, "index.yaml")}}, tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(models).To(HaveLen(1))
+
+		close(release)
+		wg.Wait()
+		Expect(requests.Load()).To(Equal(int32(1)))
+
+		cache.Invalidate()
+		_, err = cache.AvailableGalleryModels(galleries, tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(requests.Load()).To(Equal(int32(2)))
+	})
+})
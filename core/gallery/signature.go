@@ -0,0 +1,46 @@
+package gallery
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mudler/LocalAI/pkg/downloader"
+	"github.com/mudler/LocalAI/pkg/signature"
+)
+
+var ErrUnsignedGallery = errors.New("the gallery is not signed")
+
+// downloadAndUnmarshalSigned is downloader.DownloadAndUnmarshal, checking the content against the
+// signature published next to it (url + ".minisig") if the gallery has a public key
+func downloadAndUnmarshalSigned(url, basePath, publicKey string, f func(url string, d []byte) error) error {
+	if publicKey == "" {
+		return downloader.DownloadAndUnmarshal(url, basePath, f)
+	}
+	key, err := signature.ParsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	return downloader.DownloadAndUnmarshal(url, basePath, func(url string, d []byte) error {
+		var sig []byte
+		err := downloader.DownloadAndUnmarshal(url+signature.Extension, basePath, func(_ string, s []byte) error {
+			sig = s
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get the signature of %s: %w", url, err)
+		}
+		if err := key.Verify(d, sig); err != nil {
+			return fmt.Errorf("%s: %w", url, err)
+		}
+		return f(url, d)
+	})
+}
+
+// checkGallerySigned refuses the models of the galleries requiring a signature without a public key
+func checkGallerySigned(model *GalleryModel) error {
+	if model.Gallery.RequireSignature && model.Gallery.PublicKey == "" {
+		return fmt.Errorf("refusing to install %q from %q: %w", model.Name, model.Gallery.Name, ErrUnsignedGallery)
+	}
+	return nil
+}
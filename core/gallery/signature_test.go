@@ -0,0 +1,73 @@
+package gallery_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/mudler/LocalAI/core/config"
+	. "github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/pkg/signature"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Signed galleries", func() {
+	var tempdir, galleryDir string
+	var key *signature.PrivateKey
+	var galleries []config.Gallery
+
+	noStatus := func(string, string, string, float64) {}
+	writeSigned := func(name string, v interface{}) {
+		dat, err := yaml.Marshal(v)
+		Expect(err).ToNot(HaveOccurred())
+		p := filepath.Join(galleryDir, name)
+		Expect(os.WriteFile(p, dat, 0600)).To(Succeed())
+		Expect(os.WriteFile(p+signature.Extension, key.Sign(dat, "file:"+name), 0600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempdir, err = os.MkdirTemp("", "signed")
+		Expect(err).ToNot(HaveOccurred())
+		galleryDir = filepath.Join(tempdir, "gallery")
+		Expect(os.MkdirAll(galleryDir, 0750)).To(Succeed())
+
+		var pub *signature.PublicKey
+		pub, key, err = signature.GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+
+		writeSigned("test.yaml", Config{Name: "test", ConfigFile: "backend: llama-cpp\n"})
+		writeSigned("index.yaml", []GalleryModel{{Name: "test", URL: "file://" + filepath.Join(galleryDir, "test.yaml")}})
+		galleries = []config.Gallery{{Name: "signed", URL: "file://" + filepath.Join(galleryDir, "index.yaml"), PublicKey: pub.Encode()}}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempdir)
+	})
+
+	It("installs the models of signed galleries", func() {
+		Expect(InstallModelFromGallery(context.Background(), galleries, "test", tempdir, GalleryModel{}, noStatus, true)).To(Succeed())
+		_, err := os.Stat(filepath.Join(tempdir, "test.yaml"))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("refuses tampered gallery files", func() {
+		Expect(os.WriteFile(filepath.Join(galleryDir, "test.yaml"), []byte("name: test\nconfig_file: \"backend: other\"\n"), 0600)).To(Succeed())
+		err := InstallModelFromGallery(context.Background(), galleries, "test", tempdir, GalleryModel{}, noStatus, false)
+		Expect(err).To(MatchError(signature.ErrInvalidSignature))
+
+		Expect(os.Remove(filepath.Join(galleryDir, "index.yaml"+signature.Extension))).To(Succeed())
+		_, err = AvailableGalleryModels(galleries, tempdir)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("refuses unsigned galleries requiring a signature", func() {
+		galleries[0].PublicKey = ""
+		galleries[0].RequireSignature = true
+		Expect(InstallModelFromGallery(context.Background(), galleries, "test", tempdir, GalleryModel{}, noStatus, false)).To(MatchError(ErrUnsignedGallery))
+		galleries[0].RequireSignature = false
+		Expect(InstallModelFromGallery(context.Background(), galleries, "test", tempdir, GalleryModel{}, noStatus, true)).To(Succeed())
+	})
+})
@@ -0,0 +1,410 @@
+package gallery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/downloader"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrModelConfigEdited is returned when upgrading a model whose configuration file was edited since its installation
+var ErrModelConfigEdited = errors.New("the configuration of the model was edited since its installation")
+
+const (
+	upgradeDir       = ".upgrade"
+	rollbackDir      = ".rollback"
+	rollbackFileName = "rollback.json"
+)
+
+// ModelUpdate describes the differences between an installed model and its version in the gallery
+type ModelUpdate struct {
+	Name             string   `json:"name"`
+	Gallery          string   `json:"gallery"`
+	GalleryModel     string   `json:"gallery_model"`
+	ChangedFiles     []string `json:"changed_files,omitempty"`
+	AddedFiles       []string `json:"added_files,omitempty"`
+	RemovedFiles     []string `json:"removed_files,omitempty"`
+	ChangedTemplates []string `json:"changed_templates,omitempty"`
+	ConfigChanged    bool     `json:"config_changed"`
+}
+
+func (u ModelUpdate) HasChanges() bool {
+	return u.ConfigChanged || len(u.ChangedFiles)+len(u.AddedFiles)+len(u.RemovedFiles)+len(u.ChangedTemplates) > 0
+}
+
+// InstalledGalleryModels returns the names of the models installed from a gallery
+func InstalledGalleryModels(basePath string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(basePath, galleryFileName("*")))
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, m := range matches {
+		names = append(names, strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), galleryFilePrefix), ".yaml"))
+	}
+	return names, nil
+}
+
+// findInstalledGalleryModel returns the gallery model an installed model was installed from
+func findInstalledGalleryModel(models []*GalleryModel, local *Config, name, basePath string) *GalleryModel {
+	if local.Gallery != "" && local.GalleryModel != "" {
+		return FindModel(models, fmt.Sprintf("%s@%s", local.Gallery, local.GalleryModel), basePath)
+	}
+	// models installed before the gallery was recorded
+	for _, n := range []string{name, local.Name} {
+		if m := FindModel(models, n, basePath); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// upstreamConfig returns the current configuration of an installed model in the galleries
+func upstreamConfig(models []*GalleryModel, basePath, name string) (*Config, *Config, map[string]interface{}, error) {
+	local, err := GetLocalModelConfiguration(basePath, name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	model := findInstalledGalleryModel(models, local, name, basePath)
+	if model == nil {
+		return local, nil, nil, nil
+	}
+
+	upstream, _, overrides, err := galleryModelConfig(model, basePath, GalleryModel{
+		Name:            name,
+		Overrides:       local.Overrides,
+		AdditionalFiles: local.AdditionalFiles,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return local, &upstream, overrides, nil
+}
+
+func diffConfigs(name string, local, upstream *Config) ModelUpdate {
+	u := ModelUpdate{Name: name, Gallery: upstream.Gallery, GalleryModel: upstream.GalleryModel}
+
+	localFiles := map[string]File{}
+	for _, f := range local.Files {
+		localFiles[f.Filename] = f
+	}
+	upstreamFiles := map[string]bool{}
+	for _, f := range upstream.Files {
+		upstreamFiles[f.Filename] = true
+		l, exists := localFiles[f.Filename]
+		switch {
+		case !exists:
+			u.AddedFiles = append(u.AddedFiles, f.Filename)
+		case l.SHA256 != f.SHA256 || l.URI != f.URI:
+			u.ChangedFiles = append(u.ChangedFiles, f.Filename)
+		}
+	}
+	for _, f := range local.Files {
+		if !upstreamFiles[f.Filename] {
+			u.RemovedFiles = append(u.RemovedFiles, f.Filename)
+		}
+	}
+
+	localTemplates := map[string]string{}
+	for _, t := range local.PromptTemplates {
+		localTemplates[t.Name] = t.Content
+	}
+	for _, t := range upstream.PromptTemplates {
+		if c, exists := localTemplates[t.Name]; !exists || c != t.Content {
+			u.ChangedTemplates = append(u.ChangedTemplates, t.Name)
+		}
+	}
+
+	u.ConfigChanged = local.ConfigFile != upstream.ConfigFile
+	return u
+}
+
+// CheckModelUpdate compares an installed model with its version in the galleries.
+// It returns nil if the model was not installed from the galleries.
+func CheckModelUpdate(galleries []config.Gallery, basePath, name string) (*ModelUpdate, error) {
+	models, err := AvailableGalleryModels(galleries, basePath)
+	if err != nil {
+		return nil, err
+	}
+	return checkModelUpdate(models, basePath, name)
+}
+
+func checkModelUpdate(models []*GalleryModel, basePath, name string) (*ModelUpdate, error) {
+	local, upstream, _, err := upstreamConfig(models, basePath, name)
+	if err != nil || upstream == nil {
+		return nil, err
+	}
+	u := diffConfigs(name, local, upstream)
+	return &u, nil
+}
+
+// CheckModelUpdates returns the installed models which changed in the galleries
+func CheckModelUpdates(galleries []config.Gallery, basePath string) ([]ModelUpdate, error) {
+	models, err := AvailableGalleryModels(galleries, basePath)
+	if err != nil {
+		return nil, err
+	}
+	names, err := InstalledGalleryModels(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := []ModelUpdate{}
+	for _, name := range names {
+		u, err := checkModelUpdate(models, basePath, name)
+		if err != nil {
+			log.Error().Err(err).Str("model", name).Msg("failed checking for model updates")
+			continue
+		}
+		if u != nil && u.HasChanges() {
+			updates = append(updates, *u)
+		}
+	}
+	return updates, nil
+}
+
+// rollback lists the changes of an upgrade, to revert them
+type rollback struct {
+	// Added are the files which did not exist before the upgrade
+	Added []string `json:"added"`
+	// Replaced are the files moved to the rollback directory
+	Replaced []string `json:"replaced"`
+}
+
+func (r *rollback) revert(basePath, backup string) error {
+	var err error
+	for _, f := range r.Added {
+		if e := os.Remove(filepath.Join(basePath, f)); e != nil && !os.IsNotExist(e) {
+			err = errors.Join(err, e)
+		}
+	}
+	for _, f := range r.Replaced {
+		if e := os.Rename(filepath.Join(backup, f), filepath.Join(basePath, f)); e != nil {
+			err = errors.Join(err, e)
+		}
+	}
+	return err
+}
+
+// checkConfigEdited returns ErrModelConfigEdited if the configuration file of an installed model is not
+// the one written by its installation. The models installed before the digest was recorded are not checked
+func checkConfigEdited(basePath, name string, local *Config) error {
+	if local.ConfigSHA256 == "" {
+		return nil
+	}
+	dat, err := os.ReadFile(filepath.Join(basePath, name+".yaml"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if fmt.Sprintf("%x", sha256.Sum256(dat)) != local.ConfigSHA256 {
+		return fmt.Errorf("%w: install %q again with the changes as overrides to upgrade it", ErrModelConfigEdited, name)
+	}
+	return nil
+}
+
+// move moves the file to dst, creating its parent directory
+func move(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+// UpgradeModel applies the changes of an installed model in the galleries, keeping the user overrides.
+// The new files are downloaded first, and replace the previous ones only if all of them were installed
+// successfully, the files removed from the gallery being removed too. The previous version is kept,
+// and can be restored with RollbackModel. The models whose configuration file was edited since their
+// installation are not upgraded, as the edits would be lost.
+func UpgradeModel(ctx context.Context, galleries []config.Gallery, basePath, name string, downloadStatus func(string, string, string, float64), enforceScan bool) error {
+	name = strings.ReplaceAll(name, string(os.PathSeparator), "__")
+	if err := utils.VerifyPath(name, basePath); err != nil {
+		return err
+	}
+
+	models, err := AvailableGalleryModels(galleries, basePath)
+	if err != nil {
+		return err
+	}
+	local, upstream, overrides, err := upstreamConfig(models, basePath, name)
+	if err != nil {
+		return err
+	}
+	if upstream == nil {
+		return fmt.Errorf("model %q was not installed from the galleries", name)
+	}
+	upstream.Manifest = local.Manifest
+	if err := checkConfigEdited(basePath, name, local); err != nil {
+		return err
+	}
+	if err := checkGallerySigned(findInstalledGalleryModel(models, local, name, basePath)); err != nil {
+		return err
+	}
+
+	staging := filepath.Join(basePath, upgradeDir, name)
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(staging, 0750); err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	// link the files which did not change, so they are not downloaded again
+	unchanged := map[string]bool{}
+	localFiles := map[string]File{}
+	for _, f := range local.Files {
+		localFiles[f.Filename] = f
+	}
+	for _, f := range upstream.Files {
+		if l, exists := localFiles[f.Filename]; !exists || l.SHA256 != f.SHA256 || l.URI != f.URI {
+			continue
+		}
+		files, err := downloader.ExpandURI(downloader.RemoteFile{URI: f.URI, Filename: f.Filename, SHA256: f.SHA256})
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			src := filepath.Join(basePath, file.Filename)
+			if _, err := os.Stat(src); err != nil {
+				continue
+			}
+			dst := filepath.Join(staging, file.Filename)
+			if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+				return err
+			}
+			if err := os.Link(src, dst); err != nil {
+				if err := os.Symlink(src, dst); err != nil {
+					return err
+				}
+			}
+			unchanged[file.Filename] = true
+		}
+	}
+
+	if err := InstallModel(ctx, staging, name, upstream, overrides, downloadStatus, enforceScan); err != nil {
+		return fmt.Errorf("failed installing the new version of %q: %w", name, err)
+	}
+
+	newFiles := []string{}
+	err = filepath.WalkDir(staging, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(staging, path)
+		if err != nil {
+			return err
+		}
+		if !unchanged[rel] {
+			newFiles = append(newFiles, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the files of the previous version which are not used anymore, with all their shards
+	removedFiles := []string{}
+	for _, removed := range diffConfigs(name, local, upstream).RemovedFiles {
+		for _, f := range downloader.ShardNames(removed) {
+			if utils.VerifyPath(f, basePath) != nil {
+				log.Warn().Str("model", name).Msgf("not removing %q, outside of the models path", f)
+				continue
+			}
+			if _, err := os.Lstat(filepath.Join(basePath, f)); err == nil {
+				removedFiles = append(removedFiles, f)
+			}
+		}
+	}
+
+	backup := filepath.Join(basePath, rollbackDir, name)
+	if err := os.RemoveAll(backup); err != nil {
+		return err
+	}
+
+	r := &rollback{}
+	swap := func() error {
+		for _, f := range removedFiles {
+			if err := move(filepath.Join(basePath, f), filepath.Join(backup, f)); err != nil {
+				return err
+			}
+			r.Replaced = append(r.Replaced, f)
+		}
+		for _, f := range newFiles {
+			dst := filepath.Join(basePath, f)
+			if _, err := os.Lstat(dst); err == nil {
+				if err := move(dst, filepath.Join(backup, f)); err != nil {
+					return err
+				}
+				r.Replaced = append(r.Replaced, f)
+			} else {
+				r.Added = append(r.Added, f)
+			}
+			if err := move(filepath.Join(staging, f), dst); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := swap(); err != nil {
+		if e := r.revert(basePath, backup); e != nil {
+			log.Error().Err(e).Str("model", name).Msg("failed restoring the previous version of the model")
+		}
+		return fmt.Errorf("failed upgrading %q: %w", name, err)
+	}
+
+	dat, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(backup, 0750); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(backup, rollbackFileName), dat, 0600); err != nil {
+		return err
+	}
+
+	log.Info().Str("model", name).Int("files", len(newFiles)).Msg("model upgraded")
+	return nil
+}
+
+// RollbackModel restores the version of the model preceding its last upgrade
+func RollbackModel(basePath, name string) error {
+	name = strings.ReplaceAll(name, string(os.PathSeparator), "__")
+	if err := utils.VerifyPath(name, basePath); err != nil {
+		return err
+	}
+
+	backup := filepath.Join(basePath, rollbackDir, name)
+	dat, err := os.ReadFile(filepath.Join(backup, rollbackFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no previous version of %q to restore", name)
+		}
+		return err
+	}
+	r := &rollback{}
+	if err := json.Unmarshal(dat, r); err != nil {
+		return err
+	}
+
+	if err := r.revert(basePath, backup); err != nil {
+		return fmt.Errorf("failed restoring the previous version of %q: %w", name, err)
+	}
+
+	log.Info().Str("model", name).Msg("model rolled back")
+	return os.RemoveAll(backup)
+}
@@ -0,0 +1,164 @@
+package gallery_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/mudler/LocalAI/core/config"
+	. "github.com/mudler/LocalAI/core/gallery"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Model upgrades", func() {
+	var tempdir, galleryDir string
+	var server *httptest.Server
+	var tokenizerDownloads atomic.Int32
+	var galleries []config.Gallery
+
+	noStatus := func(string, string, string, float64) {}
+	shaOf := func(s string) string {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+	}
+
+	writeGalleryModel := func(weights, template string, extraFiles ...File) {
+		c := Config{
+			Name:       "test",
+			ConfigFile: "backend: llama-cpp\nparameters:\n  model: weights.bin\n",
+			Files: append([]File{
+				{Filename: "weights.bin", SHA256: shaOf(weights), URI: server.URL + "/" + weights},
+				{Filename: "tokenizer.json", URI: server.URL + "/tokenizer"},
+			}, extraFiles...),
+			PromptTemplates: []PromptTemplate{{Name: "chat", Content: template}},
+		}
+		dat, err := yaml.Marshal(c)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(galleryDir, "test.yaml"), dat, 0600)).To(Succeed())
+	}
+
+	readFile := func(name string) string {
+		dat, err := os.ReadFile(filepath.Join(tempdir, name))
+		Expect(err).ToNot(HaveOccurred())
+		return string(dat)
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempdir, err = os.MkdirTemp("", "upgrade")
+		Expect(err).ToNot(HaveOccurred())
+		galleryDir = filepath.Join(tempdir, "gallery")
+		Expect(os.MkdirAll(galleryDir, 0750)).To(Succeed())
+
+		tokenizerDownloads.Store(0)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/tokenizer" {
+				tokenizerDownloads.Add(1)
+				w.Write([]byte("{}"))
+				return
+			}
+			w.Write([]byte(r.URL.Path[1:]))
+		}))
+
+		index := []GalleryModel{{Name: "test", URL: "file://" + filepath.Join(galleryDir, "test.yaml")}}
+		dat, err := yaml.Marshal(index)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(galleryDir, "index.yaml"), dat, 0600)).To(Succeed())
+		galleries = []config.Gallery{{Name: "local", URL: "file://" + filepath.Join(galleryDir, "index.yaml")}}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(tempdir)
+	})
+
+	It("upgrades a model keeping the overrides, and rolls it back", func() {
+		writeGalleryModel("weights-v1", "v1")
+		err := InstallModelFromGallery(context.Background(), galleries, "test", tempdir, GalleryModel{Overrides: map[string]interface{}{"context_size": 512}}, noStatus, false)
+		Expect(err).ToNot(HaveOccurred())
+
+		updates, err := CheckModelUpdates(galleries, tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updates).To(BeEmpty())
+
+		writeGalleryModel("weights-v2", "v2")
+		updates, err = CheckModelUpdates(galleries, tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updates).To(HaveLen(1))
+		Expect(updates[0].Name).To(Equal("test"))
+		Expect(updates[0].ChangedFiles).To(Equal([]string{"weights.bin"}))
+		Expect(updates[0].ChangedTemplates).To(Equal([]string{"chat"}))
+		Expect(updates[0].ConfigChanged).To(BeFalse())
+
+		Expect(UpgradeModel(context.Background(), galleries, tempdir, "test", noStatus, false)).To(Succeed())
+		Expect(tokenizerDownloads.Load()).To(Equal(int32(1)))
+		Expect(readFile("weights.bin")).To(Equal("weights-v2"))
+		Expect(readFile("chat.tmpl")).To(Equal("v2"))
+		Expect(readFile("test.yaml")).To(ContainSubstring("context_size: 512"))
+
+		updates, err = CheckModelUpdates(galleries, tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updates).To(BeEmpty())
+
+		Expect(RollbackModel(tempdir, "test")).To(Succeed())
+		Expect(readFile("weights.bin")).To(Equal("weights-v1"))
+		Expect(readFile("chat.tmpl")).To(Equal("v1"))
+		Expect(readFile("tokenizer.json")).To(Equal("{}"))
+		Expect(RollbackModel(tempdir, "test")).ToNot(Succeed())
+	})
+
+	It("keeps the installed version if the upgrade fails", func() {
+		writeGalleryModel("weights-v1", "v1")
+		Expect(InstallModelFromGallery(context.Background(), galleries, "test", tempdir, GalleryModel{}, noStatus, false)).To(Succeed())
+
+		writeGalleryModel("weights-v2", "v2")
+		c, err := ReadConfigFile(filepath.Join(galleryDir, "test.yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		c.Files[0].SHA256 = shaOf("something else")
+		dat, err := yaml.Marshal(c)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(galleryDir, "test.yaml"), dat, 0600)).To(Succeed())
+
+		Expect(UpgradeModel(context.Background(), galleries, tempdir, "test", noStatus, false)).ToNot(Succeed())
+		Expect(readFile("weights.bin")).To(Equal("weights-v1"))
+		Expect(readFile("chat.tmpl")).To(Equal("v1"))
+	})
+
+	It("removes the files removed from the gallery", func() {
+		writeGalleryModel("weights-v1", "v1", File{Filename: "vocab.txt", URI: server.URL + "/vocab"})
+		Expect(InstallModelFromGallery(context.Background(), galleries, "test", tempdir, GalleryModel{}, noStatus, false)).To(Succeed())
+		Expect(readFile("vocab.txt")).To(Equal("vocab"))
+
+		writeGalleryModel("weights-v2", "v2")
+		updates, err := CheckModelUpdates(galleries, tempdir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updates).To(HaveLen(1))
+		Expect(updates[0].RemovedFiles).To(Equal([]string{"vocab.txt"}))
+
+		Expect(UpgradeModel(context.Background(), galleries, tempdir, "test", noStatus, false)).To(Succeed())
+		Expect(filepath.Join(tempdir, "vocab.txt")).ToNot(BeAnExistingFile())
+
+		Expect(RollbackModel(tempdir, "test")).To(Succeed())
+		Expect(readFile("vocab.txt")).To(Equal("vocab"))
+	})
+
+	It("refuses to upgrade a model whose configuration was edited", func() {
+		writeGalleryModel("weights-v1", "v1")
+		Expect(InstallModelFromGallery(context.Background(), galleries, "test", tempdir, GalleryModel{}, noStatus, false)).To(Succeed())
+		edited := readFile("test.yaml") + "context_size: 4096\n"
+		Expect(os.WriteFile(filepath.Join(tempdir, "test.yaml"), []byte(edited), 0600)).To(Succeed())
+
+		writeGalleryModel("weights-v2", "v2")
+		err := UpgradeModel(context.Background(), galleries, tempdir, "test", noStatus, false)
+		Expect(errors.Is(err, ErrModelConfigEdited)).To(BeTrue())
+		Expect(readFile("test.yaml")).To(Equal(edited))
+		Expect(readFile("weights.bin")).To(Equal("weights-v1"))
+	})
+})
@@ -3,6 +3,7 @@ package http
 import (
 	"embed"
 	"errors"
+	"io"
 	"net/http"
 	"strings"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/core/startup"
 	"github.com/mudler/LocalAI/pkg/model"
 
 	"github.com/gofiber/contrib/fiberzerolog"
@@ -65,11 +67,53 @@ var embedDirStatic embed.FS
 // @in header
 // @name Authorization
 
+// limitRequestBody enforces the body limit on the requests, except on the routes uploading large files:
+// the bodies are streamed so that these routes read them from the connection, without the limit
+func limitRequestBody(limit int, streamedRoutes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req := c.Request()
+		if !req.IsBodyStream() || req.Header.ContentLength() == 0 || matchesRoute(c, streamedRoutes) {
+			return c.Next()
+		}
+		if req.Header.ContentLength() > limit {
+			return fiber.ErrRequestEntityTooLarge
+		}
+		body, err := io.ReadAll(io.LimitReader(req.BodyStream(), int64(limit)+1))
+		if err != nil {
+			return err
+		}
+		if len(body) > limit {
+			return fiber.ErrRequestEntityTooLarge
+		}
+		req.SetBody(body)
+		return c.Next()
+	}
+}
+
+// matchesRoute returns true if the path of the request is one of the routes, compared as the router does:
+// ignoring the case and the trailing slash unless the routing is case sensitive or strict
+func matchesRoute(c *fiber.Ctx, routes []string) bool {
+	cfg := c.App().Config()
+	path := c.Path()
+	if !cfg.StrictRouting && len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	for _, r := range routes {
+		if path == r || (!cfg.CaseSensitive && strings.EqualFold(path, r)) {
+			return true
+		}
+	}
+	return false
+}
+
 func App(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) (*fiber.App, error) {
 
 	fiberCfg := fiber.Config{
 		Views:     renderEngine(),
 		BodyLimit: appConfig.UploadLimitMB * 1024 * 1024, // this is the default limit of 4MB
+		// The bodies are streamed for the bundles of models, and read with the limit by limitRequestBody otherwise
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 		// We disable the Fiber startup message as it does not conform to structured logging.
 		// We register a startup log line with connection information in the OnListen hook to keep things user friendly though
 		DisableStartupMessage: true,
@@ -86,6 +130,10 @@ func App(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *confi
 			var e *fiber.Error
 			if errors.As(err, &e) {
 				code = e.Code
+			} else if errors.Is(err, model.ErrCapabilityNotSupported) {
+				code = fiber.StatusBadRequest
+			} else if errors.Is(err, model.ErrBackendUnhealthy) {
+				code = fiber.StatusServiceUnavailable
 			}
 
 			// Send custom error page
@@ -119,6 +167,8 @@ func App(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *confi
 		Logger: &logger,
 	}))
 
+	app.Use(limitRequestBody(fiberCfg.BodyLimit, "/models/import"))
+
 	// Default middleware config
 
 	if !appConfig.Debug {
@@ -192,11 +242,18 @@ func App(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *confi
 	galleryService := services.NewGalleryService(appConfig)
 	galleryService.Start(appConfig.Context, cl)
 
+	// the API, the UI and the models watcher share the service, which serializes the writes of the configurations
+	modelConfigService := services.NewModelConfigService(cl, ml, appConfig)
+	startup.StartModelsWatcher(appConfig, modelConfigService)
+
+	conversationService := services.NewConversationService(appConfig)
+	conversationService.Start(appConfig.Context)
+
 	routes.RegisterElevenLabsRoutes(app, cl, ml, appConfig, auth)
-	routes.RegisterLocalAIRoutes(app, cl, ml, appConfig, galleryService, auth)
-	routes.RegisterOpenAIRoutes(app, cl, ml, appConfig, auth)
+	routes.RegisterLocalAIRoutes(app, cl, ml, appConfig, galleryService, modelConfigService, conversationService, auth)
+	routes.RegisterOpenAIRoutes(app, cl, ml, appConfig, conversationService, auth)
 	if !appConfig.DisableWebUI {
-		routes.RegisterUIRoutes(app, cl, ml, appConfig, galleryService, auth)
+		routes.RegisterUIRoutes(app, cl, ml, appConfig, galleryService, modelConfigService, auth)
 	}
 	routes.RegisterJINARoutes(app, cl, ml, appConfig, auth)
 
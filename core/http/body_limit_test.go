@@ -0,0 +1,83 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Request body limit", func() {
+	const limit = 1024
+
+	var app *fiber.App
+	var url string
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	BeforeEach(func() {
+		app = fiber.New(fiber.Config{
+			BodyLimit:                    limit,
+			StreamRequestBody:            true,
+			DisablePreParseMultipartForm: true,
+		})
+		app.Use(limitRequestBody(limit, "/models/import"))
+		app.Post("/v1/chat/completions", func(c *fiber.Ctx) error {
+			var body map[string]interface{}
+			if err := c.BodyParser(&body); err != nil {
+				return err
+			}
+			return c.JSON(body)
+		})
+		app.Post("/models/import", func(c *fiber.Ctx) error {
+			n, err := io.Copy(io.Discard, c.Context().RequestBodyStream())
+			if err != nil {
+				return err
+			}
+			return c.JSON(n)
+		})
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		url = "http://" + ln.Addr().String()
+		go app.Listener(ln)
+	})
+
+	AfterEach(func() {
+		Expect(app.Shutdown()).To(Succeed())
+	})
+
+	post := func(path string, body []byte) *http.Response {
+		resp, err := client.Post(url+path, "application/json", bytes.NewReader(body))
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(resp.Body.Close)
+		return resp
+	}
+
+	It("enforces the limit on the JSON endpoints", func() {
+		resp := post("/v1/chat/completions", []byte(`{"model": "foo"}`))
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		dat, err := io.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(dat)).To(Equal(`{"model":"foo"}`))
+
+		large := []byte(`{"model": "` + strings.Repeat("x", 4*limit) + `"}`)
+		Expect(post("/v1/chat/completions", large).StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+		Expect(post("/v1/chat/completions/", large).StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+	})
+
+	It("streams the bodies of the import route, as matched by the router", func() {
+		large := bytes.Repeat([]byte("x"), 4*limit)
+		for _, path := range []string{"/models/import", "/models/import/", "/Models/Import"} {
+			resp := post(path, large)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK), path)
+			dat, err := io.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(dat)).To(Equal("4096"), path)
+		}
+	})
+})
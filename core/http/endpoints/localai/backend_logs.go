@@ -0,0 +1,81 @@
+package localai
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/valyala/fasthttp"
+)
+
+// BackendLogsEndpoint returns the last lines of the output of the backend of a model
+// @Summary Backend logs endpoint
+// @Param model path string true "Model name"
+// @Param lines query int false "Number of lines to return (all the lines kept in memory by default)"
+// @Param follow query bool false "Keep streaming the new lines as server-sent events"
+// @Success 200 {object} schema.BackendLogsResponse "Response"
+// @Router /backend/logs/{model} [get]
+func BackendLogsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		modelName := c.Params("model")
+		// the logs are kept by model file, but the model can be referenced by name
+		if cfg, exists := cl.GetBackendConfig(modelName); exists && cfg.Model != "" {
+			modelName = cfg.Model
+		}
+
+		lines := ml.BackendLogs().Lines(modelName, c.QueryInt("lines", 0))
+
+		if !c.QueryBool("follow", false) {
+			return c.JSON(schema.BackendLogsResponse{Model: c.Params("model"), Lines: lines})
+		}
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("Transfer-Encoding", "chunked")
+
+		newLines, unsubscribe := ml.BackendLogs().Subscribe(modelName)
+		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			defer unsubscribe()
+
+			send := func(l schema.BackendLogLine) error {
+				data, err := json.Marshal(l)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "data: %s\n\n", data)
+				return w.Flush()
+			}
+
+			for _, l := range lines {
+				if err := send(l); err != nil {
+					return
+				}
+			}
+
+			// the keepalive comments allow to detect when the client goes away
+			keepalive := time.NewTicker(15 * time.Second)
+			defer keepalive.Stop()
+			for {
+				select {
+				case l := <-newLines:
+					if err := send(l); err != nil {
+						return
+					}
+				case <-keepalive.C:
+					fmt.Fprint(w, ": keepalive\n\n")
+					if err := w.Flush(); err != nil {
+						return
+					}
+				}
+			}
+		}))
+
+		return nil
+	}
+}
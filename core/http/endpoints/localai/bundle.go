@@ -0,0 +1,117 @@
+package localai
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// receiveBundle streams the bundle uploaded in the "file" field of the multipart body to a temporary file
+// of the models path, without holding it in memory. It returns the file and the "force" field
+func receiveBundle(c *fiber.Ctx, appConfig *config.ApplicationConfig) (*os.File, bool, error) {
+	mediaType, params, err := mime.ParseMediaType(string(c.Request().Header.ContentType()))
+	if err != nil || mediaType != fiber.MIMEMultipartForm {
+		return nil, false, fiber.NewError(fiber.StatusBadRequest, "the bundle must be uploaded as multipart/form-data")
+	}
+	var body io.Reader = bytes.NewReader(c.Body())
+	if c.Request().IsBodyStream() {
+		body = c.Request().BodyStream()
+	}
+
+	limit := int64(appConfig.BundleUploadLimitMB) * 1024 * 1024
+	var bundle *os.File
+	force := false
+	fail := func(err error) (*os.File, bool, error) {
+		if bundle != nil {
+			bundle.Close()
+			os.Remove(bundle.Name())
+		}
+		return nil, false, err
+	}
+
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(fiber.NewError(fiber.StatusBadRequest, err.Error()))
+		}
+
+		switch part.FormName() {
+		case "force":
+			value, err := io.ReadAll(io.LimitReader(part, 16))
+			if err != nil {
+				return fail(err)
+			}
+			force = string(value) == "true"
+		case "file":
+			if bundle != nil {
+				return fail(fiber.NewError(fiber.StatusBadRequest, "only one bundle can be imported at once"))
+			}
+			if bundle, err = os.CreateTemp(appConfig.ModelPath, ".bundle-*.tar"); err != nil {
+				return fail(err)
+			}
+			n, err := io.Copy(bundle, io.LimitReader(part, limit+1))
+			if err != nil {
+				return fail(err)
+			}
+			if n > limit {
+				return fail(fiber.NewError(fiber.StatusRequestEntityTooLarge, fmt.Sprintf("the bundle is larger than %d MB", appConfig.BundleUploadLimitMB)))
+			}
+		}
+		part.Close()
+	}
+
+	if bundle == nil {
+		return fail(fiber.NewError(fiber.StatusBadRequest, "no bundle uploaded in the file field"))
+	}
+	if _, err := bundle.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	return bundle, force, nil
+}
+
+// ImportModelsEndpoint installs the models of an uploaded bundle
+// @Summary Verify and install the models of a bundle written by `local-ai models export`.
+// @Param file formData file true "Bundle"
+// @Param force formData bool false "Replace the existing files which differ from the ones of the bundle"
+// @Success 200 {object} schema.ModelsImportResponse "Response"
+// @Router /models/import [post]
+func ImportModelsEndpoint(cl *config.BackendConfigLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		bundle, force, err := receiveBundle(c, appConfig)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			bundle.Close()
+			os.Remove(bundle.Name())
+		}()
+
+		names, err := gallery.ImportModels(appConfig.ModelPath, bundle, force, func(fileName, current, total string, percentage float64) {
+			log.Debug().Str("file", fileName).Msgf("verifying: %.2f%%", percentage)
+		})
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		if err := cl.LoadBackendConfigsFromPath(appConfig.ModelPath); err != nil {
+			return err
+		}
+		if err := cl.Preload(appConfig.ModelPath); err != nil {
+			return err
+		}
+		return c.JSON(schema.ModelsImportResponse{Models: names})
+	}
+}
@@ -0,0 +1,85 @@
+package localai
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+)
+
+// ListConversationsEndpoint lists the conversations stored on the server
+// @Summary Lists the conversations stored on the server, without their messages, the most recent first
+// @Success 200 {object} schema.ConversationListResponse "Response"
+// @Router /v1/conversations [get]
+func ListConversationsEndpoint(cs *services.ConversationService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		conversations, err := cs.List()
+		if err != nil {
+			return err
+		}
+		return c.JSON(schema.ConversationListResponse{Object: "list", Data: conversations})
+	}
+}
+
+// GetConversationEndpoint returns a conversation with its messages
+// @Summary Returns a conversation stored on the server with its messages
+// @Param id path string true "Conversation id"
+// @Success 200 {object} schema.Conversation "Response"
+// @Router /v1/conversations/{id} [get]
+func GetConversationEndpoint(cs *services.ConversationService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		conversation, err := cs.Get(c.Params("id"))
+		if err != nil {
+			return conversationError(err)
+		}
+		return c.JSON(conversation)
+	}
+}
+
+// ForkConversationEndpoint copies a conversation into a new one
+// @Summary Copies the first messages of a conversation, all of them by default, into a new conversation with its own prompt cache
+// @Param id path string true "Conversation id"
+// @Param request body schema.ConversationForkRequest false "query params"
+// @Success 201 {object} schema.Conversation "Response"
+// @Router /v1/conversations/{id}/fork [post]
+func ForkConversationEndpoint(cs *services.ConversationService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		req := schema.ConversationForkRequest{}
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+		}
+		fork, err := cs.Fork(c.Params("id"), req.Messages)
+		if err != nil {
+			return conversationError(err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(fork)
+	}
+}
+
+// DeleteConversationEndpoint removes a conversation
+// @Summary Removes a conversation stored on the server and its prompt cache
+// @Param id path string true "Conversation id"
+// @Success 200 {object} schema.ConversationDeleteResponse "Response"
+// @Router /v1/conversations/{id} [delete]
+func DeleteConversationEndpoint(cs *services.ConversationService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if err := cs.Delete(id); err != nil {
+			return conversationError(err)
+		}
+		return c.JSON(schema.ConversationDeleteResponse{ID: id, Object: "conversation.deleted", Deleted: true})
+	}
+}
+
+func conversationError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrConversationNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidConversationID), errors.Is(err, services.ErrInvalidConversationFork):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+package localai
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/services"
+)
+
+// DoctorEndpoint checks the configurations of the models
+// @Summary Checks the configurations, templates, files and backends of the models, and reports their problems
+// @Param models query string false "Comma-separated names of the models to check (all the models by default)"
+// @Param checksums query bool false "Verify the SHA256 of the files of the models (slow with large models)"
+// @Success 200 {object} services.DoctorReport "Response"
+// @Router /system/doctor [get]
+func DoctorEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		models := []string{}
+		if m := c.Query("models"); m != "" {
+			models = strings.Split(m, ",")
+		}
+		report, err := services.Doctor(appConfig, c.QueryBool("checksums"), models...)
+		if err != nil {
+			return err
+		}
+		return c.JSON(report)
+	}
+}
@@ -1,9 +1,14 @@
 package localai
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -12,6 +17,7 @@ import (
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/core/services"
 	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
 )
 
 type ModelGalleryEndpointService struct {
@@ -23,6 +29,8 @@ type ModelGalleryEndpointService struct {
 type GalleryModel struct {
 	ID        string `json:"id"`
 	ConfigURL string `json:"config_url"`
+	// Webhook is notified with a POST request when the job completes, fails or is cancelled
+	Webhook string `json:"webhook"`
 	gallery.GalleryModel
 }
 
@@ -36,15 +44,90 @@ func CreateModelGalleryEndpointService(galleries []config.Gallery, modelPath str
 
 // GetOpStatusEndpoint returns the job status
 // @Summary Returns the job status
+// @Param uuid path string true "Job ID"
+// @Param follow query bool false "Keep streaming the status updates as server-sent events, until the job is processed"
 // @Success 200 {object} gallery.GalleryOpStatus "Response"
 // @Router /models/jobs/{uuid} [get]
 func (mgs *ModelGalleryEndpointService) GetOpStatusEndpoint() func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
-		status := mgs.galleryApplier.GetStatus(c.Params("uuid"))
+		id := c.Params("uuid")
+		if !c.QueryBool("follow", false) {
+			status := mgs.galleryApplier.GetStatus(id)
+			if status == nil {
+				return fmt.Errorf("could not find any status for ID")
+			}
+			return c.JSON(status)
+		}
+
+		// subscribe before reading the status to not miss any update
+		updates, unsubscribe := mgs.galleryApplier.Subscribe(id)
+		status := mgs.galleryApplier.GetStatus(id)
 		if status == nil {
-			return fmt.Errorf("could not find any status for ID")
+			unsubscribe()
+			return fiber.NewError(fiber.StatusNotFound, "could not find any status for ID")
+		}
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("Transfer-Encoding", "chunked")
+
+		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			defer unsubscribe()
+
+			send := func(s *gallery.GalleryOpStatus) error {
+				data, err := json.Marshal(s)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "data: %s\n\n", data)
+				return w.Flush()
+			}
+
+			if err := send(status); err != nil || status.Processed {
+				return
+			}
+
+			// the keepalive comments allow to detect when the client goes away
+			keepalive := time.NewTicker(15 * time.Second)
+			defer keepalive.Stop()
+			for {
+				select {
+				case s := <-updates:
+					if err := send(s); err != nil || s.Processed {
+						return
+					}
+				case <-keepalive.C:
+					fmt.Fprint(w, ": keepalive\n\n")
+					if err := w.Flush(); err != nil {
+						return
+					}
+				}
+			}
+		}))
+
+		return nil
+	}
+}
+
+// CancelOpEndpoint cancels a job in progress
+// @Summary Cancel a job in progress, removing the files partially downloaded
+// @Param uuid path string true "Job ID"
+// @Success 200 {object} gallery.GalleryOpStatus "Response"
+// @Router /models/jobs/{uuid}/cancel [post]
+func (mgs *ModelGalleryEndpointService) CancelOpEndpoint() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("uuid")
+		if err := mgs.galleryApplier.CancelOp(id); err != nil {
+			switch {
+			case errors.Is(err, services.ErrGalleryOpNotFound):
+				return fiber.NewError(fiber.StatusNotFound, err.Error())
+			case errors.Is(err, services.ErrGalleryOpProcessed):
+				return fiber.NewError(fiber.StatusConflict, err.Error())
+			}
+			return err
 		}
-		return c.JSON(status)
+		return c.JSON(mgs.galleryApplier.GetStatus(id))
 	}
 }
 
@@ -70,18 +153,24 @@ func (mgs *ModelGalleryEndpointService) ApplyModelGalleryEndpoint() func(c *fibe
 		if err := c.BodyParser(input); err != nil {
 			return err
 		}
+		if input.Webhook != "" {
+			if err := services.ValidateWebhook(input.Webhook); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+		}
 
 		uuid, err := uuid.NewUUID()
 		if err != nil {
 			return err
 		}
-		mgs.galleryApplier.C <- gallery.GalleryOp{
+		mgs.galleryApplier.Enqueue(gallery.GalleryOp{
 			Req:              input.GalleryModel,
 			Id:               uuid.String(),
 			GalleryModelName: input.ID,
 			Galleries:        mgs.galleries,
 			ConfigURL:        input.ConfigURL,
-		}
+			Webhook:          input.Webhook,
+		})
 		return c.JSON(schema.GalleryResponse{ID: uuid.String(), StatusURL: c.BaseURL() + "/models/jobs/" + uuid.String()})
 	}
 }
@@ -95,37 +184,139 @@ func (mgs *ModelGalleryEndpointService) DeleteModelGalleryEndpoint() func(c *fib
 	return func(c *fiber.Ctx) error {
 		modelName := c.Params("name")
 
-		mgs.galleryApplier.C <- gallery.GalleryOp{
+		uuid, err := uuid.NewUUID()
+		if err != nil {
+			return err
+		}
+
+		mgs.galleryApplier.Enqueue(gallery.GalleryOp{
+			Id:               uuid.String(),
 			Delete:           true,
 			GalleryModelName: modelName,
+		})
+
+		return c.JSON(schema.GalleryResponse{ID: uuid.String(), StatusURL: c.BaseURL() + "/models/jobs/" + uuid.String()})
+	}
+}
+
+// ListModelUpdatesEndpoint lists the installed models which changed in the galleries
+// @Summary List the installed models with updates available in the galleries.
+// @Success 200 {object} []gallery.ModelUpdate "Response"
+// @Router /models/updates [get]
+func (mgs *ModelGalleryEndpointService) ListModelUpdatesEndpoint() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		updates, err := gallery.CheckModelUpdates(mgs.galleries, mgs.modelPath)
+		if err != nil {
+			return err
+		}
+		return c.JSON(updates)
+	}
+}
+
+// UpgradeModelGalleryEndpoint applies the changes of an installed model in the galleries
+// @Summary Upgrade an installed model to its version in the galleries.
+// @Param name	path string	true	"Model name"
+// @Success 200 {object} schema.GalleryResponse "Response"
+// @Router /models/upgrade/{name} [post]
+func (mgs *ModelGalleryEndpointService) UpgradeModelGalleryEndpoint() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		uuid, err := uuid.NewUUID()
+		if err != nil {
+			return err
 		}
 
+		mgs.galleryApplier.Enqueue(gallery.GalleryOp{
+			Id:               uuid.String(),
+			Upgrade:          true,
+			GalleryModelName: c.Params("name"),
+			Galleries:        mgs.galleries,
+		})
+
+		return c.JSON(schema.GalleryResponse{ID: uuid.String(), StatusURL: c.BaseURL() + "/models/jobs/" + uuid.String()})
+	}
+}
+
+// RollbackModelGalleryEndpoint restores the version of a model preceding its last upgrade
+// @Summary Restore the previous version of an upgraded model.
+// @Param name	path string	true	"Model name"
+// @Success 200 {object} schema.GalleryResponse "Response"
+// @Router /models/rollback/{name} [post]
+func (mgs *ModelGalleryEndpointService) RollbackModelGalleryEndpoint() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
 		uuid, err := uuid.NewUUID()
 		if err != nil {
 			return err
 		}
 
+		mgs.galleryApplier.Enqueue(gallery.GalleryOp{
+			Id:               uuid.String(),
+			Rollback:         true,
+			GalleryModelName: c.Params("name"),
+		})
+
 		return c.JSON(schema.GalleryResponse{ID: uuid.String(), StatusURL: c.BaseURL() + "/models/jobs/" + uuid.String()})
 	}
 }
 
 // ListModelFromGalleryEndpoint list the available models for installation from the active galleries
 // @Summary List installable models.
+// @Param q	query string	false	"Words to search in the name, description and tags of the models"
+// @Param tag	query []string	false	"Tags the models must have"
+// @Param license	query string	false	"License of the models"
+// @Param backend	query string	false	"Backend of the models"
+// @Param gallery	query string	false	"Gallery of the models"
+// @Param installed	query bool	false	"Installed state of the models"
+// @Param sort	query string	false	"Field to sort the models by (name, gallery or license), prefixed with - for a descending order"
+// @Param limit	query int	false	"Maximum number of models to return"
+// @Param cursor	query string	false	"Cursor of the page to return, from the X-Next-Cursor header of the previous page"
 // @Success 200 {object} []gallery.GalleryModel "Response"
 // @Router /models/available [get]
 func (mgs *ModelGalleryEndpointService) ListModelFromGalleryEndpoint() func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		log.Debug().Msgf("Listing models from galleries: %+v", mgs.galleries)
 
-		models, err := gallery.AvailableGalleryModels(mgs.galleries, mgs.modelPath)
+		query := gallery.ModelQuery{
+			Text:    c.Query("q"),
+			License: c.Query("license"),
+			Backend: c.Query("backend"),
+			Gallery: c.Query("gallery"),
+			Sort:    c.Query("sort"),
+			Cursor:  c.Query("cursor"),
+			Limit:   c.QueryInt("limit", 0),
+		}
+		for _, t := range c.Context().QueryArgs().PeekMulti("tag") {
+			for _, tag := range strings.Split(string(t), ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					query.Tags = append(query.Tags, tag)
+				}
+			}
+		}
+		if installed := c.Query("installed"); installed != "" {
+			b, err := strconv.ParseBool(installed)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid installed value %q", installed))
+			}
+			query.Installed = &b
+		}
+		if query.Limit < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid limit")
+		}
+
+		models, err := mgs.galleryApplier.AvailableModels(mgs.galleries)
 		if err != nil {
 			return err
 		}
-		log.Debug().Msgf("Models found from galleries: %+v", models)
-		for _, m := range models {
-			log.Debug().Msgf("Model found from galleries: %+v", m)
+		page, next, total, err := gallery.GalleryModels(models).Query(query)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		log.Debug().Int("total", total).Int("page", len(page)).Msg("Models found from galleries")
+
+		c.Set("X-Total-Count", strconv.Itoa(total))
+		if next != "" {
+			c.Set("X-Next-Cursor", next)
 		}
-		dat, err := json.Marshal(models)
+		dat, err := json.Marshal(page)
 		if err != nil {
 			return err
 		}
@@ -172,6 +363,7 @@ func (mgs *ModelGalleryEndpointService) AddModelGalleryEndpoint() func(c *fiber.
 		}
 		log.Debug().Msgf("Adding %+v to gallery list", *input)
 		mgs.galleries = append(mgs.galleries, *input)
+		mgs.galleryApplier.RefreshIndexes()
 		return c.Send(dat)
 	}
 }
@@ -196,6 +388,7 @@ func (mgs *ModelGalleryEndpointService) RemoveModelGalleryEndpoint() func(c *fib
 		mgs.galleries = slices.DeleteFunc(mgs.galleries, func(gallery config.Gallery) bool {
 			return gallery.Name == input.Name
 		})
+		mgs.galleryApplier.RefreshIndexes()
 		dat, err := json.Marshal(mgs.galleries)
 		if err != nil {
 			return err
@@ -203,3 +396,14 @@ func (mgs *ModelGalleryEndpointService) RemoveModelGalleryEndpoint() func(c *fib
 		return c.Send(dat)
 	}
 }
+
+// RefreshModelGalleriesEndpoint downloads the indexes of the galleries again
+// @Summary Drops the indexes of the galleries cached in memory, so they are downloaded again on the next listing
+// @Success 200 {object} []config.Gallery "Response"
+// @Router /models/galleries/refresh [post]
+func (mgs *ModelGalleryEndpointService) RefreshModelGalleriesEndpoint() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		mgs.galleryApplier.RefreshIndexes()
+		return c.JSON(mgs.galleries)
+	}
+}
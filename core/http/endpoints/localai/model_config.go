@@ -0,0 +1,119 @@
+package localai
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/services"
+	"gopkg.in/yaml.v3"
+)
+
+// GetModelConfigEndpoint returns the effective configuration of a model
+// @Summary Returns the configuration of a model, after the defaults are applied. Use format=yaml to get it as YAML
+// @Param name path string true "Model name"
+// @Param format query string false "json (default) or yaml"
+// @Success 200 {object} map[string]interface{} "Response"
+// @Router /models/config/{name} [get]
+func GetModelConfigEndpoint(mcs *services.ModelConfigService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		doc, err := mcs.Config(c.Params("name"))
+		if err != nil {
+			return modelConfigError(err)
+		}
+		if c.Query("format") == "yaml" {
+			dat, err := yaml.Marshal(doc)
+			if err != nil {
+				return err
+			}
+			c.Set(fiber.HeaderContentType, "application/yaml")
+			return c.Send(dat)
+		}
+		return c.JSON(doc)
+	}
+}
+
+// PutModelConfigEndpoint writes the whole configuration of a model, creating it if it does not exist
+// @Summary Replaces the configuration of a model. The body is a JSON or YAML document
+// @Param name path string true "Model name"
+// @Success 200 {object} map[string]interface{} "Response"
+// @Router /models/config/{name} [put]
+func PutModelConfigEndpoint(mcs *services.ModelConfigService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("name")
+		doc, err := modelConfigBody(c)
+		if err != nil {
+			return err
+		}
+		created, err := mcs.Replace(name, doc)
+		if err != nil {
+			return modelConfigError(err)
+		}
+		if created {
+			c.Status(fiber.StatusCreated)
+		}
+		return modelConfigResponse(c, mcs, name)
+	}
+}
+
+// PatchModelConfigEndpoint merges a partial configuration into the configuration of a model
+// @Summary Updates some fields of the configuration of a model, following the JSON merge patch semantics: null removes a field
+// @Param name path string true "Model name"
+// @Success 200 {object} map[string]interface{} "Response"
+// @Router /models/config/{name} [patch]
+func PatchModelConfigEndpoint(mcs *services.ModelConfigService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("name")
+		patch, err := modelConfigBody(c)
+		if err != nil {
+			return err
+		}
+		if err := mcs.Patch(name, patch); err != nil {
+			return modelConfigError(err)
+		}
+		return modelConfigResponse(c, mcs, name)
+	}
+}
+
+// DeleteModelConfigEndpoint removes the configuration of a model
+// @Summary Removes the configuration file of a model and unloads it. The files of the model are kept
+// @Param name path string true "Model name"
+// @Success 204
+// @Router /models/config/{name} [delete]
+func DeleteModelConfigEndpoint(mcs *services.ModelConfigService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if err := mcs.Delete(c.Params("name")); err != nil {
+			return modelConfigError(err)
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// modelConfigBody reads a JSON or YAML document: JSON being valid YAML, the integers are kept as such
+func modelConfigBody(c *fiber.Ctx) (map[string]interface{}, error) {
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(c.Body(), &doc); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "the body must be a JSON or YAML object: "+err.Error())
+	}
+	return doc, nil
+}
+
+func modelConfigResponse(c *fiber.Ctx, mcs *services.ModelConfigService, name string) error {
+	doc, err := mcs.Config(name)
+	if err != nil {
+		return modelConfigError(err)
+	}
+	return c.JSON(doc)
+}
+
+func modelConfigError(err error) error {
+	var validationErr *services.ModelConfigValidationError
+	switch {
+	case errors.Is(err, services.ErrModelConfigNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrModelConfigReadOnly):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.As(err, &validationErr):
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package localai
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/valyala/fasthttp"
+)
+
+// ModelEventsEndpoint streams the changes of the model configurations as server-sent events
+// @Summary Stream the changes of the model configurations (added, updated, removed) and the reloads of the models
+// @Success 200 {object} config.BackendConfigEvent "Response"
+// @Router /models/events [get]
+func ModelEventsEndpoint(cl *config.BackendConfigLoader) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("Transfer-Encoding", "chunked")
+
+		events, unsubscribe := cl.Subscribe()
+		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			defer unsubscribe()
+
+			// the keepalive comments allow to detect when the client goes away
+			keepalive := time.NewTicker(15 * time.Second)
+			defer keepalive.Stop()
+			for {
+				select {
+				case e := <-events:
+					data, err := json.Marshal(e)
+					if err != nil {
+						return
+					}
+					fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Event, data)
+					if err := w.Flush(); err != nil {
+						return
+					}
+				case <-keepalive.C:
+					fmt.Fprint(w, ": keepalive\n\n")
+					if err := w.Flush(); err != nil {
+						return
+					}
+				}
+			}
+		}))
+
+		return nil
+	}
+}
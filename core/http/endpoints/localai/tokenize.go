@@ -0,0 +1,74 @@
+package localai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// TokenizeEndpoint tokenizes a string with the tokenizer of a model
+// @Summary Tokenize a string with the tokenizer of a model.
+// @Param request body schema.TokenizeRequest true "query params"
+// @Success 200 {object} schema.TokenizeResponse "Response"
+// @Router /v1/tokenize [post]
+func TokenizeEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.TokenizeRequest)
+		if err := c.BodyParser(input); err != nil {
+			return err
+		}
+
+		cfg, err := tokenizerConfig(c, cl, ml, appConfig, input.Model)
+		if err != nil {
+			return err
+		}
+
+		tokens, err := backend.ModelTokenize(input.Content, ml, *cfg, appConfig)
+		if err != nil {
+			return err
+		}
+		return c.JSON(schema.TokenizeResponse{Tokens: tokens, Count: len(tokens)})
+	}
+}
+
+// DetokenizeEndpoint converts a list of tokens of a model back to text
+// @Summary Convert a list of tokens of a model back to text.
+// @Param request body schema.DetokenizeRequest true "query params"
+// @Success 200 {object} schema.DetokenizeResponse "Response"
+// @Router /v1/detokenize [post]
+func DetokenizeEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.DetokenizeRequest)
+		if err := c.BodyParser(input); err != nil {
+			return err
+		}
+
+		cfg, err := tokenizerConfig(c, cl, ml, appConfig, input.Model)
+		if err != nil {
+			return err
+		}
+
+		content, err := backend.ModelDetokenize(input.Tokens, ml, *cfg, appConfig)
+		if err != nil {
+			return err
+		}
+		return c.JSON(schema.DetokenizeResponse{Content: content})
+	}
+}
+
+func tokenizerConfig(c *fiber.Ctx, cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, modelName string) (*config.BackendConfig, error) {
+	modelFile, err := fiberContext.ModelFromContext(c, cl, ml, modelName, true)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return cl.LoadBackendConfigFileByName(modelFile, appConfig.ModelPath,
+		config.LoadOptionDebug(appConfig.Debug),
+		config.LoadOptionThreads(appConfig.Threads),
+		config.LoadOptionContextSize(appConfig.ContextSize),
+		config.LoadOptionF16(appConfig.F16),
+	)
+}
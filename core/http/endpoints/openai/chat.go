@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,8 +15,11 @@ import (
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/pkg/functions"
 	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/reasoning"
+	"github.com/mudler/LocalAI/pkg/templates/jinja"
 	"github.com/rs/zerolog/log"
 	"github.com/valyala/fasthttp"
 )
@@ -24,7 +29,7 @@ import (
 // @Param request body schema.OpenAIRequest true "query params"
 // @Success 200 {object} schema.OpenAIResponse "Response"
 // @Router /v1/chat/completions [post]
-func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startupOptions *config.ApplicationConfig) func(c *fiber.Ctx) error {
+func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, conversations *services.ConversationService, startupOptions *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	textContentToReturn := ""
 	id := uuid.New().String()
 	created := int(time.Now().Unix())
@@ -39,12 +44,18 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 		}
 		responses <- initialMessage
 
-		ComputeChoices(req, s, config, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, usage backend.TokenUsage) bool {
-			resp := schema.OpenAIResponse{
+		// the reasoning of the model is separated from the content as the tokens come
+		extractor := reasoning.NewExtractor(config.ReasoningConfig)
+		delta := func(reasoningText, content string, usage backend.TokenUsage) schema.OpenAIResponse {
+			message := &schema.Message{Content: &content, ReasoningContent: reasoningText}
+			if content == "" && reasoningText != "" {
+				message.Content = nil
+			}
+			return schema.OpenAIResponse{
 				ID:      id,
 				Created: created,
 				Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
-				Choices: []schema.Choice{{Delta: &schema.Message{Content: &s}, Index: 0}},
+				Choices: []schema.Choice{{Delta: message, Index: 0}},
 				Object:  "chat.completion.chunk",
 				Usage: schema.OpenAIUsage{
 					PromptTokens:     usage.Prompt,
@@ -52,10 +63,22 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 					TotalTokens:      usage.Prompt + usage.Completion,
 				},
 			}
+		}
 
-			responses <- resp
+		lastUsage := backend.TokenUsage{}
+		ComputeChoices(req, s, config, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, usage backend.TokenUsage) bool {
+			lastUsage = usage
+			reasoningText, content := extractor.Process(s)
+			if reasoningText == "" && content == "" {
+				// the token may be the start of a tag
+				return true
+			}
+			responses <- delta(reasoningText, content, usage)
 			return true
 		})
+		if reasoningText, content := extractor.Flush(); reasoningText != "" || content != "" {
+			responses <- delta(reasoningText, content, lastUsage)
+		}
 		close(responses)
 	}
 	processTools := func(noAction string, prompt string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse) {
@@ -67,6 +90,17 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 			return true
 		})
 
+		reasoningText, result := config.ReasoningConfig.Extract(result)
+		if reasoningText != "" {
+			responses <- schema.OpenAIResponse{
+				ID:      id,
+				Created: created,
+				Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
+				Choices: []schema.Choice{{Delta: &schema.Message{Role: "assistant", ReasoningContent: reasoningText}}},
+				Object:  "chat.completion.chunk",
+			}
+		}
+
 		textContentToReturn = functions.ParseTextContent(result, config.FunctionsConfig)
 		result = functions.CleanupLLMResult(result, config.FunctionsConfig)
 		results := functions.ParseFunctionCall(result, config.FunctionsConfig)
@@ -170,71 +204,32 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 		}
 		log.Debug().Msgf("Configuration read: %+v", config)
 
-		funcs := input.Functions
-		shouldUseFn := len(input.Functions) > 0 && config.ShouldUseFunctions()
-
-		// Allow the user to set custom actions via config file
-		// to be "embedded" in each model
-		noActionName := "answer"
-		noActionDescription := "use this action to answer without performing any action"
-
-		if config.FunctionsConfig.NoActionFunctionName != "" {
-			noActionName = config.FunctionsConfig.NoActionFunctionName
-		}
-		if config.FunctionsConfig.NoActionDescriptionName != "" {
-			noActionDescription = config.FunctionsConfig.NoActionDescriptionName
+		turn, err := continueConversation(conversations, config, input)
+		if err != nil {
+			return err
 		}
-
-		if config.ResponseFormatMap != nil {
-			d := schema.ChatCompletionResponseFormat{}
-			dat, _ := json.Marshal(config.ResponseFormatMap)
-			_ = json.Unmarshal(dat, &d)
-			if d.Type == "json_object" {
-				input.Grammar = functions.JSONBNF
+		// the turn ends with the stream when the reply is streamed
+		streaming := false
+		defer func() {
+			if !streaming {
+				turn.end()
+			}
+		}()
+		if turn != nil {
+			c.Set("X-Conversation-Id", turn.id)
+			if turn.created {
+				c.Set("X-Conversation-New", "true")
 			}
 		}
 
-		config.Grammar = input.Grammar
-
-		if shouldUseFn {
-			log.Debug().Msgf("Response needs to process functions")
+		prompt, trimmed, err := fitContextWindow(cl, ml, startupOptions, config, input)
+		if err != nil {
+			return err
 		}
-
-		switch {
-		case !config.FunctionsConfig.GrammarConfig.NoGrammar && shouldUseFn:
-			noActionGrammar := functions.Function{
-				Name:        noActionName,
-				Description: noActionDescription,
-				Parameters: map[string]interface{}{
-					"properties": map[string]interface{}{
-						"message": map[string]interface{}{
-							"type":        "string",
-							"description": "The message to reply the user with",
-						}},
-				},
-			}
-
-			// Append the no action function
-			if !config.FunctionsConfig.DisableNoAction {
-				funcs = append(funcs, noActionGrammar)
-			}
-
-			// Force picking one of the functions by the request
-			if config.FunctionToCall() != "" {
-				funcs = funcs.Select(config.FunctionToCall())
-			}
-
-			// Update input grammar
-			jsStruct := funcs.ToJSONStructure(config.FunctionsConfig.FunctionNameKey, config.FunctionsConfig.FunctionNameKey)
-			config.Grammar = jsStruct.Grammar(config.FunctionsConfig.GrammarConfig.Options()...)
-		case input.JSONFunctionGrammarObject != nil:
-			config.Grammar = input.JSONFunctionGrammarObject.Grammar(config.FunctionsConfig.GrammarConfig.Options()...)
-		default:
-			// Force picking one of the functions by the request
-			if config.FunctionToCall() != "" {
-				funcs = funcs.Select(config.FunctionToCall())
-			}
+		if config.ContextWindow.Enabled() {
+			c.Set("X-Trimmed-Messages", strconv.Itoa(trimmed))
 		}
+		predInput, shouldUseFn, noActionName := prompt.Prompt, prompt.ShouldUseFn, prompt.NoActionName
 
 		// process functions if we have any defined or if we have a function call string
 
@@ -243,157 +238,6 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 
 		log.Debug().Msgf("Parameters: %+v", config)
 
-		var predInput string
-
-		// If we are using the tokenizer template, we don't need to process the messages
-		// unless we are processing functions
-		if !config.TemplateConfig.UseTokenizerTemplate || shouldUseFn {
-			suppressConfigSystemPrompt := false
-			mess := []string{}
-			for messageIndex, i := range input.Messages {
-				var content string
-				role := i.Role
-
-				// if function call, we might want to customize the role so we can display better that the "assistant called a json action"
-				// if an "assistant_function_call" role is defined, we use it, otherwise we use the role that is passed by in the request
-				if (i.FunctionCall != nil || i.ToolCalls != nil) && i.Role == "assistant" {
-					roleFn := "assistant_function_call"
-					r := config.Roles[roleFn]
-					if r != "" {
-						role = roleFn
-					}
-				}
-				r := config.Roles[role]
-				contentExists := i.Content != nil && i.StringContent != ""
-
-				fcall := i.FunctionCall
-				if len(i.ToolCalls) > 0 {
-					fcall = i.ToolCalls
-				}
-
-				// First attempt to populate content via a chat message specific template
-				if config.TemplateConfig.ChatMessage != "" {
-					chatMessageData := model.ChatMessageTemplateData{
-						SystemPrompt: config.SystemPrompt,
-						Role:         r,
-						RoleName:     role,
-						Content:      i.StringContent,
-						FunctionCall: fcall,
-						FunctionName: i.Name,
-						LastMessage:  messageIndex == (len(input.Messages) - 1),
-						Function:     config.Grammar != "" && (messageIndex == (len(input.Messages) - 1)),
-						MessageIndex: messageIndex,
-					}
-					templatedChatMessage, err := ml.EvaluateTemplateForChatMessage(config.TemplateConfig.ChatMessage, chatMessageData)
-					if err != nil {
-						log.Error().Err(err).Interface("message", chatMessageData).Str("template", config.TemplateConfig.ChatMessage).Msg("error processing message with template, skipping")
-					} else {
-						if templatedChatMessage == "" {
-							log.Warn().Msgf("template \"%s\" produced blank output for %+v. Skipping!", config.TemplateConfig.ChatMessage, chatMessageData)
-							continue // TODO: This continue is here intentionally to skip over the line `mess = append(mess, content)` below, and to prevent the sprintf
-						}
-						log.Debug().Msgf("templated message for chat: %s", templatedChatMessage)
-						content = templatedChatMessage
-					}
-				}
-
-				marshalAnyRole := func(f any) {
-					j, err := json.Marshal(f)
-					if err == nil {
-						if contentExists {
-							content += "\n" + fmt.Sprint(r, " ", string(j))
-						} else {
-							content = fmt.Sprint(r, " ", string(j))
-						}
-					}
-				}
-				marshalAny := func(f any) {
-					j, err := json.Marshal(f)
-					if err == nil {
-						if contentExists {
-							content += "\n" + string(j)
-						} else {
-							content = string(j)
-						}
-					}
-				}
-				// If this model doesn't have such a template, or if that template fails to return a value, template at the message level.
-				if content == "" {
-					if r != "" {
-						if contentExists {
-							content = fmt.Sprint(r, i.StringContent)
-						}
-
-						if i.FunctionCall != nil {
-							marshalAnyRole(i.FunctionCall)
-						}
-						if i.ToolCalls != nil {
-							marshalAnyRole(i.ToolCalls)
-						}
-					} else {
-						if contentExists {
-							content = fmt.Sprint(i.StringContent)
-						}
-						if i.FunctionCall != nil {
-							marshalAny(i.FunctionCall)
-						}
-						if i.ToolCalls != nil {
-							marshalAny(i.ToolCalls)
-						}
-					}
-					// Special Handling: System. We care if it was printed at all, not the r branch, so check seperately
-					if contentExists && role == "system" {
-						suppressConfigSystemPrompt = true
-					}
-				}
-
-				mess = append(mess, content)
-			}
-
-			joinCharacter := "\n"
-			if config.TemplateConfig.JoinChatMessagesByCharacter != nil {
-				joinCharacter = *config.TemplateConfig.JoinChatMessagesByCharacter
-			}
-
-			predInput = strings.Join(mess, joinCharacter)
-			log.Debug().Msgf("Prompt (before templating): %s", predInput)
-
-			templateFile := ""
-
-			// A model can have a "file.bin.tmpl" file associated with a prompt template prefix
-			if ml.ExistsInModelPath(fmt.Sprintf("%s.tmpl", config.Model)) {
-				templateFile = config.Model
-			}
-
-			if config.TemplateConfig.Chat != "" && !shouldUseFn {
-				templateFile = config.TemplateConfig.Chat
-			}
-
-			if config.TemplateConfig.Functions != "" && shouldUseFn {
-				templateFile = config.TemplateConfig.Functions
-			}
-
-			if templateFile != "" {
-				templatedInput, err := ml.EvaluateTemplateForPrompt(model.ChatPromptTemplate, templateFile, model.PromptTemplateData{
-					SystemPrompt:         config.SystemPrompt,
-					SuppressSystemPrompt: suppressConfigSystemPrompt,
-					Input:                predInput,
-					Functions:            funcs,
-				})
-				if err == nil {
-					predInput = templatedInput
-					log.Debug().Msgf("Template found, input modified to: %s", predInput)
-				} else {
-					log.Debug().Msgf("Template failed loading: %s", err.Error())
-				}
-			}
-
-			log.Debug().Msgf("Prompt (after templating): %s", predInput)
-			if shouldUseFn && config.Grammar != "" {
-				log.Debug().Msgf("Grammar: %+v", config.Grammar)
-			}
-		}
-
 		switch {
 		case toStream:
 
@@ -413,14 +257,19 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 				go processTools(noActionName, predInput, input, config, ml, responses)
 			}
 
+			streaming = true
 			c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+				defer turn.end()
 				usage := &schema.OpenAIUsage{}
 				toolsCalled := false
+				reply := &streamedReply{}
+				sent := true
 				for ev := range responses {
 					usage = &ev.Usage // Copy a pointer to the latest usage chunk so that the stop message can reference it
 					if len(ev.Choices[0].Delta.ToolCalls) > 0 {
 						toolsCalled = true
 					}
+					reply.add(ev.Choices[0].Delta)
 					var buf bytes.Buffer
 					enc := json.NewEncoder(&buf)
 					enc.Encode(ev)
@@ -429,6 +278,7 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 					if err != nil {
 						log.Debug().Msgf("Sending chunk failed: %v", err)
 						input.Cancel()
+						sent = false
 					}
 					w.Flush()
 				}
@@ -458,15 +308,21 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 				w.WriteString(fmt.Sprintf("data: %s\n\n", respData))
 				w.WriteString("data: [DONE]\n\n")
 				w.Flush()
+
+				// the reply interrupted is not stored, so that the client can send the message again
+				if sent {
+					turn.save(reply.message())
+				}
 			}))
 			return nil
 
 		// no streaming mode
 		default:
 			result, tokenUsage, err := ComputeChoices(input, predInput, config, startupOptions, ml, func(s string, c *[]schema.Choice) {
+				reasoningText, s := config.ReasoningConfig.Extract(s)
 				if !shouldUseFn {
 					// no function is called, just reply and use stop as finish reason
-					*c = append(*c, schema.Choice{FinishReason: "stop", Index: 0, Message: &schema.Message{Role: "assistant", Content: &s}})
+					*c = append(*c, schema.Choice{FinishReason: "stop", Index: 0, Message: &schema.Message{Role: "assistant", Content: &s, ReasoningContent: reasoningText}})
 					return
 				}
 
@@ -484,11 +340,12 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 						return
 					}
 					*c = append(*c, schema.Choice{
-						Message: &schema.Message{Role: "assistant", Content: &result}})
+						Message: &schema.Message{Role: "assistant", Content: &result, ReasoningContent: reasoningText}})
 				default:
 					toolChoice := schema.Choice{
 						Message: &schema.Message{
-							Role: "assistant",
+							Role:             "assistant",
+							ReasoningContent: reasoningText,
 						},
 					}
 
@@ -517,8 +374,9 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 							*c = append(*c, schema.Choice{
 								FinishReason: "function_call",
 								Message: &schema.Message{
-									Role:    "assistant",
-									Content: &textContentToReturn,
+									Role:             "assistant",
+									Content:          &textContentToReturn,
+									ReasoningContent: reasoningText,
 									FunctionCall: map[string]interface{}{
 										"name":      name,
 										"arguments": args,
@@ -554,12 +412,258 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 			respData, _ := json.Marshal(resp)
 			log.Debug().Msgf("Response: %s", respData)
 
+			if len(result) > 0 {
+				turn.save(result[0].Message)
+			}
+
 			// Return the prediction in the response body
 			return c.JSON(resp)
 		}
 	}
 }
 
+// chatPrompt is the prompt built from the messages and the functions of a chat request
+type chatPrompt struct {
+	Prompt       string
+	ShouldUseFn  bool
+	NoActionName string
+}
+
+// buildChatPrompt renders the messages and the functions of a chat request with the templates of the model,
+// and sets in the configuration the grammar to apply
+func buildChatPrompt(config *config.BackendConfig, input *schema.OpenAIRequest, ml *model.ModelLoader) (*chatPrompt, error) {
+	p := &chatPrompt{}
+	funcs := input.Functions
+	shouldUseFn := len(input.Functions) > 0 && config.ShouldUseFunctions()
+
+	// Allow the user to set custom actions via config file
+	// to be "embedded" in each model
+	noActionName := "answer"
+	noActionDescription := "use this action to answer without performing any action"
+
+	if config.FunctionsConfig.NoActionFunctionName != "" {
+		noActionName = config.FunctionsConfig.NoActionFunctionName
+	}
+	if config.FunctionsConfig.NoActionDescriptionName != "" {
+		noActionDescription = config.FunctionsConfig.NoActionDescriptionName
+	}
+
+	if config.ResponseFormatMap != nil {
+		d := schema.ChatCompletionResponseFormat{}
+		dat, _ := json.Marshal(config.ResponseFormatMap)
+		_ = json.Unmarshal(dat, &d)
+		if d.Type == "json_object" {
+			input.Grammar = functions.JSONBNF
+		}
+	}
+
+	config.Grammar = input.Grammar
+
+	if shouldUseFn {
+		log.Debug().Msgf("Response needs to process functions")
+	}
+
+	switch {
+	case !config.FunctionsConfig.GrammarConfig.NoGrammar && shouldUseFn:
+		noActionGrammar := functions.Function{
+			Name:        noActionName,
+			Description: noActionDescription,
+			Parameters: map[string]interface{}{
+				"properties": map[string]interface{}{
+					"message": map[string]interface{}{
+						"type":        "string",
+						"description": "The message to reply the user with",
+					}},
+			},
+		}
+
+		// Append the no action function
+		if !config.FunctionsConfig.DisableNoAction {
+			funcs = append(funcs, noActionGrammar)
+		}
+
+		// Force picking one of the functions by the request
+		if config.FunctionToCall() != "" {
+			funcs = funcs.Select(config.FunctionToCall())
+		}
+
+		// Update input grammar
+		jsStruct := funcs.ToJSONStructure(config.FunctionsConfig.FunctionNameKey, config.FunctionsConfig.FunctionNameKey)
+		config.Grammar = jsStruct.Grammar(config.FunctionsConfig.GrammarConfig.Options()...)
+	case input.JSONFunctionGrammarObject != nil:
+		config.Grammar = input.JSONFunctionGrammarObject.Grammar(config.FunctionsConfig.GrammarConfig.Options()...)
+	default:
+		// Force picking one of the functions by the request
+		if config.FunctionToCall() != "" {
+			funcs = funcs.Select(config.FunctionToCall())
+		}
+	}
+
+	// If we are using the tokenizer template, we don't need to process the messages
+	// unless we are processing functions
+	if config.TemplateConfig.Jinja != "" {
+		var err error
+		p.Prompt, err = templateJinjaChat(ml, config, input.Messages, funcs, shouldUseFn)
+		if err != nil {
+			var raised *jinja.RaiseError
+			if errors.As(err, &raised) {
+				return nil, fiber.NewError(fiber.StatusBadRequest, raised.Message)
+			}
+			return nil, fmt.Errorf("failed rendering the chat template: %w", err)
+		}
+		log.Debug().Msgf("Prompt (after Jinja templating): %s", p.Prompt)
+	} else if !config.TemplateConfig.UseTokenizerTemplate || shouldUseFn {
+		suppressConfigSystemPrompt := false
+		mess := []string{}
+		for messageIndex, i := range input.Messages {
+			var content string
+			role := i.Role
+
+			// if function call, we might want to customize the role so we can display better that the "assistant called a json action"
+			// if an "assistant_function_call" role is defined, we use it, otherwise we use the role that is passed by in the request
+			if (i.FunctionCall != nil || i.ToolCalls != nil) && i.Role == "assistant" {
+				roleFn := "assistant_function_call"
+				r := config.Roles[roleFn]
+				if r != "" {
+					role = roleFn
+				}
+			}
+			r := config.Roles[role]
+			contentExists := i.Content != nil && i.StringContent != ""
+
+			fcall := i.FunctionCall
+			if len(i.ToolCalls) > 0 {
+				fcall = i.ToolCalls
+			}
+
+			// First attempt to populate content via a chat message specific template
+			if config.TemplateConfig.ChatMessage != "" {
+				chatMessageData := model.ChatMessageTemplateData{
+					SystemPrompt: config.SystemPrompt,
+					Role:         r,
+					RoleName:     role,
+					Content:      i.StringContent,
+					FunctionCall: fcall,
+					FunctionName: i.Name,
+					LastMessage:  messageIndex == (len(input.Messages) - 1),
+					Function:     config.Grammar != "" && (messageIndex == (len(input.Messages) - 1)),
+					MessageIndex: messageIndex,
+				}
+				templatedChatMessage, err := ml.EvaluateTemplateForChatMessage(config.TemplateConfig.ChatMessage, chatMessageData)
+				if err != nil {
+					log.Error().Err(err).Interface("message", chatMessageData).Str("template", config.TemplateConfig.ChatMessage).Msg("error processing message with template, skipping")
+				} else {
+					if templatedChatMessage == "" {
+						log.Warn().Msgf("template \"%s\" produced blank output for %+v. Skipping!", config.TemplateConfig.ChatMessage, chatMessageData)
+						continue // TODO: This continue is here intentionally to skip over the line `mess = append(mess, content)` below, and to prevent the sprintf
+					}
+					log.Debug().Msgf("templated message for chat: %s", templatedChatMessage)
+					content = templatedChatMessage
+				}
+			}
+
+			marshalAnyRole := func(f any) {
+				j, err := json.Marshal(f)
+				if err == nil {
+					if contentExists {
+						content += "\n" + fmt.Sprint(r, " ", string(j))
+					} else {
+						content = fmt.Sprint(r, " ", string(j))
+					}
+				}
+			}
+			marshalAny := func(f any) {
+				j, err := json.Marshal(f)
+				if err == nil {
+					if contentExists {
+						content += "\n" + string(j)
+					} else {
+						content = string(j)
+					}
+				}
+			}
+			// If this model doesn't have such a template, or if that template fails to return a value, template at the message level.
+			if content == "" {
+				if r != "" {
+					if contentExists {
+						content = fmt.Sprint(r, i.StringContent)
+					}
+
+					if i.FunctionCall != nil {
+						marshalAnyRole(i.FunctionCall)
+					}
+					if i.ToolCalls != nil {
+						marshalAnyRole(i.ToolCalls)
+					}
+				} else {
+					if contentExists {
+						content = fmt.Sprint(i.StringContent)
+					}
+					if i.FunctionCall != nil {
+						marshalAny(i.FunctionCall)
+					}
+					if i.ToolCalls != nil {
+						marshalAny(i.ToolCalls)
+					}
+				}
+				// Special Handling: System. We care if it was printed at all, not the r branch, so check seperately
+				if contentExists && role == "system" {
+					suppressConfigSystemPrompt = true
+				}
+			}
+
+			mess = append(mess, content)
+		}
+
+		joinCharacter := "\n"
+		if config.TemplateConfig.JoinChatMessagesByCharacter != nil {
+			joinCharacter = *config.TemplateConfig.JoinChatMessagesByCharacter
+		}
+
+		p.Prompt = strings.Join(mess, joinCharacter)
+		log.Debug().Msgf("Prompt (before templating): %s", p.Prompt)
+
+		templateFile := ""
+
+		// A model can have a "file.bin.tmpl" file associated with a prompt template prefix
+		if ml.ExistsInModelPath(fmt.Sprintf("%s.tmpl", config.Model)) {
+			templateFile = config.Model
+		}
+
+		if config.TemplateConfig.Chat != "" && !shouldUseFn {
+			templateFile = config.TemplateConfig.Chat
+		}
+
+		if config.TemplateConfig.Functions != "" && shouldUseFn {
+			templateFile = config.TemplateConfig.Functions
+		}
+
+		if templateFile != "" {
+			templatedInput, err := ml.EvaluateTemplateForPrompt(model.ChatPromptTemplate, templateFile, model.PromptTemplateData{
+				SystemPrompt:         config.SystemPrompt,
+				SuppressSystemPrompt: suppressConfigSystemPrompt,
+				Input:                p.Prompt,
+				Functions:            funcs,
+			})
+			if err == nil {
+				p.Prompt = templatedInput
+				log.Debug().Msgf("Template found, input modified to: %s", p.Prompt)
+			} else {
+				log.Debug().Msgf("Template failed loading: %s", err.Error())
+			}
+		}
+
+		log.Debug().Msgf("Prompt (after templating): %s", p.Prompt)
+		if shouldUseFn && config.Grammar != "" {
+			log.Debug().Msgf("Grammar: %+v", config.Grammar)
+		}
+	}
+
+	p.ShouldUseFn = shouldUseFn
+	p.NoActionName = noActionName
+	return p, nil
+}
+
 func handleQuestion(config *config.BackendConfig, input *schema.OpenAIRequest, ml *model.ModelLoader, o *config.ApplicationConfig, funcResults []functions.FuncCallResults, result, prompt string) (string, error) {
 
 	if len(funcResults) == 0 && result != "" {
@@ -612,5 +716,6 @@ func handleQuestion(config *config.BackendConfig, input *schema.OpenAIRequest, m
 		log.Error().Err(err).Msg("prediction failed")
 		return "", err
 	}
-	return backend.Finetune(*config, prompt, prediction.Response), nil
+	// the reasoning of the reply computed is not returned
+	return config.ReasoningConfig.Strip(backend.Finetune(*config, prompt, prediction.Response)), nil
 }
@@ -0,0 +1,287 @@
+package openai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultSummaryMaxTokens = 256
+	defaultSummaryPrompt    = "Summarize the following conversation in a few sentences. Keep the facts, the names and the decisions needed to continue it."
+
+	// templateTokensPerMessage is the margin counted for the role markers and the separators added to each message
+	// by the chat template of the backend, when the prompt is not rendered by the templates of the model
+	templateTokensPerMessage = 8
+	summaryCacheSize         = 256
+)
+
+// summaries caches the summaries of the messages trimmed, keyed by the model and the messages summarized. As the
+// conversations grow, the summary of a longer prefix is built on the cached summary of the previous one
+var summaries, _ = lru.New[string, string](summaryCacheSize)
+
+// fitContextWindow builds the prompt of a chat request, trimming the messages that do not fit in the context size
+// of the model with the strategy of its context window. The messages of the request are replaced by the ones kept,
+// and the number of messages trimmed is returned
+func fitContextWindow(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, cfg *config.BackendConfig, input *schema.OpenAIRequest) (*chatPrompt, int, error) {
+	window := cfg.ContextWindow
+	if !window.Enabled() || cfg.ContextSize == nil || *cfg.ContextSize <= 0 {
+		p, err := buildChatPrompt(cfg, input, ml)
+		return p, 0, err
+	}
+	if err := window.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	maxTokens := 0
+	if cfg.Maxtokens != nil {
+		maxTokens = *cfg.Maxtokens
+	}
+	budget := *cfg.ContextSize - window.Reserve(*cfg.ContextSize, maxTokens)
+
+	counter := &promptCounter{cfg: cfg, input: input, ml: ml, appConfig: appConfig}
+	messages := input.Messages
+	p, tokens, err := counter.count(messages)
+	if err != nil || tokens <= budget {
+		return p, 0, err
+	}
+
+	summaryTokens := 0
+	if window.Strategy == config.ContextWindowSummarize {
+		summaryTokens = window.SummaryMaxTokens
+		if summaryTokens == 0 {
+			summaryTokens = defaultSummaryMaxTokens
+		}
+	}
+
+	// find the fewest messages to trim for the prompt to fit, the count decreasing with the messages trimmed
+	candidates := trimCandidates(window, messages)
+	var fitErr error
+	n := sort.Search(len(candidates), func(i int) bool {
+		if fitErr != nil {
+			return true
+		}
+		_, tokens, err := counter.count(withoutMessages(messages, candidates[:i+1]))
+		fitErr = err
+		return tokens+summaryTokens <= budget
+	})
+	if fitErr != nil {
+		return nil, 0, fitErr
+	}
+	if n == len(candidates) {
+		log.Warn().Str("model", cfg.Name).Int("context_size", *cfg.ContextSize).Msg("the messages kept by the context window strategy exceed the context size")
+		n--
+	}
+	trimmed := candidates[:n+1]
+	// the results of the tools are trimmed with their call
+	for len(trimmed) < len(candidates) && isToolResult(messages[candidates[len(trimmed)]]) {
+		trimmed = candidates[:len(trimmed)+1]
+	}
+
+	kept := withoutMessages(messages, trimmed)
+	if summaryTokens > 0 && len(trimmed) > 0 {
+		summary, err := summarizeMessages(cl, ml, appConfig, cfg, input, pickMessages(messages, trimmed), summaryTokens)
+		if err != nil {
+			log.Error().Err(err).Str("model", cfg.Name).Msg("cannot summarize the messages trimmed, they are dropped")
+		} else {
+			kept = withSummary(kept, summary)
+		}
+	}
+
+	log.Debug().Str("model", cfg.Name).Int("trimmed", len(trimmed)).Int("kept", len(kept)).Msg("messages trimmed to fit the context size")
+	input.Messages = kept
+	p, err = buildChatPrompt(cfg, input, ml)
+	return p, len(trimmed), err
+}
+
+// promptCounter counts the tokens of the prompt of a list of messages with the tokenizer of the model,
+// or estimates them if the backend cannot tokenize
+type promptCounter struct {
+	cfg       *config.BackendConfig
+	input     *schema.OpenAIRequest
+	ml        *model.ModelLoader
+	appConfig *config.ApplicationConfig
+	estimated bool
+}
+
+func (pc *promptCounter) count(messages []schema.Message) (*chatPrompt, int, error) {
+	original := pc.input.Messages
+	pc.input.Messages = messages
+	p, err := buildChatPrompt(pc.cfg, pc.input, pc.ml)
+	pc.input.Messages = original
+	if err != nil {
+		return nil, 0, err
+	}
+
+	text := p.Prompt
+	overhead := 0
+	if text == "" {
+		// the messages are rendered by the backend with the template of its tokenizer, which adds its markers
+		// to each message and the generation prompt
+		for _, m := range messages {
+			text += m.Role + "\n" + m.StringContent + "\n"
+		}
+		overhead = (len(messages) + 1) * templateTokensPerMessage
+	}
+	if !pc.estimated {
+		tokens, err := backend.ModelTokenize(text, pc.ml, *pc.cfg, pc.appConfig)
+		if err == nil {
+			return p, len(tokens) + overhead, nil
+		}
+		log.Debug().Err(err).Str("model", pc.cfg.Name).Msg("the backend cannot tokenize the prompt, its tokens are estimated")
+		pc.estimated = true
+	}
+	return p, len(text)/4 + overhead, nil
+}
+
+// trimCandidates returns the indexes of the messages that can be trimmed, in the order they are trimmed.
+// The system messages and the last messages are always kept
+func trimCandidates(window config.ContextWindow, messages []schema.Message) []int {
+	conversation := []int{}
+	for i, m := range messages {
+		if m.Role != "system" {
+			conversation = append(conversation, i)
+		}
+	}
+
+	keepFirst := 0
+	if window.Strategy == config.ContextWindowKeepFirstLast {
+		keepFirst = window.KeepFirst
+		if keepFirst == 0 {
+			keepFirst = 1
+		}
+	}
+	keepLast := window.KeepLast
+	if keepLast == 0 {
+		keepLast = 1
+	}
+
+	if keepFirst+keepLast >= len(conversation) {
+		return []int{}
+	}
+	return conversation[keepFirst : len(conversation)-keepLast]
+}
+
+func isToolResult(m schema.Message) bool {
+	return m.Role == "tool" || m.Role == "function"
+}
+
+func withoutMessages(messages []schema.Message, trimmed []int) []schema.Message {
+	kept := make([]schema.Message, 0, len(messages))
+	next := 0
+	for i, m := range messages {
+		if next < len(trimmed) && trimmed[next] == i {
+			next++
+			continue
+		}
+		kept = append(kept, m)
+	}
+	return kept
+}
+
+func pickMessages(messages []schema.Message, indexes []int) []schema.Message {
+	picked := make([]schema.Message, 0, len(indexes))
+	for _, i := range indexes {
+		picked = append(picked, messages[i])
+	}
+	return picked
+}
+
+// withSummary adds the summary of the messages trimmed to the first system message,
+// as the chat templates often accept the system messages only at the start
+func withSummary(messages []schema.Message, summary string) []schema.Message {
+	text := "Summary of the earlier conversation: " + summary
+	if len(messages) > 0 && messages[0].Role == "system" {
+		content := messages[0].StringContent + "\n\n" + text
+		messages[0].Content = content
+		messages[0].StringContent = content
+		return messages
+	}
+	return append([]schema.Message{{Role: "system", Content: text, StringContent: text}}, messages...)
+}
+
+// summarizeMessages asks the summary model of the context window to summarize a list of messages. The summaries
+// are cached: a conversation trimming the same messages reuses its summary, and one trimming more messages only
+// summarizes the new ones with the summary of the previous ones
+func summarizeMessages(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, cfg *config.BackendConfig, input *schema.OpenAIRequest, messages []schema.Message, maxTokens int) (string, error) {
+	keys := summaryKeys(cfg, messages, maxTokens)
+	previous, summarized := "", 0
+	for i := len(keys) - 1; i >= 0; i-- {
+		if summary, ok := summaries.Get(keys[i]); ok {
+			previous, summarized = summary, i+1
+			break
+		}
+	}
+	if summarized == len(messages) {
+		return previous, nil
+	}
+
+	summaryCfg := *cfg
+	if name := cfg.ContextWindow.SummaryModel; name != "" && name != cfg.Name {
+		c, exists := cl.GetBackendConfig(name)
+		if !exists {
+			return "", fmt.Errorf("the summary model %q is not configured", name)
+		}
+		summaryCfg = c
+	}
+	summaryCfg.Maxtokens = &maxTokens
+	summaryCfg.Grammar = ""
+
+	instruction := cfg.ContextWindow.SummaryPrompt
+	if instruction == "" {
+		instruction = defaultSummaryPrompt
+	}
+	transcript := []string{}
+	if previous != "" {
+		transcript = append(transcript, "Summary of the earlier conversation: "+previous)
+	}
+	for _, m := range messages[summarized:] {
+		transcript = append(transcript, m.Role+": "+m.StringContent)
+	}
+	conversation := strings.Join(transcript, "\n")
+
+	req := &schema.OpenAIRequest{
+		Messages: []schema.Message{
+			{Role: "system", Content: instruction, StringContent: instruction},
+			{Role: "user", Content: conversation, StringContent: conversation},
+		},
+	}
+	p, err := buildChatPrompt(&summaryCfg, req, ml)
+	if err != nil {
+		return "", err
+	}
+
+	predFunc, err := backend.ModelInference(input.Context, p.Prompt, req.Messages, nil, ml, summaryCfg, appConfig, nil)
+	if err != nil {
+		return "", err
+	}
+	prediction, err := predFunc()
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(backend.Finetune(summaryCfg, p.Prompt, prediction.Response))
+	summaries.Add(keys[len(keys)-1], summary)
+	return summary, nil
+}
+
+// summaryKeys returns the keys of the summaries of each prefix of the messages, hashing the model,
+// the settings of the summary and the messages of the prefix
+func summaryKeys(cfg *config.BackendConfig, messages []schema.Message, maxTokens int) []string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\x00", cfg.Name, cfg.ContextWindow.SummaryModel, cfg.ContextWindow.SummaryPrompt, maxTokens)
+	keys := make([]string, 0, len(messages))
+	for _, m := range messages {
+		fmt.Fprintf(h, "%s\x00%d\x00%s\x00", m.Role, len(m.StringContent), m.StringContent)
+		keys = append(keys, hex.EncodeToString(h.Sum(nil)))
+	}
+	return keys
+}
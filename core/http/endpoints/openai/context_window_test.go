@@ -0,0 +1,153 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFitContextWindow(t *testing.T) {
+	modelPath := t.TempDir()
+	option := &config.ApplicationConfig{
+		Context:           context.Background(),
+		ModelPath:         modelPath,
+		AssetsDestination: t.TempDir(),
+	}
+	loader := config.NewBackendConfigLoader(modelPath)
+	ml := model.NewModelLoader(modelPath)
+
+	// the backend is not available, so the tokens are estimated to a quarter of the length of the prompt
+	newConfig := func(window config.ContextWindow) *config.BackendConfig {
+		cfg := &config.BackendConfig{Name: "test", Backend: "missing-backend", ContextWindow: window}
+		cfg.Model = "test.gguf"
+		cfg.SetDefaults(config.LoadOptionContextSize(100))
+		return cfg
+	}
+	newRequest := func() *schema.OpenAIRequest {
+		messages := []schema.Message{{Role: "system", Content: "Be brief", StringContent: "Be brief"}}
+		for i := 0; i < 10; i++ {
+			role := "user"
+			if i%2 == 1 {
+				role = "assistant"
+			}
+			// 40 characters, 41 with the newline joining the messages
+			content := fmt.Sprintf("message %02d %s", i, strings.Repeat("x", 29))
+			messages = append(messages, schema.Message{Role: role, Content: content, StringContent: content})
+		}
+		return &schema.OpenAIRequest{Messages: messages}
+	}
+
+	t.Run("does not trim without a strategy", func(t *testing.T) {
+		input := newRequest()
+		_, trimmed, err := fitContextWindow(loader, ml, option, newConfig(config.ContextWindow{}), input)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, trimmed)
+		assert.Len(t, input.Messages, 11)
+	})
+
+	t.Run("drops the oldest messages keeping the system prompt", func(t *testing.T) {
+		input := newRequest()
+		p, trimmed, err := fitContextWindow(loader, ml, option, newConfig(config.ContextWindow{Strategy: config.ContextWindowDropOldest}), input)
+		assert.NoError(t, err)
+		// 75 tokens are left after reserving a quarter of the context for the reply: 7 messages and the system prompt
+		assert.Equal(t, 3, trimmed)
+		assert.Len(t, input.Messages, 8)
+		assert.Equal(t, "system", input.Messages[0].Role)
+		assert.Contains(t, input.Messages[1].StringContent, "message 03")
+		assert.Contains(t, p.Prompt, "message 09")
+		assert.NotContains(t, p.Prompt, "message 02")
+		assert.LessOrEqual(t, len(p.Prompt)/4, 75)
+	})
+
+	t.Run("keeps the first and the last messages", func(t *testing.T) {
+		input := newRequest()
+		_, trimmed, err := fitContextWindow(loader, ml, option, newConfig(config.ContextWindow{Strategy: config.ContextWindowKeepFirstLast, KeepFirst: 2, KeepLast: 2, ReserveTokens: 25}), input)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, trimmed)
+		assert.Len(t, input.Messages, 8)
+		assert.Contains(t, input.Messages[1].StringContent, "message 00")
+		assert.Contains(t, input.Messages[2].StringContent, "message 01")
+		assert.Contains(t, input.Messages[3].StringContent, "message 05")
+		assert.Contains(t, input.Messages[7].StringContent, "message 09")
+	})
+
+	t.Run("keeps the last message even if it does not fit", func(t *testing.T) {
+		input := newRequest()
+		_, trimmed, err := fitContextWindow(loader, ml, option, newConfig(config.ContextWindow{Strategy: config.ContextWindowDropOldest, ReserveTokens: 95}), input)
+		assert.NoError(t, err)
+		assert.Equal(t, 9, trimmed)
+		assert.Len(t, input.Messages, 2)
+		assert.Contains(t, input.Messages[1].StringContent, "message 09")
+	})
+
+	t.Run("drops the messages if they cannot be summarized", func(t *testing.T) {
+		input := newRequest()
+		_, trimmed, err := fitContextWindow(loader, ml, option, newConfig(config.ContextWindow{Strategy: config.ContextWindowSummarize, SummaryMaxTokens: 20}), input)
+		assert.NoError(t, err)
+		// room is left for the summary: 5 messages and the system prompt fit in 55 tokens
+		assert.Equal(t, 5, trimmed)
+		assert.Len(t, input.Messages, 6)
+	})
+
+	t.Run("reuses the summary of the messages trimmed", func(t *testing.T) {
+		cfg := newConfig(config.ContextWindow{Strategy: config.ContextWindowSummarize, SummaryMaxTokens: 20})
+		input := newRequest()
+		summaries.Add(summaryKeys(cfg, input.Messages[1:6], 20)[4], "The user counted.")
+		defer summaries.Purge()
+
+		_, trimmed, err := fitContextWindow(loader, ml, option, cfg, input)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, trimmed)
+		assert.Len(t, input.Messages, 6)
+		assert.Equal(t, "Be brief\n\nSummary of the earlier conversation: The user counted.", input.Messages[0].StringContent)
+	})
+
+	t.Run("counts the markers of the tokenizer template", func(t *testing.T) {
+		cfg := newConfig(config.ContextWindow{Strategy: config.ContextWindowDropOldest})
+		cfg.TemplateConfig.UseTokenizerTemplate = true
+		input := newRequest()
+		counter := &promptCounter{cfg: cfg, input: input, ml: ml, appConfig: option}
+		_, tokens, err := counter.count(input.Messages[:2])
+		assert.NoError(t, err)
+		// "system\nBe brief\nuser\nmessage 00 ...\n" and the markers of the 2 messages and the generation prompt
+		assert.Equal(t, 62/4+3*templateTokensPerMessage, tokens)
+	})
+
+	t.Run("rejects an unknown strategy", func(t *testing.T) {
+		_, _, err := fitContextWindow(loader, ml, option, newConfig(config.ContextWindow{Strategy: "truncate"}), newRequest())
+		assert.ErrorContains(t, err, "unknown context_window strategy")
+	})
+}
+
+func TestWithSummary(t *testing.T) {
+	messages := withSummary([]schema.Message{
+		{Role: "system", Content: "Be brief", StringContent: "Be brief"},
+		{Role: "user", Content: "Hi", StringContent: "Hi"},
+	}, "The user asked about Rome.")
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "Be brief\n\nSummary of the earlier conversation: The user asked about Rome.", messages[0].StringContent)
+
+	messages = withSummary([]schema.Message{{Role: "user", Content: "Hi", StringContent: "Hi"}}, "The user asked about Rome.")
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "system", messages[0].Role)
+}
+
+func TestSummaryKeys(t *testing.T) {
+	cfg := &config.BackendConfig{Name: "test"}
+	messages := []schema.Message{
+		{Role: "user", StringContent: "Hi"},
+		{Role: "assistant", StringContent: "Hello"},
+	}
+	keys := summaryKeys(cfg, messages, 20)
+	assert.Len(t, keys, 2)
+	// the keys of the prefixes do not change with the messages following them
+	assert.Equal(t, keys[0], summaryKeys(cfg, messages[:1], 20)[0])
+	assert.NotEqual(t, keys[1], summaryKeys(cfg, messages, 30)[1])
+	assert.NotEqual(t, keys, summaryKeys(&config.BackendConfig{Name: "other"}, messages, 20))
+}
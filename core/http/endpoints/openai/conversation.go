@@ -0,0 +1,158 @@
+package openai
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/rs/zerolog/log"
+)
+
+// conversationTurn is a chat request continuing a conversation stored on the server
+type conversationTurn struct {
+	conversations *services.ConversationService
+	id            string
+	model         string
+	promptCache   string
+	// created is set if the conversation is not stored yet, as when it expired
+	created bool
+	// the messages sent in the request, stored with the reply
+	messages []schema.Message
+	release  func()
+}
+
+// continueConversation starts a turn of the conversation of the request, prepending its history to the messages.
+// It returns nil if the request has no conversation, and the turn must be ended once its reply is stored
+func continueConversation(conversations *services.ConversationService, cfg *config.BackendConfig, input *schema.OpenAIRequest) (*conversationTurn, error) {
+	if input.ConversationID == "" || conversations == nil {
+		return nil, nil
+	}
+
+	release, err := conversations.Begin(input.ConversationID)
+	switch {
+	case errors.Is(err, services.ErrInvalidConversationID):
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrConversationBusy):
+		return nil, fiber.NewError(fiber.StatusConflict, err.Error())
+	case err != nil:
+		return nil, err
+	}
+	turn := &conversationTurn{
+		conversations: conversations,
+		id:            input.ConversationID,
+		model:         input.Model,
+		messages:      input.Messages,
+		release:       release,
+	}
+	turn.created, err = withConversationHistory(conversations, cfg, input)
+	if err != nil {
+		turn.end()
+		return nil, err
+	}
+	turn.promptCache = cfg.PromptCachePath
+	return turn, nil
+}
+
+// withConversationHistory prepends the history of the conversation of the request to its messages, and gives
+// the conversation its own prompt cache. It returns true if the conversation is not stored yet
+func withConversationHistory(conversations *services.ConversationService, cfg *config.BackendConfig, input *schema.OpenAIRequest) (bool, error) {
+	if input.ConversationID == "" || conversations == nil {
+		return false, nil
+	}
+
+	c, err := conversations.Get(input.ConversationID)
+	created := false
+	switch {
+	case errors.Is(err, services.ErrInvalidConversationID):
+		return false, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrConversationNotFound):
+		log.Debug().Str("conversation", input.ConversationID).Msg("new conversation")
+		created = true
+	case err != nil:
+		return false, fmt.Errorf("cannot read the conversation %s: %w", input.ConversationID, err)
+	default:
+		input.Messages = append(append([]schema.Message{}, c.Messages...), input.Messages...)
+	}
+
+	if cfg.PromptCachePath != "" {
+		cfg.PromptCachePath = services.ConversationPromptCache(cfg.PromptCachePath, input.ConversationID)
+	}
+	return created, nil
+}
+
+// save stores the messages of the request and the reply in the conversation
+func (t *conversationTurn) save(reply *schema.Message) {
+	if t == nil {
+		return
+	}
+	messages := t.messages
+	if reply != nil {
+		messages = append(messages, storedReply(*reply))
+	}
+	if _, err := t.conversations.Append(t.id, t.model, t.promptCache, messages...); err != nil {
+		log.Error().Err(err).Str("conversation", t.id).Msg("cannot store the messages of the conversation")
+	}
+}
+
+// end ends the turn, so that the conversation can be continued
+func (t *conversationTurn) end() {
+	if t == nil {
+		return
+	}
+	t.release()
+}
+
+// storedReply returns the reply of the model as a message of the history
+func storedReply(reply schema.Message) schema.Message {
+	reply.Role = "assistant"
+	if content, ok := reply.Content.(*string); ok {
+		if content == nil {
+			reply.Content = nil
+		} else {
+			reply.Content = *content
+		}
+	}
+	if content, ok := reply.Content.(string); ok {
+		reply.StringContent = content
+	}
+	return reply
+}
+
+// streamedReply accumulates the deltas of a streamed reply
+type streamedReply struct {
+	content   string
+	reasoning string
+	toolCalls []schema.ToolCall
+}
+
+func (r *streamedReply) add(delta *schema.Message) {
+	if delta == nil {
+		return
+	}
+	r.reasoning += delta.ReasoningContent
+	if content, ok := delta.Content.(*string); ok && content != nil {
+		if len(delta.ToolCalls) > 0 {
+			// the text content is repeated with each tool call
+			r.content = *content
+		} else {
+			r.content += *content
+		}
+	}
+	for _, call := range delta.ToolCalls {
+		if call.Index < len(r.toolCalls) {
+			r.toolCalls[call.Index].FunctionCall.Arguments += call.FunctionCall.Arguments
+			if call.FunctionCall.Name != "" {
+				r.toolCalls[call.Index].FunctionCall.Name = call.FunctionCall.Name
+			}
+			continue
+		}
+		r.toolCalls = append(r.toolCalls, call)
+	}
+}
+
+func (r *streamedReply) message() *schema.Message {
+	return &schema.Message{Role: "assistant", Content: r.content, ReasoningContent: r.reasoning, ToolCalls: r.toolCalls}
+}
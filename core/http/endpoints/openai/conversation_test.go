@@ -0,0 +1,70 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContinueConversation(t *testing.T) {
+	conversations := services.NewConversationService(&config.ApplicationConfig{ConfigsDir: t.TempDir(), ModelPath: t.TempDir()})
+	newRequest := func(content string) *schema.OpenAIRequest {
+		input := &schema.OpenAIRequest{ConversationID: "chat-1", Messages: []schema.Message{{Role: "user", Content: content, StringContent: content}}}
+		input.Model = "llama"
+		return input
+	}
+
+	cfg := &config.BackendConfig{}
+	cfg.PromptCachePath = "cache"
+	input := newRequest("Hi")
+	turn, err := continueConversation(conversations, cfg, input)
+	assert.NoError(t, err)
+	assert.True(t, turn.created)
+	assert.Len(t, input.Messages, 1)
+	assert.Equal(t, "cache.chat-1", cfg.PromptCachePath)
+
+	// a turn is rejected while another one is in flight
+	_, err = continueConversation(conversations, &config.BackendConfig{}, newRequest("Hi again"))
+	assert.ErrorContains(t, err, "already continued")
+	reply := "Hello"
+	turn.save(&schema.Message{Role: "assistant", Content: &reply})
+	turn.end()
+
+	input = newRequest("How are you?")
+	turn, err = continueConversation(conversations, &config.BackendConfig{}, input)
+	assert.NoError(t, err)
+	assert.False(t, turn.created)
+	turn.end()
+	assert.Len(t, input.Messages, 3)
+	assert.Equal(t, "Hello", input.Messages[1].StringContent)
+	assert.Equal(t, "How are you?", input.Messages[2].StringContent)
+
+	input = newRequest("Hi")
+	input.ConversationID = "chat/1"
+	_, err = continueConversation(conversations, &config.BackendConfig{}, input)
+	assert.ErrorContains(t, err, "conversation id")
+
+	turn, err = continueConversation(nil, cfg, &schema.OpenAIRequest{ConversationID: "chat-1"})
+	assert.NoError(t, err)
+	assert.Nil(t, turn)
+}
+
+func TestStreamedReply(t *testing.T) {
+	text, empty := "The weather", ""
+	reply := &streamedReply{}
+	reply.add(&schema.Message{Role: "assistant", Content: &empty})
+	reply.add(&schema.Message{ReasoningContent: "The user asks "})
+	reply.add(&schema.Message{ReasoningContent: "the weather."})
+	reply.add(&schema.Message{Content: &text, ToolCalls: []schema.ToolCall{{Index: 0, ID: "1", Type: "function", FunctionCall: schema.FunctionCall{Name: "weather"}}}})
+	reply.add(&schema.Message{Content: &text, ToolCalls: []schema.ToolCall{{Index: 0, ID: "1", Type: "function", FunctionCall: schema.FunctionCall{Arguments: `{"city":"Rome"}`}}}})
+
+	m := reply.message()
+	assert.Equal(t, "The weather", m.Content)
+	assert.Equal(t, "The user asks the weather.", m.ReasoningContent)
+	assert.Len(t, m.ToolCalls, 1)
+	assert.Equal(t, "weather", m.ToolCalls[0].FunctionCall.Name)
+	assert.Equal(t, `{"city":"Rome"}`, m.ToolCalls[0].FunctionCall.Arguments)
+}
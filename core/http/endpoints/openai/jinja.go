@@ -0,0 +1,81 @@
+package openai
+
+import (
+	"encoding/json"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	model "github.com/mudler/LocalAI/pkg/model"
+)
+
+// templateJinjaChat renders the conversation with the Jinja chat template of the model,
+// passing the variables of the HuggingFace chat templates
+func templateJinjaChat(ml *model.ModelLoader, config *config.BackendConfig, messages []schema.Message, funcs functions.Functions, useFunctions bool) (string, error) {
+	hasSystem := false
+	msgs := []map[string]interface{}{}
+	for _, m := range messages {
+		if m.Role == "system" {
+			hasSystem = true
+		}
+		msg := map[string]interface{}{
+			"role":    m.Role,
+			"content": m.StringContent,
+		}
+		if m.Name != "" {
+			msg["name"] = m.Name
+		}
+		toolCalls := []interface{}{}
+		for _, tc := range m.ToolCalls {
+			toolCalls = append(toolCalls, map[string]interface{}{
+				"id":   tc.ID,
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      tc.FunctionCall.Name,
+					"arguments": jinjaArguments(tc.FunctionCall.Arguments),
+				},
+			})
+		}
+		if len(toolCalls) > 0 {
+			msg["tool_calls"] = toolCalls
+		}
+		msgs = append(msgs, msg)
+	}
+
+	if !hasSystem && config.SystemPrompt != "" {
+		msgs = append([]map[string]interface{}{{"role": "system", "content": config.SystemPrompt}}, msgs...)
+	}
+
+	vars := map[string]interface{}{
+		"messages":              msgs,
+		"add_generation_prompt": true,
+		"bos_token":             config.TemplateConfig.BOSToken,
+		"eos_token":             config.TemplateConfig.EOSToken,
+	}
+	if useFunctions && len(funcs) > 0 {
+		tools := []interface{}{}
+		for _, f := range funcs {
+			tools = append(tools, map[string]interface{}{
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":        f.Name,
+					"description": f.Description,
+					"parameters":  f.Parameters,
+				},
+			})
+		}
+		vars["tools"] = tools
+	}
+
+	return ml.EvaluateJinjaTemplate(config.TemplateConfig.Jinja, vars)
+}
+
+// jinjaArguments passes the JSON arguments of a tool call as such: the templates render them as mappings,
+// in the order of the arguments
+func jinjaArguments(arguments string) interface{} {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return arguments
+	}
+	return json.RawMessage(arguments)
+}
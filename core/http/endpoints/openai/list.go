@@ -42,7 +42,18 @@ func modelList(bcl *config.BackendConfigLoader, ml *model.ModelLoader, filter st
 
 	// Then iterate through the loose files:
 	for _, m := range models {
-		dataModels = append(dataModels, schema.OpenAIModel{ID: m, Object: "model"})
+		dataModel := schema.OpenAIModel{ID: m, Object: "model"}
+
+		// Report the capabilities of the models which have been loaded already
+		modelFile := m
+		if cfg, exists := bcl.GetBackendConfig(m); exists && cfg.Model != "" {
+			modelFile = cfg.Model
+		}
+		if capabilities, known := ml.GetCapabilities(modelFile); known {
+			dataModel.Capabilities = capabilities
+		}
+
+		dataModels = append(dataModels, dataModel)
 	}
 
 	return dataModels, nil
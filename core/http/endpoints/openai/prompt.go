@@ -0,0 +1,74 @@
+package openai
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// ChatPromptEndpoint returns the prompt that a chat completion request would send to the model, without running the inference
+// @Summary Render the prompt of a chat completion request, with its grammar and its tokens.
+// @Param request body schema.OpenAIRequest true "query params"
+// @Success 200 {object} schema.ChatPromptResponse "Response"
+// @Router /v1/chat/completions/prompt [post]
+func ChatPromptEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, conversations *services.ConversationService, startupOptions *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		modelFile, input, err := readRequest(c, cl, ml, startupOptions, true)
+		if err != nil {
+			return fmt.Errorf("failed reading parameters from request:%w", err)
+		}
+		defer input.Cancel()
+
+		config, input, err := mergeRequestWithConfig(modelFile, input, cl, ml, startupOptions.Debug, startupOptions.Threads, startupOptions.ContextSize, startupOptions.F16)
+		if err != nil {
+			return fmt.Errorf("failed reading parameters from request:%w", err)
+		}
+
+		// the history of the conversation is included, but the request is not stored
+		if _, err := withConversationHistory(conversations, config, input); err != nil {
+			return err
+		}
+
+		prompt, trimmed, err := fitContextWindow(cl, ml, startupOptions, config, input)
+		if err != nil {
+			return err
+		}
+		if config.ContextWindow.Enabled() {
+			c.Set("X-Trimmed-Messages", strconv.Itoa(trimmed))
+		}
+
+		// the secrets expanded in the configuration of the model, as in its system prompt, are not returned
+		resp := schema.ChatPromptResponse{
+			Model:           input.Model,
+			Prompt:          utils.RedactSecrets(prompt.Prompt),
+			Grammar:         utils.RedactSecrets(config.Grammar),
+			TrimmedMessages: trimmed,
+		}
+		// without a prompt, the backend renders the messages with the template of its tokenizer
+		if prompt.Prompt == "" && config.TemplateConfig.UseTokenizerTemplate {
+			resp.UseTokenizerTemplate = true
+			return c.JSON(resp)
+		}
+
+		tokens, err := backend.ModelTokenize(prompt.Prompt, ml, *config, startupOptions)
+		if err != nil {
+			log.Debug().Err(err).Str("model", input.Model).Msg("cannot tokenize the prompt")
+			resp.TokenizeError = err.Error()
+			return c.JSON(resp)
+		}
+		// the tokens of a prompt with secrets would give them away
+		if resp.Prompt == prompt.Prompt {
+			resp.Tokens = tokens
+		}
+		resp.TokenCount = len(tokens)
+		return c.JSON(resp)
+	}
+}
@@ -0,0 +1,97 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatPromptEndpoint(t *testing.T) {
+	modelPath := t.TempDir()
+	err := os.WriteFile(filepath.Join(modelPath, "chatml.yaml"), []byte(`name: chatml
+backend: missing-backend
+parameters:
+  model: chatml.gguf
+template:
+  chat_message: |
+    <|im_start|>{{ .RoleName }}
+    {{ .Content }}<|im_end|>
+  chat: |
+    {{ .Input }}
+    <|im_start|>assistant
+`), 0600)
+	assert.NoError(t, err)
+	secrets := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(secrets, "key"), []byte("secret-key-value"), 0600))
+	utils.SetSecretsDir(secrets)
+	defer utils.SetSecretsDir("")
+	assert.NoError(t, os.WriteFile(filepath.Join(modelPath, "secret.yaml"), []byte(`name: secret
+backend: missing-backend
+system_prompt: "The key is ${file:key}"
+parameters:
+  model: secret.gguf
+template:
+  chat_message: "{{ .RoleName }}: {{ .Content }}"
+  chat: "{{ .SystemPrompt }} {{ .Input }}"
+`), 0600))
+
+	option := &config.ApplicationConfig{
+		Context:           context.Background(),
+		ModelPath:         modelPath,
+		AssetsDestination: t.TempDir(),
+	}
+	loader := config.NewBackendConfigLoader(modelPath)
+	assert.NoError(t, loader.LoadBackendConfigsFromPath(modelPath))
+
+	app := fiber.New()
+	app.Post("/v1/chat/completions/prompt", ChatPromptEndpoint(loader, model.NewModelLoader(modelPath), nil, option))
+
+	post := func(body string) schema.ChatPromptResponse {
+		req := httptest.NewRequest("POST", "/v1/chat/completions/prompt", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		res := schema.ChatPromptResponse{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+		return res
+	}
+
+	t.Run("renders the messages with the templates of the model", func(t *testing.T) {
+		res := post(`{"model": "chatml", "messages": [{"role": "system", "content": "Be brief"}, {"role": "user", "content": "Hi"}]}`)
+
+		assert.Equal(t, "chatml", res.Model)
+		assert.Equal(t, "<|im_start|>system\nBe brief<|im_end|>\n\n<|im_start|>user\nHi<|im_end|>\n\n<|im_start|>assistant\n", res.Prompt)
+		assert.Empty(t, res.Grammar)
+		// the backend is not available to tokenize the prompt
+		assert.Empty(t, res.Tokens)
+		assert.NotEmpty(t, res.TokenizeError)
+	})
+
+	t.Run("redacts the secrets of the configuration", func(t *testing.T) {
+		res := post(`{"model": "secret", "messages": [{"role": "user", "content": "Hi"}]}`)
+
+		assert.Contains(t, res.Prompt, "The key is [REDACTED]")
+		assert.NotContains(t, res.Prompt, "secret-key-value")
+	})
+
+	t.Run("returns the grammar of the functions", func(t *testing.T) {
+		res := post(`{"model": "chatml", "messages": [{"role": "user", "content": "Weather in Rome?"}],
+			"tools": [{"type": "function", "function": {"name": "get_weather", "parameters": {"type": "object", "properties": {"city": {"type": "string"}}}}}]}`)
+
+		assert.Contains(t, res.Grammar, "get_weather")
+		assert.Contains(t, res.Prompt, "Weather in Rome?")
+	})
+}
@@ -16,6 +16,8 @@ func RegisterLocalAIRoutes(app *fiber.App,
 	ml *model.ModelLoader,
 	appConfig *config.ApplicationConfig,
 	galleryService *services.GalleryService,
+	modelConfigService *services.ModelConfigService,
+	conversations *services.ConversationService,
 	auth func(*fiber.Ctx) error) {
 
 	app.Get("/swagger/*", swagger.HandlerDefault) // default
@@ -25,16 +27,36 @@ func RegisterLocalAIRoutes(app *fiber.App,
 	modelGalleryEndpointService := localai.CreateModelGalleryEndpointService(appConfig.Galleries, appConfig.ModelPath, galleryService)
 	app.Post("/models/apply", auth, modelGalleryEndpointService.ApplyModelGalleryEndpoint())
 	app.Post("/models/delete/:name", auth, modelGalleryEndpointService.DeleteModelGalleryEndpoint())
+	app.Get("/models/updates", auth, modelGalleryEndpointService.ListModelUpdatesEndpoint())
+	app.Post("/models/upgrade/:name", auth, modelGalleryEndpointService.UpgradeModelGalleryEndpoint())
+	app.Post("/models/rollback/:name", auth, modelGalleryEndpointService.RollbackModelGalleryEndpoint())
+	app.Post("/models/import", auth, localai.ImportModelsEndpoint(cl, appConfig))
+	app.Get("/models/events", auth, localai.ModelEventsEndpoint(cl))
+
+	app.Get("/models/config/:name", auth, localai.GetModelConfigEndpoint(modelConfigService))
+	app.Put("/models/config/:name", auth, localai.PutModelConfigEndpoint(modelConfigService))
+	app.Patch("/models/config/:name", auth, localai.PatchModelConfigEndpoint(modelConfigService))
+	app.Delete("/models/config/:name", auth, localai.DeleteModelConfigEndpoint(modelConfigService))
 
 	app.Get("/models/available", auth, modelGalleryEndpointService.ListModelFromGalleryEndpoint())
 	app.Get("/models/galleries", auth, modelGalleryEndpointService.ListModelGalleriesEndpoint())
 	app.Post("/models/galleries", auth, modelGalleryEndpointService.AddModelGalleryEndpoint())
 	app.Delete("/models/galleries", auth, modelGalleryEndpointService.RemoveModelGalleryEndpoint())
+	app.Post("/models/galleries/refresh", auth, modelGalleryEndpointService.RefreshModelGalleriesEndpoint())
 	app.Get("/models/jobs/:uuid", auth, modelGalleryEndpointService.GetOpStatusEndpoint())
+	app.Post("/models/jobs/:uuid/cancel", auth, modelGalleryEndpointService.CancelOpEndpoint())
 	app.Get("/models/jobs", auth, modelGalleryEndpointService.GetAllStatusEndpoint())
 
 	app.Post("/tts", auth, localai.TTSEndpoint(cl, ml, appConfig))
 
+	app.Post("/v1/tokenize", auth, localai.TokenizeEndpoint(cl, ml, appConfig))
+	app.Post("/v1/detokenize", auth, localai.DetokenizeEndpoint(cl, ml, appConfig))
+
+	app.Get("/v1/conversations", auth, localai.ListConversationsEndpoint(conversations))
+	app.Get("/v1/conversations/:id", auth, localai.GetConversationEndpoint(conversations))
+	app.Post("/v1/conversations/:id/fork", auth, localai.ForkConversationEndpoint(conversations))
+	app.Delete("/v1/conversations/:id", auth, localai.DeleteConversationEndpoint(conversations))
+
 	// Stores
 	sl := model.NewModelLoader("")
 	app.Post("/stores/set", auth, localai.StoresSetEndpoint(sl, appConfig))
@@ -56,6 +78,9 @@ func RegisterLocalAIRoutes(app *fiber.App,
 	backendMonitorService := services.NewBackendMonitorService(ml, cl, appConfig) // Split out for now
 	app.Get("/backend/monitor", auth, localai.BackendMonitorEndpoint(backendMonitorService))
 	app.Post("/backend/shutdown", auth, localai.BackendShutdownEndpoint(backendMonitorService))
+	app.Get("/backend/logs/:model", auth, localai.BackendLogsEndpoint(cl, ml))
+
+	app.Get("/system/doctor", auth, localai.DoctorEndpoint(appConfig))
 
 	// p2p
 	if p2p.IsP2PEnabled() {
@@ -5,6 +5,7 @@ import (
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/http/endpoints/localai"
 	"github.com/mudler/LocalAI/core/http/endpoints/openai"
+	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/pkg/model"
 )
 
@@ -12,12 +13,14 @@ func RegisterOpenAIRoutes(app *fiber.App,
 	cl *config.BackendConfigLoader,
 	ml *model.ModelLoader,
 	appConfig *config.ApplicationConfig,
+	conversations *services.ConversationService,
 	auth func(*fiber.Ctx) error) {
 	// openAI compatible API endpoint
 
 	// chat
-	app.Post("/v1/chat/completions", auth, openai.ChatEndpoint(cl, ml, appConfig))
-	app.Post("/chat/completions", auth, openai.ChatEndpoint(cl, ml, appConfig))
+	app.Post("/v1/chat/completions", auth, openai.ChatEndpoint(cl, ml, conversations, appConfig))
+	app.Post("/chat/completions", auth, openai.ChatEndpoint(cl, ml, conversations, appConfig))
+	app.Post("/v1/chat/completions/prompt", auth, openai.ChatPromptEndpoint(cl, ml, conversations, appConfig))
 
 	// edit
 	app.Post("/v1/edits", auth, openai.EditEndpoint(cl, ml, appConfig))
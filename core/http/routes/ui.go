@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
 	"sort"
@@ -26,6 +27,7 @@ func RegisterUIRoutes(app *fiber.App,
 	ml *model.ModelLoader,
 	appConfig *config.ApplicationConfig,
 	galleryService *services.GalleryService,
+	modelConfigService *services.ModelConfigService,
 	auth func(*fiber.Ctx) error) {
 
 	// keeps the state of models that are being installed from the UI
@@ -88,7 +90,7 @@ func RegisterUIRoutes(app *fiber.App,
 	app.Get("/browse", auth, func(c *fiber.Ctx) error {
 		term := c.Query("term")
 
-		models, _ := gallery.AvailableGalleryModels(appConfig.Galleries, appConfig.ModelPath)
+		models, _ := galleryService.AvailableModels(appConfig.Galleries)
 
 		// Get all available tags
 		allTags := map[string]struct{}{}
@@ -138,7 +140,7 @@ func RegisterUIRoutes(app *fiber.App,
 			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 		}
 
-		models, _ := gallery.AvailableGalleryModels(appConfig.Galleries, appConfig.ModelPath)
+		models, _ := galleryService.AvailableModels(appConfig.Galleries)
 
 		return c.SendString(elements.ListModels(gallery.GalleryModels(models).Search(form.Search), processingModels, galleryService))
 	})
@@ -169,9 +171,7 @@ func RegisterUIRoutes(app *fiber.App,
 			GalleryModelName: galleryID,
 			Galleries:        appConfig.Galleries,
 		}
-		go func() {
-			galleryService.C <- op
-		}()
+		galleryService.Enqueue(op)
 
 		return c.SendString(elements.StartProgressBar(uid, "0", "Installation"))
 	})
@@ -207,10 +207,8 @@ func RegisterUIRoutes(app *fiber.App,
 			Delete:           true,
 			GalleryModelName: galleryName,
 		}
-		go func() {
-			galleryService.C <- op
-			cl.RemoveBackendConfig(galleryName)
-		}()
+		galleryService.Enqueue(op)
+		cl.RemoveBackendConfig(galleryName)
 
 		return c.SendString(elements.StartProgressBar(uid, "0", "Deletion"))
 	})
@@ -395,4 +393,39 @@ func RegisterUIRoutes(app *fiber.App,
 		// Render index
 		return c.Render("views/tts", summary)
 	})
+
+	app.Get("/logs/:model", auth, func(c *fiber.Ctx) error {
+		backendConfigs := cl.GetAllBackendConfigs()
+
+		summary := fiber.Map{
+			"Title":        "LocalAI - Backend logs of " + c.Params("model"),
+			"ModelsConfig": backendConfigs,
+			"Model":        c.Params("model"),
+			"Version":      internal.PrintableVersion(),
+			"IsP2PEnabled": p2p.IsP2PEnabled(),
+		}
+
+		// Render index
+		return c.Render("views/logs", summary)
+	})
+
+	app.Get("/models/edit/:name", auth, func(c *fiber.Ctx) error {
+		name := c.Params("name")
+		content, err := modelConfigService.File(name)
+		if err != nil && !errors.Is(err, services.ErrModelConfigReadOnly) {
+			return c.Status(fiber.StatusNotFound).Render("views/404", fiber.Map{})
+		}
+
+		summary := fiber.Map{
+			"Title":        "LocalAI - Edit the configuration of " + name,
+			"Model":        name,
+			"Config":       content,
+			"ReadOnly":     err != nil,
+			"Version":      internal.PrintableVersion(),
+			"IsP2PEnabled": p2p.IsP2PEnabled(),
+		}
+
+		// Render index
+		return c.Render("views/model-editor", summary)
+	})
 }
@@ -41,6 +41,53 @@ function submitSystemPrompt(event) {
 
 var image = "";
 
+function deleteConversation(conversationId) {
+  fetch(`/v1/conversations/${conversationId}`, {
+    method: "DELETE",
+    headers: {
+      Authorization: `Bearer ${localStorage.getItem("key")}`,
+    },
+  }).catch((error) => console.error("Failed to delete the conversation:", error));
+}
+
+// requestMessages returns the messages of the chat in the format of the API
+function requestMessages(history, systemPrompt) {
+  const messages = history.map((message) => ({ ...message }));
+
+  // if systemPrompt isn't empty, push it at the start of the messages
+  if (systemPrompt) {
+    messages.unshift({
+      role: "system",
+      content: systemPrompt
+    });
+  }
+
+  // loop all messages, and check if there are images. If there are, we need to change the content field
+  messages.forEach((message) => {
+    if (message.image) {
+      // The content field now becomes an array
+      message.content = [
+        {
+          "type": "text",
+          "text": message.content
+        }
+      ]
+      message.content.push(
+        {
+          "type": "image_url",
+          "image_url": {
+            "url": message.image,
+          }
+        }
+      );
+
+      // remove the image field
+      delete message.image;
+    }
+  });
+  return messages;
+}
+
 function submitPrompt(event) {
   event.preventDefault();
 
@@ -76,39 +123,10 @@ function readInputImage() {
     document.getElementById("input").disabled = true;
     document.getElementById('messages').scrollIntoView(false)
 
-    messages = Alpine.store("chat").messages();
-
-    // if systemPrompt isn't empty, push it at the start of messages
-    if (systemPrompt) {
-      messages.unshift({
-        role: "system",
-        content: systemPrompt
-      });
-    }
-
-    // loop all messages, and check if there are images. If there are, we need to change the content field
-    messages.forEach((message) => {
-      if (message.image) {
-        // The content field now becomes an array
-        message.content = [
-          {
-            "type": "text",
-            "text": message.content
-          }
-        ]
-        message.content.push(
-          {
-            "type": "image_url",
-            "image_url": {
-              "url": message.image,
-            }
-          }
-        );
-
-        // remove the image field
-        delete message.image;
-      }
-    });
+    // the previous messages are stored on the server with the conversation, only the new one is sent
+    const history = Alpine.store("chat").messages();
+    // the system prompt is sent at the start of the conversation
+    messages = requestMessages(history.slice(-1), history.length === 1 ? systemPrompt : "");
 
        // reset the form and the image
        image = "";
@@ -143,7 +161,7 @@ function readInputImage() {
     // }
 
     // Source: https://stackoverflow.com/a/75751803/11386095
-    const response = await fetch("/v1/chat/completions", {
+    const send = (messages) => fetch("/v1/chat/completions", {
       method: "POST",
       headers: {
         Authorization: `Bearer ${key}`,
@@ -152,9 +170,22 @@ function readInputImage() {
       body: JSON.stringify({
         model: model,
         messages: messages,
+        conversation_id: Alpine.store("chat").conversationId,
         stream: true,
       }),
     });
+    const conversationId = Alpine.store("chat").conversationId;
+    let response = await send(messages);
+
+    // the server does not have the history of the conversation, as when it expired:
+    // a new conversation is started with the full history
+    if (response.ok && history.length > 1 &&
+      (response.headers.get("X-Conversation-Id") !== conversationId || response.headers.get("X-Conversation-New") === "true")) {
+      await response.body?.cancel();
+      deleteConversation(conversationId);
+      Alpine.store("chat").restart();
+      response = await send(requestMessages(history, systemPrompt));
+    }
 
     if (!response.ok) {
       Alpine.store("chat").add(
@@ -187,6 +218,7 @@ function readInputImage() {
 
     let buffer = "";
     let contentBuffer = [];
+    let reasoningBuffer = [];
 
     try {
       while (true) {
@@ -208,7 +240,11 @@ function readInputImage() {
             try {
               const jsonData = JSON.parse(line.substring(6));
               const token = jsonData.choices[0].delta.content;
+              const reasoning = jsonData.choices[0].delta.reasoning_content;
 
+              if (reasoning) {
+                reasoningBuffer.push(reasoning);
+              }
               if (token) {
                 contentBuffer.push(token);
               }
@@ -218,7 +254,11 @@ function readInputImage() {
           }
         });
 
-        // Efficiently update the chat in batch
+        // Efficiently update the chat in batch, the reasoning coming before the reply
+        if (reasoningBuffer.length > 0) {
+          Alpine.store("chat").add("reasoning", reasoningBuffer.join(""));
+          reasoningBuffer = [];
+        }
         if (contentBuffer.length > 0) {
           addToChat(contentBuffer.join(""));
           contentBuffer = [];
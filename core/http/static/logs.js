@@ -0,0 +1,70 @@
+function submitKey(event) {
+  event.preventDefault();
+  localStorage.setItem("key", document.getElementById("apiKey").value);
+  document.getElementById("apiKey").blur();
+  followLogs();
+}
+
+let logsController = null;
+
+function appendLine(line) {
+  const logs = document.getElementById("logs");
+  const atBottom = logs.scrollTop + logs.clientHeight >= logs.scrollHeight - 10;
+  const div = document.createElement("div");
+  if (line.stream === "stderr") {
+    div.className = "text-yellow-200";
+  }
+  div.textContent = new Date(line.time).toLocaleTimeString() + " " + line.text;
+  logs.appendChild(div);
+  if (atBottom) {
+    logs.scrollTop = logs.scrollHeight;
+  }
+}
+
+async function followLogs() {
+  if (logsController) {
+    logsController.abort();
+  }
+  logsController = new AbortController();
+
+  const model = document.getElementById("logs-model").value;
+  const key = localStorage.getItem("key");
+  document.getElementById("logs").innerHTML = "";
+
+  // EventSource does not allow to set the Authorization header, read the stream with fetch instead
+  const response = await fetch("/backend/logs/" + encodeURIComponent(model) + "?follow=true", {
+    headers: {
+      Authorization: `Bearer ${key}`,
+    },
+    signal: logsController.signal,
+  });
+  if (!response.ok) {
+    const logs = document.getElementById("logs");
+    logs.innerHTML = '<p style="color:red;">Error: ' + response.statusText + '</p>';
+    return;
+  }
+
+  const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+  let buffer = "";
+  while (true) {
+    const { value, done } = await reader.read();
+    if (done) break;
+    buffer += value;
+    const events = buffer.split("\n\n");
+    buffer = events.pop();
+    for (const event of events) {
+      if (event.startsWith("data: ")) {
+        appendLine(JSON.parse(event.substring(6)));
+      }
+    }
+  }
+}
+
+document.getElementById("key").addEventListener("submit", submitKey);
+
+const storeKey = localStorage.getItem("key");
+if (storeKey) {
+  document.getElementById("apiKey").value = storeKey;
+}
+
+followLogs();
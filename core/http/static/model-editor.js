@@ -0,0 +1,43 @@
+function submitKey(event) {
+  event.preventDefault();
+  localStorage.setItem("key", document.getElementById("apiKey").value);
+  document.getElementById("apiKey").blur();
+}
+
+function setStatus(text, error) {
+  const status = document.getElementById("editor-status");
+  status.className = "mb-4 text-sm " + (error ? "text-red-400" : "text-green-400");
+  status.textContent = text;
+}
+
+async function saveConfig() {
+  const model = document.getElementById("editor-model").value;
+  const key = localStorage.getItem("key");
+
+  const response = await fetch("/models/config/" + encodeURIComponent(model), {
+    method: "PUT",
+    headers: {
+      Authorization: `Bearer ${key}`,
+      "Content-Type": "application/yaml",
+    },
+    body: document.getElementById("editor").value,
+  });
+  if (!response.ok) {
+    const body = await response.json().catch(() => ({}));
+    setStatus("Error: " + (body.error && body.error.message ? body.error.message : response.statusText), true);
+    return;
+  }
+  setStatus("Saved at " + new Date().toLocaleTimeString() + ": the model is reloaded when its requests in flight are completed", false);
+}
+
+document.getElementById("key").addEventListener("submit", submitKey);
+
+const save = document.getElementById("save");
+if (save) {
+  save.addEventListener("click", saveConfig);
+}
+
+const storeKey = localStorage.getItem("key");
+if (storeKey) {
+  document.getElementById("apiKey").value = storeKey;
+}
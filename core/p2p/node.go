@@ -7,11 +7,21 @@ import (
 
 const defaultServicesID = "services_localai"
 
+// BackendWorkerID is the service ID used by workers exposing
+// a generic gRPC backend (e.g. whisper, piper) over the p2p network
+const BackendWorkerID = "backend_workers"
+
+// BackendPrefix namespaces the backends of the workers, so that they do not
+// replace the local backends with the same name
+const BackendPrefix = "p2p-"
+
 type NodeData struct {
 	Name          string
 	ID            string
 	TunnelAddress string
 	LastSeen      time.Time
+	// Backend is the name of the gRPC backend served by the node, if any
+	Backend string
 }
 
 func (d NodeData) IsOnline() bool {
@@ -47,3 +57,26 @@ func AddNode(serviceID string, node NodeData) {
 	}
 	nodes[serviceID][node.ID] = node
 }
+
+// GetAvailableBackends returns the gRPC backends exposed by the online backend workers,
+// keyed by backend name prefixed with BackendPrefix, pointing to the local tunnel address.
+// When several workers serve the same backend, the one with the lowest node ID is used, so
+// that the choice does not change between calls while that worker stays online
+func GetAvailableBackends() map[string]string {
+	workers := map[string]NodeData{}
+	for _, n := range GetAvailableNodes(BackendWorkerID) {
+		if n.Backend == "" || n.TunnelAddress == "" || !n.IsOnline() {
+			continue
+		}
+		name := BackendPrefix + n.Backend
+		if w, ok := workers[name]; ok && w.ID < n.ID {
+			continue
+		}
+		workers[name] = n
+	}
+	backends := map[string]string{}
+	for name, n := range workers {
+		backends[name] = n.TunnelAddress
+	}
+	return backends
+}
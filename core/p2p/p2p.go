@@ -273,6 +273,17 @@ func ensureService(ctx context.Context, n *node.Node, nd *NodeData, sserv string
 
 // This is the P2P worker main
 func ExposeService(ctx context.Context, host, port, token, servicesID string) error {
+	return exposeService(ctx, host, port, token, servicesID, "")
+}
+
+// ExposeBackendService exposes a gRPC backend running on host:port to the p2p network.
+// The backend name is announced along with the node, so the main instance can
+// register it as an external gRPC backend
+func ExposeBackendService(ctx context.Context, host, port, token, backend string) error {
+	return exposeService(ctx, host, port, token, BackendWorkerID, backend)
+}
+
+func exposeService(ctx context.Context, host, port, token, servicesID, backend string) error {
 	if servicesID == "" {
 		servicesID = defaultServicesID
 	}
@@ -316,6 +327,7 @@ func ExposeService(ctx context.Context, host, port, token, servicesID string) er
 				Name:     name,
 				LastSeen: time.Now(),
 				ID:       nodeID(name),
+				Backend:  backend,
 			}
 			ledger.Add(servicesID, updatedMap)
 			//	}
@@ -26,6 +26,10 @@ func ExposeService(ctx context.Context, host, port, token, servicesID string) er
 	return fmt.Errorf("not implemented")
 }
 
+func ExposeBackendService(ctx context.Context, host, port, token, backend string) error {
+	return fmt.Errorf("not implemented")
+}
+
 func IsP2PEnabled() bool {
 	return false
 }
@@ -1,6 +1,8 @@
 package schema
 
 import (
+	"time"
+
 	"github.com/mudler/LocalAI/core/p2p"
 	gopsutil "github.com/shirou/gopsutil/v3/process"
 )
@@ -15,11 +17,26 @@ type BackendMonitorResponse struct {
 	CPUPercent    float64
 }
 
+type BackendLogLine struct {
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"`
+	Text   string    `json:"text"`
+}
+
+type BackendLogsResponse struct {
+	Model string           `json:"model"`
+	Lines []BackendLogLine `json:"lines"`
+}
+
 type GalleryResponse struct {
 	ID        string `json:"uuid"`
 	StatusURL string `json:"status"`
 }
 
+type ModelsImportResponse struct {
+	Models []string `json:"models"`
+}
+
 // @Description TTS request body
 type TTSRequest struct {
 	Model    string `json:"model" yaml:"model"` // model name or full path
@@ -29,6 +46,73 @@ type TTSRequest struct {
 	Language string `json:"language,omitempty" yaml:"language,omitempty"` // (optional) language to use with TTS model
 }
 
+// Conversation is a chat history stored on the server
+type Conversation struct {
+	ID           string    `json:"id"`
+	Object       string    `json:"object"`
+	Model        string    `json:"model"`
+	Created      int64     `json:"created"`
+	Updated      int64     `json:"updated"`
+	ForkedFrom   string    `json:"forked_from,omitempty"`
+	MessageCount int       `json:"message_count"`
+	Messages     []Message `json:"messages,omitempty"`
+	// PromptCache is the prompt cache file of the conversation, relative to the models path
+	PromptCache string `json:"prompt_cache,omitempty"`
+}
+
+type ConversationListResponse struct {
+	Object string         `json:"object"`
+	Data   []Conversation `json:"data"`
+}
+
+// @Description Fork request body
+type ConversationForkRequest struct {
+	// Messages is the number of messages of the conversation copied in the fork, all of them if 0
+	Messages int `json:"messages"`
+}
+
+type ConversationDeleteResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
+
+// @Description Tokenize request body
+type TokenizeRequest struct {
+	Model   string `json:"model" yaml:"model"`
+	Content string `json:"content" yaml:"content"`
+}
+
+type TokenizeResponse struct {
+	Tokens []int32 `json:"tokens" yaml:"tokens"`
+	Count  int     `json:"count" yaml:"count"`
+}
+
+// @Description Detokenize request body
+type DetokenizeRequest struct {
+	Model  string  `json:"model" yaml:"model"`
+	Tokens []int32 `json:"tokens" yaml:"tokens"`
+}
+
+type DetokenizeResponse struct {
+	Content string `json:"content" yaml:"content"`
+}
+
+// ChatPromptResponse is the prompt built from a chat request, without running the inference
+type ChatPromptResponse struct {
+	Model string `json:"model"`
+	// Prompt is empty when the messages are rendered by the backend with the template of the tokenizer
+	Prompt               string  `json:"prompt"`
+	UseTokenizerTemplate bool    `json:"use_tokenizer_template,omitempty"`
+	Grammar              string  `json:"grammar,omitempty"`
+	Tokens               []int32 `json:"tokens,omitempty"`
+	TokenCount           int     `json:"token_count,omitempty"`
+	// TrimmedMessages is the number of messages trimmed by the context window strategy of the model
+	TrimmedMessages int `json:"trimmed_messages,omitempty"`
+	// TokenizeError is set when the backend could not tokenize the prompt
+	TokenizeError string `json:"tokenize_error,omitempty"`
+}
+
 type StoresSet struct {
 	Store string `json:"store,omitempty" yaml:"store,omitempty"`
 
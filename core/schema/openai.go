@@ -74,6 +74,9 @@ type Message struct {
 	// The message content
 	Content interface{} `json:"content" yaml:"content"`
 
+	// The reasoning of the model, separated from the content of its reply
+	ReasoningContent string `json:"reasoning_content,omitempty" yaml:"reasoning_content,omitempty"`
+
 	StringContent string   `json:"string_content,omitempty" yaml:"string_content,omitempty"`
 	StringImages  []string `json:"string_images,omitempty" yaml:"string_images,omitempty"`
 
@@ -98,6 +101,8 @@ type FunctionCall struct {
 type OpenAIModel struct {
 	ID     string `json:"id"`
 	Object string `json:"object"`
+	// Capabilities advertised by the backend of the model, if loaded
+	Capabilities []string `json:"capabilities,omitempty"`
 }
 
 type DeleteAssistantResponse struct {
@@ -163,6 +168,9 @@ type OpenAIRequest struct {
 	// Messages is read only by chat/completion API calls
 	Messages []Message `json:"messages" yaml:"messages"`
 
+	// ConversationID continues a conversation stored on the server (not supported by OpenAI)
+	ConversationID string `json:"conversation_id,omitempty" yaml:"conversation_id"`
+
 	// A list of available functions to call
 	Functions    functions.Functions `json:"functions" yaml:"functions"`
 	FunctionCall interface{}         `json:"function_call" yaml:"function_call"` // might be a string or an object
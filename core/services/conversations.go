@@ -0,0 +1,345 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrConversationNotFound    = errors.New("conversation not found")
+	ErrInvalidConversationID   = errors.New("the conversation id must be made of letters, digits, '-' and '_', up to 128 characters")
+	ErrInvalidConversationFork = errors.New("invalid number of messages to fork")
+	ErrConversationBusy        = errors.New("the conversation is already continued by another request")
+)
+
+var conversationIDRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,128}$`)
+
+// ConversationService stores the chat histories of the conversations on the server, one JSON file per
+// conversation in the conversations directory of the configs path. The conversations are deleted when
+// they are not continued for longer than their TTL
+type ConversationService struct {
+	dir       string
+	modelPath string
+	ttl       time.Duration
+
+	mu sync.Mutex
+	// the conversations with a turn in flight
+	inFlight map[string]bool
+}
+
+func NewConversationService(appConfig *config.ApplicationConfig) *ConversationService {
+	return &ConversationService{
+		dir:       filepath.Join(appConfig.ConfigsDir, "conversations"),
+		modelPath: appConfig.ModelPath,
+		ttl:       appConfig.ConversationsTTL,
+		inFlight:  map[string]bool{},
+	}
+}
+
+// Start deletes the conversations expired and their prompt cache, at startup and then periodically
+// until the context is done
+func (s *ConversationService) Start(ctx context.Context) {
+	if s.ttl <= 0 {
+		return
+	}
+	interval := min(s.ttl, time.Hour)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.sweep()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Begin starts a turn of a conversation, and returns the function ending it. A conversation has one turn at
+// a time, as a turn stores its messages after the history read at its start
+func (s *ConversationService) Begin(id string) (func(), error) {
+	if !conversationIDRegex.MatchString(id) {
+		return nil, ErrInvalidConversationID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inFlight[id] {
+		return nil, ErrConversationBusy
+	}
+	s.inFlight[id] = true
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.inFlight, id)
+			s.mu.Unlock()
+		})
+	}, nil
+}
+
+// ConversationPromptCache returns the prompt cache file of a conversation, so that the conversations of
+// a model with a prompt cache do not overwrite each other's cache
+func ConversationPromptCache(promptCachePath, id string) string {
+	return promptCachePath + "." + id
+}
+
+// Get returns a conversation with its messages
+func (s *ConversationService) Get(id string) (*schema.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(id)
+}
+
+// List returns the conversations, without their messages, the most recent first
+func (s *ConversationService) List() ([]schema.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	conversations := []schema.Conversation{}
+	for _, id := range ids {
+		c, err := s.read(id)
+		if err != nil {
+			if !errors.Is(err, ErrConversationNotFound) {
+				log.Warn().Err(err).Str("conversation", id).Msg("cannot read the conversation")
+			}
+			continue
+		}
+		c.Messages = nil
+		conversations = append(conversations, *c)
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].Updated > conversations[j].Updated
+	})
+	return conversations, nil
+}
+
+// Append adds messages to a conversation, creating it if it does not exist
+func (s *ConversationService) Append(id, model, promptCache string, messages ...schema.Message) (*schema.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.read(id)
+	switch {
+	case errors.Is(err, ErrConversationNotFound):
+		c = &schema.Conversation{ID: id, Object: "conversation", Created: time.Now().Unix()}
+	case err != nil:
+		return nil, err
+	}
+	c.Model = model
+	if promptCache != "" {
+		c.PromptCache = promptCache
+	}
+	c.Messages = append(c.Messages, messages...)
+	if err := s.write(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Fork copies the first messages of a conversation, all of them if messages is 0, into a new conversation.
+// The new conversation starts with a copy of the prompt cache, whose prefix is shared
+func (s *ConversationService) Fork(id string, messages int) (*schema.Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	if messages < 0 || messages > len(c.Messages) {
+		return nil, fmt.Errorf("%w: the conversation has %d messages", ErrInvalidConversationFork, len(c.Messages))
+	}
+	if messages == 0 {
+		messages = len(c.Messages)
+	}
+
+	fork := &schema.Conversation{
+		ID:         "conv_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Object:     "conversation",
+		Model:      c.Model,
+		Created:    time.Now().Unix(),
+		ForkedFrom: c.ID,
+		Messages:   append([]schema.Message{}, c.Messages[:messages]...),
+	}
+	if c.PromptCache != "" {
+		fork.PromptCache = ConversationPromptCache(strings.TrimSuffix(c.PromptCache, "."+c.ID), fork.ID)
+		if err := s.copyPromptCache(c.PromptCache, fork.PromptCache); err != nil {
+			log.Warn().Err(err).Str("conversation", c.ID).Msg("cannot copy the prompt cache of the conversation")
+			fork.PromptCache = ""
+		}
+	}
+	if err := s.write(fork); err != nil {
+		return nil, err
+	}
+	return fork, nil
+}
+
+// Delete removes a conversation and its prompt cache
+func (s *ConversationService) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.read(id)
+	if err != nil {
+		return err
+	}
+	return s.remove(c)
+}
+
+// sweep reads the conversations without a turn in flight, removing the ones expired
+func (s *ConversationService) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, err := s.ids()
+	if err != nil {
+		log.Warn().Err(err).Msg("cannot list the conversations")
+		return
+	}
+	for _, id := range ids {
+		if s.inFlight[id] {
+			continue
+		}
+		if _, err := s.read(id); err != nil && !errors.Is(err, ErrConversationNotFound) {
+			log.Warn().Err(err).Str("conversation", id).Msg("cannot read the conversation")
+		}
+	}
+}
+
+// ids returns the ids of the conversations stored
+func (s *ConversationService) ids() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	ids := []string{}
+	for _, entry := range entries {
+		id, isConversation := strings.CutSuffix(entry.Name(), ".json")
+		if !entry.IsDir() && isConversation {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *ConversationService) file(id string) (string, error) {
+	if !conversationIDRegex.MatchString(id) {
+		return "", ErrInvalidConversationID
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// read loads a conversation, removing it if it expired
+func (s *ConversationService) read(id string) (*schema.Conversation, error) {
+	file, err := s.file(id)
+	if err != nil {
+		return nil, err
+	}
+	dat, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &schema.Conversation{}
+	if err := json.Unmarshal(dat, c); err != nil {
+		return nil, fmt.Errorf("cannot parse the conversation %s: %w", id, err)
+	}
+
+	if s.ttl > 0 && time.Since(time.Unix(c.Updated, 0)) > s.ttl {
+		log.Debug().Str("conversation", id).Msg("conversation expired")
+		if err := s.remove(c); err != nil {
+			log.Warn().Err(err).Str("conversation", id).Msg("cannot remove the conversation expired")
+		}
+		return nil, ErrConversationNotFound
+	}
+	return c, nil
+}
+
+// write saves a conversation atomically
+func (s *ConversationService) write(c *schema.Conversation) error {
+	file, err := s.file(c.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+
+	c.Updated = time.Now().Unix()
+	c.MessageCount = len(c.Messages)
+	dat, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, dat, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func (s *ConversationService) remove(c *schema.Conversation) error {
+	if c.PromptCache != "" && utils.VerifyPath(c.PromptCache, s.modelPath) == nil {
+		if err := os.Remove(filepath.Join(s.modelPath, c.PromptCache)); err != nil && !os.IsNotExist(err) {
+			log.Warn().Err(err).Str("conversation", c.ID).Msg("cannot remove the prompt cache of the conversation")
+		}
+	}
+	file, err := s.file(c.ID)
+	if err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+func (s *ConversationService) copyPromptCache(from, to string) error {
+	if err := utils.VerifyPath(from, s.modelPath); err != nil {
+		return err
+	}
+	if err := utils.VerifyPath(to, s.modelPath); err != nil {
+		return err
+	}
+	src, err := os.Open(filepath.Join(s.modelPath, from))
+	if os.IsNotExist(err) {
+		// the model did not write the cache yet
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(filepath.Join(s.modelPath, to), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,160 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	. "github.com/mudler/LocalAI/core/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConversationService", func() {
+	var tempdir string
+	var cs *ConversationService
+
+	message := func(role, content string) schema.Message {
+		return schema.Message{Role: role, Content: content, StringContent: content}
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempdir, err = os.MkdirTemp("", "conversations")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Join(tempdir, "models"), 0750)).To(Succeed())
+
+		cs = NewConversationService(config.NewApplicationConfig(
+			config.WithModelPath(filepath.Join(tempdir, "models")),
+			config.WithConfigsDir(filepath.Join(tempdir, "configs")),
+			config.WithConversationsTTL(time.Hour),
+		))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempdir)
+	})
+
+	It("creates and continues a conversation", func() {
+		_, err := cs.Get("chat-1")
+		Expect(err).To(MatchError(ErrConversationNotFound))
+
+		_, err = cs.Append("chat-1", "llama", "", message("user", "Hi"), message("assistant", "Hello"))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = cs.Append("chat-1", "llama", "", message("user", "How are you?"))
+		Expect(err).ToNot(HaveOccurred())
+
+		c, err := cs.Get("chat-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Model).To(Equal("llama"))
+		Expect(c.MessageCount).To(Equal(3))
+		Expect(c.Messages[2].StringContent).To(Equal("How are you?"))
+
+		conversations, err := cs.List()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conversations).To(HaveLen(1))
+		Expect(conversations[0].MessageCount).To(Equal(3))
+		Expect(conversations[0].Messages).To(BeEmpty())
+	})
+
+	It("rejects the invalid ids", func() {
+		_, err := cs.Append("../models/x", "llama", "", message("user", "Hi"))
+		Expect(err).To(MatchError(ErrInvalidConversationID))
+		Expect(filepath.Join(tempdir, "models", "x.json")).ToNot(BeAnExistingFile())
+	})
+
+	It("deletes the conversations expired", func() {
+		_, err := cs.Append("chat-1", "llama", "", message("user", "Hi"))
+		Expect(err).ToNot(HaveOccurred())
+
+		file := filepath.Join(tempdir, "configs", "conversations", "chat-1.json")
+		c := schema.Conversation{}
+		dat, err := os.ReadFile(file)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(json.Unmarshal(dat, &c)).To(Succeed())
+		c.Updated = time.Now().Add(-2 * time.Hour).Unix()
+		dat, err = json.Marshal(c)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(file, dat, 0600)).To(Succeed())
+
+		conversations, err := cs.List()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conversations).To(BeEmpty())
+		Expect(file).ToNot(BeAnExistingFile())
+	})
+
+	It("deletes the conversations expired and their prompt cache periodically", func() {
+		promptCache := ConversationPromptCache("llama", "chat-1")
+		Expect(os.WriteFile(filepath.Join(tempdir, "models", promptCache), []byte("kv"), 0600)).To(Succeed())
+		_, err := cs.Append("chat-1", "llama", promptCache, message("user", "Hi"))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = cs.Append("chat-2", "llama", "", message("user", "Hi"))
+		Expect(err).ToNot(HaveOccurred())
+
+		file := filepath.Join(tempdir, "configs", "conversations", "chat-1.json")
+		c := schema.Conversation{}
+		dat, err := os.ReadFile(file)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(json.Unmarshal(dat, &c)).To(Succeed())
+		c.Updated = time.Now().Add(-2 * time.Hour).Unix()
+		dat, err = json.Marshal(c)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(file, dat, 0600)).To(Succeed())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cs.Start(ctx)
+		Eventually(file).ShouldNot(BeAnExistingFile())
+		Expect(filepath.Join(tempdir, "models", promptCache)).ToNot(BeAnExistingFile())
+		Expect(filepath.Join(tempdir, "configs", "conversations", "chat-2.json")).To(BeAnExistingFile())
+	})
+
+	It("has one turn at a time per conversation", func() {
+		end, err := cs.Begin("chat-1")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = cs.Begin("chat-1")
+		Expect(err).To(MatchError(ErrConversationBusy))
+		endOther, err := cs.Begin("chat-2")
+		Expect(err).ToNot(HaveOccurred())
+		endOther()
+
+		end()
+		end()
+		end, err = cs.Begin("chat-1")
+		Expect(err).ToNot(HaveOccurred())
+		end()
+
+		_, err = cs.Begin("../chat")
+		Expect(err).To(MatchError(ErrInvalidConversationID))
+	})
+
+	It("forks a conversation with its prompt cache", func() {
+		promptCache := ConversationPromptCache("cache/llama", "chat-1")
+		Expect(os.MkdirAll(filepath.Join(tempdir, "models", "cache"), 0750)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(tempdir, "models", promptCache), []byte("kv"), 0600)).To(Succeed())
+		_, err := cs.Append("chat-1", "llama", promptCache, message("user", "Hi"), message("assistant", "Hello"), message("user", "Bye"))
+		Expect(err).ToNot(HaveOccurred())
+
+		fork, err := cs.Fork("chat-1", 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fork.ID).ToNot(Equal("chat-1"))
+		Expect(fork.ForkedFrom).To(Equal("chat-1"))
+		Expect(fork.Messages).To(HaveLen(2))
+		Expect(fork.PromptCache).To(Equal("cache/llama." + fork.ID))
+		Expect(filepath.Join(tempdir, "models", fork.PromptCache)).To(BeAnExistingFile())
+
+		_, err = cs.Fork("chat-1", 4)
+		Expect(err).To(MatchError(ErrInvalidConversationFork))
+
+		Expect(cs.Delete("chat-1")).To(Succeed())
+		Expect(filepath.Join(tempdir, "models", promptCache)).ToNot(BeAnExistingFile())
+		_, err = cs.Get("chat-1")
+		Expect(err).To(MatchError(ErrConversationNotFound))
+		_, err = cs.Get(fork.ID)
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
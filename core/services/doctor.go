@@ -0,0 +1,343 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates/jinja"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DoctorError   = "error"
+	DoctorWarning = "warning"
+)
+
+// The checks reported by the doctor
+const (
+	DoctorCheckConfig   = "config"
+	DoctorCheckTemplate = "template"
+	DoctorCheckFiles    = "files"
+	DoctorCheckChecksum = "checksum"
+	DoctorCheckBackend  = "backend"
+)
+
+// DoctorProblem is a problem found in the configuration of a model
+type DoctorProblem struct {
+	Model    string `json:"model,omitempty"`
+	File     string `json:"file"`
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// DoctorReport lists the problems of the configurations of the models path
+type DoctorReport struct {
+	OK       bool            `json:"ok"`
+	Models   int             `json:"models"`
+	Errors   int             `json:"errors"`
+	Warnings int             `json:"warnings"`
+	Problems []DoctorProblem `json:"problems"`
+}
+
+// weightsExtensions are the extensions of the model files which must be in the models path,
+// while the other backends might download the models by their name
+var weightsExtensions = []string{".gguf", ".ggml", ".bin", ".safetensors", ".onnx", ".pt", ".pth"}
+
+// sampleConversation is rendered with the templates of the models
+var sampleConversation = []struct{ role, content string }{
+	{"system", "You are a helpful assistant."},
+	{"user", "Hello!"},
+	{"assistant", "Hi, how can I help you?"},
+	{"user", "What is the capital of France?"},
+}
+
+var sampleFunctions = functions.Functions{{
+	Name:        "get_weather",
+	Description: "Get the current weather of a city",
+	Parameters: map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}},
+		"required":   []interface{}{"city"},
+	},
+}}
+
+// Doctor loads the configuration files of the models path and reports their problems: the configurations
+// which cannot be loaded, the templates which cannot be parsed or rendered, the files which are missing
+// or do not match their checksum, and the backends which are not available.
+// When models are given, only their configurations are checked
+func Doctor(appConfig *config.ApplicationConfig, checksums bool, models ...string) (*DoctorReport, error) {
+	entries, err := os.ReadDir(appConfig.ModelPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read directory '%s': %w", appConfig.ModelPath, err)
+	}
+
+	d := &doctor{
+		appConfig: appConfig,
+		checksums: checksums,
+		ml:        model.NewModelLoader(appConfig.ModelPath),
+		report:    &DoctorReport{Problems: []DoctorProblem{}},
+	}
+	names := map[string]string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || config.IsProfileFile(name) ||
+			(!strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml")) {
+			continue
+		}
+		cfg, ignored := d.loadConfig(name)
+		if cfg == nil {
+			continue
+		}
+		if len(models) > 0 && !slices.Contains(models, cfg.Name) {
+			continue
+		}
+		d.report.Models++
+		if ignored != nil {
+			d.add(cfg.Name, name, DoctorCheckConfig, DoctorWarning, "fields ignored: "+ignored.Error())
+		}
+		if other, exists := names[cfg.Name]; exists {
+			d.add(cfg.Name, name, DoctorCheckConfig, DoctorError, fmt.Sprintf("the model %q is defined by %s too", cfg.Name, other))
+			continue
+		}
+		names[cfg.Name] = name
+
+		if err := cfg.ContextWindow.Validate(); err != nil {
+			d.add(cfg.Name, name, DoctorCheckConfig, DoctorError, err.Error())
+		}
+		if err := cfg.ReasoningConfig.Validate(); err != nil {
+			d.add(cfg.Name, name, DoctorCheckConfig, DoctorError, err.Error())
+		}
+		d.checkTemplates(name, cfg)
+		d.checkFiles(name, cfg)
+		d.checkBackend(name, cfg)
+	}
+	for _, m := range models {
+		if _, exists := names[m]; !exists {
+			d.add(m, "", DoctorCheckConfig, DoctorError, "no configuration file found for the model")
+		}
+	}
+
+	d.report.OK = d.report.Errors == 0
+	return d.report, nil
+}
+
+type doctor struct {
+	appConfig *config.ApplicationConfig
+	checksums bool
+	ml        *model.ModelLoader
+	report    *DoctorReport
+}
+
+func (d *doctor) add(modelName, file, check, severity, message string) {
+	d.report.Problems = append(d.report.Problems, DoctorProblem{
+		Model:    modelName,
+		File:     file,
+		Check:    check,
+		Severity: severity,
+		Message:  message,
+	})
+	if severity == DoctorError {
+		d.report.Errors++
+	} else {
+		d.report.Warnings++
+	}
+}
+
+// loadConfig reads a configuration file as the loader does, returning as well the error
+// of the fields it ignores
+func (d *doctor) loadConfig(file string) (*config.BackendConfig, error) {
+	dat, err := config.ResolveBackendConfigFile(filepath.Join(d.appConfig.ModelPath, file))
+	if err == nil {
+		dat, err = utils.ExpandYAMLVariables(dat)
+	}
+	if err != nil {
+		d.add("", file, DoctorCheckConfig, DoctorError, err.Error())
+		return nil, nil
+	}
+
+	cfg := &config.BackendConfig{}
+	if err := yaml.Unmarshal(dat, cfg); err != nil {
+		d.add("", file, DoctorCheckConfig, DoctorError, err.Error())
+		return nil, nil
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(dat))
+	decoder.KnownFields(true)
+	ignored := decoder.Decode(&config.BackendConfig{})
+
+	if cfg.Name == "" {
+		d.add("", file, DoctorCheckConfig, DoctorError, "the configuration has no name")
+		return nil, nil
+	}
+	if !cfg.Validate() {
+		d.add(cfg.Name, file, DoctorCheckConfig, DoctorError, "the backend and the files of the model must be plain names relative to the models path")
+		return nil, nil
+	}
+	cfg.SetDefaults(d.appConfig.ToConfigLoaderOptions()...)
+	return cfg, ignored
+}
+
+func (d *doctor) checkTemplates(file string, cfg *config.BackendConfig) {
+	tc := cfg.TemplateConfig
+	for _, t := range []struct{ field, name string }{
+		{"chat", tc.Chat},
+		{"chat_message", tc.ChatMessage},
+		{"completion", tc.Completion},
+		{"edit", tc.Edit},
+		{"function", tc.Functions},
+	} {
+		// the Go templates which are not files are used as the templates themselves
+		if t.name != "" && !strings.Contains(t.name, "{{") && !utils.ExistsInPath(d.appConfig.ModelPath, t.name+".tmpl") {
+			d.add(cfg.Name, file, DoctorCheckTemplate, DoctorWarning, fmt.Sprintf("template.%s: %s.tmpl not found, %q is used as the template itself", t.field, t.name, t.name))
+		}
+	}
+
+	render := func(field string, f func() (string, error)) string {
+		out, err := f()
+		if err != nil {
+			d.add(cfg.Name, file, DoctorCheckTemplate, DoctorError, fmt.Sprintf("template.%s: %s", field, err))
+			return ""
+		}
+		if strings.TrimSpace(out) == "" {
+			d.add(cfg.Name, file, DoctorCheckTemplate, DoctorWarning, fmt.Sprintf("template.%s renders an empty prompt", field))
+		}
+		return out
+	}
+
+	input := ""
+	if tc.ChatMessage != "" {
+		messages := []string{}
+		input = render("chat_message", func() (string, error) {
+			for i, m := range sampleConversation {
+				out, err := d.ml.EvaluateTemplateForChatMessage(tc.ChatMessage, model.ChatMessageTemplateData{
+					SystemPrompt: cfg.SystemPrompt,
+					Role:         cfg.Roles[m.role],
+					RoleName:     m.role,
+					Content:      m.content,
+					MessageIndex: i,
+					LastMessage:  i == len(sampleConversation)-1,
+				})
+				if err != nil {
+					return "", err
+				}
+				messages = append(messages, out)
+			}
+			return strings.Join(messages, "\n"), nil
+		})
+	}
+	if tc.Chat != "" {
+		render("chat", func() (string, error) {
+			return d.ml.EvaluateTemplateForPrompt(model.ChatPromptTemplate, tc.Chat, model.PromptTemplateData{Input: input})
+		})
+	}
+	if tc.Completion != "" {
+		render("completion", func() (string, error) {
+			return d.ml.EvaluateTemplateForPrompt(model.CompletionPromptTemplate, tc.Completion, model.PromptTemplateData{Input: "Once upon a time"})
+		})
+	}
+	if tc.Edit != "" {
+		render("edit", func() (string, error) {
+			return d.ml.EvaluateTemplateForPrompt(model.EditPromptTemplate, tc.Edit, model.PromptTemplateData{Input: "Hello wrld", Instruction: "Fix the spelling"})
+		})
+	}
+	if tc.Functions != "" {
+		render("function", func() (string, error) {
+			return d.ml.EvaluateTemplateForPrompt(model.FunctionsPromptTemplate, tc.Functions, model.PromptTemplateData{Input: input, Functions: sampleFunctions})
+		})
+	}
+	if tc.Jinja != "" {
+		messages := []interface{}{}
+		for _, m := range sampleConversation {
+			messages = append(messages, map[string]interface{}{"role": m.role, "content": m.content})
+		}
+		_, err := d.ml.EvaluateJinjaTemplate(tc.Jinja, map[string]interface{}{
+			"messages":              messages,
+			"add_generation_prompt": true,
+			"bos_token":             tc.BOSToken,
+			"eos_token":             tc.EOSToken,
+		})
+		var raised *jinja.RaiseError
+		switch {
+		case errors.As(err, &raised):
+			// the templates refuse the conversations they do not support, e.g. the system messages
+			d.add(cfg.Name, file, DoctorCheckTemplate, DoctorWarning, "template.jinja refuses the sample conversation: "+raised.Message)
+		case err != nil:
+			d.add(cfg.Name, file, DoctorCheckTemplate, DoctorError, "template.jinja: "+err.Error())
+		}
+	}
+}
+
+func (d *doctor) checkFiles(file string, cfg *config.BackendConfig) {
+	modelPath := d.appConfig.ModelPath
+
+	if cfg.Model != "" {
+		modelFile := cfg.ModelFileName()
+		switch {
+		case utils.ExistsInPath(modelPath, modelFile):
+		case cfg.IsModelURL():
+			d.add(cfg.Name, file, DoctorCheckFiles, DoctorWarning, fmt.Sprintf("the model file %s is not downloaded yet", modelFile))
+		case slices.Contains(weightsExtensions, strings.ToLower(filepath.Ext(modelFile))):
+			d.add(cfg.Name, file, DoctorCheckFiles, DoctorError, fmt.Sprintf("the model file %s is missing", modelFile))
+		default:
+			d.add(cfg.Name, file, DoctorCheckFiles, DoctorWarning, fmt.Sprintf("the model %s is not a file of the models path, the backend has to download it", modelFile))
+		}
+	}
+
+	if cfg.MMProj != "" && !utils.ExistsInPath(modelPath, cfg.MMProjFileName()) {
+		severity := DoctorError
+		if cfg.IsMMProjURL() {
+			severity = DoctorWarning
+		}
+		d.add(cfg.Name, file, DoctorCheckFiles, severity, fmt.Sprintf("the mmproj file %s is missing", cfg.MMProjFileName()))
+	}
+
+	for _, f := range cfg.DownloadFiles {
+		if !utils.ExistsInPath(modelPath, f.Filename) {
+			d.add(cfg.Name, file, DoctorCheckFiles, DoctorError, fmt.Sprintf("the file %s is missing", f.Filename))
+			continue
+		}
+		if !d.checksums || f.SHA256 == "" {
+			continue
+		}
+		sha, err := fileSHA256(filepath.Join(modelPath, f.Filename))
+		if err != nil {
+			d.add(cfg.Name, file, DoctorCheckChecksum, DoctorError, err.Error())
+		} else if !strings.EqualFold(sha, f.SHA256) {
+			d.add(cfg.Name, file, DoctorCheckChecksum, DoctorError, fmt.Sprintf("the SHA256 of %s is %s, expected %s", f.Filename, sha, f.SHA256))
+		}
+	}
+}
+
+func (d *doctor) checkBackend(file string, cfg *config.BackendConfig) {
+	// the models without backend are loaded with the first backend able to
+	if cfg.Backend == "" {
+		return
+	}
+	if !model.BackendAvailable(d.appConfig.AssetsDestination, cfg.Backend, d.appConfig.ExternalGRPCBackends) {
+		d.add(cfg.Name, file, DoctorCheckBackend, DoctorError, fmt.Sprintf("the backend %s is not available in the backend assets nor as an external backend", cfg.Backend))
+	}
+}
+
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,91 @@
+package services_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mudler/LocalAI/core/config"
+	. "github.com/mudler/LocalAI/core/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Doctor", func() {
+	var tempdir string
+	var appConfig *config.ApplicationConfig
+
+	write := func(file, content string) {
+		Expect(os.WriteFile(filepath.Join(tempdir, file), []byte(content), 0600)).To(Succeed())
+	}
+
+	problems := func(report *DoctorReport) []string {
+		p := []string{}
+		for _, pb := range report.Problems {
+			p = append(p, pb.Severity+" "+pb.File+" "+pb.Check)
+		}
+		return p
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempdir, err = os.MkdirTemp("", "models")
+		Expect(err).ToNot(HaveOccurred())
+		appConfig = config.NewApplicationConfig(config.WithModelPath(tempdir), config.WithBackendAssetsOutput(tempdir))
+
+		write("a.gguf", "weights")
+		write("chat.tmpl", "{{.Input}}\nASSISTANT:")
+		write("good.yaml", "name: good\nparameters:\n  model: a.gguf\ntemplate:\n  chat: chat\n  chat_message: \"{{.RoleName}}: {{.Content}}\"\n  jinja: \"{% for m in messages %}{{ m.content }}{% endfor %}\"\n")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempdir)
+	})
+
+	It("reports no problem for valid configurations", func() {
+		report, err := Doctor(appConfig, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Problems).To(BeEmpty())
+		Expect(report.OK).To(BeTrue())
+		Expect(report.Models).To(Equal(1))
+	})
+
+	It("reports the problems of the configurations", func() {
+		write("template.yaml", "name: template\nparameters:\n  model: a.gguf\ntemplate:\n  chat: \"{{.Input\"\n  jinja: \"{{ raise_exception('System role not supported') }}\"\n")
+		write("files.yaml", "name: files\nmmproj: mmproj.gguf\nparameters:\n  model: missing.gguf\ndownload_files:\n- filename: a.gguf\n  sha256: 0000\n")
+		write("backend.yaml", "name: backend\nbackend: unknown\nunknown_field: true\nparameters:\n  model: a.gguf\n")
+		write("cycle.yaml", "name: cycle\nextends: cycle\n")
+
+		report, err := Doctor(appConfig, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.OK).To(BeFalse())
+		Expect(problems(report)).To(ConsistOf(
+			"warning backend.yaml config",
+			"error backend.yaml backend",
+			"error cycle.yaml config",
+			"error files.yaml files",
+			"error files.yaml files",
+			"error files.yaml checksum",
+			"error template.yaml template",
+			"warning template.yaml template",
+		))
+		Expect(report.Errors).To(Equal(6))
+		Expect(report.Warnings).To(Equal(2))
+
+		report, err = Doctor(appConfig, false, "files", "other")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(problems(report)).To(ConsistOf(
+			"error cycle.yaml config",
+			"error files.yaml files",
+			"error files.yaml files",
+			"error  config",
+		))
+	})
+
+	It("finds the external backends", func() {
+		write("backend.yaml", "name: backend\nbackend: remote\nparameters:\n  model: a.gguf\n")
+		appConfig.ExternalGRPCBackends = map[string]string{"remote": "127.0.0.1:50051"}
+		report, err := Doctor(appConfig, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.OK).To(BeTrue())
+	})
+})
@@ -1,36 +1,73 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/pkg/downloader"
 	"github.com/mudler/LocalAI/pkg/startup"
 	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v2"
 )
 
+const webhookTimeout = 10 * time.Second
+
 type GalleryService struct {
 	appConfig *config.ApplicationConfig
 	sync.Mutex
-	C        chan gallery.GalleryOp
-	statuses map[string]*gallery.GalleryOpStatus
+	C           chan gallery.GalleryOp
+	statuses    map[string]*gallery.GalleryOpStatus
+	indexes     *gallery.IndexCache
+	cancels     map[string]context.CancelFunc
+	queued      map[string]bool     // the operations waiting to be processed, true when cancelled before they start
+	pending     []gallery.GalleryOp // the operations enqueued, processed in order
+	wake        chan struct{}
+	subscribers map[string]map[chan *gallery.GalleryOpStatus]struct{}
 }
 
+var (
+	ErrGalleryOpNotFound  = errors.New("could not find any operation for ID")
+	ErrGalleryOpProcessed = errors.New("the operation is already processed")
+	ErrInvalidWebhook     = errors.New("the webhook must be an http or https URL")
+)
+
 func NewGalleryService(appConfig *config.ApplicationConfig) *GalleryService {
 	return &GalleryService{
-		appConfig: appConfig,
-		C:         make(chan gallery.GalleryOp),
-		statuses:  make(map[string]*gallery.GalleryOpStatus),
+		appConfig:   appConfig,
+		C:           make(chan gallery.GalleryOp),
+		statuses:    make(map[string]*gallery.GalleryOpStatus),
+		indexes:     gallery.NewIndexCache(appConfig.GalleryRefreshInterval),
+		cancels:     make(map[string]context.CancelFunc),
+		queued:      make(map[string]bool),
+		wake:        make(chan struct{}, 1),
+		subscribers: make(map[string]map[chan *gallery.GalleryOpStatus]struct{}),
 	}
 }
 
-func prepareModel(modelPath string, req gallery.GalleryModel, downloadStatus func(string, string, string, float64), enforceScan bool) error {
+// AvailableModels returns the models of the galleries, from the indexes cached in memory
+func (g *GalleryService) AvailableModels(galleries []config.Gallery) ([]*gallery.GalleryModel, error) {
+	return g.indexes.AvailableGalleryModels(galleries, g.appConfig.ModelPath)
+}
+
+// RefreshIndexes drops the indexes cached in memory, so they are downloaded again
+func (g *GalleryService) RefreshIndexes() {
+	g.indexes.Invalidate()
+}
+
+func prepareModel(ctx context.Context, modelPath string, req gallery.GalleryModel, downloadStatus func(string, string, string, float64), enforceScan bool) error {
 
 	config, err := gallery.GetGalleryConfigFromURL(req.URL, modelPath)
 	if err != nil {
@@ -39,13 +76,22 @@ func prepareModel(modelPath string, req gallery.GalleryModel, downloadStatus fun
 
 	config.Files = append(config.Files, req.AdditionalFiles...)
 
-	return gallery.InstallModel(modelPath, req.Name, &config, req.Overrides, downloadStatus, enforceScan)
+	return gallery.InstallModel(ctx, modelPath, req.Name, &config, req.Overrides, downloadStatus, enforceScan)
 }
 
 func (g *GalleryService) UpdateStatus(s string, op *gallery.GalleryOpStatus) {
 	g.Lock()
 	defer g.Unlock()
 	g.statuses[s] = op
+
+	for ch := range g.subscribers[s] {
+		// subscribers only need the latest status: drop the one they did not read yet
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- op
+	}
 }
 
 func (g *GalleryService) GetStatus(s string) *gallery.GalleryOpStatus {
@@ -62,6 +108,78 @@ func (g *GalleryService) GetAllStatus() map[string]*gallery.GalleryOpStatus {
 	return g.statuses
 }
 
+// Subscribe returns a channel receiving the status updates of the operation.
+// The returned function must be called to stop receiving them.
+func (g *GalleryService) Subscribe(s string) (chan *gallery.GalleryOpStatus, func()) {
+	g.Lock()
+	defer g.Unlock()
+
+	ch := make(chan *gallery.GalleryOpStatus, 1)
+	if g.subscribers[s] == nil {
+		g.subscribers[s] = make(map[chan *gallery.GalleryOpStatus]struct{})
+	}
+	g.subscribers[s][ch] = struct{}{}
+
+	return ch, func() {
+		g.Lock()
+		defer g.Unlock()
+		delete(g.subscribers[s], ch)
+		if len(g.subscribers[s]) == 0 {
+			delete(g.subscribers, s)
+		}
+	}
+}
+
+// Enqueue submits an operation to the service without waiting for the operations in progress.
+// The operations are processed in the order they are enqueued, and can be cancelled until they start
+func (g *GalleryService) Enqueue(op gallery.GalleryOp) {
+	if op.Id != "" {
+		g.Lock()
+		g.queued[op.Id] = false
+		g.Unlock()
+		g.UpdateStatus(op.Id, &gallery.GalleryOpStatus{Message: "queued", GalleryModelName: op.GalleryModelName})
+	}
+	g.Lock()
+	g.pending = append(g.pending, op)
+	g.Unlock()
+	select {
+	case g.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next removes the first operation enqueued
+func (g *GalleryService) next() (gallery.GalleryOp, bool) {
+	g.Lock()
+	defer g.Unlock()
+	if len(g.pending) == 0 {
+		return gallery.GalleryOp{}, false
+	}
+	op := g.pending[0]
+	g.pending = g.pending[1:]
+	return op, true
+}
+
+// CancelOp stops the operation in progress, removing the files partially downloaded,
+// or marks the operation queued so that it is not processed
+func (g *GalleryService) CancelOp(s string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if cancel, exists := g.cancels[s]; exists {
+		cancel()
+		return nil
+	}
+	if _, exists := g.queued[s]; exists {
+		g.queued[s] = true
+		return nil
+	}
+	if status, exists := g.statuses[s]; exists && status.Processed {
+		return ErrGalleryOpProcessed
+	}
+	return ErrGalleryOpNotFound
+}
+
 func (g *GalleryService) Start(c context.Context, cl *config.BackendConfigLoader) {
 	go func() {
 		for {
@@ -69,105 +187,203 @@ func (g *GalleryService) Start(c context.Context, cl *config.BackendConfigLoader
 			case <-c.Done():
 				return
 			case op := <-g.C:
-				utils.ResetDownloadTimers()
-
-				g.UpdateStatus(op.Id, &gallery.GalleryOpStatus{Message: "processing", Progress: 0})
-
-				// updates the status with an error
-				var updateError func(e error)
-				if !g.appConfig.OpaqueErrors {
-					updateError = func(e error) {
-						g.UpdateStatus(op.Id, &gallery.GalleryOpStatus{Error: e, Processed: true, Message: "error: " + e.Error()})
-					}
-				} else {
-					updateError = func(_ error) {
-						g.UpdateStatus(op.Id, &gallery.GalleryOpStatus{Error: fmt.Errorf("an error occurred"), Processed: true})
-					}
+				g.processOp(c, cl, op)
+			case <-g.wake:
+				for op, ok := g.next(); ok && c.Err() == nil; op, ok = g.next() {
+					g.processOp(c, cl, op)
 				}
+			}
+		}
+	}()
+}
 
-				// displayDownload displays the download progress
-				progressCallback := func(fileName string, current string, total string, percentage float64) {
-					g.UpdateStatus(op.Id, &gallery.GalleryOpStatus{Message: "processing", FileName: fileName, Progress: percentage, TotalFileSize: total, DownloadedFileSize: current})
-					utils.DisplayDownloadFunction(fileName, current, total, percentage)
-				}
+func (g *GalleryService) processOp(c context.Context, cl *config.BackendConfigLoader, op gallery.GalleryOp) {
+	ctx, cancel := context.WithCancel(c)
+	g.Lock()
+	cancelled := g.queued[op.Id]
+	delete(g.queued, op.Id)
+	if !cancelled {
+		g.cancels[op.Id] = cancel
+	}
+	g.Unlock()
+	if cancelled {
+		cancel()
+		log.Info().Str("id", op.Id).Str("model", op.GalleryModelName).Msg("gallery operation cancelled before it started")
+		status := &gallery.GalleryOpStatus{Cancelled: true, Processed: true, GalleryModelName: op.GalleryModelName, Message: "cancelled"}
+		g.UpdateStatus(op.Id, status)
+		g.notify(op, gallery.GalleryOpCancelled, status)
+		return
+	}
 
-				var err error
-
-				// delete a model
-				if op.Delete {
-					modelConfig := &config.BackendConfig{}
-
-					// Galleryname is the name of the model in this case
-					dat, err := os.ReadFile(filepath.Join(g.appConfig.ModelPath, op.GalleryModelName+".yaml"))
-					if err != nil {
-						updateError(err)
-						continue
-					}
-					err = yaml.Unmarshal(dat, modelConfig)
-					if err != nil {
-						updateError(err)
-						continue
-					}
-
-					files := []string{}
-					// Remove the model from the config
-					if modelConfig.Model != "" {
-						files = append(files, modelConfig.ModelFileName())
-					}
-
-					if modelConfig.MMProj != "" {
-						files = append(files, modelConfig.MMProjFileName())
-					}
-
-					err = gallery.DeleteModelFromSystem(g.appConfig.ModelPath, op.GalleryModelName, files)
-					if err != nil {
-						updateError(err)
-						continue
-					}
-				} else {
-					// if the request contains a gallery name, we apply the gallery from the gallery list
-					if op.GalleryModelName != "" {
-						err = gallery.InstallModelFromGallery(op.Galleries, op.GalleryModelName, g.appConfig.ModelPath, op.Req, progressCallback, g.appConfig.EnforcePredownloadScans)
-					} else if op.ConfigURL != "" {
-						err = startup.InstallModels(op.Galleries, op.ConfigURL, g.appConfig.ModelPath, g.appConfig.EnforcePredownloadScans, progressCallback, op.ConfigURL)
-						if err != nil {
-							updateError(err)
-							continue
-						}
-						err = cl.Preload(g.appConfig.ModelPath)
-					} else {
-						err = prepareModel(g.appConfig.ModelPath, op.Req, progressCallback, g.appConfig.EnforcePredownloadScans)
-					}
-				}
+	utils.ResetDownloadTimers()
+	defer func() {
+		g.Lock()
+		delete(g.cancels, op.Id)
+		g.Unlock()
+		cancel()
+	}()
 
-				if err != nil {
-					updateError(err)
-					continue
-				}
+	g.UpdateStatus(op.Id, &gallery.GalleryOpStatus{Message: "processing", Progress: 0})
 
-				// Reload models
-				err = cl.LoadBackendConfigsFromPath(g.appConfig.ModelPath)
-				if err != nil {
-					updateError(err)
-					continue
-				}
+	// displayDownload displays the download progress
+	progressCallback := func(fileName string, current string, total string, percentage float64) {
+		g.UpdateStatus(op.Id, &gallery.GalleryOpStatus{Message: "processing", FileName: fileName, Progress: percentage, TotalFileSize: total, DownloadedFileSize: current})
+		utils.DisplayDownloadFunction(fileName, current, total, percentage)
+	}
 
-				err = cl.Preload(g.appConfig.ModelPath)
-				if err != nil {
-					updateError(err)
-					continue
-				}
+	var status *gallery.GalleryOpStatus
+	event := gallery.GalleryOpCompleted
+	err := g.applyOp(ctx, cl, op, progressCallback)
+	switch {
+	case ctx.Err() != nil:
+		log.Info().Str("id", op.Id).Str("model", op.GalleryModelName).Msg("gallery operation cancelled")
+		event = gallery.GalleryOpCancelled
+		status = &gallery.GalleryOpStatus{Cancelled: true, Processed: true, GalleryModelName: op.GalleryModelName, Message: "cancelled"}
+	case err != nil:
+		event = gallery.GalleryOpFailed
+		if !g.appConfig.OpaqueErrors {
+			status = &gallery.GalleryOpStatus{Error: err, Processed: true, Message: "error: " + err.Error()}
+		} else {
+			status = &gallery.GalleryOpStatus{Error: fmt.Errorf("an error occurred"), Processed: true}
+		}
+	default:
+		status = &gallery.GalleryOpStatus{
+			Deletion:         op.Delete,
+			Processed:        true,
+			GalleryModelName: op.GalleryModelName,
+			Message:          "completed",
+			Progress:         100}
+	}
+	g.UpdateStatus(op.Id, status)
+	g.notify(op, event, status)
+}
+
+// notify posts the end of an operation to the webhooks of the configuration and to the one of the request
+func (g *GalleryService) notify(op gallery.GalleryOp, event string, status *gallery.GalleryOpStatus) {
+	webhooks := g.appConfig.GalleryWebhooks
+	if op.Webhook != "" {
+		webhooks = append(slices.Clone(webhooks), op.Webhook)
+	}
+	notifyWebhooks(webhooks, gallery.GalleryOpEvent{ID: op.Id, Event: event, Status: status})
+}
+
+// ValidateWebhook checks that a webhook is an absolute http or https URL
+func ValidateWebhook(webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidWebhook, webhook)
+	}
+	return nil
+}
+
+func (g *GalleryService) applyOp(ctx context.Context, cl *config.BackendConfigLoader, op gallery.GalleryOp, progressCallback func(string, string, string, float64)) error {
+	var err error
+
+	// upgrade a model, or restore its previous version
+	if op.Upgrade {
+		err = gallery.UpgradeModel(ctx, op.Galleries, g.appConfig.ModelPath, op.GalleryModelName, progressCallback, g.appConfig.EnforcePredownloadScans)
+	} else if op.Rollback {
+		err = gallery.RollbackModel(g.appConfig.ModelPath, op.GalleryModelName)
+	} else if op.Delete {
+		modelConfig := &config.BackendConfig{}
+
+		// Galleryname is the name of the model in this case
+		dat, err := os.ReadFile(filepath.Join(g.appConfig.ModelPath, op.GalleryModelName+".yaml"))
+		if err != nil {
+			return err
+		}
+		err = yaml.Unmarshal(dat, modelConfig)
+		if err != nil {
+			return err
+		}
+
+		files := []string{}
+		// Remove the model from the config
+		if modelConfig.Model != "" {
+			files = append(files, modelConfig.ModelFileName())
+		}
 
-				g.UpdateStatus(op.Id,
-					&gallery.GalleryOpStatus{
-						Deletion:         op.Delete,
-						Processed:        true,
-						GalleryModelName: op.GalleryModelName,
-						Message:          "completed",
-						Progress:         100})
+		if modelConfig.MMProj != "" {
+			files = append(files, modelConfig.MMProjFileName())
+		}
+
+		err = gallery.DeleteModelFromSystem(g.appConfig.ModelPath, op.GalleryModelName, files)
+		if err != nil {
+			return err
+		}
+
+		// remove the files which are not used by other models anymore
+		if g.appConfig.BlobsPath != "" {
+			removed, err := downloader.NewBlobStore(g.appConfig.BlobsPath).GC(g.appConfig.ModelPath)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to garbage collect the blob store")
+			} else if len(removed) > 0 {
+				log.Info().Strs("blobs", removed).Msg("removed unreferenced blobs")
 			}
 		}
-	}()
+	} else {
+		// if the request contains a gallery name, we apply the gallery from the gallery list
+		if op.GalleryModelName != "" {
+			err = gallery.InstallModelFromGallery(ctx, op.Galleries, op.GalleryModelName, g.appConfig.ModelPath, op.Req, progressCallback, g.appConfig.EnforcePredownloadScans)
+		} else if op.ConfigURL != "" {
+			err = startup.InstallModels(ctx, op.Galleries, op.ConfigURL, g.appConfig.ModelPath, g.appConfig.EnforcePredownloadScans, progressCallback, op.ConfigURL)
+			if err != nil {
+				return err
+			}
+			err = cl.Preload(g.appConfig.ModelPath)
+		} else {
+			err = prepareModel(ctx, g.appConfig.ModelPath, op.Req, progressCallback, g.appConfig.EnforcePredownloadScans)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Reload models
+	err = cl.LoadBackendConfigsFromPath(g.appConfig.ModelPath)
+	if err != nil {
+		return err
+	}
+
+	return cl.Preload(g.appConfig.ModelPath)
+}
+
+// notifyWebhooks posts the event of the end of an operation to the webhooks, in the background
+func notifyWebhooks(webhooks []string, event gallery.GalleryOpEvent) {
+	if len(webhooks) == 0 {
+		return
+	}
+	dat, err := json.Marshal(event)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to encode the gallery operation event")
+		return
+	}
+	for _, url := range webhooks {
+		if err := ValidateWebhook(url); err != nil {
+			log.Error().Err(err).Msg("invalid gallery webhook")
+			continue
+		}
+		go func(url string) {
+			ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(dat))
+			if err != nil {
+				log.Error().Err(err).Str("url", url).Msg("invalid gallery webhook")
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				log.Error().Err(err).Str("url", url).Msg("failed to notify the gallery webhook")
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				log.Error().Int("status", resp.StatusCode).Str("url", url).Msg("the gallery webhook returned an error")
+			}
+		}(url)
+	}
 }
 
 type galleryModel struct {
@@ -180,10 +396,10 @@ func processRequests(modelPath string, enforceScan bool, galleries []config.Gall
 	for _, r := range requests {
 		utils.ResetDownloadTimers()
 		if r.ID == "" {
-			err = prepareModel(modelPath, r.GalleryModel, utils.DisplayDownloadFunction, enforceScan)
+			err = prepareModel(context.Background(), modelPath, r.GalleryModel, utils.DisplayDownloadFunction, enforceScan)
 
 		} else {
-			err = gallery.InstallModelFromGallery(
+			err = gallery.InstallModelFromGallery(context.Background(),
 				galleries, r.ID, modelPath, r.GalleryModel, utils.DisplayDownloadFunction, enforceScan)
 		}
 	}
@@ -0,0 +1,89 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/gallery"
+	. "github.com/mudler/LocalAI/core/services"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GalleryService", func() {
+	var tempdir string
+	var g *GalleryService
+	var cancel context.CancelFunc
+
+	BeforeEach(func() {
+		var err error
+		tempdir, err = os.MkdirTemp("", "models")
+		Expect(err).ToNot(HaveOccurred())
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		g = NewGalleryService(config.NewApplicationConfig(config.WithContext(ctx), config.WithModelPath(tempdir)))
+	})
+
+	AfterEach(func() {
+		cancel()
+		os.RemoveAll(tempdir)
+	})
+
+	It("cancels an operation before it starts", func() {
+		g.Enqueue(gallery.GalleryOp{Id: "queued", Rollback: true, GalleryModelName: "model"})
+		Expect(g.GetStatus("queued").Message).To(Equal("queued"))
+		Expect(g.CancelOp("queued")).To(Succeed())
+
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		g.Start(ctx, config.NewBackendConfigLoader(tempdir))
+		Eventually(func() bool {
+			status := g.GetStatus("queued")
+			return status != nil && status.Processed
+		}).Should(BeTrue())
+		status := g.GetStatus("queued")
+		Expect(status.Cancelled).To(BeTrue())
+		Expect(status.Error).To(BeNil())
+		Expect(errors.Is(g.CancelOp("queued"), ErrGalleryOpProcessed)).To(BeTrue())
+	})
+
+	It("processes the operations in the order they are enqueued", func() {
+		// a model deleted twice: the second deletion fails if processed after the first one
+		for i := 0; i < 5; i++ {
+			model := fmt.Sprintf("model-%d", i)
+			Expect(os.WriteFile(filepath.Join(tempdir, model+".yaml"), []byte("name: "+model), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tempdir, "._gallery_"+model+".yaml"), []byte("name: "+model), 0600)).To(Succeed())
+			g.Enqueue(gallery.GalleryOp{Id: model + "-first", Delete: true, GalleryModelName: model})
+			g.Enqueue(gallery.GalleryOp{Id: model + "-second", Delete: true, GalleryModelName: model})
+		}
+
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		g.Start(ctx, config.NewBackendConfigLoader(tempdir))
+		Eventually(func() bool {
+			status := g.GetStatus("model-4-second")
+			return status != nil && status.Processed
+		}).Should(BeTrue())
+		for i := 0; i < 5; i++ {
+			Expect(g.GetStatus(fmt.Sprintf("model-%d-first", i)).Error).To(BeNil())
+			Expect(g.GetStatus(fmt.Sprintf("model-%d-second", i)).Error).To(HaveOccurred())
+		}
+	})
+
+	It("does not know the operations never submitted", func() {
+		Expect(errors.Is(g.CancelOp("unknown"), ErrGalleryOpNotFound)).To(BeTrue())
+	})
+
+	It("validates the webhooks", func() {
+		Expect(ValidateWebhook("https://example.com/hook")).To(Succeed())
+		Expect(ValidateWebhook("http://127.0.0.1:8080/hook")).To(Succeed())
+		for _, webhook := range []string{"file:///etc/passwd", "gopher://example.com", "example.com/hook", "https://"} {
+			Expect(errors.Is(ValidateWebhook(webhook), ErrInvalidWebhook)).To(BeTrue(), webhook)
+		}
+	})
+})
@@ -0,0 +1,418 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrModelConfigNotFound = errors.New("could not find any configuration for the model")
+	ErrModelConfigReadOnly = errors.New("the configuration of the model is not stored in a file of the models path")
+)
+
+// ModelConfigValidationError is returned when a configuration written is not valid
+type ModelConfigValidationError struct {
+	Err error
+}
+
+func (e *ModelConfigValidationError) Error() string {
+	return fmt.Sprintf("invalid model configuration: %s", e.Err)
+}
+
+func (e *ModelConfigValidationError) Unwrap() error {
+	return e.Err
+}
+
+var modelConfigNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._@:+-]*$`)
+
+// ModelConfigService reads and writes the configuration files of the models path, and applies
+// their changes while running: the loaded models whose load options changed are reloaded once
+// their requests in flight are completed
+type ModelConfigService struct {
+	appConfig *config.ApplicationConfig
+	cl        *config.BackendConfigLoader
+	ml        *model.ModelLoader
+
+	// writes serializes the writes of the configuration files
+	writes sync.Mutex
+
+	mu        sync.Mutex
+	reloading map[string]context.CancelFunc
+}
+
+func NewModelConfigService(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) *ModelConfigService {
+	return &ModelConfigService{
+		appConfig: appConfig,
+		cl:        cl,
+		ml:        ml,
+		reloading: make(map[string]context.CancelFunc),
+	}
+}
+
+// Sync reads again the configuration files, and reloads the loaded models whose load options changed.
+// The models whose configuration was removed are unloaded
+func (s *ModelConfigService) Sync() ([]config.BackendConfigChange, error) {
+	changes, err := s.cl.SyncBackendConfigsFromPath(s.appConfig.ModelPath, s.appConfig.ToConfigLoaderOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	preload := false
+	for _, c := range changes {
+		log.Info().Str("model", c.Name()).Str("event", c.Event).Msg("model configuration changed")
+		if c.New != nil && (c.New.IsModelURL() || c.New.IsMMProjURL() || len(c.New.DownloadFiles) > 0) {
+			preload = true
+		}
+	}
+	if preload {
+		if err := s.cl.Preload(s.appConfig.ModelPath); err != nil {
+			log.Error().Err(err).Msg("error downloading models")
+		}
+	}
+
+	for _, c := range changes {
+		switch c.Event {
+		case config.BackendConfigUpdated:
+			// the preloading might have changed the configuration
+			updated, exists := s.cl.GetBackendConfig(c.New.Name)
+			if !exists || !backend.LoadOptionsChanged(*c.Old, updated) {
+				continue
+			}
+			s.reload(*c.Old, &updated)
+		case config.BackendConfigRemoved:
+			s.reload(*c.Old, nil)
+		}
+	}
+	return changes, nil
+}
+
+// reload restarts a model with its new configuration once its requests in flight are completed,
+// or only stops it when the configuration was removed.
+// A newer change of the model cancels the pending reload
+func (s *ModelConfigService) reload(old config.BackendConfig, updated *config.BackendConfig) {
+	s.mu.Lock()
+	if cancel, exists := s.reloading[old.Name]; exists {
+		cancel()
+	}
+	ctx, cancel := context.WithCancel(s.appConfig.Context)
+	s.reloading[old.Name] = cancel
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			if ctx.Err() == nil {
+				delete(s.reloading, old.Name)
+			}
+			s.mu.Unlock()
+			cancel()
+		}()
+
+		loaded, err := s.ml.UnloadWhenIdle(ctx, old.Model)
+		if ctx.Err() != nil {
+			return
+		}
+		if updated == nil {
+			if loaded && err != nil {
+				log.Error().Err(err).Str("model", old.Name).Msg("error unloading the model of the configuration removed")
+			}
+			s.ml.BackendLogs().Remove(old.Model)
+			return
+		}
+		if !loaded {
+			return
+		}
+		if err == nil {
+			err = backend.PreloadModel(*updated, s.ml, s.appConfig)
+		}
+
+		event := config.BackendConfigEvent{Event: config.BackendConfigReloaded, Model: updated.Name}
+		if err != nil {
+			log.Error().Err(err).Str("model", updated.Name).Msg("error reloading the model with its new configuration")
+			event.Error = err.Error()
+		} else {
+			log.Info().Str("model", updated.Name).Msg("model reloaded with its new configuration")
+		}
+		s.cl.Notify(event)
+	}()
+}
+
+// Config returns the effective configuration of a model, after the defaults are applied
+func (s *ModelConfigService) Config(name string) (map[string]interface{}, error) {
+	cfg, exists := s.cl.GetBackendConfig(name)
+	if !exists {
+		return nil, ErrModelConfigNotFound
+	}
+	dat, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(dat, &doc); err != nil {
+		return nil, err
+	}
+	// the secrets are not returned
+	redactValues(doc)
+	return doc, nil
+}
+
+func redactValues(v interface{}) interface{} {
+	switch value := v.(type) {
+	case string:
+		return utils.RedactSecrets(value)
+	case map[string]interface{}:
+		for k, e := range value {
+			value[k] = redactValues(e)
+		}
+	case []interface{}:
+		for i, e := range value {
+			value[i] = redactValues(e)
+		}
+	}
+	return v
+}
+
+// File returns the content of the configuration file of a model
+func (s *ModelConfigService) File(name string) (string, error) {
+	file, err := s.configFile(name)
+	if err != nil {
+		return "", err
+	}
+	dat, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return string(dat), nil
+}
+
+// Replace writes the whole configuration of a model, creating it if it does not exist.
+// It returns true if the configuration was created
+func (s *ModelConfigService) Replace(name string, doc map[string]interface{}) (bool, error) {
+	s.writes.Lock()
+	defer s.writes.Unlock()
+
+	file, err := s.configFile(name)
+	created := errors.Is(err, ErrModelConfigNotFound)
+	switch {
+	case created:
+		if !modelConfigNameRegex.MatchString(name) {
+			return false, &ModelConfigValidationError{Err: fmt.Errorf("the name %q cannot be used as a file name", name)}
+		}
+		file = filepath.Join(s.appConfig.ModelPath, name+".yaml")
+		if _, err := os.Stat(file); err == nil {
+			return false, &ModelConfigValidationError{Err: fmt.Errorf("the file %s already exists", filepath.Base(file))}
+		}
+	case err != nil:
+		return false, err
+	}
+	if err := checkNoVariables(doc); err != nil {
+		return false, &ModelConfigValidationError{Err: err}
+	}
+
+	if err := s.write(name, file, doc); err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
+// Patch merges a partial configuration into the configuration file of a model,
+// following the JSON merge patch semantics: the null values remove the fields
+func (s *ModelConfigService) Patch(name string, patch map[string]interface{}) error {
+	s.writes.Lock()
+	defer s.writes.Unlock()
+
+	file, err := s.configFile(name)
+	if err != nil {
+		return err
+	}
+	// the references of the file are kept, but the patch cannot add new ones
+	if err := checkNoVariables(patch); err != nil {
+		return &ModelConfigValidationError{Err: err}
+	}
+	dat, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(dat, &doc); err != nil {
+		return fmt.Errorf("cannot unmarshal config file: %w", err)
+	}
+
+	return s.write(name, file, mergePatch(doc, patch))
+}
+
+// Delete removes the configuration file of a model, and unloads the model. The files of the model are kept
+func (s *ModelConfigService) Delete(name string) error {
+	s.writes.Lock()
+	defer s.writes.Unlock()
+
+	file, err := s.configFile(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil {
+		return err
+	}
+	_, err = s.Sync()
+	return err
+}
+
+// configFile returns the file of the configuration of a model, which must be in the models path
+func (s *ModelConfigService) configFile(name string) (string, error) {
+	cfg, exists := s.cl.GetBackendConfig(name)
+	if !exists {
+		return "", ErrModelConfigNotFound
+	}
+	file := cfg.ConfigFile()
+	if file == "" || filepath.Dir(file) != filepath.Clean(s.appConfig.ModelPath) {
+		return "", ErrModelConfigReadOnly
+	}
+	return file, nil
+}
+
+// write validates a configuration, writes it atomically and applies it
+func (s *ModelConfigService) write(name, file string, doc map[string]interface{}) error {
+	if n, exists := doc["name"]; !exists || n == nil {
+		doc["name"] = name
+	} else if n != name {
+		return &ModelConfigValidationError{Err: fmt.Errorf("the name %v does not match the model %q", n, name)}
+	}
+
+	dat, err := yaml.Marshal(doc)
+	if err != nil {
+		return &ModelConfigValidationError{Err: err}
+	}
+	if err := validateModelConfig(dat, s.appConfig.ToConfigLoaderOptions()...); err != nil {
+		return &ModelConfigValidationError{Err: err}
+	}
+
+	// the temporary file is hidden, so that the watcher of the models path ignores it
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(dat); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return err
+	}
+
+	_, err = s.Sync()
+	return err
+}
+
+// checkNoVariables refuses the references to the environment variables and to the files in the
+// configurations written through the API, which would give access to the secrets of the server
+func checkNoVariables(v interface{}) error {
+	switch value := v.(type) {
+	case string:
+		if utils.HasVariables(value) {
+			return fmt.Errorf("the configurations written through the API cannot reference environment variables or files: %q (escape it as $${...})", value)
+		}
+	case map[string]interface{}:
+		for k, e := range value {
+			if err := checkNoVariables(k); err != nil {
+				return err
+			}
+			if err := checkNoVariables(e); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range value {
+			if err := checkNoVariables(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateModelConfig checks that a configuration only has known fields of the expected types,
+// that it can be loaded and that its values are in range
+func validateModelConfig(dat []byte, opts ...config.ConfigLoaderOption) error {
+	dat, err := utils.ExpandYAMLVariables(dat)
+	if err != nil {
+		return err
+	}
+	cfg := &config.BackendConfig{}
+	decoder := yaml.NewDecoder(bytes.NewReader(dat))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil {
+		return err
+	}
+
+	cfg.SetDefaults(opts...)
+	if !cfg.Validate() {
+		return errors.New("the backend and the files of the model must be plain names relative to the models path")
+	}
+	if err := cfg.ContextWindow.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.ReasoningConfig.Validate(); err != nil {
+		return err
+	}
+
+	switch {
+	case cfg.ContextSize != nil && *cfg.ContextSize < 0:
+		return errors.New("context_size must not be negative")
+	case cfg.Threads != nil && *cfg.Threads < 0:
+		return errors.New("threads must not be negative")
+	case cfg.Temperature != nil && *cfg.Temperature < 0:
+		return errors.New("temperature must not be negative")
+	case cfg.TopP != nil && (*cfg.TopP < 0 || *cfg.TopP > 1):
+		return errors.New("top_p must be between 0 and 1")
+	case cfg.TopK != nil && *cfg.TopK < 0:
+		return errors.New("top_k must not be negative")
+	case cfg.Maxtokens != nil && *cfg.Maxtokens < 0:
+		return errors.New("max_tokens must not be negative")
+	}
+	return nil
+}
+
+// mergePatch applies a JSON merge patch (RFC 7386) to a document
+func mergePatch(doc, patch map[string]interface{}) map[string]interface{} {
+	for k, v := range patch {
+		if v == nil {
+			delete(doc, k)
+			continue
+		}
+		p, isMap := v.(map[string]interface{})
+		if !isMap {
+			doc[k] = v
+			continue
+		}
+		d, isMap := doc[k].(map[string]interface{})
+		if !isMap {
+			d = map[string]interface{}{}
+		}
+		doc[k] = mergePatch(d, p)
+	}
+	return doc
+}
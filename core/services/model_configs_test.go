@@ -0,0 +1,117 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/mudler/LocalAI/core/config"
+	. "github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/model"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ModelConfigService", func() {
+	var tempdir string
+	var cl *config.BackendConfigLoader
+	var mcs *ModelConfigService
+	var cancel context.CancelFunc
+
+	BeforeEach(func() {
+		var err error
+		tempdir, err = os.MkdirTemp("", "models")
+		Expect(err).ToNot(HaveOccurred())
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		appConfig := config.NewApplicationConfig(config.WithContext(ctx), config.WithModelPath(tempdir))
+		cl = config.NewBackendConfigLoader(tempdir)
+		mcs = NewModelConfigService(cl, model.NewModelLoader(tempdir), appConfig)
+
+		Expect(os.WriteFile(filepath.Join(tempdir, "a.yaml"), []byte("name: a\ncontext_size: 2048\nparameters:\n  model: a.gguf\n  temperature: 0.2\n"), 0600)).To(Succeed())
+		Expect(cl.LoadBackendConfigsFromPath(tempdir)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		cancel()
+		os.RemoveAll(tempdir)
+	})
+
+	It("returns the effective configuration", func() {
+		doc, err := mcs.Config("a")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(doc).To(HaveKeyWithValue("context_size", 2048))
+		Expect(doc["parameters"]).To(HaveKeyWithValue("model", "a.gguf"))
+
+		_, err = mcs.Config("b")
+		Expect(err).To(MatchError(ErrModelConfigNotFound))
+	})
+
+	It("creates and replaces the configurations", func() {
+		created, err := mcs.Replace("b", map[string]interface{}{"parameters": map[string]interface{}{"model": "b.gguf"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created).To(BeTrue())
+		b, exists := cl.GetBackendConfig("b")
+		Expect(exists).To(BeTrue())
+		Expect(b.Model).To(Equal("b.gguf"))
+		Expect(filepath.Join(tempdir, "b.yaml")).To(BeAnExistingFile())
+
+		created, err = mcs.Replace("a", map[string]interface{}{"name": "a", "parameters": map[string]interface{}{"model": "c.gguf"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(created).To(BeFalse())
+		a, _ := cl.GetBackendConfig("a")
+		Expect(a.Model).To(Equal("c.gguf"))
+		Expect(*a.ContextSize).ToNot(Equal(2048))
+	})
+
+	It("patches the configurations", func() {
+		Expect(mcs.Patch("a", map[string]interface{}{
+			"context_size": nil,
+			"parameters":   map[string]interface{}{"temperature": 0.7},
+		})).To(Succeed())
+
+		a, _ := cl.GetBackendConfig("a")
+		Expect(a.Model).To(Equal("a.gguf"))
+		Expect(*a.Temperature).To(Equal(0.7))
+		Expect(*a.ContextSize).ToNot(Equal(2048))
+
+		dat, err := os.ReadFile(filepath.Join(tempdir, "a.yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(dat)).ToNot(ContainSubstring("context_size"))
+	})
+
+	It("rejects the invalid configurations", func() {
+		var validationErr *ModelConfigValidationError
+		for _, patch := range []map[string]interface{}{
+			{"unknown_field": true},
+			{"context_size": "large"},
+			{"name": "b"},
+			{"backend": "../llama"},
+			{"parameters": map[string]interface{}{"top_p": 2}},
+			{"parameters": map[string]interface{}{"model": "${file:/run/secrets/token}"}},
+			{"stopwords": []interface{}{"${HOME}"}},
+		} {
+			Expect(errors.As(mcs.Patch("a", patch), &validationErr)).To(BeTrue(), "%v", patch)
+		}
+		_, err := mcs.Replace("../b", map[string]interface{}{})
+		Expect(errors.As(err, &validationErr)).To(BeTrue())
+		_, err = mcs.Replace("b", map[string]interface{}{"parameters": map[string]interface{}{"model": "${HF_TOKEN}"}})
+		Expect(errors.As(err, &validationErr)).To(BeTrue())
+		Expect(mcs.Patch("a", map[string]interface{}{"description": "costs $${PRICE}"})).To(Succeed())
+		Expect(mcs.Patch("a", map[string]interface{}{"description": nil})).To(Succeed())
+
+		// the file is not modified
+		a, _ := cl.GetBackendConfig("a")
+		Expect(*a.ContextSize).To(Equal(2048))
+	})
+
+	It("deletes the configurations", func() {
+		Expect(mcs.Delete("a")).To(Succeed())
+		_, exists := cl.GetBackendConfig("a")
+		Expect(exists).To(BeFalse())
+		Expect(filepath.Join(tempdir, "a.yaml")).ToNot(BeAnExistingFile())
+		Expect(mcs.Delete("a")).To(MatchError(ErrModelConfigNotFound))
+	})
+})
@@ -1,6 +1,7 @@
 package startup
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -11,9 +12,13 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"dario.cat/mergo"
 	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
+const modelsManifestFile = "models_manifest.yaml"
+
 type fileHandler func(fileContent []byte, appConfig *config.ApplicationConfig) error
 
 type configFileHandler struct {
@@ -142,6 +147,10 @@ func readApiKeysJson(startupAppConfig config.ApplicationConfig) fileHandler {
 
 			log.Trace().Int("numKeys", len(fileKeys)).Msg("discovered API keys from api keys dynamic config dile")
 
+			for i, key := range fileKeys {
+				fileKeys[i] = utils.ExpandVariables(key)
+			}
+
 			appConfig.ApiKeys = append(startupAppConfig.ApiKeys, fileKeys...)
 		} else {
 			log.Trace().Msg("no API keys discovered from dynamic config file")
@@ -165,6 +174,9 @@ func readExternalBackendsJson(startupAppConfig config.ApplicationConfig) fileHan
 			if err != nil {
 				return err
 			}
+			for name, uri := range fileBackends {
+				fileBackends[name] = utils.ExpandVariables(uri)
+			}
 			appConfig.ExternalGRPCBackends = startupAppConfig.ExternalGRPCBackends
 			err = mergo.Merge(&appConfig.ExternalGRPCBackends, &fileBackends)
 			if err != nil {
@@ -178,3 +190,43 @@ func readExternalBackendsJson(startupAppConfig config.ApplicationConfig) fileHan
 	}
 	return handler
 }
+
+func readModelsManifest(cl *config.BackendConfigLoader) fileHandler {
+	var applied []byte
+	handler := func(fileContent []byte, appConfig *config.ApplicationConfig) error {
+		// the manifest is reconciled when it changes, not on every event or poll
+		if len(fileContent) == 0 || bytes.Equal(fileContent, applied) {
+			return nil
+		}
+		log.Debug().Msg("processing models manifest")
+
+		manifest, err := gallery.ReadManifest(fileContent)
+		if err != nil {
+			return err
+		}
+
+		actions, err := gallery.ReconcileManifest(appConfig.Context, appConfig.Galleries, appConfig.ModelPath, manifest,
+			appConfig.ModelsManifestDryRun, utils.DisplayDownloadFunction, appConfig.EnforcePredownloadScans)
+		if len(actions) > 0 && !appConfig.ModelsManifestDryRun {
+			for _, a := range actions {
+				if a.Action == gallery.ManifestDelete {
+					cl.RemoveBackendConfig(a.Model)
+				}
+			}
+			if e := cl.LoadBackendConfigsFromPath(appConfig.ModelPath, appConfig.ToConfigLoaderOptions()...); e != nil {
+				log.Error().Err(e).Msg("error loading config files")
+			}
+			if e := cl.Preload(appConfig.ModelPath); e != nil {
+				log.Error().Err(e).Msg("error downloading models")
+			}
+		}
+		if err != nil {
+			// the manifest is reconciled again on the next event or poll
+			return err
+		}
+		applied = fileContent
+		log.Info().Int("changes", len(actions)).Bool("dryRun", appConfig.ModelsManifestDryRun).Msg("models manifest reconciled")
+		return nil
+	}
+	return handler
+}
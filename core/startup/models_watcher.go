@@ -0,0 +1,108 @@
+package startup
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultModelsPathPollInterval is used when the file system events are not available
+	defaultModelsPathPollInterval = 10 * time.Second
+	// modelsWatcherDebounce groups the events of the editors, which write the files in several steps
+	modelsWatcherDebounce = 500 * time.Millisecond
+)
+
+// modelsWatcher applies the changes of the configuration files of the models path while running
+type modelsWatcher struct {
+	appConfig *config.ApplicationConfig
+	configs   *services.ModelConfigService
+}
+
+// StartModelsWatcher watches the models path until the application context is done,
+// applying the changes with the model configuration service of the application
+func StartModelsWatcher(options *config.ApplicationConfig, configs *services.ModelConfigService) {
+	if options.DisableModelsWatcher {
+		return
+	}
+
+	w := &modelsWatcher{
+		appConfig: options,
+		configs:   configs,
+	}
+
+	if options.ModelsPathPollInterval > 0 {
+		log.Debug().Msg("Poll interval set, falling back to polling for changes of the models path")
+		go w.poll(options.ModelsPathPollInterval)
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = watcher.Add(options.ModelPath)
+		if err != nil {
+			watcher.Close()
+		}
+	}
+	if err != nil {
+		log.Warn().Err(err).Msgf("unable to watch the models path, polling it every %s", defaultModelsPathPollInterval)
+		go w.poll(defaultModelsPathPollInterval)
+		return
+	}
+	go w.watch(watcher)
+}
+
+func (w *modelsWatcher) poll(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.appConfig.Context.Done():
+			return
+		case <-ticker.C:
+			w.sync()
+		}
+	}
+}
+
+func (w *modelsWatcher) watch(watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+
+	debounce := time.NewTimer(modelsWatcherDebounce)
+	debounce.Stop()
+	for {
+		select {
+		case <-w.appConfig.Context.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			name := filepath.Base(event.Name)
+			if strings.HasPrefix(name, ".") || (!strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml")) {
+				continue
+			}
+			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				debounce.Reset(modelsWatcherDebounce)
+			}
+		case <-debounce.C:
+			w.sync()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Error().Err(err).Msg("models path watcher error received")
+		}
+	}
+}
+
+func (w *modelsWatcher) sync() {
+	if _, err := w.configs.Sync(); err != nil {
+		log.Error().Err(err).Msg("error reading the model configurations")
+	}
+}
@@ -5,13 +5,17 @@ import (
 	"os"
 
 	"github.com/mudler/LocalAI/core"
+	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/internal"
 	"github.com/mudler/LocalAI/pkg/assets"
+	"github.com/mudler/LocalAI/pkg/downloader"
 	"github.com/mudler/LocalAI/pkg/library"
 	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/oci"
 	pkgStartup "github.com/mudler/LocalAI/pkg/startup"
+	"github.com/mudler/LocalAI/pkg/utils"
 	"github.com/mudler/LocalAI/pkg/xsysinfo"
 	"github.com/rs/zerolog/log"
 )
@@ -60,12 +64,23 @@ func Startup(opts ...config.AppOption) (*config.BackendConfigLoader, *model.Mode
 		}
 	}
 
-	if err := pkgStartup.InstallModels(options.Galleries, options.ModelLibraryURL, options.ModelPath, options.EnforcePredownloadScans, nil, options.ModelsURL...); err != nil {
+	downloader.SetDefaultOptions(
+		downloader.WithConnections(options.DownloadConnections),
+		downloader.WithRetries(options.DownloadRetries),
+		downloader.WithBlobStore(options.BlobsPath),
+		downloader.WithHuggingFaceToken(options.HuggingFaceToken),
+	)
+
+	oci.SetRegistryCredentials(options.OCIRegistries)
+	utils.SetSecretsDir(options.SecretsDir)
+
+	if err := pkgStartup.InstallModels(options.Context, options.Galleries, options.ModelLibraryURL, options.ModelPath, options.EnforcePredownloadScans, nil, options.ModelsURL...); err != nil {
 		log.Error().Err(err).Msg("error installing models")
 	}
 
 	cl := config.NewBackendConfigLoader(options.ModelPath)
 	ml := model.NewModelLoader(options.ModelPath)
+	ml.SetBackendLogs(model.NewBackendLogs(options.BackendLogLines, options.BackendLogsDir))
 
 	configLoaderOpts := options.ToConfigLoaderOptions()
 
@@ -144,14 +159,47 @@ func Startup(opts ...config.AppOption) (*config.BackendConfigLoader, *model.Mode
 		}()
 	}
 
+	var supervisor *model.Supervisor
+	if options.BackendSupervisor {
+		supervisor = model.NewSupervisor(
+			ml,
+			model.WithSupervisorInterval(options.BackendSupervisorInterval),
+			model.WithSupervisorMaxFailures(options.BackendSupervisorMaxFailures),
+			model.WithSupervisorCooldown(options.BackendSupervisorCooldown),
+		)
+		ml.SetSupervisor(supervisor)
+		go supervisor.Run()
+		go func() {
+			<-options.Context.Done()
+			log.Debug().Msgf("Context canceled, shutting down")
+			supervisor.Shutdown()
+		}()
+	}
+
+	// Load the pinned models in the background
+	for _, c := range cl.GetAllBackendConfigs() {
+		if !c.Pinned {
+			continue
+		}
+		if supervisor != nil {
+			supervisor.Pin(c.Model)
+		}
+		go func(c config.BackendConfig) {
+			log.Info().Str("model", c.Name).Msg("Loading pinned model")
+			if err := backend.PreloadModel(c, ml, options); err != nil {
+				log.Error().Err(err).Str("model", c.Name).Msg("error loading pinned model")
+			}
+		}(c)
+	}
+
 	// Watch the configuration directory
-	startWatcher(options)
+	startWatcher(options, cl)
 
 	log.Info().Msg("core/startup process completed!")
 	return cl, ml, options, nil
 }
 
-func startWatcher(options *config.ApplicationConfig) {
+func startWatcher(options *config.ApplicationConfig, cl *config.BackendConfigLoader) {
 	if options.DynamicConfigsDir == "" {
 		// No need to start the watcher if the directory is not set
 		return
@@ -171,6 +219,9 @@ func startWatcher(options *config.ApplicationConfig) {
 	}
 
 	configHandler := newConfigFileHandler(options)
+	if err := configHandler.Register(modelsManifestFile, readModelsManifest(cl), true); err != nil {
+		log.Error().Err(err).Str("file", modelsManifestFile).Msg("unable to register config file handler")
+	}
 	if err := configHandler.Watch(); err != nil {
 		log.Error().Err(err).Msg("failed creating watcher")
 	}
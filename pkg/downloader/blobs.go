@@ -0,0 +1,216 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+var sha256Regexp = regexp.MustCompile("^[a-f0-9]{64}$")
+
+// BlobStore is a content-addressed directory of files keyed by their SHA256.
+// Model files are materialized in the models path as hardlinks to the blobs,
+// or as symlinks when the blobs are on a different filesystem.
+type BlobStore struct {
+	Path string
+}
+
+func NewBlobStore(path string) *BlobStore {
+	return &BlobStore{Path: path}
+}
+
+func (b *BlobStore) dir() string {
+	return filepath.Join(b.Path, "sha256")
+}
+
+func (b *BlobStore) BlobPath(sha string) (string, error) {
+	sha = strings.ToLower(sha)
+	if !sha256Regexp.MatchString(sha) {
+		return "", fmt.Errorf("invalid sha256 %q", sha)
+	}
+	return filepath.Join(b.dir(), sha), nil
+}
+
+// Has returns true if the store contains a blob with the given SHA256
+func (b *BlobStore) Has(sha string) bool {
+	p, err := b.BlobPath(sha)
+	if err != nil {
+		return false
+	}
+	st, err := os.Stat(p)
+	return err == nil && st.Mode().IsRegular()
+}
+
+// Link materializes the blob at dst, replacing any existing file
+func (b *BlobStore) Link(sha, dst string) error {
+	blob, err := b.BlobPath(sha)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Link(blob, dst); err == nil {
+		return nil
+	}
+	abs, err := filepath.Abs(blob)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(abs, dst)
+}
+
+// Add moves the file to the store, and links it back at its original path.
+// The SHA256 must have been verified by the caller.
+func (b *BlobStore) Add(filePath, sha string) error {
+	if b.Has(sha) {
+		return b.Link(sha, filePath)
+	}
+
+	blob, err := b.BlobPath(sha)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(b.dir(), 0750); err != nil {
+		return err
+	}
+
+	// same filesystem: the file is now also the blob
+	if err := os.Link(filePath, blob); err == nil {
+		return nil
+	}
+
+	if err := copyFile(filePath, blob+".partial"); err != nil {
+		return err
+	}
+	if err := os.Rename(blob+".partial", blob); err != nil {
+		return err
+	}
+	return b.Link(sha, filePath)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// GC removes the blobs which are not referenced anymore by any file in the models paths.
+// It returns the SHA256 of the removed blobs.
+func (b *BlobStore) GC(modelsPaths ...string) ([]string, error) {
+	entries, err := os.ReadDir(b.dir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	blobs := map[string]fs.FileInfo{}
+	for _, e := range entries {
+		if !sha256Regexp.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		blobs[e.Name()] = info
+	}
+
+	// the paths are compared once their symlinks are resolved, as the models path or the store may be symlinks
+	blobsDir, err := resolvePath(b.dir())
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := map[string]bool{}
+	for _, modelsPath := range modelsPaths {
+		root, err := resolvePath(modelsPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path == blobsDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if d.Type()&fs.ModeSymlink != 0 {
+				target, err := resolvePath(path)
+				if err != nil {
+					// dangling symlink
+					return nil
+				}
+				if filepath.Dir(target) == blobsDir {
+					referenced[filepath.Base(target)] = true
+				}
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			for sha, blob := range blobs {
+				if !referenced[sha] && blob.Size() == info.Size() && os.SameFile(blob, info) {
+					referenced[sha] = true
+				}
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	removed := []string{}
+	for sha := range blobs {
+		if referenced[sha] {
+			continue
+		}
+		log.Debug().Msgf("Removing unreferenced blob %s", sha)
+		if err := os.Remove(filepath.Join(b.dir(), sha)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, sha)
+	}
+	return removed, nil
+}
+
+// resolvePath returns the absolute path with its symlinks resolved
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
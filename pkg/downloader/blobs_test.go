@@ -0,0 +1,108 @@
+package downloader_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	. "github.com/mudler/LocalAI/pkg/downloader"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Blob store", func() {
+	var dir, modelsPath, blobsPath string
+	var content []byte
+	var sha string
+	var requests atomic.Int32
+	var server *httptest.Server
+
+	noStatus := func(string, string, string, float64) {}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "blobs")
+		Expect(err).ToNot(HaveOccurred())
+		modelsPath = filepath.Join(dir, "models")
+		blobsPath = filepath.Join(dir, "blobs")
+
+		content = []byte("some model weights")
+		sha = fmt.Sprintf("%x", sha256.Sum256(content))
+		requests.Store(0)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.Write(content)
+		}))
+		SetDefaultOptions(WithRetryBackoff(10*time.Millisecond), WithBlobStore(blobsPath))
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+		SetDefaultOptions(WithRetryBackoff(time.Second), WithBlobStore(""))
+	})
+
+	It("downloads the files shared between models only once", func() {
+		first := filepath.Join(modelsPath, "a.gguf")
+		second := filepath.Join(modelsPath, "b.gguf")
+
+		Expect(DownloadFile(server.URL+"/model.bin", first, sha, 1, 1, noStatus)).To(Succeed())
+		Expect(DownloadFile(server.URL+"/model.bin", second, sha, 1, 1, noStatus)).To(Succeed())
+		Expect(requests.Load()).To(Equal(int32(1)))
+
+		store := NewBlobStore(blobsPath)
+		Expect(store.Has(sha)).To(BeTrue())
+		for _, f := range []string{first, second} {
+			data, err := os.ReadFile(f)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal(content))
+		}
+	})
+
+	It("removes the blobs which are not referenced anymore", func() {
+		file := filepath.Join(modelsPath, "a.gguf")
+		Expect(DownloadFile(server.URL+"/model.bin", file, sha, 1, 1, noStatus)).To(Succeed())
+
+		store := NewBlobStore(blobsPath)
+		removed, err := store.GC(modelsPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(removed).To(BeEmpty())
+		Expect(store.Has(sha)).To(BeTrue())
+
+		Expect(os.Remove(file)).To(Succeed())
+		removed, err = store.GC(modelsPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(removed).To(ConsistOf(sha))
+		Expect(store.Has(sha)).To(BeFalse())
+	})
+
+	It("resolves the symlinks of the models path", func() {
+		real := filepath.Join(dir, "real")
+		Expect(os.MkdirAll(real, 0750)).To(Succeed())
+		Expect(os.Symlink(real, modelsPath)).To(Succeed())
+
+		// the store is in the models path, through its symlink
+		store := NewBlobStore(filepath.Join(modelsPath, "blobs"))
+		blob, err := store.BlobPath(sha)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Dir(blob), 0750)).To(Succeed())
+		Expect(os.WriteFile(blob, content, 0600)).To(Succeed())
+		link := filepath.Join(real, "a.gguf")
+		Expect(os.Symlink(filepath.Join(real, "blobs", "sha256", sha), link)).To(Succeed())
+
+		removed, err := store.GC(modelsPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(removed).To(BeEmpty())
+		Expect(store.Has(sha)).To(BeTrue())
+
+		Expect(os.Remove(link)).To(Succeed())
+		removed, err = store.GC(modelsPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(removed).To(ConsistOf(sha))
+	})
+})
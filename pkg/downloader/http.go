@@ -0,0 +1,531 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// files smaller than this are always downloaded with a single connection
+const minSegmentSize = 16 * 1024 * 1024
+
+type Option func(*options)
+
+type options struct {
+	connections int
+	retries     int
+	backoff     time.Duration
+	blobs       *BlobStore
+	hfToken     string
+}
+
+var defaultOptions = options{
+	connections: 1,
+	retries:     3,
+	backoff:     time.Second,
+	hfToken:     huggingFaceTokenFromEnv(),
+}
+
+// WithConnections sets the number of parallel connections used to download
+// a file, when the server supports range requests
+func WithConnections(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.connections = n
+		}
+	}
+}
+
+// WithRetries sets how many times a download is retried after a transient error
+func WithRetries(n int) Option {
+	return func(o *options) {
+		if n >= 0 {
+			o.retries = n
+		}
+	}
+}
+
+// WithRetryBackoff sets the delay before the first retry, doubled at each attempt up to 5 minutes
+func WithRetryBackoff(d time.Duration) Option {
+	return func(o *options) {
+		o.backoff = d
+	}
+}
+
+// WithBlobStore keeps the downloaded files with a known SHA256 in a content-addressed
+// directory, so the same file is downloaded and stored only once
+func WithBlobStore(path string) Option {
+	return func(o *options) {
+		if path == "" {
+			o.blobs = nil
+			return
+		}
+		o.blobs = NewBlobStore(path)
+	}
+}
+
+// WithHuggingFaceToken sets the token used to download from gated or private HuggingFace repositories.
+// It defaults to the HF_TOKEN environment variable
+func WithHuggingFaceToken(token string) Option {
+	return func(o *options) {
+		if token != "" {
+			o.hfToken = token
+		}
+	}
+}
+
+// SetDefaultOptions sets the options used by DownloadFile
+func SetDefaultOptions(opts ...Option) {
+	for _, o := range opts {
+		o(&defaultOptions)
+	}
+}
+
+// partialDownload is stored next to the .partial file, and allows to resume
+// the download only if the remote file did not change in the meantime
+type partialDownload struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Size         int64     `json:"size"`
+	Segments     []segment `json:"segments,omitempty"`
+}
+
+type segment struct {
+	Start   int64 `json:"start"`
+	End     int64 `json:"end"` // inclusive
+	Written int64 `json:"written"`
+}
+
+func (s *segment) done() bool {
+	return s.Start+s.Written > s.End
+}
+
+// validator returns the value for the If-Range header, which requires a strong ETag
+func (p *partialDownload) validator() string {
+	if p.ETag != "" && !strings.HasPrefix(p.ETag, "W/") {
+		return p.ETag
+	}
+	return p.LastModified
+}
+
+func partialMetaPath(tmpFilePath string) string {
+	return tmpFilePath + ".json"
+}
+
+func readPartialMeta(tmpFilePath, url string) *partialDownload {
+	data, err := os.ReadFile(partialMetaPath(tmpFilePath))
+	if err != nil {
+		return nil
+	}
+	p := &partialDownload{}
+	if err := json.Unmarshal(data, p); err != nil || p.URL != url || p.validator() == "" {
+		return nil
+	}
+	return p
+}
+
+func writePartialMeta(tmpFilePath string, p *partialDownload) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(partialMetaPath(tmpFilePath), data, 0600)
+}
+
+func removePartialDownload(tmpFilePath string) error {
+	if err := removePartialFile(partialMetaPath(tmpFilePath)); err != nil {
+		return err
+	}
+	return removePartialFile(tmpFilePath)
+}
+
+// errRemoteChanged is returned when the remote file changed since the partial download started
+var errRemoteChanged = errors.New("remote file changed, restarting the download")
+
+type statusError struct {
+	url  string
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("failed to download url %q, invalid status code %d", e.url, e.code)
+}
+
+// permanentError wraps the errors which are not worth retrying (e.g. local I/O errors)
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func isTransient(err error) bool {
+	var pe *permanentError
+	if errors.As(err, &pe) {
+		return false
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusTooManyRequests || se.code == http.StatusRequestTimeout
+	}
+	return true
+}
+
+// downloadHTTP downloads url into tmpFilePath, resuming a previous partial download
+// when possible, and retrying with an exponential backoff on transient errors.
+// It returns true if the content was hashed by the progress writer.
+func downloadHTTP(ctx context.Context, url, tmpFilePath string, progress *progressWriter, o options) (bool, error) {
+	restarted := false
+	for attempt := 0; ; attempt++ {
+		hashed, err := downloadHTTPAttempt(ctx, url, tmpFilePath, progress, o)
+		if err == nil {
+			return hashed, nil
+		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if errors.Is(err, errRemoteChanged) {
+			if rerr := removePartialDownload(tmpFilePath); rerr != nil {
+				return false, rerr
+			}
+			// the partial file is gone, the next attempt downloads the new file from the start:
+			// this is not a failure, so it does not count as a retry (but only once, if the file keeps changing)
+			if !restarted {
+				restarted = true
+				log.Warn().Msgf("Download of %q: %s", url, err)
+				attempt--
+				continue
+			}
+		}
+		if !isTransient(err) || attempt >= o.retries {
+			return false, err
+		}
+		wait := retryBackoff(o.backoff, attempt)
+		log.Warn().Err(err).Msgf("Download of %q failed, retrying in %s (%d/%d)", url, wait, attempt+1, o.retries)
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// maxRetryBackoff bounds the delay between the retries
+const maxRetryBackoff = 5 * time.Minute
+
+// retryBackoff returns the delay before the retry following the given attempt,
+// doubled at each attempt up to maxRetryBackoff
+func retryBackoff(backoff time.Duration, attempt int) time.Duration {
+	for ; attempt > 0 && backoff < maxRetryBackoff; attempt-- {
+		backoff *= 2
+	}
+	return min(backoff, maxRetryBackoff)
+}
+
+func downloadHTTPAttempt(ctx context.Context, url, tmpFilePath string, progress *progressWriter, o options) (bool, error) {
+	meta := readPartialMeta(tmpFilePath, url)
+
+	// a partial download can only be resumed the same way it was started
+	if o.connections > 1 && meta == nil {
+		segmented, err := newSegmentedDownload(ctx, url, o.connections)
+		if err != nil {
+			return false, err
+		}
+		if segmented != nil {
+			if err := removePartialDownload(tmpFilePath); err != nil {
+				return false, &permanentError{err}
+			}
+			meta = segmented
+		}
+	}
+
+	if meta != nil && len(meta.Segments) > 0 {
+		return false, downloadSegments(ctx, url, tmpFilePath, meta, progress)
+	}
+
+	return true, downloadStream(ctx, url, tmpFilePath, meta, progress)
+}
+
+// newSegmentedDownload returns the segments to download in parallel, or nil
+// if the server does not support range requests
+func newSegmentedDownload(ctx context.Context, url string, connections int) (*partialDownload, error) {
+	req, err := newRequest(ctx, http.MethodHead, url)
+	if err != nil {
+		return nil, &permanentError{err}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		// some servers do not allow HEAD requests, fallback to a single stream
+		return nil, nil
+	}
+
+	p := &partialDownload{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Size:         resp.ContentLength,
+	}
+	if resp.Header.Get("Accept-Ranges") != "bytes" || p.validator() == "" || p.Size < minSegmentSize {
+		return nil, nil
+	}
+
+	n := int64(connections)
+	if p.Size/n < minSegmentSize {
+		n = p.Size / minSegmentSize
+	}
+	if n < 2 {
+		return nil, nil
+	}
+	segmentSize := p.Size / n
+	for i := int64(0); i < n; i++ {
+		s := segment{Start: i * segmentSize, End: (i+1)*segmentSize - 1}
+		if i == n-1 {
+			s.End = p.Size - 1
+		}
+		p.Segments = append(p.Segments, s)
+	}
+	return p, nil
+}
+
+// downloadStream downloads the file with a single connection, appending to the partial file if it can be resumed
+func downloadStream(ctx context.Context, url, tmpFilePath string, meta *partialDownload, progress *progressWriter) error {
+	var offset int64
+	if st, err := os.Stat(tmpFilePath); err == nil && meta != nil {
+		offset = st.Size()
+	}
+
+	req, err := newRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return &permanentError{err}
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", meta.validator())
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		log.Info().Msgf("Resuming download of %q from %s", url, formatBytes(offset))
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// the partial file might be already complete
+		if contentRangeTotal(resp.Header.Get("Content-Range")) != offset {
+			return errRemoteChanged
+		}
+		progress.reset(offset, offset)
+		return hashFile(tmpFilePath, progress)
+	case resp.StatusCode >= 400:
+		return &statusError{url: url, code: resp.StatusCode}
+	default:
+		// the server sent the whole file
+		offset = 0
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	} else {
+		meta = &partialDownload{
+			URL:          url,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Size:         resp.ContentLength,
+		}
+		if err := writePartialMeta(tmpFilePath, meta); err != nil {
+			return &permanentError{err}
+		}
+	}
+
+	outFile, err := os.OpenFile(tmpFilePath, flags, 0644)
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to create file %q: %v", tmpFilePath, err)}
+	}
+	defer outFile.Close()
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	progress.reset(offset, total)
+	if offset > 0 {
+		// the hash has to cover the content downloaded before
+		if err := hashFile(tmpFilePath, progress); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.Copy(outFile, io.TeeReader(resp.Body, progress)); err != nil {
+		return fmt.Errorf("failed to write file %q: %w", tmpFilePath, err)
+	}
+	return nil
+}
+
+// downloadSegments downloads the segments of the file in parallel, writing them at their offset in the partial file
+func downloadSegments(ctx context.Context, url, tmpFilePath string, meta *partialDownload, progress *progressWriter) error {
+	f, err := os.OpenFile(tmpFilePath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to create file %q: %v", tmpFilePath, err)}
+	}
+	defer f.Close()
+	if err := f.Truncate(meta.Size); err != nil {
+		return &permanentError{err}
+	}
+
+	var mu sync.Mutex
+	save := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := writePartialMeta(tmpFilePath, meta); err != nil {
+			log.Warn().Err(err).Msgf("failed to save the state of the download of %q", url)
+		}
+	}
+	save()
+
+	var written int64
+	for _, s := range meta.Segments {
+		written += s.Written
+	}
+	progress.reset(written, meta.Size)
+	if written > 0 {
+		log.Info().Msgf("Resuming download of %q from %s", url, formatBytes(written))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		// keep the state on disk to resume after a crash
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				save()
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(meta.Segments))
+	for i := range meta.Segments {
+		s := &meta.Segments[i]
+		if s.done() {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- downloadSegment(ctx, url, meta.validator(), f, s, &mu, progress)
+		}()
+	}
+	wg.Wait()
+	close(done)
+	close(errs)
+	save()
+
+	err = nil
+	for e := range errs {
+		err = errors.Join(err, e)
+	}
+	return err
+}
+
+func downloadSegment(ctx context.Context, url, validator string, f *os.File, s *segment, mu *sync.Mutex, progress *progressWriter) error {
+	mu.Lock()
+	start := s.Start + s.Written
+	mu.Unlock()
+
+	req, err := newRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, s.End))
+	req.Header.Set("If-Range", validator)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return errRemoteChanged
+	case resp.StatusCode >= 400:
+		return &statusError{url: url, code: resp.StatusCode}
+	}
+
+	buf := make([]byte, 32*1024)
+	for start <= s.End {
+		n, rerr := resp.Body.Read(buf)
+		if int64(n) > s.End-start+1 {
+			n = int(s.End - start + 1)
+		}
+		if n > 0 {
+			if _, err := f.WriteAt(buf[:n], start); err != nil {
+				return &permanentError{err}
+			}
+			start += int64(n)
+			mu.Lock()
+			s.Written += int64(n)
+			mu.Unlock()
+			progress.Write(buf[:n])
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+	if start <= s.End {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// contentRangeTotal returns the total size from a "bytes */1234" Content-Range header, or -1
+func contentRangeTotal(contentRange string) int64 {
+	i := strings.LastIndex(contentRange, "/")
+	if i < 0 {
+		return -1
+	}
+	total, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return total
+}
+
+// hashFile feeds the content of the partial file to the hash of the progress writer
+func hashFile(path string, progress *progressWriter) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return &permanentError{err}
+	}
+	defer f.Close()
+	if _, err := io.Copy(progress.hash, f); err != nil {
+		return &permanentError{err}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package downloader_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/mudler/LocalAI/pkg/downloader"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTP downloads", func() {
+	var dir string
+	var content []byte
+	var sha string
+
+	noStatus := func(string, string, string, float64) {}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "downloader")
+		Expect(err).ToNot(HaveOccurred())
+		SetDefaultOptions(WithRetryBackoff(10*time.Millisecond), WithRetries(3), WithConnections(1))
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+		SetDefaultOptions(WithRetryBackoff(time.Second), WithConnections(1))
+	})
+
+	setContent := func(size int) {
+		content = bytes.Repeat([]byte("0123456789abcdef"), size/16)
+		sha = fmt.Sprintf("%x", sha256.Sum256(content))
+	}
+
+	It("resumes an interrupted download", func() {
+		setContent(1024 * 1024)
+		var mu sync.Mutex
+		ranges := []string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			first := len(ranges) == 1
+			mu.Unlock()
+
+			w.Header().Set("ETag", `"v1"`)
+			if first {
+				// send only half of the file, then drop the connection
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				w.Write(content[:len(content)/2])
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+			http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(content))
+		}))
+		defer server.Close()
+
+		target := filepath.Join(dir, "model.bin")
+		Expect(DownloadFile(server.URL+"/model.bin", target, sha, 1, 1, noStatus)).To(Succeed())
+
+		data, err := os.ReadFile(target)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(content))
+		Expect(ranges).To(HaveLen(2))
+		Expect(ranges[1]).To(Equal(fmt.Sprintf("bytes=%d-", len(content)/2)))
+		Expect(filepath.Join(dir, "model.bin.partial")).ToNot(BeAnExistingFile())
+		Expect(filepath.Join(dir, "model.bin.partial.json")).ToNot(BeAnExistingFile())
+	})
+
+	It("downloads in parallel segments", func() {
+		setContent(40 * 1024 * 1024)
+		var mu sync.Mutex
+		ranges := []string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				mu.Lock()
+				ranges = append(ranges, r.Header.Get("Range"))
+				mu.Unlock()
+			}
+			w.Header().Set("ETag", `"v1"`)
+			http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(content))
+		}))
+		defer server.Close()
+
+		SetDefaultOptions(WithConnections(4))
+		target := filepath.Join(dir, "model.bin")
+		Expect(DownloadFile(server.URL+"/model.bin", target, sha, 1, 1, noStatus)).To(Succeed())
+
+		data, err := os.ReadFile(target)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(bytes.Equal(data, content)).To(BeTrue())
+		// 40MB with segments of at least 16MB
+		Expect(ranges).To(HaveLen(2))
+		Expect(ranges).To(ContainElement(HavePrefix("bytes=0-")))
+	})
+
+	It("does not retry on client errors", func() {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		Expect(DownloadFile(server.URL+"/model.bin", filepath.Join(dir, "model.bin"), "", 1, 1, noStatus)).ToNot(Succeed())
+		Expect(requests).To(Equal(1))
+	})
+
+	It("retries on server errors", func() {
+		setContent(1024)
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(content))
+		}))
+		defer server.Close()
+
+		Expect(DownloadFile(server.URL+"/model.bin", filepath.Join(dir, "model.bin"), sha, 1, 1, noStatus)).To(Succeed())
+		Expect(requests).To(Equal(3))
+	})
+
+	It("restarts the download right away when the remote file changed, without counting a retry", func() {
+		setContent(1024 * 1024)
+		var mu sync.Mutex
+		ranges := []string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			n := len(ranges)
+			mu.Unlock()
+
+			w.Header().Set("ETag", `"v1"`)
+			switch n {
+			case 1:
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				w.Write(content[:len(content)/2])
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			case 2:
+				// the file shrank since the first request
+				w.Header().Set("Content-Range", "bytes */10")
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			http.ServeContent(w, r, "model.bin", time.Time{}, bytes.NewReader(content))
+		}))
+		defer server.Close()
+
+		SetDefaultOptions(WithRetries(1))
+		target := filepath.Join(dir, "model.bin")
+		Expect(DownloadFile(server.URL+"/model.bin", target, sha, 1, 1, noStatus)).To(Succeed())
+
+		data, err := os.ReadFile(target)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(content))
+		Expect(ranges).To(Equal([]string{"", fmt.Sprintf("bytes=%d-", len(content)/2), ""}))
+	})
+
+	It("stops and removes the partial download when cancelled", func() {
+		setContent(1024)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			// never sends the rest of the file
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		status := func(string, string, string, float64) { cancel() }
+		target := filepath.Join(dir, "model.bin")
+		err := DownloadFileWithContext(ctx, server.URL+"/model.bin", target, sha, 1, 1, status)
+		Expect(err).To(MatchError(context.Canceled))
+
+		files, err := os.ReadDir(dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(files).To(BeEmpty())
+	})
+
+	It("stops the download of an OCI image when cancelled", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the registry never answers
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		done := make(chan error)
+		go func() {
+			done <- DownloadFileWithContext(ctx, "oci://"+strings.TrimPrefix(server.URL, "http://")+"/model:latest", filepath.Join(dir, "model.bin"), "", 1, 1, noStatus)
+		}()
+		Eventually(done, 10*time.Second).Should(Receive(MatchError(context.DeadlineExceeded)))
+	})
+})
@@ -0,0 +1,327 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// HuggingFaceEndpoint is the base URL of the HuggingFace hub. It can be changed with HF_ENDPOINT, e.g. to use a mirror
+var HuggingFaceEndpoint = huggingFaceEndpointFromEnv()
+
+func huggingFaceEndpointFromEnv() string {
+	if e := os.Getenv("HF_ENDPOINT"); e != "" {
+		return strings.TrimSuffix(e, "/")
+	}
+	return "https://huggingface.co"
+}
+
+func huggingFaceTokenFromEnv() string {
+	if t := os.Getenv("HF_TOKEN"); t != "" {
+		return t
+	}
+	return os.Getenv("HUGGING_FACE_HUB_TOKEN")
+}
+
+func huggingFaceHost() string {
+	u, err := url.Parse(HuggingFaceEndpoint)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// newRequest creates a request to url, authenticated if url points to the HuggingFace hub
+func newRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := defaultOptions.hfToken; token != "" && req.URL.Host == huggingFaceHost() {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// huggingFaceURI is a parsed huggingface://owner/repo/path@branch URI
+type huggingFaceURI struct {
+	Owner  string
+	Repo   string
+	Branch string
+	Path   string
+}
+
+func parseHuggingFaceURI(s string) (huggingFaceURI, error) {
+	rest := strings.TrimPrefix(s, HuggingFacePrefix)
+	u := huggingFaceURI{Branch: "main"}
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		u.Branch = rest[i+1:]
+		rest = rest[:i]
+	}
+	parts := strings.SplitN(rest, "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return u, fmt.Errorf("invalid huggingface URI %q, expected %sowner/repo/file", s, HuggingFacePrefix)
+	}
+	u.Owner, u.Repo = parts[0], parts[1]
+	if len(parts) == 3 {
+		u.Path = parts[2]
+	}
+	return u, nil
+}
+
+func (u huggingFaceURI) resolveURL(p string) string {
+	return fmt.Sprintf("%s/%s/%s/resolve/%s/%s", HuggingFaceEndpoint, u.Owner, u.Repo, u.Branch, p)
+}
+
+func (u huggingFaceURI) uri(p string) string {
+	return fmt.Sprintf("%s%s/%s/%s@%s", HuggingFacePrefix, u.Owner, u.Repo, p, u.Branch)
+}
+
+// isListing returns true if the URI refers to a folder, or to the files matching a glob pattern
+func (u huggingFaceURI) isListing() bool {
+	return u.Path == "" || strings.HasSuffix(u.Path, "/") || strings.ContainsAny(u.Path, "*?[")
+}
+
+// listingRoot returns the folder which contains all the files matched by the URI
+func (u huggingFaceURI) listingRoot() string {
+	root := []string{}
+	for _, s := range strings.Split(strings.TrimSuffix(u.Path, "/"), "/") {
+		if strings.ContainsAny(s, "*?[") {
+			break
+		}
+		root = append(root, s)
+	}
+	if !strings.HasSuffix(u.Path, "/") && len(root) == len(strings.Split(u.Path, "/")) {
+		// the last element is a file
+		root = root[:len(root)-1]
+	}
+	return strings.Join(root, "/")
+}
+
+type huggingFaceTreeEntry struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	LFS  *struct {
+		Oid string `json:"oid"`
+	} `json:"lfs,omitempty"`
+}
+
+var linkNextRegexp = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// listFiles returns all the files in the folder of the repository, recursively
+func (u huggingFaceURI) listFiles(folder string) ([]huggingFaceTreeEntry, error) {
+	next := fmt.Sprintf("%s/api/models/%s/%s/tree/%s", HuggingFaceEndpoint, u.Owner, u.Repo, url.PathEscape(u.Branch))
+	if folder != "" {
+		next += "/" + folder
+	}
+	next += "?recursive=true"
+
+	files := []huggingFaceTreeEntry{}
+	for next != "" {
+		req, err := newRequest(context.Background(), http.MethodGet, next)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		entries := []huggingFaceTreeEntry{}
+		err = json.NewDecoder(resp.Body).Decode(&entries)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed listing the files of %s/%s: %w", u.Owner, u.Repo, &statusError{url: next, code: resp.StatusCode})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed listing the files of %s/%s: %w", u.Owner, u.Repo, err)
+		}
+		for _, e := range entries {
+			if e.Type == "file" {
+				files = append(files, e)
+			}
+		}
+
+		next = ""
+		if m := linkNextRegexp.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			next = m[1]
+		}
+	}
+	return files, nil
+}
+
+func (e huggingFaceTreeEntry) sha256() string {
+	if e.LFS == nil {
+		// files not stored with LFS are identified by their git hash only
+		return ""
+	}
+	return e.LFS.Oid
+}
+
+// RemoteFile is a file to download, and the path where it is stored relative to the models path
+type RemoteFile struct {
+	URI      string
+	Filename string
+	SHA256   string
+}
+
+var shardRegexp = regexp.MustCompile(`^(.+)-(\d{5})-of-(\d{5})\.gguf$`)
+
+// shardCount returns the number of files of a sharded GGUF, if name is the first of them
+func shardCount(name string) int {
+	m := shardRegexp.FindStringSubmatch(name)
+	if m == nil || m[2] != "00001" {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[3])
+	return n
+}
+
+func shardName(name string, i int) string {
+	m := shardRegexp.FindStringSubmatch(name)
+	return fmt.Sprintf("%s-%05d-of-%s.gguf", m[1], i, m[3])
+}
+
+// ShardNames returns the names of all the files of a sharded GGUF if name is the first of them,
+// or name otherwise
+func ShardNames(name string) []string {
+	n := shardCount(filepath.Base(name))
+	if n < 2 {
+		return []string{name}
+	}
+	names := []string{}
+	for i := 1; i <= n; i++ {
+		names = append(names, filepath.Join(filepath.Dir(name), shardName(filepath.Base(name), i)))
+	}
+	return names
+}
+
+// IsMultiFileURI returns true if the URI refers to a HuggingFace folder, or to the files matching a glob pattern
+func IsMultiFileURI(uri string) bool {
+	if !strings.HasPrefix(uri, HuggingFacePrefix) {
+		return false
+	}
+	u, err := parseHuggingFaceURI(uri)
+	return err == nil && u.isListing()
+}
+
+// ExpandURI returns the files to download for f. HuggingFace folders and glob patterns
+// (e.g. huggingface://owner/repo/*Q4_K_M*.gguf) are expanded to the matching files of the
+// repository, stored in the f.Filename folder. The first file of a sharded GGUF
+// (model-00001-of-00003.gguf) is expanded to all the files of the set.
+// Any other file is returned as is.
+func ExpandURI(f RemoteFile) ([]RemoteFile, error) {
+	var hf *huggingFaceURI
+	if strings.HasPrefix(f.URI, HuggingFacePrefix) {
+		u, err := parseHuggingFaceURI(f.URI)
+		if err != nil {
+			return nil, err
+		}
+		if u.isListing() {
+			return u.expandListing(f.Filename)
+		}
+		hf = &u
+	}
+
+	name := remoteFileName(f.URI)
+	n := shardCount(name)
+	if n < 2 {
+		return []RemoteFile{f}, nil
+	}
+
+	dstName := filepath.Base(f.Filename)
+	if f.Filename == "" || shardCount(dstName) != n {
+		log.Warn().Str("filename", f.Filename).Msgf("%q is a sharded GGUF: the files are stored with their original name", f.URI)
+		dstName = name
+	}
+
+	files := []RemoteFile{}
+	i := strings.LastIndex(f.URI, name)
+	for s := 1; s <= n; s++ {
+		file := RemoteFile{
+			URI:      f.URI[:i] + shardName(name, s) + f.URI[i+len(name):],
+			Filename: filepath.Join(filepath.Dir(f.Filename), shardName(dstName, s)),
+		}
+		if s == 1 {
+			file.SHA256 = f.SHA256
+		}
+		files = append(files, file)
+	}
+
+	if hf != nil {
+		// the listing of the repository gives the SHA of all the files of the set
+		folder := path.Dir(hf.Path)
+		if folder == "." {
+			folder = ""
+		}
+		entries, err := hf.listFiles(folder)
+		if err != nil {
+			log.Warn().Err(err).Msgf("unable to get the SHA of the files of %q", f.URI)
+			return files, nil
+		}
+		shas := map[string]string{}
+		for _, e := range entries {
+			shas[path.Base(e.Path)] = e.sha256()
+		}
+		for s := range files {
+			if files[s].SHA256 == "" {
+				files[s].SHA256 = shas[shardName(name, s+1)]
+			}
+		}
+	}
+	return files, nil
+}
+
+func (u huggingFaceURI) expandListing(dst string) ([]RemoteFile, error) {
+	root := u.listingRoot()
+	entries, err := u.listFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []RemoteFile{}
+	for _, e := range entries {
+		if strings.ContainsAny(u.Path, "*?[") {
+			if ok, err := path.Match(u.Path, e.Path); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", u.Path, err)
+			} else if !ok {
+				continue
+			}
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(e.Path, root), "/")
+		files = append(files, RemoteFile{
+			URI:      u.uri(e.Path),
+			Filename: filepath.Join(dst, filepath.FromSlash(rel)),
+			SHA256:   e.sha256(),
+		})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files matching %q in %s/%s", u.Path, u.Owner, u.Repo)
+	}
+	return files, nil
+}
+
+func remoteFileName(uri string) string {
+	if strings.HasPrefix(uri, HuggingFacePrefix) {
+		u, err := parseHuggingFaceURI(uri)
+		if err != nil {
+			return ""
+		}
+		return path.Base(u.Path)
+	}
+	p, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	return path.Base(p.Path)
+}
@@ -0,0 +1,133 @@
+package downloader_test
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/mudler/LocalAI/pkg/downloader"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HuggingFace", func() {
+	var server *httptest.Server
+	var endpoint string
+	var authorizations []string
+
+	files := map[string]string{
+		"README.md":                     "readme",
+		"model.Q4_K_M.gguf":             "q4",
+		"model.Q8_0.gguf":               "q8",
+		"big/big-00001-of-00002.gguf":   "shard 1",
+		"big/big-00002-of-00002.gguf":   "shard 2",
+		"tokenizer/tokenizer.json":      "{}",
+		"tokenizer/tokenizer_config.js": "{}",
+	}
+	shaOf := func(content string) string {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	}
+
+	noStatus := func(string, string, string, float64) {}
+
+	BeforeEach(func() {
+		authorizations = []string{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorizations = append(authorizations, r.Header.Get("Authorization"))
+
+			if folder, ok := strings.CutPrefix(r.URL.Path, "/api/models/owner/repo/tree/main"); ok {
+				folder = strings.TrimPrefix(folder, "/")
+				entries := []map[string]interface{}{}
+				for p, content := range files {
+					if folder != "" && !strings.HasPrefix(p, folder+"/") {
+						continue
+					}
+					entries = append(entries, map[string]interface{}{
+						"type": "file",
+						"path": p,
+						"size": len(content),
+						"lfs":  map[string]interface{}{"oid": shaOf(content)},
+					})
+				}
+				json.NewEncoder(w).Encode(entries)
+				return
+			}
+
+			if p, ok := strings.CutPrefix(r.URL.Path, "/owner/repo/resolve/main/"); ok {
+				if content, ok := files[p]; ok {
+					w.Write([]byte(content))
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		endpoint = HuggingFaceEndpoint
+		HuggingFaceEndpoint = server.URL
+		SetDefaultOptions(WithRetryBackoff(10*time.Millisecond), WithHuggingFaceToken("secret"))
+	})
+
+	AfterEach(func() {
+		server.Close()
+		HuggingFaceEndpoint = endpoint
+		SetDefaultOptions(WithRetryBackoff(time.Second))
+	})
+
+	It("converts URIs with folders and branches", func() {
+		Expect(ConvertURL("huggingface://owner/repo/folder/model.gguf@dev")).To(Equal(server.URL + "/owner/repo/resolve/dev/folder/model.gguf"))
+		Expect(ConvertURL("huggingface://owner/repo/model.gguf")).To(Equal(server.URL + "/owner/repo/resolve/main/model.gguf"))
+	})
+
+	It("expands glob patterns", func() {
+		expanded, err := ExpandURI(RemoteFile{URI: "huggingface://owner/repo/*Q4_K_M*.gguf"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(expanded).To(ConsistOf(RemoteFile{
+			URI:      "huggingface://owner/repo/model.Q4_K_M.gguf@main",
+			Filename: "model.Q4_K_M.gguf",
+			SHA256:   shaOf("q4"),
+		}))
+	})
+
+	It("expands folders", func() {
+		expanded, err := ExpandURI(RemoteFile{URI: "huggingface://owner/repo/tokenizer/", Filename: "tok"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(expanded).To(HaveLen(2))
+		Expect(expanded[0].Filename).To(HavePrefix("tok" + string(filepath.Separator)))
+	})
+
+	It("expands sharded models", func() {
+		expanded, err := ExpandURI(RemoteFile{URI: "huggingface://owner/repo/big/big-00001-of-00002.gguf", Filename: "big-00001-of-00002.gguf"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(expanded).To(Equal([]RemoteFile{
+			{URI: "huggingface://owner/repo/big/big-00001-of-00002.gguf", Filename: "big-00001-of-00002.gguf", SHA256: shaOf("shard 1")},
+			{URI: "huggingface://owner/repo/big/big-00002-of-00002.gguf", Filename: "big-00002-of-00002.gguf", SHA256: shaOf("shard 2")},
+		}))
+
+		expanded, err = ExpandURI(RemoteFile{URI: "https://example.com/m-00001-of-00003.gguf?download=true", Filename: "models/m-00001-of-00003.gguf"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(expanded).To(HaveLen(3))
+		Expect(expanded[2].URI).To(Equal("https://example.com/m-00003-of-00003.gguf?download=true"))
+		Expect(expanded[2].Filename).To(Equal(filepath.Join("models", "m-00003-of-00003.gguf")))
+	})
+
+	It("sends the token only to the HuggingFace hub", func() {
+		dir, err := os.MkdirTemp("", "hf")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		Expect(DownloadFile("huggingface://owner/repo/model.Q8_0.gguf", filepath.Join(dir, "model.gguf"), shaOf("q8"), 1, 1, noStatus)).To(Succeed())
+		Expect(authorizations).To(ContainElement("Bearer secret"))
+
+		other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Authorization")).To(BeEmpty())
+			w.Write([]byte("other"))
+		}))
+		defer other.Close()
+		Expect(DownloadFile(other.URL+"/other.gguf", filepath.Join(dir, "other.gguf"), "", 1, 1, noStatus)).To(Succeed())
+	})
+})
@@ -1,8 +1,12 @@
 package downloader
 
-import "hash"
+import (
+	"hash"
+	"sync"
+)
 
 type progressWriter struct {
+	sync.Mutex
 	fileName       string
 	total          int64
 	fileNo         int
@@ -12,8 +16,25 @@ type progressWriter struct {
 	hash           hash.Hash
 }
 
+// reset sets the progress of a download which is (re)starting
+func (pw *progressWriter) reset(written, total int64) {
+	pw.Lock()
+	defer pw.Unlock()
+	pw.written = written
+	pw.total = total
+	if pw.hash != nil {
+		pw.hash.Reset()
+	}
+}
+
 func (pw *progressWriter) Write(p []byte) (n int, err error) {
-	n, err = pw.hash.Write(p)
+	pw.Lock()
+	defer pw.Unlock()
+
+	n = len(p)
+	if pw.hash != nil {
+		n, err = pw.hash.Write(p)
+	}
 	pw.written += int64(n)
 
 	if pw.total > 0 {
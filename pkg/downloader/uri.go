@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
@@ -62,7 +63,11 @@ func DownloadAndUnmarshal(url string, basePath string, f func(url string, i []by
 	}
 
 	// Send a GET request to the URL
-	response, err := http.Get(url)
+	req, err := newRequest(context.Background(), http.MethodGet, url)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -126,22 +131,13 @@ func ConvertURL(s string) string {
 
 		return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", org, project, branch, projectPath)
 	case strings.HasPrefix(s, HuggingFacePrefix):
-		repository := strings.Replace(s, HuggingFacePrefix, "", 1)
 		// convert repository to a full URL.
 		// e.g. TheBloke/Mixtral-8x7B-v0.1-GGUF/mixtral-8x7b-v0.1.Q2_K.gguf@main -> https://huggingface.co/TheBloke/Mixtral-8x7B-v0.1-GGUF/resolve/main/mixtral-8x7b-v0.1.Q2_K.gguf
-		owner := strings.Split(repository, "/")[0]
-		repo := strings.Split(repository, "/")[1]
-
-		branch := "main"
-		if strings.Contains(repo, "@") {
-			branch = strings.Split(repository, "@")[1]
-		}
-		filepath := strings.Split(repository, "/")[2]
-		if strings.Contains(filepath, "@") {
-			filepath = strings.Split(filepath, "@")[0]
+		u, err := parseHuggingFaceURI(s)
+		if err != nil {
+			return s
 		}
-
-		return fmt.Sprintf("https://huggingface.co/%s/%s/resolve/%s/%s", owner, repo, branch, filepath)
+		return u.resolveURL(u.Path)
 	}
 
 	return s
@@ -162,6 +158,12 @@ func removePartialFile(tmpFilePath string) error {
 }
 
 func DownloadFile(url string, filePath, sha string, fileN, total int, downloadStatus func(string, string, string, float64)) error {
+	return DownloadFileWithContext(context.Background(), url, filePath, sha, fileN, total, downloadStatus)
+}
+
+// DownloadFileWithContext is DownloadFile, stopping the download when ctx is cancelled.
+// The partial download is removed in that case, rather than kept to be resumed.
+func DownloadFileWithContext(ctx context.Context, url string, filePath, sha string, fileN, total int, downloadStatus func(string, string, string, float64)) error {
 	url = ConvertURL(url)
 	if LooksLikeOCI(url) {
 		progressStatus := func(desc ocispec.Descriptor) io.Writer {
@@ -177,16 +179,25 @@ func DownloadFile(url string, filePath, sha string, fileN, total int, downloadSt
 
 		if strings.HasPrefix(url, OllamaPrefix) {
 			url = strings.TrimPrefix(url, OllamaPrefix)
-			return oci.OllamaFetchModel(url, filePath, progressStatus)
+			err := oci.OllamaFetchModelWithContext(ctx, url, filePath, progressStatus)
+			if err != nil && ctx.Err() != nil {
+				os.Remove(filePath)
+				return fmt.Errorf("download of %q cancelled: %w", url, ctx.Err())
+			}
+			return err
 		}
 
 		url = strings.TrimPrefix(url, OCIPrefix)
-		img, err := oci.GetImage(url, "", nil, nil)
-		if err != nil {
-			return fmt.Errorf("failed to get image %q: %v", url, err)
+		img, err := oci.GetImageWithContext(ctx, url, "", nil, nil)
+		if err == nil {
+			err = oci.ExtractOCIImageWithContext(ctx, img, filepath.Dir(filePath))
+		} else {
+			err = fmt.Errorf("failed to get image %q: %v", url, err)
 		}
-
-		return oci.ExtractOCIImage(img, filepath.Dir(filePath))
+		if err != nil && ctx.Err() != nil {
+			return fmt.Errorf("download of %q cancelled: %w", url, ctx.Err())
+		}
+		return err
 	}
 
 	// Check if the file already exists
@@ -202,6 +213,9 @@ func DownloadFile(url string, filePath, sha string, fileN, total int, downloadSt
 			if calculatedSHA == sha {
 				// SHA matches, skip downloading
 				log.Debug().Msgf("File %q already exists and matches the SHA. Skipping download", filePath)
+				if blobs := defaultOptions.blobs; blobs != nil {
+					return blobs.Add(filePath, sha)
+				}
 				return nil
 			}
 			// SHA doesn't match, delete the file and download again
@@ -221,18 +235,12 @@ func DownloadFile(url string, filePath, sha string, fileN, total int, downloadSt
 		return fmt.Errorf("failed to check file %q existence: %v", filePath, err)
 	}
 
-	log.Info().Msgf("Downloading %q", url)
-
-	// Download file
-	resp, err := http.Get(url)
-	if err != nil {
-		return fmt.Errorf("failed to download file %q: %v", filePath, err)
+	if blobs := defaultOptions.blobs; blobs != nil && sha != "" && blobs.Has(sha) {
+		log.Info().Msgf("File %q is already in the blob store, skipping download of %q", filePath, url)
+		return blobs.Link(sha, filePath)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("failed to download url %q, invalid status code %d", url, resp.StatusCode)
-	}
+	log.Info().Msgf("Downloading %q", url)
 
 	// Create parent directory
 	err = os.MkdirAll(filepath.Dir(filePath), 0750)
@@ -240,47 +248,54 @@ func DownloadFile(url string, filePath, sha string, fileN, total int, downloadSt
 		return fmt.Errorf("failed to create parent directory for file %q: %v", filePath, err)
 	}
 
-	// save partial download to dedicated file
+	// save partial download to dedicated file, which allows to resume it
 	tmpFilePath := filePath + ".partial"
 
-	// remove tmp file
-	err = removePartialFile(tmpFilePath)
-	if err != nil {
-		return err
-	}
-
-	// Create and write file content
-	outFile, err := os.Create(tmpFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to create file %q: %v", tmpFilePath, err)
-	}
-	defer outFile.Close()
-
 	progress := &progressWriter{
 		fileName:       tmpFilePath,
-		total:          resp.ContentLength,
 		hash:           sha256.New(),
 		fileNo:         fileN,
 		totalFiles:     total,
 		downloadStatus: downloadStatus,
 	}
-	_, err = io.Copy(io.MultiWriter(outFile, progress), resp.Body)
+	hashed, err := downloadHTTP(ctx, url, tmpFilePath, progress, defaultOptions)
 	if err != nil {
-		return fmt.Errorf("failed to write file %q: %v", filePath, err)
+		if ctx.Err() != nil {
+			if rerr := removePartialDownload(tmpFilePath); rerr != nil {
+				log.Warn().Err(rerr).Msgf("failed to remove the partial download of %q", url)
+			}
+			return fmt.Errorf("download of %q cancelled: %w", url, ctx.Err())
+		}
+		return fmt.Errorf("failed to download file %q: %w", filePath, err)
 	}
 
 	err = os.Rename(tmpFilePath, filePath)
 	if err != nil {
 		return fmt.Errorf("failed to rename temporary file %s -> %s: %v", tmpFilePath, filePath, err)
 	}
+	if err := removePartialFile(partialMetaPath(tmpFilePath)); err != nil {
+		return err
+	}
 
 	if sha != "" {
 		// Verify SHA
 		calculatedSHA := fmt.Sprintf("%x", progress.hash.Sum(nil))
+		if !hashed {
+			// the file was downloaded in segments
+			calculatedSHA, err = calculateSHA(filePath)
+			if err != nil {
+				return fmt.Errorf("failed to calculate SHA for file %q: %v", filePath, err)
+			}
+		}
 		if calculatedSHA != sha {
 			log.Debug().Msgf("SHA mismatch for file %q ( calculated: %s != metadata: %s )", filePath, calculatedSHA, sha)
 			return fmt.Errorf("SHA mismatch for file %q ( calculated: %s != metadata: %s )", filePath, calculatedSHA, sha)
 		}
+		if blobs := defaultOptions.blobs; blobs != nil {
+			if err := blobs.Add(filePath, sha); err != nil {
+				return fmt.Errorf("failed to add %q to the blob store: %w", filePath, err)
+			}
+		}
 	} else {
 		log.Debug().Msgf("SHA missing for %q. Skipping validation", filePath)
 	}
@@ -371,13 +386,18 @@ var ErrUnsafeFilesFound = errors.New("unsafe files found")
 
 func HuggingFaceScan(uri string) (*HuggingFaceScanResult, error) {
 	cleanParts := strings.Split(ConvertURL(uri), "/")
-	if len(cleanParts) <= 4 || cleanParts[2] != "huggingface.co" {
+	if len(cleanParts) <= 4 || cleanParts[2] != huggingFaceHost() {
 		return nil, ErrNonHuggingFaceFile
 	}
-	results, err := http.Get(fmt.Sprintf("https://huggingface.co/api/models/%s/%s/scan", cleanParts[3], cleanParts[4]))
+	req, err := newRequest(context.Background(), http.MethodGet, fmt.Sprintf("%s/api/models/%s/%s/scan", HuggingFaceEndpoint, cleanParts[3], cleanParts[4]))
+	if err != nil {
+		return nil, err
+	}
+	results, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer results.Body.Close()
 	if results.StatusCode != 200 {
 		return nil, fmt.Errorf("unexpected status code during HuggingFaceScan: %d", results.StatusCode)
 	}
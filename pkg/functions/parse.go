@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/mudler/LocalAI/pkg/reasoning"
 	"github.com/mudler/LocalAI/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
@@ -80,6 +81,9 @@ type FunctionsConfig struct {
 	// This might be useful for certain models trained with the function name as the first token.
 	FunctionNameKey      string `yaml:"function_name_key"`
 	FunctionArgumentsKey string `yaml:"function_arguments_key"`
+
+	// ReasoningConfig is the reasoning configuration of the model, whose blocks are ignored when parsing the function calls
+	ReasoningConfig reasoning.Config `yaml:"-"`
 }
 
 type ReplaceResult struct {
@@ -193,6 +197,9 @@ func ParseFunctionCall(llmresult string, functionConfig FunctionsConfig) []FuncC
 
 	log.Debug().Msgf("LLM result: %s", llmresult)
 
+	// the reasoning of the model may contain drafts of the calls
+	llmresult = functionConfig.ReasoningConfig.Strip(llmresult)
+
 	for _, item := range functionConfig.ReplaceFunctionResults {
 		k, v := item.Key, item.Value
 		log.Debug().Msgf("Replacing %s with %s", k, v)
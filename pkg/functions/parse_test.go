@@ -2,6 +2,7 @@ package functions_test
 
 import (
 	. "github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/reasoning"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -25,6 +26,27 @@ var _ = Describe("LocalAI function parse tests", func() {
 		})
 	})
 
+	Context("when the model reasons before calling the function", func() {
+		It("should ignore the calls inside the reasoning blocks", func() {
+			input := "<think>I could call {\"name\": \"sub\", \"arguments\": {\"x\": 5}}, or rather add</think>\n{\"name\": \"add\", \"arguments\": {\"x\": 5, \"y\": 3}}"
+
+			results := ParseFunctionCall(input, functionConfig)
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Name).To(Equal("add"))
+			Expect(results[0].Arguments).To(Equal(`{"x":5,"y":3}`))
+		})
+
+		It("should use the reasoning tags of the model", func() {
+			input := `[THINK]sub({"x":5})[/THINK] add({"x":5,"y":3})`
+			functionConfig.ResponseRegex = []string{`(?P<name>\w+)\s*\((?P<arguments>.*)\)`}
+			functionConfig.ReasoningConfig = reasoning.Config{StartTag: "[THINK]", EndTag: "[/THINK]"}
+
+			results := ParseFunctionCall(input, functionConfig)
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Name).To(Equal("add"))
+		})
+	})
+
 	Context("when not using grammars and regex is needed", func() {
 		It("should extract function name and arguments from the regex", func() {
 			input := `add({"x":5,"y":3})`
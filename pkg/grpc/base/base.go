@@ -65,6 +65,10 @@ func (llm *Base) TokenizeString(opts *pb.PredictOptions) (pb.TokenizationRespons
 	return pb.TokenizationResponse{}, fmt.Errorf("unimplemented")
 }
 
+func (llm *Base) Detokenize(*pb.DetokenizeRequest) (string, error) {
+	return "", fmt.Errorf("unimplemented")
+}
+
 // backends may wish to call this to capture the gopsutil info, then enhance with additional memory usage details?
 func (llm *Base) Status() (pb.StatusResponse, error) {
 	return pb.StatusResponse{
@@ -72,6 +76,13 @@ func (llm *Base) Status() (pb.StatusResponse, error) {
 	}, nil
 }
 
+// Capabilities returns the features supported by the backend.
+// Backends should override it to advertise what they support: by default
+// capabilities are unknown, and clients will not restrict any operation
+func (llm *Base) Capabilities() (*pb.CapabilitiesResponse, error) {
+	return nil, fmt.Errorf("unimplemented")
+}
+
 func (llm *Base) StoresSet(*pb.StoresSetOptions) error {
 	return fmt.Errorf("unimplemented")
 }
@@ -4,13 +4,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/mudler/LocalAI/core/schema"
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type Client struct {
@@ -39,6 +40,69 @@ func (c *Client) setBusy(v bool) {
 	c.Unlock()
 }
 
+// dialTarget is the address and the dial options of a backend URI
+type dialTarget struct {
+	address string
+	opts    []grpc.DialOption
+	// modTimes are the modification times of the TLS files when the options were built
+	modTimes []time.Time
+}
+
+// the dial options of the backends are built once, as loading their TLS configuration reads files,
+// and are built again when the TLS files change (e.g. a certificate is renewed)
+var (
+	dialTargetsMu sync.Mutex
+	dialTargets   = map[string]dialTarget{}
+)
+
+// tlsFilesModTimes returns the modification times of the TLS files of the connection,
+// the zero time for the files that cannot be read
+func tlsFilesModTimes(o ConnectionOptions) []time.Time {
+	modTimes := []time.Time{}
+	for _, f := range []string{o.CAFile, o.CertFile, o.KeyFile} {
+		if f == "" {
+			continue
+		}
+		var modTime time.Time
+		if info, err := os.Stat(f); err == nil {
+			modTime = info.ModTime()
+		}
+		modTimes = append(modTimes, modTime)
+	}
+	return modTimes
+}
+
+func dialTargetFor(uri string) (dialTarget, error) {
+	opts, err := ParseBackendURI(uri)
+	if err != nil {
+		return dialTarget{}, err
+	}
+	modTimes := tlsFilesModTimes(opts)
+
+	dialTargetsMu.Lock()
+	defer dialTargetsMu.Unlock()
+	if t, ok := dialTargets[uri]; ok && slices.EqualFunc(t.modTimes, modTimes, time.Time.Equal) {
+		return t, nil
+	}
+	delete(dialTargets, uri)
+	dialOpts, err := opts.DialOptions()
+	if err != nil {
+		return dialTarget{}, err
+	}
+	t := dialTarget{address: opts.Address, opts: dialOpts, modTimes: modTimes}
+	dialTargets[uri] = t
+	return t, nil
+}
+
+// dial connects to the backend, honoring the TLS and token settings of the backend URI
+func (c *Client) dial() (*grpc.ClientConn, error) {
+	t, err := dialTargetFor(c.address)
+	if err != nil {
+		return nil, err
+	}
+	return grpc.Dial(t.address, t.opts...)
+}
+
 func (c *Client) HealthCheck(ctx context.Context) (bool, error) {
 	if !c.parallel {
 		c.opMutex.Lock()
@@ -46,7 +110,7 @@ func (c *Client) HealthCheck(ctx context.Context) (bool, error) {
 	}
 	c.setBusy(true)
 	defer c.setBusy(false)
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return false, err
 	}
@@ -80,7 +144,7 @@ func (c *Client) Embeddings(ctx context.Context, in *pb.PredictOptions, opts ...
 		c.wd.Mark(c.address)
 		defer c.wd.UnMark(c.address)
 	}
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -101,7 +165,7 @@ func (c *Client) Predict(ctx context.Context, in *pb.PredictOptions, opts ...grp
 		c.wd.Mark(c.address)
 		defer c.wd.UnMark(c.address)
 	}
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -122,7 +186,7 @@ func (c *Client) LoadModel(ctx context.Context, in *pb.ModelOptions, opts ...grp
 		c.wd.Mark(c.address)
 		defer c.wd.UnMark(c.address)
 	}
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -142,7 +206,7 @@ func (c *Client) PredictStream(ctx context.Context, in *pb.PredictOptions, f fun
 		c.wd.Mark(c.address)
 		defer c.wd.UnMark(c.address)
 	}
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return err
 	}
@@ -181,7 +245,7 @@ func (c *Client) GenerateImage(ctx context.Context, in *pb.GenerateImageRequest,
 		c.wd.Mark(c.address)
 		defer c.wd.UnMark(c.address)
 	}
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -201,7 +265,7 @@ func (c *Client) TTS(ctx context.Context, in *pb.TTSRequest, opts ...grpc.CallOp
 		c.wd.Mark(c.address)
 		defer c.wd.UnMark(c.address)
 	}
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -221,7 +285,7 @@ func (c *Client) AudioTranscription(ctx context.Context, in *pb.TranscriptReques
 		c.wd.Mark(c.address)
 		defer c.wd.UnMark(c.address)
 	}
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -261,7 +325,7 @@ func (c *Client) TokenizeString(ctx context.Context, in *pb.PredictOptions, opts
 		c.wd.Mark(c.address)
 		defer c.wd.UnMark(c.address)
 	}
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -276,6 +340,26 @@ func (c *Client) TokenizeString(ctx context.Context, in *pb.PredictOptions, opts
 	return res, nil
 }
 
+func (c *Client) Detokenize(ctx context.Context, in *pb.DetokenizeRequest, opts ...grpc.CallOption) (*pb.Reply, error) {
+	if !c.parallel {
+		c.opMutex.Lock()
+		defer c.opMutex.Unlock()
+	}
+	c.setBusy(true)
+	defer c.setBusy(false)
+	if c.wd != nil {
+		c.wd.Mark(c.address)
+		defer c.wd.UnMark(c.address)
+	}
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	client := pb.NewBackendClient(conn)
+	return client.Detokenize(ctx, in, opts...)
+}
+
 func (c *Client) Status(ctx context.Context) (*pb.StatusResponse, error) {
 	if !c.parallel {
 		c.opMutex.Lock()
@@ -283,7 +367,7 @@ func (c *Client) Status(ctx context.Context) (*pb.StatusResponse, error) {
 	}
 	c.setBusy(true)
 	defer c.setBusy(false)
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -292,6 +376,20 @@ func (c *Client) Status(ctx context.Context) (*pb.StatusResponse, error) {
 	return client.Status(ctx, &pb.HealthMessage{})
 }
 
+func (c *Client) Capabilities(ctx context.Context) (*pb.CapabilitiesResponse, error) {
+	if !c.parallel {
+		c.opMutex.Lock()
+		defer c.opMutex.Unlock()
+	}
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	client := pb.NewBackendClient(conn)
+	return client.Capabilities(ctx, &pb.HealthMessage{})
+}
+
 func (c *Client) StoresSet(ctx context.Context, in *pb.StoresSetOptions, opts ...grpc.CallOption) (*pb.Result, error) {
 	if !c.parallel {
 		c.opMutex.Lock()
@@ -299,7 +397,7 @@ func (c *Client) StoresSet(ctx context.Context, in *pb.StoresSetOptions, opts ..
 	}
 	c.setBusy(true)
 	defer c.setBusy(false)
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -315,7 +413,7 @@ func (c *Client) StoresDelete(ctx context.Context, in *pb.StoresDeleteOptions, o
 	}
 	c.setBusy(true)
 	defer c.setBusy(false)
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -331,7 +429,7 @@ func (c *Client) StoresGet(ctx context.Context, in *pb.StoresGetOptions, opts ..
 	}
 	c.setBusy(true)
 	defer c.setBusy(false)
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -347,7 +445,7 @@ func (c *Client) StoresFind(ctx context.Context, in *pb.StoresFindOptions, opts
 	}
 	c.setBusy(true)
 	defer c.setBusy(false)
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -363,7 +461,7 @@ func (c *Client) Rerank(ctx context.Context, in *pb.RerankRequest, opts ...grpc.
 	}
 	c.setBusy(true)
 	defer c.setBusy(false)
-	conn, err := grpc.Dial(c.address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
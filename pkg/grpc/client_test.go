@@ -0,0 +1,71 @@
+package grpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dialTargetFor", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "grpc")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	writeCA := func(path string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "test CA"},
+			NotBefore:             time.Now(),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)).To(Succeed())
+	}
+
+	It("builds the dial options again when the TLS files change", func() {
+		ca := filepath.Join(dir, "ca.pem")
+		writeCA(ca)
+		uri := "grpcs://backend:50051?ca=" + ca
+
+		first, err := dialTargetFor(uri)
+		Expect(err).ToNot(HaveOccurred())
+		cached, err := dialTargetFor(uri)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cached.opts).To(HaveLen(len(first.opts)))
+		Expect(cached.opts[0]).To(BeIdenticalTo(first.opts[0]))
+
+		Expect(os.WriteFile(ca, []byte("not a certificate"), 0600)).To(Succeed())
+		later := time.Now().Add(time.Minute)
+		Expect(os.Chtimes(ca, later, later)).To(Succeed())
+		_, err = dialTargetFor(uri)
+		Expect(err).To(HaveOccurred())
+
+		writeCA(ca)
+		renewed, err := dialTargetFor(uri)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(renewed.opts[0]).ToNot(BeIdenticalTo(first.opts[0]))
+	})
+})
@@ -0,0 +1,288 @@
+package grpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// SchemeGRPC is the scheme of plaintext backend URIs (grpc://host:port)
+	SchemeGRPC = "grpc"
+	// SchemeGRPCS is the scheme of TLS backend URIs (grpcs://host:port)
+	SchemeGRPCS = "grpcs"
+)
+
+// Environment variables read by StartServer and RunServer to secure the gRPC server
+// of a backend.
+const (
+	EnvServerTLSCert     = "LOCALAI_BACKEND_TLS_CERT"
+	EnvServerTLSKey      = "LOCALAI_BACKEND_TLS_KEY"
+	EnvServerTLSClientCA = "LOCALAI_BACKEND_TLS_CLIENT_CA"
+	EnvServerAuthToken   = "LOCALAI_BACKEND_AUTH_TOKEN"
+)
+
+// ServerEnv is the list of the environment variables used to configure the gRPC server security
+var ServerEnv = []string{EnvServerTLSCert, EnvServerTLSKey, EnvServerTLSClientCA, EnvServerAuthToken}
+
+// ConnectionOptions describes how to connect to a backend.
+// It is parsed from a backend URI, which can be either a plain address (host:port)
+// or an URI in the form:
+//
+//	grpc://host:port?token=secret
+//	grpcs://host:port?ca=/path/ca.pem&cert=/path/client.pem&key=/path/client.key&token=secret
+//
+// Supported query parameters are:
+//   - ca: PEM file with the CA used to verify the server certificate
+//   - cert, key: PEM files with the client certificate and key (mTLS)
+//   - server_name: overrides the server name used to verify the certificate
+//   - insecure_skip_verify: disables the verification of the server certificate
+//   - token: bearer token sent in the gRPC metadata of every call
+type ConnectionOptions struct {
+	Address            string
+	TLS                bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+	Token              string
+}
+
+// ParseBackendURI parses a backend URI into ConnectionOptions
+func ParseBackendURI(uri string) (ConnectionOptions, error) {
+	if !strings.HasPrefix(uri, SchemeGRPC+"://") && !strings.HasPrefix(uri, SchemeGRPCS+"://") {
+		return ConnectionOptions{Address: uri}, nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ConnectionOptions{}, fmt.Errorf("invalid backend uri: %w", err)
+	}
+	if u.Host == "" {
+		return ConnectionOptions{}, fmt.Errorf("invalid backend uri %q: missing address", uri)
+	}
+
+	q := u.Query()
+	opts := ConnectionOptions{
+		Address:    u.Host,
+		TLS:        u.Scheme == SchemeGRPCS,
+		CAFile:     q.Get("ca"),
+		CertFile:   q.Get("cert"),
+		KeyFile:    q.Get("key"),
+		ServerName: q.Get("server_name"),
+		Token:      q.Get("token"),
+	}
+
+	if v := q.Get("insecure_skip_verify"); v != "" {
+		opts.InsecureSkipVerify, err = strconv.ParseBool(v)
+		if err != nil {
+			return ConnectionOptions{}, fmt.Errorf("invalid insecure_skip_verify value %q: %w", v, err)
+		}
+	}
+
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return ConnectionOptions{}, fmt.Errorf("invalid backend uri: cert and key must be specified together")
+	}
+
+	if !opts.TLS && (opts.CAFile != "" || opts.CertFile != "" || opts.ServerName != "" || opts.InsecureSkipVerify) {
+		return ConnectionOptions{}, fmt.Errorf("invalid backend uri: TLS options require the %s:// scheme", SchemeGRPCS)
+	}
+
+	return opts, nil
+}
+
+// RedactBackendURI hides the token of a backend URI, to log it
+func RedactBackendURI(uri string) string {
+	if !strings.HasPrefix(uri, SchemeGRPC+"://") && !strings.HasPrefix(uri, SchemeGRPCS+"://") {
+		return uri
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "[invalid backend uri]"
+	}
+	q := u.Query()
+	if q.Get("token") == "" {
+		return uri
+	}
+	q.Set("token", "REDACTED")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// DialOptions returns the grpc.DialOption needed to connect to the backend
+func (o ConnectionOptions) DialOptions() ([]grpc.DialOption, error) {
+	dialOpts := []grpc.DialOption{}
+
+	if o.TLS {
+		tlsConfig := &tls.Config{
+			ServerName:         o.ServerName,
+			InsecureSkipVerify: o.InsecureSkipVerify,
+			MinVersion:         tls.VersionTLS12,
+		}
+		if o.CAFile != "" {
+			pool, err := loadCertPool(o.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if o.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed loading client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	if o.Token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(tokenCredentials(o.Token)))
+	}
+
+	return dialOpts, nil
+}
+
+// tokenCredentials sends a bearer token in the metadata of every call
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity returns false to allow tokens over connections
+// which are already secured by other means (e.g. p2p tunnels)
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid certificates found in %s", caFile)
+	}
+	return pool, nil
+}
+
+// ServerOptions configures the security of the gRPC server of a backend
+type ServerOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	Token        string
+}
+
+type ServerOption func(*ServerOptions)
+
+func WithServerTLS(certFile, keyFile string) ServerOption {
+	return func(o *ServerOptions) {
+		o.CertFile = certFile
+		o.KeyFile = keyFile
+	}
+}
+
+// WithServerClientCA requires clients to present a certificate signed by the given CA (mTLS)
+func WithServerClientCA(caFile string) ServerOption {
+	return func(o *ServerOptions) {
+		o.ClientCAFile = caFile
+	}
+}
+
+// WithServerAuthToken requires clients to send the given bearer token
+func WithServerAuthToken(token string) ServerOption {
+	return func(o *ServerOptions) {
+		o.Token = token
+	}
+}
+
+func newServerOptions(opts ...ServerOption) *ServerOptions {
+	o := &ServerOptions{
+		CertFile:     os.Getenv(EnvServerTLSCert),
+		KeyFile:      os.Getenv(EnvServerTLSKey),
+		ClientCAFile: os.Getenv(EnvServerTLSClientCA),
+		Token:        os.Getenv(EnvServerAuthToken),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *ServerOptions) grpcServerOptions() ([]grpc.ServerOption, error) {
+	serverOpts := []grpc.ServerOption{}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed loading server certificate: %w", err)
+		}
+		tlsConfig := &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+		if o.ClientCAFile != "" {
+			pool, err := loadCertPool(o.ClientCAFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.ClientCAs = pool
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	} else if o.ClientCAFile != "" {
+		return nil, fmt.Errorf("a client CA requires a server certificate and key")
+	}
+
+	if o.Token != "" {
+		serverOpts = append(serverOpts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if err := o.authorize(ctx); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := o.authorize(ss.Context()); err != nil {
+					return err
+				}
+				return handler(srv, ss)
+			}),
+		)
+	}
+
+	return serverOpts, nil
+}
+
+func (o *ServerOptions) authorize(ctx context.Context) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing credentials")
+	}
+	for _, v := range md.Get("authorization") {
+		token := strings.TrimPrefix(v, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(o.Token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid credentials")
+}
@@ -0,0 +1,63 @@
+package grpc_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/grpc"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseBackendURI", func() {
+	It("keeps plain addresses as they are", func() {
+		opts, err := ParseBackendURI("127.0.0.1:50051")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(opts).To(Equal(ConnectionOptions{Address: "127.0.0.1:50051"}))
+	})
+	It("parses plaintext uris with a token", func() {
+		opts, err := ParseBackendURI("grpc://backend:50051?token=secret")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(opts.Address).To(Equal("backend:50051"))
+		Expect(opts.TLS).To(BeFalse())
+		Expect(opts.Token).To(Equal("secret"))
+	})
+	It("parses TLS uris", func() {
+		opts, err := ParseBackendURI("grpcs://backend:50051?ca=/ca.pem&cert=/client.pem&key=/client.key&server_name=foo&insecure_skip_verify=true")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(opts).To(Equal(ConnectionOptions{
+			Address:            "backend:50051",
+			TLS:                true,
+			CAFile:             "/ca.pem",
+			CertFile:           "/client.pem",
+			KeyFile:            "/client.key",
+			ServerName:         "foo",
+			InsecureSkipVerify: true,
+		}))
+	})
+	It("rejects a certificate without key", func() {
+		_, err := ParseBackendURI("grpcs://backend:50051?cert=/client.pem")
+		Expect(err).To(HaveOccurred())
+	})
+	It("rejects TLS options on plaintext uris", func() {
+		_, err := ParseBackendURI("grpc://backend:50051?ca=/ca.pem")
+		Expect(err).To(HaveOccurred())
+	})
+	It("fails to build dial options with a missing CA", func() {
+		opts, err := ParseBackendURI("grpcs://backend:50051?ca=/does/not/exist.pem")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = opts.DialOptions()
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("RedactBackendURI", func() {
+	It("hides the token", func() {
+		redacted := RedactBackendURI("grpcs://backend:50051?ca=/ca.pem&token=secret")
+		Expect(redacted).ToNot(ContainSubstring("secret"))
+		Expect(redacted).To(ContainSubstring("token=REDACTED"))
+		Expect(redacted).To(ContainSubstring("backend:50051"))
+	})
+	It("keeps the uris without token", func() {
+		Expect(RedactBackendURI("127.0.0.1:50051")).To(Equal("127.0.0.1:50051"))
+		Expect(RedactBackendURI("grpc://backend:50051")).To(Equal("grpc://backend:50051"))
+	})
+})
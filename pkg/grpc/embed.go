@@ -81,6 +81,10 @@ func (e *embedBackend) TokenizeString(ctx context.Context, in *pb.PredictOptions
 	return e.s.TokenizeString(ctx, in)
 }
 
+func (e *embedBackend) Detokenize(ctx context.Context, in *pb.DetokenizeRequest, opts ...grpc.CallOption) (*pb.Reply, error) {
+	return e.s.Detokenize(ctx, in)
+}
+
 func (e *embedBackend) Status(ctx context.Context) (*pb.StatusResponse, error) {
 	return e.s.Status(ctx, &pb.HealthMessage{})
 }
@@ -101,6 +105,10 @@ func (e *embedBackend) StoresFind(ctx context.Context, in *pb.StoresFindOptions,
 	return e.s.StoresFind(ctx, in)
 }
 
+func (e *embedBackend) Capabilities(ctx context.Context) (*pb.CapabilitiesResponse, error) {
+	return e.s.Capabilities(ctx, &pb.HealthMessage{})
+}
+
 func (e *embedBackend) Rerank(ctx context.Context, in *pb.RerankRequest, opts ...grpc.CallOption) (*pb.RerankResult, error) {
 	return e.s.Rerank(ctx, in)
 }
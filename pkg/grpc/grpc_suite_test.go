@@ -0,0 +1,13 @@
+package grpc_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGRPC(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "LocalAI gRPC test suite")
+}
@@ -18,7 +18,9 @@ type LLM interface {
 	AudioTranscription(*pb.TranscriptRequest) (schema.TranscriptionResult, error)
 	TTS(*pb.TTSRequest) error
 	TokenizeString(*pb.PredictOptions) (pb.TokenizationResponse, error)
+	Detokenize(*pb.DetokenizeRequest) (string, error)
 	Status() (pb.StatusResponse, error)
+	Capabilities() (*pb.CapabilitiesResponse, error)
 
 	StoresSet(*pb.StoresSetOptions) error
 	StoresDelete(*pb.StoresDeleteOptions) error
@@ -8,6 +8,8 @@ import (
 
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // A GRPC Server that allows to run LLM inference.
@@ -158,6 +160,18 @@ func (s *server) TokenizeString(ctx context.Context, in *pb.PredictOptions) (*pb
 	}, err
 }
 
+func (s *server) Detokenize(ctx context.Context, in *pb.DetokenizeRequest) (*pb.Reply, error) {
+	if s.llm.Locking() {
+		s.llm.Lock()
+		defer s.llm.Unlock()
+	}
+	res, err := s.llm.Detokenize(in)
+	if err != nil {
+		return nil, err
+	}
+	return newReply(res), nil
+}
+
 func (s *server) Status(ctx context.Context, in *pb.HealthMessage) (*pb.StatusResponse, error) {
 	res, err := s.llm.Status()
 	if err != nil {
@@ -167,6 +181,18 @@ func (s *server) Status(ctx context.Context, in *pb.HealthMessage) (*pb.StatusRe
 	return &res, nil
 }
 
+// Capabilities reports the features supported by the backend.
+// Backends that do not advertise their capabilities return codes.Unimplemented,
+// so that clients can tell them apart from backends that support nothing
+func (s *server) Capabilities(ctx context.Context, in *pb.HealthMessage) (*pb.CapabilitiesResponse, error) {
+	res, err := s.llm.Capabilities()
+	if err != nil {
+		return nil, status.Error(codes.Unimplemented, err.Error())
+	}
+
+	return res, nil
+}
+
 func (s *server) StoresSet(ctx context.Context, in *pb.StoresSetOptions) (*pb.Result, error) {
 	if s.llm.Locking() {
 		s.llm.Lock()
@@ -215,12 +241,19 @@ func (s *server) StoresFind(ctx context.Context, in *pb.StoresFindOptions) (*pb.
 	return &res, nil
 }
 
-func StartServer(address string, model LLM) error {
+// StartServer starts the gRPC server of a backend and blocks until it stops.
+// TLS, mTLS and token authentication are configured with the given options,
+// or with the LOCALAI_BACKEND_TLS_* and LOCALAI_BACKEND_AUTH_TOKEN environment variables
+func StartServer(address string, model LLM, opts ...ServerOption) error {
+	serverOpts, err := newServerOptions(opts...).grpcServerOptions()
+	if err != nil {
+		return err
+	}
 	lis, err := net.Listen("tcp", address)
 	if err != nil {
 		return err
 	}
-	s := grpc.NewServer()
+	s := grpc.NewServer(serverOpts...)
 	pb.RegisterBackendServer(s, &server{llm: model})
 	log.Printf("gRPC Server listening at %v", lis.Addr())
 	if err := s.Serve(lis); err != nil {
@@ -230,12 +263,16 @@ func StartServer(address string, model LLM) error {
 	return nil
 }
 
-func RunServer(address string, model LLM) (func() error, error) {
+func RunServer(address string, model LLM, opts ...ServerOption) (func() error, error) {
+	serverOpts, err := newServerOptions(opts...).grpcServerOptions()
+	if err != nil {
+		return nil, err
+	}
 	lis, err := net.Listen("tcp", address)
 	if err != nil {
 		return nil, err
 	}
-	s := grpc.NewServer()
+	s := grpc.NewServer(serverOpts...)
 	pb.RegisterBackendServer(s, &server{llm: model})
 	log.Printf("gRPC Server listening at %v", lis.Addr())
 	if err = s.Serve(lis); err != nil {
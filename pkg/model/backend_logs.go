@@ -0,0 +1,219 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultBackendLogLines = 1000
+	// lines attached to the errors of the backends failing to load
+	backendLogErrorLines = 20
+	// size after which the backend log files are rotated
+	backendLogFileMaxSize = 10 * 1024 * 1024
+)
+
+// BackendLogs keeps the last lines of the output of the backends, per model.
+// Lines are optionally written also to a file per model, rotated when it gets too big.
+type BackendLogs struct {
+	sync.Mutex
+	size   int
+	dir    string
+	models map[string]*backendLog
+}
+
+type backendLog struct {
+	// the ring buffer of the lines, allocated with the first line
+	lines       []schema.BackendLogLine
+	next        int
+	full        bool
+	subscribers map[chan schema.BackendLogLine]struct{}
+	file        *os.File
+	fileSize    int64
+}
+
+func NewBackendLogs(size int, dir string) *BackendLogs {
+	if size <= 0 {
+		size = defaultBackendLogLines
+	}
+	return &BackendLogs{
+		size:   size,
+		dir:    dir,
+		models: make(map[string]*backendLog),
+	}
+}
+
+func (bl *BackendLogs) get(modelName string) *backendLog {
+	l, ok := bl.models[modelName]
+	if !ok {
+		l = &backendLog{
+			subscribers: make(map[chan schema.BackendLogLine]struct{}),
+		}
+		bl.models[modelName] = l
+	}
+	return l
+}
+
+// Append adds a line to the log of the model, and sends it to the subscribers
+func (bl *BackendLogs) Append(modelName, stream, text string) {
+	bl.Lock()
+	defer bl.Unlock()
+
+	line := schema.BackendLogLine{Time: time.Now(), Stream: stream, Text: text}
+	l := bl.get(modelName)
+	if l.lines == nil {
+		l.lines = make([]schema.BackendLogLine, bl.size)
+	}
+	l.lines[l.next] = line
+	l.next = (l.next + 1) % len(l.lines)
+	if l.next == 0 {
+		l.full = true
+	}
+
+	for ch := range l.subscribers {
+		select {
+		case ch <- line:
+		default:
+			// slow subscribers lose lines instead of blocking the backend output
+		}
+	}
+
+	if bl.dir != "" {
+		bl.writeFile(modelName, l, line)
+	}
+}
+
+func (bl *BackendLogs) writeFile(modelName string, l *backendLog, line schema.BackendLogLine) {
+	path := filepath.Join(bl.dir, logFileName(modelName))
+	if l.file != nil && l.fileSize > backendLogFileMaxSize {
+		l.file.Close()
+		l.file = nil
+		if err := os.Rename(path, path+".1"); err != nil {
+			log.Error().Err(err).Str("model", modelName).Msg("failed rotating backend log file")
+		}
+	}
+	if l.file == nil {
+		if err := os.MkdirAll(bl.dir, 0750); err != nil {
+			log.Error().Err(err).Msg("failed creating the backend logs directory")
+			return
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+		if err != nil {
+			log.Error().Err(err).Str("model", modelName).Msg("failed opening backend log file")
+			return
+		}
+		st, err := f.Stat()
+		if err == nil {
+			l.fileSize = st.Size()
+		}
+		l.file = f
+	}
+	n, err := fmt.Fprintf(l.file, "%s %s %s\n", line.Time.Format(time.RFC3339), line.Stream, line.Text)
+	if err != nil {
+		log.Error().Err(err).Str("model", modelName).Msg("failed writing backend log file")
+	}
+	l.fileSize += int64(n)
+}
+
+func logFileName(modelName string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(modelName) + ".log"
+}
+
+// Lines returns the last n lines of the log of the model, or all of them if n <= 0
+func (bl *BackendLogs) Lines(modelName string, n int) []schema.BackendLogLine {
+	bl.Lock()
+	defer bl.Unlock()
+
+	l, ok := bl.models[modelName]
+	if !ok {
+		return []schema.BackendLogLine{}
+	}
+
+	lines := []schema.BackendLogLine{}
+	if l.full {
+		lines = append(lines, l.lines[l.next:]...)
+	}
+	lines = append(lines, l.lines[:l.next]...)
+
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// Subscribe returns a channel receiving the new lines of the log of the model.
+// The returned function must be called to stop receiving them.
+func (bl *BackendLogs) Subscribe(modelName string) (chan schema.BackendLogLine, func()) {
+	bl.Lock()
+	defer bl.Unlock()
+
+	ch := make(chan schema.BackendLogLine, 100)
+	l := bl.get(modelName)
+	l.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		bl.Lock()
+		defer bl.Unlock()
+		delete(l.subscribers, ch)
+		// the models followed before their backend writes anything are not kept
+		if len(l.subscribers) == 0 && l.lines == nil && bl.models[modelName] == l {
+			delete(bl.models, modelName)
+		}
+	}
+}
+
+// Close drops the lines of the model and closes its log file, when its backend is stopped.
+// The subscribers keep receiving the lines of the next backend of the model
+func (bl *BackendLogs) Close(modelName string) {
+	bl.Lock()
+	defer bl.Unlock()
+
+	l, ok := bl.models[modelName]
+	if !ok {
+		return
+	}
+	if l.file != nil {
+		if err := l.file.Close(); err != nil {
+			log.Error().Err(err).Str("model", modelName).Msg("failed closing backend log file")
+		}
+		l.file = nil
+	}
+	l.lines, l.next, l.full = nil, 0, false
+	if len(l.subscribers) == 0 {
+		delete(bl.models, modelName)
+	}
+}
+
+// Remove drops the lines of the model and removes its log files, when the model is removed
+func (bl *BackendLogs) Remove(modelName string) {
+	bl.Close(modelName)
+	if bl.dir == "" {
+		return
+	}
+	path := filepath.Join(bl.dir, logFileName(modelName))
+	for _, f := range []string{path, path + ".1"} {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			log.Error().Err(err).Str("model", modelName).Msg("failed removing backend log file")
+		}
+	}
+}
+
+// withBackendLogs attaches the last lines of the backend output to the error
+func (bl *BackendLogs) withBackendLogs(modelName string, err error) error {
+	lines := bl.Lines(modelName, backendLogErrorLines)
+	if len(lines) == 0 {
+		return err
+	}
+	text := []string{}
+	for _, l := range lines {
+		text = append(text, l.Text)
+	}
+	return fmt.Errorf("%w\nlast backend log lines:\n%s", err, strings.Join(text, "\n"))
+}
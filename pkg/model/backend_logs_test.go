@@ -0,0 +1,84 @@
+package model_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/LocalAI/pkg/model"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BackendLogs", func() {
+	It("keeps only the last lines of each model", func() {
+		bl := NewBackendLogs(3, "")
+		for i := 0; i < 5; i++ {
+			bl.Append("foo", "stdout", fmt.Sprintf("line %d", i))
+		}
+		bl.Append("bar", "stderr", "other")
+
+		lines := bl.Lines("foo", 0)
+		Expect(lines).To(HaveLen(3))
+		Expect(lines[0].Text).To(Equal("line 2"))
+		Expect(lines[2].Text).To(Equal("line 4"))
+
+		lines = bl.Lines("foo", 1)
+		Expect(lines).To(HaveLen(1))
+		Expect(lines[0].Text).To(Equal("line 4"))
+
+		Expect(bl.Lines("bar", 0)).To(HaveLen(1))
+		Expect(bl.Lines("baz", 0)).To(BeEmpty())
+	})
+
+	It("streams the new lines to the subscribers", func() {
+		bl := NewBackendLogs(3, "")
+		ch, unsubscribe := bl.Subscribe("foo")
+		bl.Append("foo", "stderr", "hello")
+		line := <-ch
+		Expect(line.Text).To(Equal("hello"))
+		Expect(line.Stream).To(Equal("stderr"))
+
+		unsubscribe()
+		bl.Append("foo", "stderr", "world")
+		Expect(ch).ToNot(Receive())
+	})
+
+	It("writes the lines to a file per model", func() {
+		dir, err := os.MkdirTemp("", "backend-logs")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		bl := NewBackendLogs(3, dir)
+		bl.Append("sub/foo.gguf", "stdout", "hello")
+
+		content, err := os.ReadFile(filepath.Join(dir, "sub_foo.gguf.log"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring("stdout hello"))
+	})
+
+	It("drops the lines of a backend stopped and keeps the subscribers", func() {
+		dir, err := os.MkdirTemp("", "backend-logs")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		bl := NewBackendLogs(3, dir)
+		ch, unsubscribe := bl.Subscribe("foo")
+		defer unsubscribe()
+		bl.Append("foo", "stdout", "hello")
+		Eventually(ch).Should(Receive())
+
+		bl.Close("foo")
+		Expect(bl.Lines("foo", 0)).To(BeEmpty())
+		Expect(filepath.Join(dir, "foo.log")).To(BeAnExistingFile())
+
+		bl.Append("foo", "stdout", "restarted")
+		Eventually(ch).Should(Receive())
+		Expect(bl.Lines("foo", 0)).To(HaveLen(1))
+
+		bl.Remove("foo")
+		Expect(bl.Lines("foo", 0)).To(BeEmpty())
+		Expect(filepath.Join(dir, "foo.log")).ToNot(BeAnExistingFile())
+	})
+})
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mudler/LocalAI/pkg/grpc"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/rs/zerolog/log"
+)
+
+// Capabilities that can be advertised by the backends
+const (
+	CapabilityChat          = "chat"
+	CapabilityEmbeddings    = "embeddings"
+	CapabilityImages        = "images"
+	CapabilityTTS           = "tts"
+	CapabilityTranscription = "transcription"
+	CapabilityRerank        = "rerank"
+	CapabilityTokenize      = "tokenize"
+	CapabilityVision        = "vision"
+	CapabilityGrammar       = "grammar"
+	CapabilityStreaming     = "streaming"
+	CapabilityLogprobs      = "logprobs"
+	CapabilityStores        = "stores"
+)
+
+var ErrCapabilityNotSupported = errors.New("operation not supported by the backend")
+
+// CapabilitiesList converts the capabilities advertised by a backend into a list of capability names
+func CapabilitiesList(c *pb.CapabilitiesResponse) []string {
+	caps := []string{}
+	for _, cap := range []struct {
+		name      string
+		supported bool
+	}{
+		{CapabilityChat, c.GetChat()},
+		{CapabilityEmbeddings, c.GetEmbeddings()},
+		{CapabilityImages, c.GetImages()},
+		{CapabilityTTS, c.GetTts()},
+		{CapabilityTranscription, c.GetTranscription()},
+		{CapabilityRerank, c.GetRerank()},
+		{CapabilityTokenize, c.GetTokenize()},
+		{CapabilityVision, c.GetVision()},
+		{CapabilityGrammar, c.GetGrammar()},
+		{CapabilityStreaming, c.GetStreaming()},
+		{CapabilityLogprobs, c.GetLogprobs()},
+		{CapabilityStores, c.GetStores()},
+	} {
+		if cap.supported {
+			caps = append(caps, cap.name)
+		}
+	}
+	return caps
+}
+
+// updateCapabilities asks the backend for its capabilities and caches them.
+// Backends that do not implement the Capabilities call are considered
+// to support every operation.
+func (ml *ModelLoader) updateCapabilities(modelName string, client grpc.Backend) {
+	res, err := client.Capabilities(context.Background())
+	if err != nil {
+		log.Debug().Err(err).Str("model", modelName).Msg("backend does not advertise its capabilities")
+		ml.capabilities.Delete(modelName)
+		return
+	}
+	caps := CapabilitiesList(res)
+	log.Debug().Str("model", modelName).Strs("capabilities", caps).Msg("backend capabilities")
+	ml.capabilities.Set(modelName, caps)
+}
+
+// GetCapabilities returns the capabilities advertised by the backend of a loaded model.
+// The second return value is false if the capabilities are not known
+// (the model is not loaded or its backend does not advertise them)
+func (ml *ModelLoader) GetCapabilities(modelName string) ([]string, bool) {
+	if !ml.capabilities.Exists(modelName) {
+		return nil, false
+	}
+	return ml.capabilities.Get(modelName), true
+}
+
+// CheckCapabilities returns an error wrapping ErrCapabilityNotSupported if the backend
+// of the model is known not to support one of the given capabilities
+func (ml *ModelLoader) CheckCapabilities(modelName string, capabilities ...string) error {
+	caps, known := ml.GetCapabilities(modelName)
+	if !known {
+		return nil
+	}
+CAPABILITY:
+	for _, c := range capabilities {
+		for _, s := range caps {
+			if s == c {
+				continue CAPABILITY
+			}
+		}
+		return fmt.Errorf("%w: model '%s' does not support %s", ErrCapabilityNotSupported, modelName, c)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package model_test
+
+import (
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	. "github.com/mudler/LocalAI/pkg/model"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Capabilities", func() {
+	It("lists the advertised capabilities", func() {
+		Expect(CapabilitiesList(&pb.CapabilitiesResponse{Chat: true, Streaming: true, Vision: true})).To(
+			Equal([]string{CapabilityChat, CapabilityVision, CapabilityStreaming}))
+		Expect(CapabilitiesList(&pb.CapabilitiesResponse{})).To(BeEmpty())
+	})
+	It("does not restrict models with unknown capabilities", func() {
+		ml := NewModelLoader("")
+		_, known := ml.GetCapabilities("foo")
+		Expect(known).To(BeFalse())
+		Expect(ml.CheckCapabilities("foo", CapabilityTTS)).To(Succeed())
+	})
+})
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// inFlight counts the requests being processed by each backend address.
+// It is passed to the GRPC clients as their watchdog, forwarding the updates to the
+// watchdog of the loader when enabled
+type inFlight struct {
+	sync.Mutex
+	requests map[string]int
+	ml       *ModelLoader
+}
+
+func newInFlight(ml *ModelLoader) *inFlight {
+	return &inFlight{
+		requests: make(map[string]int),
+		ml:       ml,
+	}
+}
+
+func (i *inFlight) Mark(address string) {
+	i.Lock()
+	i.requests[address]++
+	i.Unlock()
+	if i.ml.wd != nil {
+		i.ml.wd.Mark(address)
+	}
+}
+
+func (i *inFlight) UnMark(address string) {
+	i.Lock()
+	if i.requests[address] <= 1 {
+		delete(i.requests, address)
+	} else {
+		i.requests[address]--
+	}
+	i.Unlock()
+	if i.ml.wd != nil {
+		i.ml.wd.UnMark(address)
+	}
+}
+
+func (i *inFlight) count(address string) int {
+	i.Lock()
+	defer i.Unlock()
+	return i.requests[address]
+}
+
+// drainCheckInterval is the interval between the checks of the in-flight requests of a model to unload
+var drainCheckInterval = 100 * time.Millisecond
+
+// UnloadWhenIdle stops a loaded model as soon as it has no requests in flight,
+// so that the next request loads it again with its current options.
+// It returns false if the model was not loaded
+func (ml *ModelLoader) UnloadWhenIdle(ctx context.Context, modelName string) (bool, error) {
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+
+	// the queued requests of the non-parallel clients are in flight only when they
+	// start: the backend has to be idle for two consecutive checks
+	idleChecks := 0
+	for {
+		ml.mu.Lock()
+		addr, loaded := ml.models[modelName]
+		if !loaded {
+			ml.mu.Unlock()
+			return false, nil
+		}
+		if ml.requests.count(string(addr)) > 0 {
+			idleChecks = 0
+		} else {
+			idleChecks++
+		}
+		if idleChecks >= 2 {
+			if ml.supervisor != nil {
+				// the supervisor must not restart the model with the previous options
+				ml.supervisor.forget(modelName)
+			}
+			err := ml.stopModel(modelName)
+			ml.mu.Unlock()
+			return true, err
+		}
+		ml.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -184,6 +184,41 @@ ENTRY:
 	return orderedBackends.Keys(), nil
 }
 
+// BackendAvailable returns true if a backend can be started, as an external backend
+// or a process of the asset directory
+func BackendAvailable(assetDir, backend string, externalBackends map[string]string) bool {
+	backend = strings.ToLower(backend)
+	if realBackend, exists := Aliases[backend]; exists {
+		backend = realBackend
+	}
+	switch backend {
+	case Gpt4AllLlamaBackend, Gpt4AllMptBackend, Gpt4AllJBackend:
+		backend = Gpt4All
+	}
+	if _, exists := externalBackends[backend]; exists {
+		return true
+	}
+	if utils.VerifyPath(backendPath(assetDir, backend), assetDir) != nil {
+		return false
+	}
+	if _, err := os.Stat(backendPath(assetDir, backend)); err == nil {
+		return true
+	}
+	// the llama.cpp variants are selected when starting the backend
+	if backend == LLamaCPP {
+		entries, err := os.ReadDir(backendPath(assetDir, ""))
+		if err != nil {
+			return false
+		}
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), LLamaCPP) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // selectGRPCProcess selects the GRPC process to start based on system capabilities
 func selectGRPCProcess(backend, assetDir string, f16 bool) string {
 	foundCUDA := false
@@ -298,7 +333,7 @@ func (ml *ModelLoader) grpcModel(backend string, o *Options) func(string, string
 
 		// Check if the backend is provided as external
 		if uri, ok := o.externalBackends[backend]; ok {
-			log.Debug().Msgf("Loading external backend: %s", uri)
+			log.Debug().Msgf("Loading external backend: %s", grpc.RedactBackendURI(uri))
 			// check if uri is a file or a address
 			if _, err := os.Stat(uri); err == nil {
 				serverAddress, err := getFreeAddress()
@@ -389,17 +424,20 @@ func (ml *ModelLoader) grpcModel(backend string, o *Options) func(string, string
 			return "", fmt.Errorf("could not load model (no success): %s", res.Message)
 		}
 
+		ml.updateCapabilities(modelName, client.GRPC(o.parallelRequests, ml.wd))
+
 		return client, nil
 	}
 }
 
 func (ml *ModelLoader) resolveAddress(addr ModelAddress, parallel bool) (grpc.Backend, error) {
+	// the clients track the requests in flight, to unload the models only once they are idle
 	if parallel {
-		return addr.GRPC(parallel, ml.wd), nil
+		return grpc.NewClient(string(addr), parallel, ml.requests, true), nil
 	}
 
 	if _, ok := ml.grpcClients[string(addr)]; !ok {
-		ml.grpcClients[string(addr)] = addr.GRPC(parallel, ml.wd)
+		ml.grpcClients[string(addr)] = grpc.NewClient(string(addr), parallel, ml.requests, true)
 	}
 	return ml.grpcClients[string(addr)], nil
 }
@@ -446,7 +484,7 @@ func (ml *ModelLoader) BackendLoader(opts ...Option) (client grpc.Backend, err e
 
 	addr, err := ml.LoadModel(o.model, ml.grpcModel(backendToConsume, o))
 	if err != nil {
-		return nil, err
+		return nil, ml.backendLogs.withBackendLogs(o.model, err)
 	}
 
 	return ml.resolveAddress(addr, o.parallelRequests)
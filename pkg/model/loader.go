@@ -13,6 +13,7 @@ import (
 	"github.com/mudler/LocalAI/pkg/functions"
 	"github.com/mudler/LocalAI/pkg/grpc"
 	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/mudler/LocalAI/pkg/xsync"
 
 	process "github.com/mudler/go-processmanager"
 	"github.com/rs/zerolog/log"
@@ -54,6 +55,10 @@ type ModelLoader struct {
 	grpcProcesses map[string]*process.Process
 	templates     *templates.TemplateCache
 	wd            *WatchDog
+	supervisor    *Supervisor
+	capabilities  *xsync.SyncedMap[string, []string]
+	backendLogs   *BackendLogs
+	requests      *inFlight
 }
 
 type ModelAddress string
@@ -73,7 +78,10 @@ func NewModelLoader(modelPath string) *ModelLoader {
 		models:        make(map[string]ModelAddress),
 		templates:     templates.NewTemplateCache(modelPath),
 		grpcProcesses: make(map[string]*process.Process),
+		capabilities:  xsync.NewSyncedMap[string, []string](),
+		backendLogs:   NewBackendLogs(defaultBackendLogLines, ""),
 	}
+	nml.requests = newInFlight(nml)
 
 	return nml
 }
@@ -82,6 +90,19 @@ func (ml *ModelLoader) SetWatchDog(wd *WatchDog) {
 	ml.wd = wd
 }
 
+func (ml *ModelLoader) SetSupervisor(s *Supervisor) {
+	ml.supervisor = s
+}
+
+func (ml *ModelLoader) SetBackendLogs(bl *BackendLogs) {
+	ml.backendLogs = bl
+}
+
+// BackendLogs returns the output of the backends started by the loader
+func (ml *ModelLoader) BackendLogs() *BackendLogs {
+	return ml.backendLogs
+}
+
 func (ml *ModelLoader) ExistsInModelPath(s string) bool {
 	return utils.ExistsInPath(ml.ModelPath, s)
 }
@@ -150,15 +171,29 @@ func (ml *ModelLoader) LoadModel(modelName string, loader func(string, string) (
 		return model, nil
 	}
 
+	// Fail fast if the backend of the model keeps crashing
+	if ml.supervisor != nil {
+		if err := ml.supervisor.allowLoad(modelName); err != nil {
+			return "", err
+		}
+	}
+
 	// Load the model and keep it in memory for later use
 	modelFile := filepath.Join(ml.ModelPath, modelName)
 	log.Debug().Msgf("Loading model in memory from file: %s", modelFile)
 
 	model, err := loader(modelName, modelFile)
 	if err != nil {
+		if ml.supervisor != nil {
+			ml.supervisor.recordLoadFailure(modelName)
+		}
 		return "", err
 	}
 
+	if ml.supervisor != nil {
+		ml.supervisor.loaded(modelName, loader)
+	}
+
 	// TODO: Add a helper method to iterate all prompt templates associated with a config if and only if it's YAML?
 	// Minor perf loss here until this is fixed, but we initialize on first request
 
@@ -179,6 +214,7 @@ func (ml *ModelLoader) ShutdownModel(modelName string) error {
 }
 
 func (ml *ModelLoader) stopModel(modelName string) error {
+	defer ml.backendLogs.Close(modelName)
 	defer ml.deleteProcess(modelName)
 	if _, ok := ml.models[modelName]; !ok {
 		return fmt.Errorf("model %s not found", modelName)
@@ -187,6 +223,31 @@ func (ml *ModelLoader) stopModel(modelName string) error {
 	//return ml.deleteProcess(modelName)
 }
 
+func (ml *ModelLoader) isLoaded(modelName string) bool {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	_, ok := ml.models[modelName]
+	return ok
+}
+
+// removeCrashedBackend cleans up a backend which is not responding anymore.
+// It returns false if the backend process is still running.
+func (ml *ModelLoader) removeCrashedBackend(modelName string, addr ModelAddress) bool {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	if ml.models[modelName] != addr {
+		// the model was reloaded or stopped in the meantime
+		return false
+	}
+	if p, ok := ml.grpcProcesses[modelName]; ok && p.IsAlive() {
+		return false
+	}
+	if err := ml.deleteProcess(modelName); err != nil {
+		log.Error().Err(err).Str("process", modelName).Msg("error stopping process")
+	}
+	return true
+}
+
 func (ml *ModelLoader) CheckIsLoaded(s string) ModelAddress {
 	var client grpc.Backend
 	if m, ok := ml.models[s]; ok {
@@ -200,13 +261,16 @@ func (ml *ModelLoader) CheckIsLoaded(s string) ModelAddress {
 		if !alive {
 			log.Warn().Msgf("GRPC Model not responding: %s", err.Error())
 			log.Warn().Msgf("Deleting the process in order to recreate it")
-			if !ml.grpcProcesses[s].IsAlive() {
+			if p, ok := ml.grpcProcesses[s]; !ok || !p.IsAlive() {
 				log.Debug().Msgf("GRPC Process is not responding: %s", s)
 				// stop and delete the process, this forces to re-load the model and re-create again the service
 				err := ml.deleteProcess(s)
 				if err != nil {
 					log.Error().Err(err).Str("process", s).Msg("error stopping process")
 				}
+				if ml.supervisor != nil {
+					ml.supervisor.recordFailure(s)
+				}
 				return ""
 			}
 		}
@@ -233,6 +297,11 @@ func (ml *ModelLoader) EvaluateTemplateForPrompt(templateType templates.Template
 	return ml.templates.EvaluateTemplate(templateType, templateName, in)
 }
 
+// EvaluateJinjaTemplate renders a Jinja2 chat template, as the tokenizer.chat_template of the GGUF files
+func (ml *ModelLoader) EvaluateJinjaTemplate(templateName string, in map[string]interface{}) (string, error) {
+	return ml.templates.EvaluateJinjaTemplate(templateName, in)
+}
+
 func (ml *ModelLoader) EvaluateTemplateForChatMessage(templateName string, messageData ChatMessageTemplateData) (string, error) {
 	return ml.templates.EvaluateTemplate(ChatMessageTemplate, templateName, messageData)
 }
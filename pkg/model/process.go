@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/hpcloud/tail"
+	"github.com/mudler/LocalAI/pkg/grpc"
 	process "github.com/mudler/go-processmanager"
 	"github.com/rs/zerolog/log"
 )
@@ -37,6 +38,7 @@ func (ml *ModelLoader) deleteProcess(s string) error {
 	}
 	delete(ml.grpcProcesses, s)
 	delete(ml.models, s)
+	ml.capabilities.Delete(s)
 	return nil
 }
 
@@ -69,6 +71,23 @@ func (ml *ModelLoader) GetGRPCPID(id string) (int, error) {
 	return strconv.Atoi(p.PID)
 }
 
+// backendEnvironment returns the environment for the backends started locally.
+// The gRPC server security settings are not propagated, as the
+// local backends are always reached on the loopback interface without credentials
+func backendEnvironment() []string {
+	env := []string{}
+ENV:
+	for _, e := range os.Environ() {
+		for _, v := range grpc.ServerEnv {
+			if strings.HasPrefix(e, v+"=") {
+				continue ENV
+			}
+		}
+		env = append(env, e)
+	}
+	return env
+}
+
 func (ml *ModelLoader) startProcess(grpcProcess, id string, serverAddress string, args ...string) error {
 	// Make sure the process is executable
 	if err := os.Chmod(grpcProcess, 0700); err != nil {
@@ -83,7 +102,7 @@ func (ml *ModelLoader) startProcess(grpcProcess, id string, serverAddress string
 		process.WithTemporaryStateDir(),
 		process.WithName(grpcProcess),
 		process.WithArgs(append(args, []string{"--addr", serverAddress}...)...),
-		process.WithEnvironment(os.Environ()...),
+		process.WithEnvironment(backendEnvironment()...),
 	)
 
 	if ml.wd != nil {
@@ -116,6 +135,7 @@ func (ml *ModelLoader) startProcess(grpcProcess, id string, serverAddress string
 		}
 		for line := range t.Lines {
 			log.Debug().Msgf("GRPC(%s): stderr %s", strings.Join([]string{id, serverAddress}, "-"), line.Text)
+			ml.backendLogs.Append(id, "stderr", line.Text)
 		}
 	}()
 	go func() {
@@ -125,6 +145,7 @@ func (ml *ModelLoader) startProcess(grpcProcess, id string, serverAddress string
 		}
 		for line := range t.Lines {
 			log.Debug().Msgf("GRPC(%s): stdout %s", strings.Join([]string{id, serverAddress}, "-"), line.Text)
+			ml.backendLogs.Append(id, "stdout", line.Text)
 		}
 	}()
 
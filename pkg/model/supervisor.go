@@ -0,0 +1,295 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The Supervisor health-checks the loaded backends in the background.
+// Crashed backends are cleaned up and restarted with an exponential backoff,
+// and pinned models are reloaded as soon as they are not loaded anymore.
+// When a backend keeps crashing, the circuit breaker trips: the model is marked
+// unhealthy and loading it fails fast until the cooldown expires.
+
+var ErrBackendUnhealthy = errors.New("backend is unhealthy")
+
+type Supervisor struct {
+	sync.Mutex
+	ml *ModelLoader
+
+	interval     time.Duration
+	backoffBase  time.Duration
+	backoffMax   time.Duration
+	maxFailures  int
+	cooldown     time.Duration
+	resetAfter   time.Duration
+	healthChecks time.Duration
+
+	backends map[string]*supervisedBackend
+	stop     chan bool
+}
+
+type supervisedBackend struct {
+	loader func(string, string) (ModelAddress, error)
+	pinned bool
+
+	// crashed is true when the backend has to be restarted
+	crashed     bool
+	failures    int
+	lastFailure time.Time
+	nextAttempt time.Time
+	// loadBackoff is set when loading the model failed: the loads fail fast until then
+	loadBackoff time.Time
+
+	unhealthy   bool
+	unhealthyAt time.Time
+}
+
+type SupervisorOption func(*Supervisor)
+
+func WithSupervisorInterval(d time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.interval = d
+	}
+}
+
+// WithSupervisorMaxFailures sets the number of consecutive failures
+// after which the model is marked unhealthy
+func WithSupervisorMaxFailures(n int) SupervisorOption {
+	return func(s *Supervisor) {
+		s.maxFailures = n
+	}
+}
+
+func WithSupervisorBackoff(base, max time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.backoffBase = base
+		s.backoffMax = max
+	}
+}
+
+// WithSupervisorCooldown sets for how long an unhealthy model fails fast
+// before a new loading attempt is allowed
+func WithSupervisorCooldown(d time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.cooldown = d
+	}
+}
+
+func NewSupervisor(ml *ModelLoader, opts ...SupervisorOption) *Supervisor {
+	s := &Supervisor{
+		ml:           ml,
+		interval:     10 * time.Second,
+		backoffBase:  time.Second,
+		backoffMax:   5 * time.Minute,
+		maxFailures:  5,
+		cooldown:     5 * time.Minute,
+		resetAfter:   5 * time.Minute,
+		healthChecks: 10 * time.Second,
+		backends:     make(map[string]*supervisedBackend),
+		stop:         make(chan bool, 1),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+func (s *Supervisor) Shutdown() {
+	s.stop <- true
+}
+
+// Pin marks a model to be reloaded as soon as it is not loaded anymore
+func (s *Supervisor) Pin(modelName string) {
+	s.Lock()
+	defer s.Unlock()
+	s.backend(modelName).pinned = true
+}
+
+// IsUnhealthy returns true if the circuit breaker of the model is open
+func (s *Supervisor) IsUnhealthy(modelName string) bool {
+	s.Lock()
+	defer s.Unlock()
+	b, ok := s.backends[modelName]
+	return ok && b.unhealthy
+}
+
+func (s *Supervisor) backend(modelName string) *supervisedBackend {
+	b, ok := s.backends[modelName]
+	if !ok {
+		b = &supervisedBackend{}
+		s.backends[modelName] = b
+	}
+	return b
+}
+
+// allowLoad returns an error if the model is unhealthy.
+// Once the cooldown expires, a new attempt is allowed: if it fails again
+// the model is marked unhealthy right away.
+func (s *Supervisor) allowLoad(modelName string) error {
+	s.Lock()
+	defer s.Unlock()
+	b, ok := s.backends[modelName]
+	if !ok {
+		return nil
+	}
+	if !b.unhealthy {
+		if wait := time.Until(b.loadBackoff); wait > 0 {
+			return fmt.Errorf("%w: loading model '%s' failed %d times, retrying in %s", ErrBackendUnhealthy, modelName, b.failures, wait.Round(time.Millisecond))
+		}
+		return nil
+	}
+	if time.Since(b.unhealthyAt) < s.cooldown {
+		return fmt.Errorf("%w: model '%s' crashed %d times, retrying in %s", ErrBackendUnhealthy, modelName, b.failures, (s.cooldown - time.Since(b.unhealthyAt)).Round(time.Second))
+	}
+	log.Info().Str("model", modelName).Msg("[Supervisor] cooldown expired, allowing a new attempt")
+	b.unhealthy = false
+	b.failures = s.maxFailures - 1
+	return nil
+}
+
+// loaded records how to reload a model that was loaded successfully
+func (s *Supervisor) loaded(modelName string, loader func(string, string) (ModelAddress, error)) {
+	s.Lock()
+	defer s.Unlock()
+	b := s.backend(modelName)
+	b.loader = loader
+	b.crashed = false
+	b.loadBackoff = time.Time{}
+}
+
+// forget drops how to reload a model, until it is loaded again
+func (s *Supervisor) forget(modelName string) {
+	s.Lock()
+	defer s.Unlock()
+	if b, ok := s.backends[modelName]; ok {
+		b.loader = nil
+		b.crashed = false
+	}
+}
+
+func (s *Supervisor) recordFailure(modelName string) {
+	s.Lock()
+	defer s.Unlock()
+	b := s.backend(modelName)
+	b.crashed = true
+	b.failures++
+	b.lastFailure = time.Now()
+	b.nextAttempt = b.lastFailure.Add(s.backoff(b.failures))
+	if b.failures >= s.maxFailures && !b.unhealthy {
+		log.Error().Str("model", modelName).Int("failures", b.failures).Msg("[Supervisor] backend keeps crashing, marking it as unhealthy")
+		b.unhealthy = true
+		b.unhealthyAt = b.lastFailure
+	}
+}
+
+// recordLoadFailure records a failure to load a model, which is not loaded again before the backoff
+func (s *Supervisor) recordLoadFailure(modelName string) {
+	s.recordFailure(modelName)
+	s.Lock()
+	defer s.Unlock()
+	b := s.backend(modelName)
+	b.loadBackoff = b.nextAttempt
+}
+
+func (s *Supervisor) recordHealthy(modelName string) {
+	s.Lock()
+	defer s.Unlock()
+	b, ok := s.backends[modelName]
+	if ok && b.failures > 0 && time.Since(b.lastFailure) > s.resetAfter {
+		b.failures = 0
+	}
+}
+
+// backoff returns the delay before the next attempt after the given number of failures
+func (s *Supervisor) backoff(failures int) time.Duration {
+	d := s.backoffBase
+	for i := 1; i < failures && d < s.backoffMax; i++ {
+		d *= 2
+	}
+	if d > s.backoffMax {
+		d = s.backoffMax
+	}
+	return d
+}
+
+func (s *Supervisor) Run() {
+	log.Info().Msg("[Supervisor] starting backend supervisor")
+
+	for {
+		select {
+		case <-s.stop:
+			log.Info().Msg("[Supervisor] stopping backend supervisor")
+			return
+		case <-time.After(s.interval):
+			s.checkBackends()
+			s.restartBackends()
+		}
+	}
+}
+
+// checkBackends health-checks all the loaded backends, cleaning up the crashed ones
+func (s *Supervisor) checkBackends() {
+	s.ml.mu.Lock()
+	models := make(map[string]ModelAddress, len(s.ml.models))
+	for name, addr := range s.ml.models {
+		models[name] = addr
+	}
+	s.ml.mu.Unlock()
+
+	for name, addr := range models {
+		ctx, cancel := context.WithTimeout(context.Background(), s.healthChecks)
+		// use a parallel client to not wait for in-flight requests
+		alive, err := addr.GRPC(true, nil).HealthCheck(ctx)
+		cancel()
+		if alive {
+			s.recordHealthy(name)
+			continue
+		}
+
+		if !s.ml.removeCrashedBackend(name, addr) {
+			// the process is still running, it might be just busy
+			continue
+		}
+
+		log.Warn().Err(err).Str("model", name).Msg("[Supervisor] backend crashed")
+		s.recordFailure(name)
+	}
+}
+
+// restartBackends reloads the crashed backends and the pinned models which are not loaded
+func (s *Supervisor) restartBackends() {
+	type restart struct {
+		name   string
+		loader func(string, string) (ModelAddress, error)
+	}
+	toRestart := []restart{}
+
+	s.Lock()
+	for name, b := range s.backends {
+		if b.loader == nil || b.unhealthy || !(b.crashed || b.pinned) {
+			continue
+		}
+		if time.Now().Before(b.nextAttempt) {
+			continue
+		}
+		toRestart = append(toRestart, restart{name: name, loader: b.loader})
+	}
+	s.Unlock()
+
+	for _, r := range toRestart {
+		if s.ml.isLoaded(r.name) {
+			continue
+		}
+		log.Info().Str("model", r.name).Msg("[Supervisor] restarting backend")
+		// the failure is recorded by LoadModel
+		if _, err := s.ml.LoadModel(r.name, r.loader); err != nil {
+			log.Error().Err(err).Str("model", r.name).Msg("[Supervisor] failed restarting backend")
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package model_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/mudler/LocalAI/pkg/model"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Supervisor", func() {
+	It("fails fast once a model keeps crashing", func() {
+		ml := NewModelLoader("")
+		sup := NewSupervisor(ml, WithSupervisorMaxFailures(2), WithSupervisorCooldown(time.Hour))
+		ml.SetSupervisor(sup)
+
+		// the address is not reachable, so the model is detected as crashed at each load
+		attempts := 0
+		loader := func(string, string) (ModelAddress, error) {
+			attempts++
+			return ModelAddress("127.0.0.1:1"), nil
+		}
+
+		_, err := ml.LoadModel("foo", loader)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = ml.LoadModel("foo", loader)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(attempts).To(Equal(2))
+		Expect(sup.IsUnhealthy("foo")).To(BeFalse())
+
+		_, err = ml.LoadModel("foo", loader)
+		Expect(errors.Is(err, ErrBackendUnhealthy)).To(BeTrue())
+		Expect(sup.IsUnhealthy("foo")).To(BeTrue())
+		Expect(attempts).To(Equal(2))
+	})
+
+	It("backs off the loads of a backend failing to start", func() {
+		ml := NewModelLoader("")
+		sup := NewSupervisor(ml, WithSupervisorMaxFailures(3), WithSupervisorBackoff(50*time.Millisecond, time.Second), WithSupervisorCooldown(time.Hour))
+		ml.SetSupervisor(sup)
+
+		attempts := 0
+		loader := func(string, string) (ModelAddress, error) {
+			attempts++
+			return "", errors.New("backend failed to start")
+		}
+
+		_, err := ml.LoadModel("foo", loader)
+		Expect(err).To(MatchError("backend failed to start"))
+		Expect(attempts).To(Equal(1))
+
+		// the loads fail fast during the backoff
+		_, err = ml.LoadModel("foo", loader)
+		Expect(errors.Is(err, ErrBackendUnhealthy)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("retrying in"))
+		Expect(attempts).To(Equal(1))
+
+		time.Sleep(60 * time.Millisecond)
+		_, err = ml.LoadModel("foo", loader)
+		Expect(err).To(MatchError("backend failed to start"))
+		Expect(attempts).To(Equal(2))
+
+		// the backoff doubles with the failures
+		time.Sleep(60 * time.Millisecond)
+		_, err = ml.LoadModel("foo", loader)
+		Expect(errors.Is(err, ErrBackendUnhealthy)).To(BeTrue())
+		Expect(attempts).To(Equal(2))
+
+		time.Sleep(60 * time.Millisecond)
+		_, err = ml.LoadModel("foo", loader)
+		Expect(err).To(MatchError("backend failed to start"))
+		Expect(attempts).To(Equal(3))
+		Expect(sup.IsUnhealthy("foo")).To(BeTrue())
+
+		_, err = ml.LoadModel("foo", loader)
+		Expect(errors.Is(err, ErrBackendUnhealthy)).To(BeTrue())
+		Expect(attempts).To(Equal(3))
+	})
+})
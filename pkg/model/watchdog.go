@@ -4,6 +4,7 @@ import (
 	"sync"
 	"time"
 
+	grpc "github.com/mudler/LocalAI/pkg/grpc"
 	process "github.com/mudler/go-processmanager"
 	"github.com/rs/zerolog/log"
 )
@@ -107,20 +108,20 @@ func (wd *WatchDog) checkIdle() {
 	defer wd.Unlock()
 	log.Debug().Msg("[WatchDog] Watchdog checks for idle connections")
 	for address, t := range wd.idleTime {
-		log.Debug().Msgf("[WatchDog] %s: idle connection", address)
+		log.Debug().Msgf("[WatchDog] %s: idle connection", grpc.RedactBackendURI(address))
 		if time.Since(t) > wd.idletimeout {
-			log.Warn().Msgf("[WatchDog] Address %s is idle for too long, killing it", address)
+			log.Warn().Msgf("[WatchDog] Address %s is idle for too long, killing it", grpc.RedactBackendURI(address))
 			model, ok := wd.addressModelMap[address]
 			if ok {
 				if err := wd.pm.ShutdownModel(model); err != nil {
 					log.Error().Err(err).Str("model", model).Msg("[watchdog] error shutting down model")
 				}
-				log.Debug().Msgf("[WatchDog] model shut down: %s", address)
+				log.Debug().Msgf("[WatchDog] model shut down: %s", grpc.RedactBackendURI(address))
 				delete(wd.idleTime, address)
 				delete(wd.addressModelMap, address)
 				delete(wd.addressMap, address)
 			} else {
-				log.Warn().Msgf("[WatchDog] Address %s unresolvable", address)
+				log.Warn().Msgf("[WatchDog] Address %s unresolvable", grpc.RedactBackendURI(address))
 				delete(wd.idleTime, address)
 			}
 		}
@@ -133,7 +134,7 @@ func (wd *WatchDog) checkBusy() {
 	log.Debug().Msg("[WatchDog] Watchdog checks for busy connections")
 
 	for address, t := range wd.timetable {
-		log.Debug().Msgf("[WatchDog] %s: active connection", address)
+		log.Debug().Msgf("[WatchDog] %s: active connection", grpc.RedactBackendURI(address))
 
 		if time.Since(t) > wd.timeout {
 
@@ -143,12 +144,12 @@ func (wd *WatchDog) checkBusy() {
 				if err := wd.pm.ShutdownModel(model); err != nil {
 					log.Error().Err(err).Str("model", model).Msg("[watchdog] error shutting down model")
 				}
-				log.Debug().Msgf("[WatchDog] model shut down: %s", address)
+				log.Debug().Msgf("[WatchDog] model shut down: %s", grpc.RedactBackendURI(address))
 				delete(wd.timetable, address)
 				delete(wd.addressModelMap, address)
 				delete(wd.addressMap, address)
 			} else {
-				log.Warn().Msgf("[WatchDog] Address %s unresolvable", address)
+				log.Warn().Msgf("[WatchDog] Address %s unresolvable", grpc.RedactBackendURI(address))
 				delete(wd.timetable, address)
 			}
 		}
@@ -0,0 +1,68 @@
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// RegistryCredentials are the credentials used to pull and push images from a registry
+type RegistryCredentials struct {
+	Registry string `json:"registry" yaml:"registry"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	// Token is a bearer token, used instead of username and password
+	Token string `json:"token" yaml:"token"`
+}
+
+// ParseRegistryCredentials parses the credentials of the registries from a JSON list
+func ParseRegistryCredentials(registries string) ([]RegistryCredentials, error) {
+	if registries == "" {
+		return nil, nil
+	}
+	var credentials []RegistryCredentials
+	if err := json.Unmarshal([]byte(registries), &credentials); err != nil {
+		return nil, fmt.Errorf("unable to load OCI registries credentials: %w", err)
+	}
+	return credentials, nil
+}
+
+var (
+	credentialsMu       sync.RWMutex
+	registryCredentials []RegistryCredentials
+)
+
+// SetRegistryCredentials sets the credentials used for the registries, they take precedence
+// over the ones of the docker config.json
+func SetRegistryCredentials(credentials []RegistryCredentials) {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+	registryCredentials = credentials
+}
+
+type credentialsKeychain struct{}
+
+func (credentialsKeychain) Resolve(r authn.Resource) (authn.Authenticator, error) {
+	credentialsMu.RLock()
+	defer credentialsMu.RUnlock()
+
+	for _, c := range registryCredentials {
+		registry, err := name.NewRegistry(c.Registry)
+		if err != nil || registry.RegistryStr() != r.RegistryStr() {
+			continue
+		}
+		return authn.FromConfig(authn.AuthConfig{
+			Username:      c.Username,
+			Password:      c.Password,
+			RegistryToken: c.Token,
+		}), nil
+	}
+	return authn.Anonymous, nil
+}
+
+// Keychain resolves the credentials of a registry from the ones set with SetRegistryCredentials,
+// falling back to the docker config.json (DOCKER_CONFIG) and its credential helpers
+var Keychain = authn.NewMultiKeychain(credentialsKeychain{}, authn.DefaultKeychain)
@@ -0,0 +1,29 @@
+package oci_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseRegistryCredentials", func() {
+	It("parses the credentials of the registries", func() {
+		credentials, err := ParseRegistryCredentials(`[{"registry":"registry.example.com","username":"user","password":"secret"},{"registry":"ghcr.io","token":"abc"}]`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(credentials).To(Equal([]RegistryCredentials{
+			{Registry: "registry.example.com", Username: "user", Password: "secret"},
+			{Registry: "ghcr.io", Token: "abc"},
+		}))
+	})
+
+	It("returns no credentials for an empty string", func() {
+		credentials, err := ParseRegistryCredentials("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(credentials).To(BeEmpty())
+	})
+
+	It("fails on invalid JSON", func() {
+		_, err := ParseRegistryCredentials("registry.example.com")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -13,6 +13,11 @@ import (
 )
 
 func FetchImageBlob(r, reference, dst string, statusReader func(ocispec.Descriptor) io.Writer) error {
+	return FetchImageBlobWithContext(context.Background(), r, reference, dst, statusReader)
+}
+
+// FetchImageBlobWithContext is FetchImageBlob, stopping the download when ctx is cancelled
+func FetchImageBlobWithContext(ctx context.Context, r, reference, dst string, statusReader func(ocispec.Descriptor) io.Writer) error {
 	// 0. Create a file store for the output
 	fs, err := os.Create(dst)
 	if err != nil {
@@ -21,7 +26,6 @@ func FetchImageBlob(r, reference, dst string, statusReader func(ocispec.Descript
 	defer fs.Close()
 
 	// 1. Connect to a remote repository
-	ctx := context.Background()
 	repo, err := remote.NewRepository(r)
 	if err != nil {
 		return fmt.Errorf("failed to create repository: %v", err)
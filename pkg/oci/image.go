@@ -61,9 +61,15 @@ var defaultRetryPredicate = func(err error) bool {
 
 // ExtractOCIImage will extract a given targetImage into a given targetDestination
 func ExtractOCIImage(img v1.Image, targetDestination string) error {
+	return ExtractOCIImageWithContext(context.Background(), img, targetDestination)
+}
+
+// ExtractOCIImageWithContext is ExtractOCIImage, stopping the extraction when ctx is cancelled
+func ExtractOCIImageWithContext(ctx context.Context, img v1.Image, targetDestination string) error {
 	reader := mutate.Extract(img)
+	defer reader.Close()
 
-	_, err := archive.Apply(context.Background(), targetDestination, reader, archive.WithNoSameOwner())
+	_, err := archive.Apply(ctx, targetDestination, reader, archive.WithNoSameOwner())
 
 	return err
 }
@@ -85,10 +91,23 @@ func ParseImageParts(image string) (tag, repository, dstimage string) {
 	return tag, repository, image
 }
 
+// authOption authenticates with auth if set, or with the credentials of the Keychain otherwise
+func authOption(auth *registrytypes.AuthConfig) remote.Option {
+	if auth != nil {
+		return remote.WithAuth(staticAuth{auth})
+	}
+	return remote.WithAuthFromKeychain(Keychain)
+}
+
 // GetImage if returns the proper image to pull with transport and auth
 // tries local daemon first and then fallbacks into remote
-// if auth is nil, it will try to use the default keychain https://github.com/google/go-containerregistry/tree/main/pkg/authn#tldr-for-consumers-of-this-package
+// if auth is nil, it will try to use the credentials set with SetRegistryCredentials, and then the default keychain https://github.com/google/go-containerregistry/tree/main/pkg/authn#tldr-for-consumers-of-this-package
 func GetImage(targetImage, targetPlatform string, auth *registrytypes.AuthConfig, t http.RoundTripper) (v1.Image, error) {
+	return GetImageWithContext(context.Background(), targetImage, targetPlatform, auth, t)
+}
+
+// GetImageWithContext is GetImage, the layers of the image being downloaded with ctx
+func GetImageWithContext(ctx context.Context, targetImage, targetPlatform string, auth *registrytypes.AuthConfig, t http.RoundTripper) (v1.Image, error) {
 	var platform *v1.Platform
 	var image v1.Image
 	var err error
@@ -122,12 +141,9 @@ func GetImage(targetImage, targetPlatform string, auth *registrytypes.AuthConfig
 	opts := []remote.Option{
 		remote.WithTransport(tr),
 		remote.WithPlatform(*platform),
+		remote.WithContext(ctx),
 	}
-	if auth != nil {
-		opts = append(opts, remote.WithAuth(staticAuth{auth}))
-	} else {
-		opts = append(opts, remote.WithAuthFromKeychain(authn.DefaultKeychain))
-	}
+	opts = append(opts, authOption(auth))
 
 	image, err = remote.Image(ref, opts...)
 
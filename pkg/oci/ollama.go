@@ -1,6 +1,7 @@
 package oci
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -32,6 +33,10 @@ type LayerDetail struct {
 }
 
 func OllamaModelManifest(image string) (*Manifest, error) {
+	return ollamaModelManifest(context.Background(), image)
+}
+
+func ollamaModelManifest(ctx context.Context, image string) (*Manifest, error) {
 	// parse the repository and tag from `image`. `image` should be for e.g. gemma:2b, or foobar/gemma:2b
 
 	// if there is a : in the image, then split it
@@ -39,7 +44,7 @@ func OllamaModelManifest(image string) (*Manifest, error) {
 	tag, repository, image := ParseImageParts(image)
 
 	// get e.g. https://registry.ollama.ai/v2/library/llama3/manifests/latest
-	req, err := http.NewRequest("GET", "https://registry.ollama.ai/v2/"+repository+"/"+image+"/manifests/"+tag, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://registry.ollama.ai/v2/"+repository+"/"+image+"/manifests/"+tag, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -49,6 +54,7 @@ func OllamaModelManifest(image string) (*Manifest, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	// parse the JSON response
 	var manifest Manifest
@@ -61,7 +67,11 @@ func OllamaModelManifest(image string) (*Manifest, error) {
 }
 
 func OllamaModelBlob(image string) (string, error) {
-	manifest, err := OllamaModelManifest(image)
+	return ollamaModelBlob(context.Background(), image)
+}
+
+func ollamaModelBlob(ctx context.Context, image string) (string, error) {
+	manifest, err := ollamaModelManifest(ctx, image)
 	if err != nil {
 		return "", err
 	}
@@ -77,12 +87,17 @@ func OllamaModelBlob(image string) (string, error) {
 }
 
 func OllamaFetchModel(image string, output string, statusWriter func(ocispec.Descriptor) io.Writer) error {
+	return OllamaFetchModelWithContext(context.Background(), image, output, statusWriter)
+}
+
+// OllamaFetchModelWithContext is OllamaFetchModel, stopping the download when ctx is cancelled
+func OllamaFetchModelWithContext(ctx context.Context, image string, output string, statusWriter func(ocispec.Descriptor) io.Writer) error {
 	_, repository, imageNoTag := ParseImageParts(image)
 
-	blobID, err := OllamaModelBlob(image)
+	blobID, err := ollamaModelBlob(ctx, image)
 	if err != nil {
 		return err
 	}
 
-	return FetchImageBlob(fmt.Sprintf("registry.ollama.ai/%s/%s", repository, imageNoTag), blobID, output, statusWriter)
+	return FetchImageBlobWithContext(ctx, fmt.Sprintf("registry.ollama.ai/%s/%s", repository, imageNoTag), blobID, output, statusWriter)
 }
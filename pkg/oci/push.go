@@ -0,0 +1,114 @@
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	registrytypes "github.com/docker/docker/api/types/registry"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// fileLayer returns a layer containing only the file, at its path relative to baseDir
+func fileLayer(baseDir, file string) (v1.Layer, error) {
+	path := filepath.Join(baseDir, file)
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	opener := func() (io.ReadCloser, error) {
+		st, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			defer f.Close()
+			tw := tar.NewWriter(pw)
+			err := tw.WriteHeader(&tar.Header{
+				Name:    filepath.ToSlash(file),
+				Mode:    0644,
+				Size:    st.Size(),
+				ModTime: st.ModTime(),
+			})
+			if err == nil {
+				_, err = io.Copy(tw, f)
+			}
+			if err == nil {
+				err = tw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		return pr, nil
+	}
+
+	return tarball.LayerFromOpener(opener,
+		tarball.WithMediaType(types.OCILayer),
+		// model weights do not compress well
+		tarball.WithCompressionLevel(gzip.BestSpeed),
+	)
+}
+
+// PushFiles pushes the files (relative to baseDir) to targetImage, with a layer for each file.
+// The image is extracted back to the same layout when pulled with an oci:// URI.
+// It returns the digest of the pushed image.
+func PushFiles(targetImage, baseDir string, files []string, annotations map[string]string, auth *registrytypes.AuthConfig, t http.RoundTripper) (string, error) {
+	ref, err := name.ParseReference(targetImage)
+	if err != nil {
+		return "", err
+	}
+
+	img := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	img = mutate.ConfigMediaType(img, types.OCIConfigJSON)
+	for _, file := range files {
+		layer, err := fileLayer(baseDir, file)
+		if err != nil {
+			return "", fmt.Errorf("failed packaging %q: %w", file, err)
+		}
+		img, err = mutate.Append(img, mutate.Addendum{
+			Layer:       layer,
+			Annotations: map[string]string{ocispec.AnnotationTitle: filepath.ToSlash(file)},
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(annotations) > 0 {
+		img = mutate.Annotations(img, annotations).(v1.Image)
+	}
+
+	if t == nil {
+		t = http.DefaultTransport
+	}
+	tr := transport.NewRetry(t,
+		transport.WithRetryBackoff(defaultRetryBackoff),
+		transport.WithRetryPredicate(defaultRetryPredicate),
+	)
+
+	if err := remote.Write(ref, img, remote.WithTransport(tr), authOption(auth)); err != nil {
+		return "", err
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return "", err
+	}
+	return digest.String(), nil
+}
@@ -0,0 +1,72 @@
+package oci_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	. "github.com/mudler/LocalAI/pkg/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Push", func() {
+	var server *httptest.Server
+	var host string
+
+	BeforeEach(func() {
+		reg := registry.New()
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			reg.ServeHTTP(w, r)
+		}))
+		host = strings.TrimPrefix(server.URL, "http://")
+	})
+
+	AfterEach(func() {
+		server.Close()
+		SetRegistryCredentials(nil)
+	})
+
+	It("pushes files to a private registry and pulls them back", func() {
+		src, err := os.MkdirTemp("", "push")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(src)
+		Expect(os.WriteFile(filepath.Join(src, "model.yaml"), []byte("name: model"), 0600)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(src, "weights"), 0750)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(src, "weights", "model.gguf"), []byte("weights"), 0600)).To(Succeed())
+
+		image := host + "/models/model:latest"
+		files := []string{"model.yaml", filepath.Join("weights", "model.gguf")}
+
+		_, err = PushFiles(image, src, files, nil, nil, nil)
+		Expect(err).To(HaveOccurred())
+
+		SetRegistryCredentials([]RegistryCredentials{{Registry: host, Username: "user", Password: "secret"}})
+		digest, err := PushFiles(image, src, files, map[string]string{"org.opencontainers.image.title": "model"}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(digest).To(HavePrefix("sha256:"))
+
+		img, err := GetImage(image, "", nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		dst, err := os.MkdirTemp("", "pull")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dst)
+		Expect(ExtractOCIImage(img, dst)).To(Succeed())
+
+		data, err := os.ReadFile(filepath.Join(dst, "weights", "model.gguf"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("weights"))
+		data, err = os.ReadFile(filepath.Join(dst, "model.yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("name: model"))
+	})
+})
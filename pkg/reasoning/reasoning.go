@@ -0,0 +1,241 @@
+package reasoning
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultStartTag = "<think>"
+	DefaultEndTag   = "</think>"
+)
+
+// Config sets how the reasoning of a model, the text it writes before its reply, is separated from the content of the reply
+type Config struct {
+	// Disable keeps the reasoning in the content of the reply
+	Disable bool `yaml:"disable"`
+
+	// StartTag and EndTag delimit the reasoning blocks, <think> and </think> by default
+	StartTag string `yaml:"start_tag"`
+	EndTag   string `yaml:"end_tag"`
+
+	// StartOpen is for the models whose template opens the reasoning block in the prompt:
+	// the reply starts with the reasoning, until the end tag
+	StartOpen bool `yaml:"start_open"`
+
+	// Regex extracts the reasoning with its first group instead of the tags, the matches being removed from the content.
+	// The streamed replies are then buffered: their reasoning and their content are sent at their end, all at once
+	Regex string `yaml:"regex"`
+}
+
+// compiledRegex is a regex of the configurations compiled, or its error
+type compiledRegex struct {
+	re  *regexp.Regexp
+	err error
+}
+
+// regexes caches the regexes of the configurations, compiled on their first use
+var regexes sync.Map
+
+func (c Config) regex() (*regexp.Regexp, error) {
+	if compiled, ok := regexes.Load(c.Regex); ok {
+		return compiled.(compiledRegex).re, compiled.(compiledRegex).err
+	}
+	re, err := regexp.Compile(c.Regex)
+	regexes.Store(c.Regex, compiledRegex{re: re, err: err})
+	return re, err
+}
+
+// Validate checks the regex of the configuration
+func (c Config) Validate() error {
+	if c.Regex == "" {
+		return nil
+	}
+	re, err := c.regex()
+	if err != nil {
+		return fmt.Errorf("invalid reasoning regex: %w", err)
+	}
+	if re.NumSubexp() < 1 {
+		return fmt.Errorf("the reasoning regex %q has no group capturing the reasoning", c.Regex)
+	}
+	return nil
+}
+
+func (c Config) tags() (string, string) {
+	start, end := c.StartTag, c.EndTag
+	if start == "" {
+		start = DefaultStartTag
+	}
+	if end == "" {
+		end = DefaultEndTag
+	}
+	return start, end
+}
+
+// Extract separates the reasoning of a reply from its content. The reply without reasoning block is returned unchanged
+func (c Config) Extract(text string) (reasoning, content string) {
+	if c.Disable {
+		return "", text
+	}
+	e := NewExtractor(c)
+	r1, c1 := e.Process(text)
+	r2, c2 := e.Flush()
+	if !e.matched {
+		return "", text
+	}
+	return strings.TrimSpace(r1 + r2), strings.TrimSpace(c1 + c2)
+}
+
+// Strip removes the reasoning of a reply
+func (c Config) Strip(text string) string {
+	_, content := c.Extract(text)
+	return content
+}
+
+func (c Config) extractRegex(text string) (string, string, bool) {
+	re, err := c.regex()
+	if err != nil {
+		log.Error().Err(err).Msg("invalid reasoning regex, the reasoning is not extracted")
+		return "", text, false
+	}
+	matches := re.FindAllStringSubmatch(text, -1)
+	blocks := []string{}
+	for _, match := range matches {
+		if len(match) > 1 && strings.TrimSpace(match[1]) != "" {
+			blocks = append(blocks, strings.TrimSpace(match[1]))
+		}
+	}
+	return strings.Join(blocks, "\n\n"), re.ReplaceAllString(text, ""), len(matches) > 0
+}
+
+// Extractor separates the reasoning of a streamed reply from its content, token by token.
+// The text that may be the start of a tag is held until the next tokens tell
+type Extractor struct {
+	config     Config
+	start, end string
+
+	inside  bool
+	pending string
+	// a block was found
+	matched bool
+	// the spaces at the start of a block and after it are trimmed
+	trimReasoning, trimContent bool
+	// a separator is added between the blocks
+	reasoned, separate bool
+	// the whole reply, to extract with the regex
+	reply strings.Builder
+}
+
+func NewExtractor(c Config) *Extractor {
+	start, end := c.tags()
+	return &Extractor{
+		config:        c,
+		start:         start,
+		end:           end,
+		inside:        c.StartOpen,
+		matched:       c.StartOpen,
+		trimReasoning: c.StartOpen,
+	}
+}
+
+// Process returns the reasoning and the content of a token
+func (e *Extractor) Process(token string) (reasoning, content string) {
+	switch {
+	case e.config.Disable:
+		return "", token
+	case e.config.Regex != "":
+		e.reply.WriteString(token)
+		return "", ""
+	}
+
+	var r, c strings.Builder
+	text := e.pending + token
+	e.pending = ""
+	for text != "" {
+		tag := e.start
+		if e.inside {
+			tag = e.end
+		}
+		if i := strings.Index(text, tag); i >= 0 {
+			e.emit(&r, &c, text[:i])
+			text = text[i+len(tag):]
+			e.toggle()
+			continue
+		}
+		keep := partialTag(text, tag)
+		e.emit(&r, &c, text[:len(text)-keep])
+		e.pending = text[len(text)-keep:]
+		break
+	}
+	return r.String(), c.String()
+}
+
+// Flush returns the text held at the end of the reply
+func (e *Extractor) Flush() (reasoning, content string) {
+	if e.config.Regex != "" && !e.config.Disable {
+		reasoning, content, e.matched = e.config.extractRegex(e.reply.String())
+		e.reply.Reset()
+		if e.matched {
+			content = strings.TrimSpace(content)
+		}
+		return reasoning, content
+	}
+	var r, c strings.Builder
+	e.emit(&r, &c, e.pending)
+	e.pending = ""
+	return r.String(), c.String()
+}
+
+func (e *Extractor) toggle() {
+	e.inside = !e.inside
+	e.matched = true
+	if e.inside {
+		e.trimReasoning = true
+		e.separate = e.reasoned
+	} else {
+		e.trimContent = true
+	}
+}
+
+func (e *Extractor) emit(r, c *strings.Builder, text string) {
+	if !e.inside {
+		if e.trimContent {
+			text = strings.TrimLeft(text, " \t\r\n")
+			e.trimContent = text == ""
+		}
+		c.WriteString(text)
+		return
+	}
+
+	if e.trimReasoning {
+		text = strings.TrimLeft(text, " \t\r\n")
+		e.trimReasoning = text == ""
+	}
+	if text == "" {
+		return
+	}
+	if e.separate {
+		r.WriteString("\n\n")
+		e.separate = false
+	}
+	r.WriteString(text)
+	e.reasoned = true
+}
+
+// partialTag returns the length of the longest end of the text which is the start of the tag
+func partialTag(text, tag string) int {
+	n := len(tag) - 1
+	if len(text) < n {
+		n = len(text)
+	}
+	for ; n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package reasoning_test
+
+import (
+	"strings"
+
+	. "github.com/mudler/LocalAI/pkg/reasoning"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// stream processes a reply split in tokens of the given size
+func stream(cfg Config, reply string, size int) (string, string) {
+	e := NewExtractor(cfg)
+	var reasoning, content strings.Builder
+	for i := 0; i < len(reply); i += size {
+		r, c := e.Process(reply[i:min(i+size, len(reply))])
+		reasoning.WriteString(r)
+		content.WriteString(c)
+	}
+	r, c := e.Flush()
+	reasoning.WriteString(r)
+	content.WriteString(c)
+	return reasoning.String(), content.String()
+}
+
+var _ = Describe("Reasoning extraction", func() {
+	reply := "<think>\nThe user greets me.\n</think>\n\nHello!"
+
+	It("extracts the reasoning of a reply", func() {
+		reasoning, content := Config{}.Extract(reply)
+		Expect(reasoning).To(Equal("The user greets me."))
+		Expect(content).To(Equal("Hello!"))
+
+		reasoning, content = Config{}.Extract("Hello!")
+		Expect(reasoning).To(BeEmpty())
+		Expect(content).To(Equal("Hello!"))
+	})
+
+	It("extracts the reasoning of a streamed reply whatever the tokens", func() {
+		for _, size := range []int{1, 2, 3, 5, 7, len(reply)} {
+			reasoning, content := stream(Config{}, reply, size)
+			Expect(strings.TrimSpace(reasoning)).To(Equal("The user greets me."), "tokens of %d", size)
+			Expect(content).To(Equal("Hello!"), "tokens of %d", size)
+		}
+	})
+
+	It("keeps the text that only looks like a tag", func() {
+		reasoning, content := stream(Config{}, "a <b> <thin", 2)
+		Expect(reasoning).To(BeEmpty())
+		Expect(content).To(Equal("a <b> <thin"))
+	})
+
+	It("supports several blocks and an unterminated one", func() {
+		reasoning, content := Config{}.Extract("<think>one</think>A<think>two</think>B<think>three")
+		Expect(reasoning).To(Equal("one\n\ntwo\n\nthree"))
+		Expect(content).To(Equal("AB"))
+	})
+
+	It("supports the custom tags and the blocks opened by the prompt", func() {
+		c := Config{StartTag: "[THINK]", EndTag: "[/THINK]", StartOpen: true}
+		reasoning, content := stream(c, "Let me see.[/THINK]Sure.", 3)
+		Expect(reasoning).To(Equal("Let me see."))
+		Expect(content).To(Equal("Sure."))
+	})
+
+	It("extracts the reasoning with a regex", func() {
+		c := Config{Regex: `(?s)<reasoning>(.*?)</reasoning>`}
+		Expect(c.Validate()).To(Succeed())
+		reasoning, content := stream(c, "<reasoning>Why not</reasoning> Yes", 4)
+		Expect(reasoning).To(Equal("Why not"))
+		Expect(content).To(Equal("Yes"))
+
+		Expect(Config{Regex: "reasoning"}.Validate()).To(MatchError(ContainSubstring("no group")))
+		Expect(Config{Regex: "("}.Validate()).To(HaveOccurred())
+		// the regexes are compiled once, with their error
+		Expect(Config{Regex: "("}.Validate()).To(HaveOccurred())
+		Expect(Config{Regex: "("}.Strip("<reasoning>Why not</reasoning> Yes")).To(Equal("<reasoning>Why not</reasoning> Yes"))
+	})
+
+	It("keeps the reasoning in the content when disabled", func() {
+		c := Config{Disable: true}
+		reasoning, content := stream(c, reply, 3)
+		Expect(reasoning).To(BeEmpty())
+		Expect(content).To(Equal(reply))
+		Expect(c.Strip(reply)).To(Equal(reply))
+	})
+})
+
+var _ = Describe("Reasoning stripping", func() {
+	It("returns the replies without reasoning unchanged", func() {
+		Expect(Config{}.Strip(" Hello!\n")).To(Equal(" Hello!\n"))
+		Expect(Config{Regex: `(?s)<r>(.*?)</r>`}.Strip(" Hello!\n")).To(Equal(" Hello!\n"))
+		Expect(Config{}.Strip("<think>{\"name\": \"draft\"}</think> {\"name\": \"search\"}")).To(Equal(`{"name": "search"}`))
+	})
+})
@@ -0,0 +1,186 @@
+// Package signature signs and verifies files with ed25519 keys, in the format of minisign
+// (https://jedisct1.github.io/minisign/), so that files signed by one tool can be verified by the other.
+package signature
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Extension is the extension of the signature of a file
+const Extension = ".minisig"
+
+const (
+	untrustedCommentPrefix = "untrusted comment: "
+	trustedCommentPrefix   = "trusted comment: "
+)
+
+var (
+	algEd25519          = [2]byte{'E', 'd'}
+	algPrehashedEd25519 = [2]byte{'E', 'D'}
+	kdfNone             = [2]byte{0, 0}
+	checksumBlake2b     = [2]byte{'B', '2'}
+
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+type PublicKey struct {
+	ID  [8]byte
+	Key ed25519.PublicKey
+}
+
+type PrivateKey struct {
+	ID  [8]byte
+	Key ed25519.PrivateKey
+}
+
+// GenerateKey creates a new key pair
+func GenerateKey() (*PublicKey, *PrivateKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, nil, err
+	}
+	return &PublicKey{ID: id, Key: pub}, &PrivateKey{ID: id, Key: priv}, nil
+}
+
+func keyID(id [8]byte) string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(id[:]))
+}
+
+// decodeLines returns the base64 decoded lines of s which are not comments
+func decodeLines(s string) ([][]byte, []string, error) {
+	data := [][]byte{}
+	comments := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, untrustedCommentPrefix):
+		case strings.HasPrefix(line, trustedCommentPrefix):
+			comments = append(comments, strings.TrimPrefix(line, trustedCommentPrefix))
+		default:
+			d, err := base64.StdEncoding.DecodeString(line)
+			if err != nil {
+				return nil, nil, err
+			}
+			data = append(data, d)
+		}
+	}
+	return data, comments, nil
+}
+
+// ParsePublicKey parses a minisign public key, either the content of the key file or its base64 encoded line
+func ParsePublicKey(s string) (*PublicKey, error) {
+	data, _, err := decodeLines(s)
+	if err != nil || len(data) != 1 || len(data[0]) != 2+8+ed25519.PublicKeySize || !bytes.Equal(data[0][:2], algEd25519[:]) {
+		return nil, fmt.Errorf("invalid public key")
+	}
+	k := &PublicKey{Key: ed25519.PublicKey(data[0][10:])}
+	copy(k.ID[:], data[0][2:10])
+	return k, nil
+}
+
+// Encode returns the base64 encoded public key
+func (k *PublicKey) Encode() string {
+	return base64.StdEncoding.EncodeToString(append(append(algEd25519[:], k.ID[:]...), k.Key...))
+}
+
+// String returns the content of the public key file
+func (k *PublicKey) String() string {
+	return fmt.Sprintf("%sminisign public key %s\n%s\n", untrustedCommentPrefix, keyID(k.ID), k.Encode())
+}
+
+func privateKeyChecksum(id [8]byte, key ed25519.PrivateKey) []byte {
+	sum := blake2b.Sum256(append(append(algEd25519[:], id[:]...), key...))
+	return sum[:]
+}
+
+// ParsePrivateKey parses an unencrypted minisign secret key, as written by `minisign -G -W`
+func ParsePrivateKey(s string) (*PrivateKey, error) {
+	data, _, err := decodeLines(s)
+	if err != nil || len(data) != 1 || len(data[0]) != 2+2+2+32+8+8+8+ed25519.PrivateKeySize+32 {
+		return nil, fmt.Errorf("invalid private key")
+	}
+	d := data[0]
+	if !bytes.Equal(d[:2], algEd25519[:]) || !bytes.Equal(d[4:6], checksumBlake2b[:]) {
+		return nil, fmt.Errorf("invalid private key")
+	}
+	if !bytes.Equal(d[2:4], kdfNone[:]) {
+		return nil, fmt.Errorf("encrypted private keys are not supported")
+	}
+	keynum := d[54:]
+	k := &PrivateKey{Key: ed25519.PrivateKey(keynum[8 : 8+ed25519.PrivateKeySize])}
+	copy(k.ID[:], keynum[:8])
+	if !bytes.Equal(keynum[8+ed25519.PrivateKeySize:], privateKeyChecksum(k.ID, k.Key)) {
+		return nil, fmt.Errorf("invalid private key checksum")
+	}
+	return k, nil
+}
+
+// String returns the content of the (unencrypted) private key file
+func (k *PrivateKey) String() string {
+	d := append(algEd25519[:], kdfNone[:]...)
+	d = append(d, checksumBlake2b[:]...)
+	d = append(d, make([]byte, 32+8+8)...) // kdf salt and limits, unused
+	d = append(d, k.ID[:]...)
+	d = append(d, k.Key...)
+	d = append(d, privateKeyChecksum(k.ID, k.Key)...)
+	return fmt.Sprintf("%sminisign secret key %s\n%s\n", untrustedCommentPrefix, keyID(k.ID), base64.StdEncoding.EncodeToString(d))
+}
+
+func (k *PrivateKey) Public() *PublicKey {
+	return &PublicKey{ID: k.ID, Key: k.Key.Public().(ed25519.PublicKey)}
+}
+
+// Sign returns the signature of message. The trusted comment is signed as well.
+func (k *PrivateKey) Sign(message []byte, trustedComment string) []byte {
+	hash := blake2b.Sum512(message)
+	sig := ed25519.Sign(k.Key, hash[:])
+	global := ed25519.Sign(k.Key, append(append([]byte{}, sig...), trustedComment...))
+
+	line := append(append(algPrehashedEd25519[:], k.ID[:]...), sig...)
+	return []byte(fmt.Sprintf("%ssignature from minisign secret key\n%s\n%s%s\n%s\n",
+		untrustedCommentPrefix,
+		base64.StdEncoding.EncodeToString(line),
+		trustedCommentPrefix, trustedComment,
+		base64.StdEncoding.EncodeToString(global)))
+}
+
+// Verify checks that signature is a valid signature of message by the key
+func (k *PublicKey) Verify(message, signature []byte) error {
+	data, comments, err := decodeLines(string(signature))
+	if err != nil || len(data) != 2 || len(comments) != 1 || len(data[0]) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+	alg, id, sig := data[0][:2], data[0][2:10], data[0][10:]
+	if !bytes.Equal(id, k.ID[:]) {
+		return fmt.Errorf("%w: signed with key %s, expected %s", ErrInvalidSignature, keyID([8]byte(id)), keyID(k.ID))
+	}
+
+	switch {
+	case bytes.Equal(alg, algPrehashedEd25519[:]):
+		hash := blake2b.Sum512(message)
+		message = hash[:]
+	case !bytes.Equal(alg, algEd25519[:]):
+		return fmt.Errorf("%w: unsupported algorithm", ErrInvalidSignature)
+	}
+	if !ed25519.Verify(k.Key, message, sig) {
+		return ErrInvalidSignature
+	}
+	if !ed25519.Verify(k.Key, append(append([]byte{}, sig...), comments[0]...), data[1]) {
+		return fmt.Errorf("%w: invalid trusted comment", ErrInvalidSignature)
+	}
+	return nil
+}
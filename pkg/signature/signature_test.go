@@ -0,0 +1,52 @@
+package signature_test
+
+import (
+	"strings"
+
+	. "github.com/mudler/LocalAI/pkg/signature"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Signatures", func() {
+	var pub *PublicKey
+	var priv *PrivateKey
+
+	BeforeEach(func() {
+		var err error
+		pub, priv, err = GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("signs and verifies messages", func() {
+		sig := priv.Sign([]byte("hello"), "file:index.yaml")
+		Expect(pub.Verify([]byte("hello"), sig)).To(Succeed())
+		Expect(pub.Verify([]byte("hello!"), sig)).To(MatchError(ErrInvalidSignature))
+	})
+
+	It("refuses signatures of other keys", func() {
+		_, other, err := GenerateKey()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pub.Verify([]byte("hello"), other.Sign([]byte("hello"), ""))).ToNot(Succeed())
+	})
+
+	It("refuses tampered trusted comments", func() {
+		sig := string(priv.Sign([]byte("hello"), "timestamp:1"))
+		tampered := []byte(strings.Replace(sig, "timestamp:1", "timestamp:2", 1))
+		Expect(pub.Verify([]byte("hello"), tampered)).ToNot(Succeed())
+	})
+
+	It("encodes and parses the keys", func() {
+		parsedPriv, err := ParsePrivateKey(priv.String())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parsedPriv).To(Equal(priv))
+
+		for _, s := range []string{pub.String(), pub.Encode()} {
+			parsedPub, err := ParsePublicKey(s)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsedPub).To(Equal(pub))
+		}
+		Expect(parsedPriv.Public()).To(Equal(pub))
+	})
+
+})
@@ -1,6 +1,7 @@
 package startup
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -19,11 +20,14 @@ import (
 // InstallModels will preload models from the given list of URLs and galleries
 // It will download the model if it is not already present in the model path
 // It will also try to resolve if the model is an embedded model YAML configuration
-func InstallModels(galleries []config.Gallery, modelLibraryURL string, modelPath string, enforceScan bool, downloadStatus func(string, string, string, float64), models ...string) error {
+func InstallModels(ctx context.Context, galleries []config.Gallery, modelLibraryURL string, modelPath string, enforceScan bool, downloadStatus func(string, string, string, float64), models ...string) error {
 	// create an error that groups all errors
 	var err error
 
 	for _, url := range models {
+		if ctx.Err() != nil {
+			return errors.Join(err, ctx.Err())
+		}
 
 		// As a best effort, try to resolve the model from the remote library
 		// if it's not resolved we try with the other method below
@@ -82,7 +86,10 @@ func InstallModels(galleries []config.Gallery, modelLibraryURL string, modelPath
 
 			// Extract filename from URL
 			fileName, e := filenameFromUrl(url)
-			if e != nil || fileName == "" {
+			if downloader.IsMultiFileURI(url) {
+				// the files are stored with their path in the repository
+				fileName, e = "", nil
+			} else if e != nil || fileName == "" {
 				fileName = utils.MD5(url)
 				if strings.HasSuffix(url, ".yaml") || strings.HasSuffix(url, ".yml") {
 					fileName = fileName + ".yaml"
@@ -92,22 +99,31 @@ func InstallModels(galleries []config.Gallery, modelLibraryURL string, modelPath
 				//continue
 			}
 
-			modelPath := filepath.Join(modelPath, fileName)
-
-			if e := utils.VerifyPath(fileName, modelPath); e != nil {
-				log.Error().Err(e).Str("filepath", modelPath).Msg("error verifying path")
+			files, e := downloader.ExpandURI(downloader.RemoteFile{URI: url, Filename: fileName})
+			if e != nil {
+				log.Error().Err(e).Str("url", url).Msg("error expanding URL")
 				err = errors.Join(err, e)
 				continue
 			}
 
-			// check if file exists
-			if _, e := os.Stat(modelPath); errors.Is(e, os.ErrNotExist) {
-				e := downloader.DownloadFile(url, modelPath, "", 0, 0, func(fileName, current, total string, percent float64) {
-					utils.DisplayDownloadFunction(fileName, current, total, percent)
-				})
-				if e != nil {
-					log.Error().Err(e).Str("url", url).Str("filepath", modelPath).Msg("error downloading model")
+			for i, file := range files {
+				filePath := filepath.Join(modelPath, file.Filename)
+
+				if e := utils.VerifyPath(file.Filename, modelPath); e != nil {
+					log.Error().Err(e).Str("filepath", filePath).Msg("error verifying path")
 					err = errors.Join(err, e)
+					continue
+				}
+
+				// check if file exists
+				if _, e := os.Stat(filePath); errors.Is(e, os.ErrNotExist) {
+					e := downloader.DownloadFileWithContext(ctx, file.URI, filePath, file.SHA256, i, len(files), func(fileName, current, total string, percent float64) {
+						utils.DisplayDownloadFunction(fileName, current, total, percent)
+					})
+					if e != nil {
+						log.Error().Err(e).Str("url", file.URI).Str("filepath", filePath).Msg("error downloading model")
+						err = errors.Join(err, e)
+					}
 				}
 			}
 		default:
@@ -130,7 +146,7 @@ func InstallModels(galleries []config.Gallery, modelLibraryURL string, modelPath
 				}
 			} else {
 				// Check if it's a model gallery, or print a warning
-				e, found := installModel(galleries, url, modelPath, downloadStatus, enforceScan)
+				e, found := installModel(ctx, galleries, url, modelPath, downloadStatus, enforceScan)
 				if e != nil && found {
 					log.Error().Err(err).Msgf("[startup] failed installing model '%s'", url)
 					err = errors.Join(err, e)
@@ -144,7 +160,7 @@ func InstallModels(galleries []config.Gallery, modelLibraryURL string, modelPath
 	return err
 }
 
-func installModel(galleries []config.Gallery, modelName, modelPath string, downloadStatus func(string, string, string, float64), enforceScan bool) (error, bool) {
+func installModel(ctx context.Context, galleries []config.Gallery, modelName, modelPath string, downloadStatus func(string, string, string, float64), enforceScan bool) (error, bool) {
 	models, err := gallery.AvailableGalleryModels(galleries, modelPath)
 	if err != nil {
 		return err, false
@@ -160,7 +176,7 @@ func installModel(galleries []config.Gallery, modelName, modelPath string, downl
 	}
 
 	log.Info().Str("model", modelName).Str("license", model.License).Msg("installing model")
-	err = gallery.InstallModelFromGallery(galleries, modelName, modelPath, gallery.GalleryModel{}, downloadStatus, enforceScan)
+	err = gallery.InstallModelFromGallery(ctx, galleries, modelName, modelPath, gallery.GalleryModel{}, downloadStatus, enforceScan)
 	if err != nil {
 		return err, true
 	}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"text/template"
 
+	"github.com/mudler/LocalAI/pkg/templates/jinja"
 	"github.com/mudler/LocalAI/pkg/utils"
 
 	"github.com/Masterminds/sprig/v3"
@@ -21,12 +23,14 @@ type TemplateCache struct {
 	mu            sync.Mutex
 	templatesPath string
 	templates     map[TemplateType]map[string]*template.Template
+	jinja         map[string]*jinja.Template
 }
 
 func NewTemplateCache(templatesPath string) *TemplateCache {
 	tc := &TemplateCache{
 		templatesPath: templatesPath,
 		templates:     make(map[TemplateType]map[string]*template.Template),
+		jinja:         make(map[string]*jinja.Template),
 	}
 	return tc
 }
@@ -63,6 +67,39 @@ func (tc *TemplateCache) EvaluateTemplate(templateType TemplateType, templateNam
 	return buf.String(), nil
 }
 
+// EvaluateJinjaTemplate renders a Jinja2 template, as the chat templates of the HuggingFace models.
+// templateName is either the name of a .jinja file in the templates path or the template itself
+func (tc *TemplateCache) EvaluateJinjaTemplate(templateName string, in map[string]interface{}) (string, error) {
+	tc.mu.Lock()
+	m, ok := tc.jinja[templateName]
+	if !ok {
+		var err error
+		m, err = tc.loadJinjaTemplate(templateName)
+		if err != nil {
+			tc.mu.Unlock()
+			return "", err
+		}
+		tc.jinja[templateName] = m
+	}
+	tc.mu.Unlock()
+
+	return m.Execute(in)
+}
+
+func (tc *TemplateCache) loadJinjaTemplate(templateName string) (*jinja.Template, error) {
+	templateFile := fmt.Sprintf("%s.jinja", templateName)
+	dat := templateName
+	// the templates are usually long: only short names can be files
+	if !strings.ContainsAny(templateName, "{\n") && utils.VerifyPath(templateFile, tc.templatesPath) == nil && utils.ExistsInPath(tc.templatesPath, templateFile) {
+		d, err := os.ReadFile(filepath.Join(tc.templatesPath, templateFile))
+		if err != nil {
+			return nil, err
+		}
+		dat = string(d)
+	}
+	return jinja.Parse(dat)
+}
+
 func (tc *TemplateCache) loadTemplateIfExists(templateType TemplateType, templateName string) error {
 
 	// Check if the template was already loaded
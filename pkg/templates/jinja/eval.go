@@ -0,0 +1,747 @@
+package jinja
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	errBreak    = errors.New("break outside of a loop")
+	errContinue = errors.New("continue outside of a loop")
+)
+
+const (
+	// maxCallDepth is the depth of the calls of the macros past which the rendering fails, as Jinja raises a RecursionError
+	maxCallDepth = 100
+	// maxIterations is the number of iterations of the loops of a rendering, and of the items of a range or a repetition,
+	// past which the rendering fails
+	maxIterations = 100000
+)
+
+// state is the context of a rendering: the variables of the scopes, from the outer to the inner one
+type state struct {
+	scopes []map[string]interface{}
+	// the depth of the calls of the macros, and the iterations of the loops rendered
+	depth, iterations int
+}
+
+// iterate counts an iteration of a loop of the rendering
+func (s *state) iterate() error {
+	s.iterations++
+	if s.iterations > maxIterations {
+		return fmt.Errorf("the loops of the template exceed %d iterations", maxIterations)
+	}
+	return nil
+}
+
+func (s *state) lookup(name string) interface{} {
+	for i := len(s.scopes) - 1; i >= 0; i-- {
+		if v, exists := s.scopes[i][name]; exists {
+			return v
+		}
+	}
+	return undefined{name: name}
+}
+
+func (s *state) set(name string, v interface{}) {
+	s.scopes[len(s.scopes)-1][name] = v
+}
+
+func (s *state) push() { s.scopes = append(s.scopes, map[string]interface{}{}) }
+
+func (s *state) pop() { s.scopes = s.scopes[:len(s.scopes)-1] }
+
+func (s *state) render(nodes []node, out *strings.Builder) error {
+	for _, n := range nodes {
+		if err := s.renderNode(n, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *state) renderNode(n node, out *strings.Builder) error {
+	switch t := n.(type) {
+	case textNode:
+		out.WriteString(t.text)
+	case outputNode:
+		v, err := s.eval(t.expr)
+		if err != nil {
+			return err
+		}
+		out.WriteString(toString(v))
+	case ifNode:
+		for i, cond := range t.conds {
+			v, err := s.eval(cond)
+			if err != nil {
+				return err
+			}
+			if truthy(v) {
+				return s.render(t.bodies[i], out)
+			}
+		}
+		return s.render(t.orelse, out)
+	case forNode:
+		return s.renderFor(t, out)
+	case setNode:
+		var v interface{}
+		if t.body != nil {
+			sb := &strings.Builder{}
+			if err := s.render(t.body, sb); err != nil {
+				return err
+			}
+			v = sb.String()
+		} else {
+			var err error
+			if v, err = s.eval(t.value); err != nil {
+				return err
+			}
+		}
+		return s.assign(t, v)
+	case macroNode:
+		s.set(t.name, s.macro(t))
+	case callBlockNode:
+		call, ok := t.call.(callExpr)
+		if !ok {
+			return fmt.Errorf("call blocks need a macro call")
+		}
+		body := t.body
+		caller := callable(func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+			sb := &strings.Builder{}
+			err := s.render(body, sb)
+			return sb.String(), err
+		})
+		s.push()
+		s.set("caller", caller)
+		v, err := s.eval(call)
+		s.pop()
+		if err != nil {
+			return err
+		}
+		out.WriteString(toString(v))
+	case doNode:
+		_, err := s.eval(t.expr)
+		return err
+	case breakNode:
+		return errBreak
+	case continueNode:
+		return errContinue
+	}
+	return nil
+}
+
+func (s *state) assign(t setNode, v interface{}) error {
+	if t.attr != "" {
+		ns, ok := s.lookup(t.targets[0]).(*namespace)
+		if !ok {
+			return fmt.Errorf("cannot assign attribute %s of %s: not a namespace", t.attr, t.targets[0])
+		}
+		ns.attrs[t.attr] = v
+		return nil
+	}
+	if len(t.targets) == 1 {
+		s.set(t.targets[0], v)
+		return nil
+	}
+	items, err := iterate(v)
+	if err != nil {
+		return err
+	}
+	if len(items) != len(t.targets) {
+		return fmt.Errorf("cannot unpack %d values to %d variables", len(items), len(t.targets))
+	}
+	for i, name := range t.targets {
+		s.set(name, items[i])
+	}
+	return nil
+}
+
+func (s *state) renderFor(t forNode, out *strings.Builder) error {
+	iterable, err := s.eval(t.iter)
+	if err != nil {
+		return err
+	}
+	all, err := iterate(iterable)
+	if err != nil {
+		return err
+	}
+
+	s.push()
+	defer s.pop()
+
+	bind := func(item interface{}) error {
+		if len(t.targets) == 1 {
+			s.set(t.targets[0], item)
+			return nil
+		}
+		return s.assign(setNode{targets: t.targets}, item)
+	}
+
+	// the loop variables count only the items matching the condition
+	items := all
+	if t.cond != nil {
+		items = []interface{}{}
+		for _, item := range all {
+			if err := s.iterate(); err != nil {
+				return err
+			}
+			if err := bind(item); err != nil {
+				return err
+			}
+			v, err := s.eval(t.cond)
+			if err != nil {
+				return err
+			}
+			if truthy(v) {
+				items = append(items, item)
+			}
+		}
+	}
+
+	if len(items) == 0 {
+		return s.render(t.orelse, out)
+	}
+
+	for i, item := range items {
+		if err := s.iterate(); err != nil {
+			return err
+		}
+		loop := dictFromMap(map[string]interface{}{
+			"index":     i + 1,
+			"index0":    i,
+			"revindex":  len(items) - i,
+			"revindex0": len(items) - i - 1,
+			"first":     i == 0,
+			"last":      i == len(items)-1,
+			"length":    len(items),
+			"previtem":  undefined{name: "previtem"},
+			"nextitem":  undefined{name: "nextitem"},
+			"depth":     1,
+			"depth0":    0,
+			"cycle": callable(func(args []interface{}, _ map[string]interface{}) (interface{}, error) {
+				if len(args) == 0 {
+					return nil, fmt.Errorf("loop.cycle needs at least one argument")
+				}
+				return args[i%len(args)], nil
+			}),
+		})
+		if i > 0 {
+			loop.set("previtem", items[i-1])
+		}
+		if i < len(items)-1 {
+			loop.set("nextitem", items[i+1])
+		}
+		s.set("loop", loop)
+		if err := bind(item); err != nil {
+			return err
+		}
+		err := s.render(t.body, out)
+		switch {
+		case errors.Is(err, errBreak):
+			return nil
+		case errors.Is(err, errContinue):
+		case err != nil:
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *state) macro(m macroNode) callable {
+	return func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+		if s.depth >= maxCallDepth {
+			return nil, fmt.Errorf("maximum recursion depth exceeded calling the macro %s", m.name)
+		}
+		s.depth++
+		defer func() { s.depth-- }()
+
+		// macros see the variables of the template, but their assignments are local
+		s.push()
+		defer s.pop()
+		for i, p := range m.params {
+			switch {
+			case i < len(args):
+				s.set(p, args[i])
+			case kwargs[p] != nil:
+				s.set(p, kwargs[p])
+			case m.defaults[p] != nil:
+				v, err := s.eval(m.defaults[p])
+				if err != nil {
+					return nil, err
+				}
+				s.set(p, v)
+			default:
+				s.set(p, undefined{name: p})
+			}
+		}
+		varargs := []interface{}{}
+		if len(args) > len(m.params) {
+			varargs = args[len(m.params):]
+		}
+		s.set("varargs", varargs)
+		s.set("kwargs", dictFromMap(kwargs))
+		sb := &strings.Builder{}
+		if err := s.render(m.body, sb); err != nil {
+			return nil, err
+		}
+		return sb.String(), nil
+	}
+}
+
+func (s *state) evalArgs(args []expr, kwargs []kwarg) ([]interface{}, map[string]interface{}, error) {
+	values := make([]interface{}, len(args))
+	for i, a := range args {
+		v, err := s.eval(a)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[i] = v
+	}
+	named := map[string]interface{}{}
+	for _, k := range kwargs {
+		v, err := s.eval(k.value)
+		if err != nil {
+			return nil, nil, err
+		}
+		named[k.name] = v
+	}
+	return values, named, nil
+}
+
+func (s *state) eval(e expr) (interface{}, error) {
+	switch t := e.(type) {
+	case literal:
+		return t.value, nil
+	case nameExpr:
+		return s.lookup(t.name), nil
+	case listExpr:
+		l := make([]interface{}, len(t.items))
+		for i, item := range t.items {
+			v, err := s.eval(item)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = v
+		}
+		return l, nil
+	case dictExpr:
+		m := newDict()
+		for i := range t.keys {
+			k, err := s.eval(t.keys[i])
+			if err != nil {
+				return nil, err
+			}
+			v, err := s.eval(t.values[i])
+			if err != nil {
+				return nil, err
+			}
+			m.set(toString(k), v)
+		}
+		return m, nil
+	case attrExpr:
+		obj, err := s.eval(t.obj)
+		if err != nil {
+			return nil, err
+		}
+		return getAttr(obj, t.name), nil
+	case indexExpr:
+		obj, err := s.eval(t.obj)
+		if err != nil {
+			return nil, err
+		}
+		index, err := s.eval(t.index)
+		if err != nil {
+			return nil, err
+		}
+		return getItem(obj, index), nil
+	case sliceExpr:
+		return s.evalSlice(t)
+	case callExpr:
+		args, kwargs, err := s.evalArgs(t.args, t.kwargs)
+		if err != nil {
+			return nil, err
+		}
+		// methods of the strings, lists and dicts
+		if attr, ok := t.fn.(attrExpr); ok {
+			obj, err := s.eval(attr.obj)
+			if err != nil {
+				return nil, err
+			}
+			if v, handled, err := callMethod(obj, attr.name, args, kwargs); handled {
+				return v, err
+			}
+		}
+		fn, err := s.eval(t.fn)
+		if err != nil {
+			return nil, err
+		}
+		f, ok := fn.(callable)
+		if !ok {
+			return nil, fmt.Errorf("%s is not callable", describe(t.fn))
+		}
+		return f(args, kwargs)
+	case filterExpr:
+		operand, err := s.eval(t.operand)
+		if err != nil {
+			return nil, err
+		}
+		args, kwargs, err := s.evalArgs(t.args, t.kwargs)
+		if err != nil {
+			return nil, err
+		}
+		return s.applyFilter(t.name, operand, args, kwargs)
+	case testExpr:
+		operand, err := s.eval(t.operand)
+		if err != nil {
+			return nil, err
+		}
+		args, _, err := s.evalArgs(t.args, nil)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := applyTest(t.name, operand, args)
+		if err != nil {
+			return nil, err
+		}
+		return ok != t.negate, nil
+	case unaryExpr:
+		v, err := s.eval(t.operand)
+		if err != nil {
+			return nil, err
+		}
+		switch t.op {
+		case "not":
+			return !truthy(v), nil
+		case "-":
+			switch n := v.(type) {
+			case int:
+				return -n, nil
+			case float64:
+				return -n, nil
+			}
+			return nil, fmt.Errorf("bad operand type for unary -: %s", typeName(v))
+		}
+		return v, nil
+	case binaryExpr:
+		return s.evalBinary(t)
+	case condExpr:
+		cond, err := s.eval(t.cond)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(cond) {
+			return s.eval(t.yes)
+		}
+		if t.no == nil {
+			return undefined{}, nil
+		}
+		return s.eval(t.no)
+	}
+	return nil, fmt.Errorf("unknown expression %T", e)
+}
+
+func describe(e expr) string {
+	switch t := e.(type) {
+	case nameExpr:
+		return t.name
+	case attrExpr:
+		return describe(t.obj) + "." + t.name
+	}
+	return "expression"
+}
+
+func getAttr(obj interface{}, name string) interface{} {
+	switch t := obj.(type) {
+	case *dict:
+		if v, exists := t.get(name); exists {
+			return v
+		}
+	case *namespace:
+		if v, exists := t.attrs[name]; exists {
+			return v
+		}
+	}
+	return undefined{name: name}
+}
+
+func getItem(obj, index interface{}) interface{} {
+	switch t := obj.(type) {
+	case *dict:
+		if v, exists := t.get(toString(index)); exists {
+			return v
+		}
+	case *namespace:
+		if v, exists := t.attrs[toString(index)]; exists {
+			return v
+		}
+	case []interface{}:
+		if i, ok := index.(int); ok {
+			if i < 0 {
+				i += len(t)
+			}
+			if i >= 0 && i < len(t) {
+				return t[i]
+			}
+		}
+	case string:
+		if i, ok := index.(int); ok {
+			r := []rune(t)
+			if i < 0 {
+				i += len(r)
+			}
+			if i >= 0 && i < len(r) {
+				return string(r[i])
+			}
+		}
+	}
+	return undefined{name: toString(index)}
+}
+
+func (s *state) evalSlice(t sliceExpr) (interface{}, error) {
+	obj, err := s.eval(t.obj)
+	if err != nil {
+		return nil, err
+	}
+	bound := func(e expr) (*int, error) {
+		if e == nil {
+			return nil, nil
+		}
+		v, err := s.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return nil, nil
+		}
+		i, ok := v.(int)
+		if !ok {
+			return nil, fmt.Errorf("slice indices must be integers")
+		}
+		return &i, nil
+	}
+	start, err := bound(t.start)
+	if err != nil {
+		return nil, err
+	}
+	stop, err := bound(t.stop)
+	if err != nil {
+		return nil, err
+	}
+	step, err := bound(t.step)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []interface{}
+	str, isString := obj.(string)
+	if isString {
+		for _, r := range str {
+			items = append(items, string(r))
+		}
+	} else if items, err = iterate(obj); err != nil {
+		return nil, err
+	}
+
+	sliced := sliceItems(items, start, stop, step)
+	if isString {
+		sb := strings.Builder{}
+		for _, r := range sliced {
+			sb.WriteString(r.(string))
+		}
+		return sb.String(), nil
+	}
+	return sliced, nil
+}
+
+// sliceItems implements the semantics of the Python slices
+func sliceItems(items []interface{}, start, stop, step *int) []interface{} {
+	n := len(items)
+	st := 1
+	if step != nil && *step != 0 {
+		st = *step
+	}
+	clamp := func(p *int, def int, lo, hi int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += n
+		}
+		return max(lo, min(i, hi))
+	}
+	result := []interface{}{}
+	if st > 0 {
+		for i := clamp(start, 0, 0, n); i < clamp(stop, n, 0, n); i += st {
+			result = append(result, items[i])
+		}
+	} else {
+		for i := clamp(start, n-1, -1, n-1); i > clamp(stop, -1, -1, n-1); i += st {
+			result = append(result, items[i])
+		}
+	}
+	return result
+}
+
+func (s *state) evalBinary(t binaryExpr) (interface{}, error) {
+	left, err := s.eval(t.left)
+	if err != nil {
+		return nil, err
+	}
+	switch t.op {
+	case "and":
+		if !truthy(left) {
+			return left, nil
+		}
+		return s.eval(t.right)
+	case "or":
+		if truthy(left) {
+			return left, nil
+		}
+		return s.eval(t.right)
+	}
+
+	right, err := s.eval(t.right)
+	if err != nil {
+		return nil, err
+	}
+	switch t.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", ">", "<=", ">=":
+		c, err := compare(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch t.op {
+		case "<":
+			return c < 0, nil
+		case ">":
+			return c > 0, nil
+		case "<=":
+			return c <= 0, nil
+		}
+		return c >= 0, nil
+	case "in", "not in":
+		in, err := contains(right, left)
+		if err != nil {
+			return nil, err
+		}
+		return in == (t.op == "in"), nil
+	case "~":
+		return toString(left) + toString(right), nil
+	case "+":
+		switch l := left.(type) {
+		case string:
+			if r, ok := right.(string); ok {
+				return l + r, nil
+			}
+		case []interface{}:
+			if r, ok := right.([]interface{}); ok {
+				return append(append([]interface{}{}, l...), r...), nil
+			}
+		}
+	case "*":
+		// repetition of strings and lists
+		if n, ok := right.(int); ok {
+			switch l := left.(type) {
+			case string:
+				if n > maxIterations {
+					return nil, fmt.Errorf("the repetition of a string exceeds %d times", maxIterations)
+				}
+				return strings.Repeat(l, max(n, 0)), nil
+			case []interface{}:
+				if n > maxIterations {
+					return nil, fmt.Errorf("the repetition of a list exceeds %d times", maxIterations)
+				}
+				r := []interface{}{}
+				for i := 0; i < n; i++ {
+					r = append(r, l...)
+				}
+				return r, nil
+			}
+		}
+	}
+
+	if !isNumber(left) || !isNumber(right) {
+		return nil, fmt.Errorf("unsupported operand types for %s: %s and %s", t.op, typeName(left), typeName(right))
+	}
+	li, lInt := left.(int)
+	ri, rInt := right.(int)
+	lf, rf := toFloat(left), toFloat(right)
+	switch t.op {
+	case "+":
+		if lInt && rInt {
+			return li + ri, nil
+		}
+		return lf + rf, nil
+	case "-":
+		if lInt && rInt {
+			return li - ri, nil
+		}
+		return lf - rf, nil
+	case "*":
+		if lInt && rInt {
+			return li * ri, nil
+		}
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return lf / rf, nil
+	case "//":
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		if lInt && rInt {
+			return int(math.Floor(lf / rf)), nil
+		}
+		return math.Floor(lf / rf), nil
+	case "%":
+		if rf == 0 {
+			return nil, fmt.Errorf("modulo by zero")
+		}
+		if lInt && rInt {
+			return ((li % ri) + ri) % ri, nil
+		}
+		return lf - rf*math.Floor(lf/rf), nil
+	case "**":
+		if lInt && rInt && ri >= 0 {
+			return int(math.Pow(lf, rf)), nil
+		}
+		return math.Pow(lf, rf), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", t.op)
+}
+
+func contains(container, item interface{}) (bool, error) {
+	switch c := container.(type) {
+	case string:
+		s, ok := item.(string)
+		if !ok {
+			return false, fmt.Errorf("'in <string>' requires a string, not %s", typeName(item))
+		}
+		return strings.Contains(c, s), nil
+	case []interface{}:
+		for _, e := range c {
+			if equal(e, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case *dict:
+		_, exists := c.get(toString(item))
+		return exists, nil
+	case *namespace:
+		_, exists := c.attrs[toString(item)]
+		return exists, nil
+	case undefined, nil:
+		return false, nil
+	}
+	return false, fmt.Errorf("%s is not a container", typeName(container))
+}
@@ -0,0 +1,813 @@
+package jinja
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RaiseError is returned when a template calls raise_exception, usually to refuse a conversation it does not support
+type RaiseError struct {
+	Message string
+}
+
+func (e *RaiseError) Error() string {
+	return e.Message
+}
+
+func arg(args []interface{}, kwargs map[string]interface{}, i int, name string, def interface{}) interface{} {
+	if i < len(args) {
+		return args[i]
+	}
+	if v, exists := kwargs[name]; exists {
+		return v
+	}
+	return def
+}
+
+// globals returns the functions available to all the templates
+func globals() map[string]interface{} {
+	return map[string]interface{}{
+		"range": callable(func(args []interface{}, _ map[string]interface{}) (interface{}, error) {
+			start, stop, step := 0, 0, 1
+			switch len(args) {
+			case 1:
+				stop = toInt(args[0])
+			case 2:
+				start, stop = toInt(args[0]), toInt(args[1])
+			case 3:
+				start, stop, step = toInt(args[0]), toInt(args[1]), toInt(args[2])
+			default:
+				return nil, fmt.Errorf("range expects between 1 and 3 arguments")
+			}
+			if step == 0 {
+				return nil, fmt.Errorf("range step cannot be zero")
+			}
+			if n := (stop - start) / step; n > maxIterations {
+				return nil, fmt.Errorf("range exceeds %d items", maxIterations)
+			}
+			l := []interface{}{}
+			for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+				l = append(l, i)
+			}
+			return l, nil
+		}),
+		"raise_exception": callable(func(args []interface{}, _ map[string]interface{}) (interface{}, error) {
+			msg := "raise_exception called"
+			if len(args) > 0 {
+				msg = toString(args[0])
+			}
+			return nil, &RaiseError{Message: msg}
+		}),
+		"namespace": callable(func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+			ns := &namespace{attrs: map[string]interface{}{}}
+			if len(args) > 0 {
+				if d, ok := args[0].(*dict); ok {
+					for k, v := range d.values {
+						ns.attrs[k] = v
+					}
+				}
+			}
+			for k, v := range kwargs {
+				ns.attrs[k] = v
+			}
+			return ns, nil
+		}),
+		"dict": callable(func(args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+			m := newDict()
+			if len(args) > 0 {
+				if err := m.update(args[0]); err != nil {
+					return nil, err
+				}
+			}
+			for _, k := range sortedKeys(kwargs) {
+				m.set(k, kwargs[k])
+			}
+			return m, nil
+		}),
+		"strftime_now": callable(func(args []interface{}, _ map[string]interface{}) (interface{}, error) {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("strftime_now expects a format")
+			}
+			return strftime(time.Now(), toString(args[0])), nil
+		}),
+	}
+}
+
+// strftime formats a time with the directives of the C strftime used by the templates
+func strftime(t time.Time, format string) string {
+	directives := map[byte]string{
+		'Y': "2006", 'y': "06", 'm': "01", 'B': "January", 'b': "Jan", 'd': "02", 'A': "Monday", 'a': "Mon",
+		'H': "15", 'I': "03", 'M': "04", 'S': "05", 'p': "PM", 'Z': "MST", 'z': "-0700",
+	}
+	sb := strings.Builder{}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			sb.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch c := format[i]; c {
+		case '%':
+			sb.WriteByte('%')
+		case 'j':
+			fmt.Fprintf(&sb, "%03d", t.YearDay())
+		case '-':
+			// %-d: day without padding, as glibc
+			if i+1 < len(format) && format[i+1] == 'd' {
+				i++
+				fmt.Fprintf(&sb, "%d", t.Day())
+			}
+		default:
+			if layout, exists := directives[c]; exists {
+				sb.WriteString(t.Format(layout))
+			} else {
+				sb.WriteByte('%')
+				sb.WriteByte(c)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func capitalize(s string) string {
+	r := []rune(strings.ToLower(s))
+	if len(r) > 0 {
+		r[0] = unicode.ToUpper(r[0])
+	}
+	return string(r)
+}
+
+func title(s string) string {
+	r := []rune(s)
+	upper := true
+	for i, c := range r {
+		if unicode.IsLetter(c) {
+			if upper {
+				r[i] = unicode.ToUpper(c)
+			} else {
+				r[i] = unicode.ToLower(c)
+			}
+			upper = false
+		} else {
+			upper = true
+		}
+	}
+	return string(r)
+}
+
+// attribute returns the value of a dotted attribute of an item, as the attribute argument of map and selectattr
+func attribute(item interface{}, name string) interface{} {
+	for _, part := range strings.Split(name, ".") {
+		item = getAttr(item, part)
+	}
+	return item
+}
+
+func (s *state) applyFilter(name string, v interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	switch name {
+	case "safe", "e", "escape", "forceescape":
+		// the chat templates are not HTML: there is nothing to escape
+		return v, nil
+	case "trim":
+		chars := arg(args, kwargs, 0, "chars", nil)
+		if chars != nil {
+			return strings.Trim(toString(v), toString(chars)), nil
+		}
+		return strings.TrimSpace(toString(v)), nil
+	case "length", "count":
+		return length(v)
+	case "tojson":
+		indent := ""
+		switch i := arg(args, kwargs, 0, "indent", nil).(type) {
+		case int:
+			if i < 0 {
+				return nil, fmt.Errorf("tojson indent cannot be negative")
+			}
+			indent = strings.Repeat(" ", i)
+		case string:
+			indent = i
+		}
+		return toJSON(v, indent, 0), nil
+	case "upper":
+		return strings.ToUpper(toString(v)), nil
+	case "lower":
+		return strings.ToLower(toString(v)), nil
+	case "title":
+		return title(toString(v)), nil
+	case "capitalize":
+		return capitalize(toString(v)), nil
+	case "string":
+		return toString(v), nil
+	case "default", "d":
+		def := arg(args, kwargs, 0, "default_value", "")
+		boolean := truthy(arg(args, kwargs, 1, "boolean", false))
+		if _, isUndefined := v.(undefined); isUndefined || (boolean && !truthy(v)) {
+			return def, nil
+		}
+		return v, nil
+	case "first", "last":
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			return undefined{name: name}, nil
+		}
+		if name == "first" {
+			return items[0], nil
+		}
+		return items[len(items)-1], nil
+	case "join":
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		attr := arg(args, kwargs, 1, "attribute", nil)
+		parts := make([]string, len(items))
+		for i, item := range items {
+			if attr != nil {
+				item = attribute(item, toString(attr))
+			}
+			parts[i] = toString(item)
+		}
+		return strings.Join(parts, toString(arg(args, kwargs, 0, "d", ""))), nil
+	case "list":
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		return append([]interface{}{}, items...), nil
+	case "int":
+		return toInt(v), nil
+	case "float":
+		if f, ok := v.(string); ok {
+			var r float64
+			if _, err := fmt.Sscan(strings.TrimSpace(f), &r); err == nil {
+				return r, nil
+			}
+			return 0.0, nil
+		}
+		return toFloat(v), nil
+	case "abs":
+		switch n := v.(type) {
+		case int:
+			if n < 0 {
+				return -n, nil
+			}
+			return n, nil
+		case float64:
+			return math.Abs(n), nil
+		}
+		return nil, fmt.Errorf("bad operand type for abs: %s", typeName(v))
+	case "round":
+		precision := toInt(arg(args, kwargs, 0, "precision", 0))
+		method := toString(arg(args, kwargs, 1, "method", "common"))
+		p := math.Pow(10, float64(precision))
+		f := toFloat(v) * p
+		switch method {
+		case "ceil":
+			f = math.Ceil(f)
+		case "floor":
+			f = math.Floor(f)
+		default:
+			f = math.Round(f)
+		}
+		return f / p, nil
+	case "replace":
+		old, new := toString(arg(args, kwargs, 0, "old", "")), toString(arg(args, kwargs, 1, "new", ""))
+		n := -1
+		if c, ok := arg(args, kwargs, 2, "count", nil).(int); ok {
+			n = c
+		}
+		return strings.Replace(toString(v), old, new, n), nil
+	case "items":
+		m, ok := v.(*dict)
+		if !ok {
+			return nil, fmt.Errorf("items expects a mapping, not %s", typeName(v))
+		}
+		return m.items(), nil
+	case "indent":
+		width := "    "
+		switch w := arg(args, kwargs, 0, "width", 4).(type) {
+		case int:
+			if w < 0 {
+				return nil, fmt.Errorf("indent width cannot be negative")
+			}
+			width = strings.Repeat(" ", w)
+		case string:
+			width = w
+		}
+		first := truthy(arg(args, kwargs, 1, "first", false))
+		blank := truthy(arg(args, kwargs, 2, "blank", false))
+		lines := strings.Split(toString(v), "\n")
+		for i, line := range lines {
+			if (i == 0 && !first) || (line == "" && !blank) {
+				continue
+			}
+			lines[i] = width + line
+		}
+		return strings.Join(lines, "\n"), nil
+	case "map":
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]interface{}, len(items))
+		for i, item := range items {
+			if attr, exists := kwargs["attribute"]; exists {
+				result[i] = attribute(item, toString(attr))
+				if _, isUndefined := result[i].(undefined); isUndefined && kwargs["default"] != nil {
+					result[i] = kwargs["default"]
+				}
+				continue
+			}
+			if len(args) == 0 {
+				return nil, fmt.Errorf("map expects a filter or an attribute")
+			}
+			if result[i], err = s.applyFilter(toString(args[0]), item, args[1:], nil); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	case "select", "reject", "selectattr", "rejectattr":
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		byAttr := strings.HasSuffix(name, "attr")
+		keep := strings.HasPrefix(name, "select")
+		result := []interface{}{}
+		for _, item := range items {
+			testArgs := args
+			value := item
+			if byAttr {
+				if len(args) == 0 {
+					return nil, fmt.Errorf("%s expects an attribute", name)
+				}
+				value = attribute(item, toString(args[0]))
+				testArgs = args[1:]
+			}
+			var ok bool
+			if len(testArgs) == 0 {
+				ok = truthy(value)
+			} else if ok, err = applyTest(toString(testArgs[0]), value, testArgs[1:]); err != nil {
+				return nil, err
+			}
+			if ok == keep {
+				result = append(result, item)
+			}
+		}
+		return result, nil
+	case "reverse":
+		if str, ok := v.(string); ok {
+			r := []rune(str)
+			for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+				r[i], r[j] = r[j], r[i]
+			}
+			return string(r), nil
+		}
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]interface{}, len(items))
+		for i, item := range items {
+			result[len(items)-1-i] = item
+		}
+		return result, nil
+	case "sort":
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		result := append([]interface{}{}, items...)
+		reverse := truthy(arg(args, kwargs, 0, "reverse", false))
+		attr := arg(args, kwargs, 2, "attribute", nil)
+		key := func(item interface{}) interface{} {
+			if attr != nil {
+				item = attribute(item, toString(attr))
+			}
+			if str, ok := item.(string); ok && !truthy(arg(args, kwargs, 1, "case_sensitive", false)) {
+				return strings.ToLower(str)
+			}
+			return item
+		}
+		var sortErr error
+		sort.SliceStable(result, func(i, j int) bool {
+			c, err := compare(key(result[i]), key(result[j]))
+			if err != nil {
+				sortErr = err
+			}
+			if reverse {
+				return c > 0
+			}
+			return c < 0
+		})
+		return result, sortErr
+	case "unique":
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		result := []interface{}{}
+		for _, item := range items {
+			found := false
+			for _, r := range result {
+				if equal(r, item) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				result = append(result, item)
+			}
+		}
+		return result, nil
+	case "sum":
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		var total interface{} = arg(args, kwargs, 1, "start", 0)
+		attr := arg(args, kwargs, 0, "attribute", nil)
+		for _, item := range items {
+			if attr != nil {
+				item = attribute(item, toString(attr))
+			}
+			if total, err = s.evalBinary(binaryExpr{op: "+", left: literal{value: total}, right: literal{value: item}}); err != nil {
+				return nil, err
+			}
+		}
+		return total, nil
+	case "min", "max":
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			return undefined{name: name}, nil
+		}
+		best := items[0]
+		for _, item := range items[1:] {
+			c, err := compare(item, best)
+			if err != nil {
+				return nil, err
+			}
+			if (name == "min" && c < 0) || (name == "max" && c > 0) {
+				best = item
+			}
+		}
+		return best, nil
+	case "wordcount":
+		return len(strings.Fields(toString(v))), nil
+	case "format":
+		return printf(toString(v), args, kwargs)
+	}
+	return nil, fmt.Errorf("unknown filter %s", name)
+}
+
+// printf formats the arguments with the %-directives of Python, as the format filter:
+// the positional arguments, or the keyword arguments with the %(name)s directives
+func printf(format string, args []interface{}, kwargs map[string]interface{}) (string, error) {
+	sb := strings.Builder{}
+	next := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			sb.WriteByte(format[i])
+			continue
+		}
+		j := i + 1
+		var value interface{}
+		named := false
+		if j < len(format) && format[j] == '(' {
+			end := strings.IndexByte(format[j:], ')')
+			if end < 0 {
+				return "", fmt.Errorf("incomplete format key in %q", format)
+			}
+			key := format[j+1 : j+end]
+			v, exists := kwargs[key]
+			if !exists {
+				return "", fmt.Errorf("format key %q is not defined", key)
+			}
+			value, named = v, true
+			j += end + 1
+		}
+		spec := "%"
+		for j < len(format) && strings.IndexByte("-+ 0#.0123456789", format[j]) >= 0 {
+			spec += string(format[j])
+			j++
+		}
+		if j == len(format) {
+			return "", fmt.Errorf("incomplete format in %q", format)
+		}
+		verb := format[j]
+		i = j
+		if verb == '%' {
+			sb.WriteByte('%')
+			continue
+		}
+		if !named {
+			if next >= len(args) {
+				return "", fmt.Errorf("not enough arguments for the format %q", format)
+			}
+			value = args[next]
+			next++
+		}
+		switch verb {
+		case 's':
+			fmt.Fprintf(&sb, spec+"s", toString(value))
+		case 'r':
+			fmt.Fprintf(&sb, spec+"s", repr(value))
+		case 'd', 'i', 'u':
+			fmt.Fprintf(&sb, spec+"d", toInt(value))
+		case 'x', 'X', 'o':
+			fmt.Fprintf(&sb, spec+string(verb), toInt(value))
+		case 'c':
+			if n, ok := value.(int); ok {
+				sb.WriteRune(rune(n))
+			} else {
+				sb.WriteString(toString(value))
+			}
+		case 'f', 'F', 'e', 'E', 'g', 'G':
+			if !strings.Contains(spec, ".") && verb != 'g' && verb != 'G' {
+				spec += ".6"
+			}
+			fmt.Fprintf(&sb, spec+string(verb), toFloat(value))
+		default:
+			return "", fmt.Errorf("unsupported format character %q in %q", verb, format)
+		}
+	}
+	if next < len(args) {
+		return "", fmt.Errorf("not all arguments converted during the string formatting of %q", format)
+	}
+	return sb.String(), nil
+}
+
+func applyTest(name string, v interface{}, args []interface{}) (bool, error) {
+	_, isUndefined := v.(undefined)
+	switch name {
+	case "defined":
+		return !isUndefined, nil
+	case "undefined":
+		return isUndefined, nil
+	case "none":
+		return v == nil, nil
+	case "string":
+		_, ok := v.(string)
+		return ok, nil
+	case "number":
+		switch v.(type) {
+		case int, float64:
+			return true, nil
+		}
+		return false, nil
+	case "integer":
+		_, ok := v.(int)
+		return ok, nil
+	case "float":
+		_, ok := v.(float64)
+		return ok, nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok, nil
+	case "true":
+		return v == true, nil
+	case "false":
+		return v == false, nil
+	case "mapping":
+		switch v.(type) {
+		case *dict, *namespace:
+			return true, nil
+		}
+		return false, nil
+	case "sequence", "iterable":
+		switch v.(type) {
+		case string, []interface{}, *dict:
+			return true, nil
+		}
+		return false, nil
+	case "callable":
+		_, ok := v.(callable)
+		return ok, nil
+	case "odd", "even", "divisibleby":
+		n, ok := v.(int)
+		if !ok {
+			return false, fmt.Errorf("%s expects an integer, not %s", name, typeName(v))
+		}
+		switch name {
+		case "odd":
+			return n%2 != 0, nil
+		case "even":
+			return n%2 == 0, nil
+		}
+		if len(args) == 0 || toInt(args[0]) == 0 {
+			return false, fmt.Errorf("divisibleby expects a divisor")
+		}
+		return n%toInt(args[0]) == 0, nil
+	case "lower", "upper":
+		str, ok := v.(string)
+		if !ok {
+			return false, nil
+		}
+		if name == "lower" {
+			return str == strings.ToLower(str), nil
+		}
+		return str == strings.ToUpper(str), nil
+	}
+
+	if len(args) == 0 {
+		return false, fmt.Errorf("unknown test %s", name)
+	}
+	other := args[0]
+	switch name {
+	case "eq", "equalto", "==":
+		return equal(v, other), nil
+	case "ne", "!=":
+		return !equal(v, other), nil
+	case "in":
+		return contains(other, v)
+	case "sameas":
+		return v == other, nil
+	case "lt", "lessthan", "<", "gt", "greaterthan", ">", "le", "<=", "ge", ">=":
+		c, err := compare(v, other)
+		if err != nil {
+			return false, err
+		}
+		switch name {
+		case "lt", "lessthan", "<":
+			return c < 0, nil
+		case "gt", "greaterthan", ">":
+			return c > 0, nil
+		case "le", "<=":
+			return c <= 0, nil
+		}
+		return c >= 0, nil
+	}
+	return false, fmt.Errorf("unknown test %s", name)
+}
+
+// callMethod calls the methods of the strings, lists and dicts, reporting whether the object has the method
+func callMethod(obj interface{}, name string, args []interface{}, kwargs map[string]interface{}) (interface{}, bool, error) {
+	switch t := obj.(type) {
+	case string:
+		return stringMethod(t, name, args, kwargs)
+	case *dict:
+		switch name {
+		case "items":
+			return t.items(), true, nil
+		case "keys":
+			keys := []interface{}{}
+			for _, k := range t.keys {
+				keys = append(keys, k)
+			}
+			return keys, true, nil
+		case "values":
+			values := []interface{}{}
+			for _, k := range t.keys {
+				values = append(values, t.values[k])
+			}
+			return values, true, nil
+		case "get":
+			if len(args) == 0 {
+				return nil, true, fmt.Errorf("get expects a key")
+			}
+			if v, exists := t.get(toString(args[0])); exists {
+				return v, true, nil
+			}
+			return arg(args, kwargs, 1, "default", nil), true, nil
+		case "update":
+			// the templates call update in expressions: it returns None, as in Python
+			if len(args) > 0 {
+				if err := t.update(args[0]); err != nil {
+					return nil, true, err
+				}
+			}
+			for _, k := range sortedKeys(kwargs) {
+				t.set(k, kwargs[k])
+			}
+			return nil, true, nil
+		}
+	case []interface{}:
+		switch name {
+		case "index":
+			for i, item := range t {
+				if len(args) > 0 && equal(item, args[0]) {
+					return i, true, nil
+				}
+			}
+			return nil, true, fmt.Errorf("item is not in the list")
+		case "count":
+			n := 0
+			for _, item := range t {
+				if len(args) > 0 && equal(item, args[0]) {
+					n++
+				}
+			}
+			return n, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// splitFields splits a string around the runs of whitespaces in at most n parts, as Python's str.split()
+func splitFields(s string, n int) []string {
+	parts := []string{}
+	rest := strings.TrimLeftFunc(s, unicode.IsSpace)
+	for rest != "" {
+		if n > 0 && len(parts) == n-1 {
+			return append(parts, rest)
+		}
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			return append(parts, rest)
+		}
+		parts = append(parts, rest[:end])
+		rest = strings.TrimLeftFunc(rest[end:], unicode.IsSpace)
+	}
+	return parts
+}
+
+func stringMethod(s, name string, args []interface{}, kwargs map[string]interface{}) (interface{}, bool, error) {
+	chars := func() string {
+		if c := arg(args, kwargs, 0, "chars", nil); c != nil {
+			return toString(c)
+		}
+		return " \t\n\r\v\f"
+	}
+	switch name {
+	case "strip":
+		return strings.Trim(s, chars()), true, nil
+	case "lstrip":
+		return strings.TrimLeft(s, chars()), true, nil
+	case "rstrip":
+		return strings.TrimRight(s, chars()), true, nil
+	case "upper":
+		return strings.ToUpper(s), true, nil
+	case "lower":
+		return strings.ToLower(s), true, nil
+	case "title":
+		return title(s), true, nil
+	case "capitalize":
+		return capitalize(s), true, nil
+	case "startswith", "endswith":
+		prefixes := []interface{}{arg(args, kwargs, 0, "prefix", "")}
+		if l, ok := prefixes[0].([]interface{}); ok {
+			prefixes = l
+		}
+		for _, p := range prefixes {
+			if (name == "startswith" && strings.HasPrefix(s, toString(p))) || (name == "endswith" && strings.HasSuffix(s, toString(p))) {
+				return true, true, nil
+			}
+		}
+		return false, true, nil
+	case "split":
+		sep := arg(args, kwargs, 0, "sep", nil)
+		n := -1
+		if m, ok := arg(args, kwargs, 1, "maxsplit", nil).(int); ok && m >= 0 {
+			n = m + 1
+		}
+		var parts []string
+		if sep == nil {
+			parts = splitFields(s, n)
+		} else {
+			parts = strings.SplitN(s, toString(sep), n)
+		}
+		result := make([]interface{}, len(parts))
+		for i, p := range parts {
+			result[i] = p
+		}
+		return result, true, nil
+	case "replace":
+		n := -1
+		if c, ok := arg(args, kwargs, 2, "count", nil).(int); ok {
+			n = c
+		}
+		return strings.Replace(s, toString(arg(args, kwargs, 0, "old", "")), toString(arg(args, kwargs, 1, "new", "")), n), true, nil
+	case "find":
+		return strings.Index(s, toString(arg(args, kwargs, 0, "sub", ""))), true, nil
+	case "count":
+		return strings.Count(s, toString(arg(args, kwargs, 0, "sub", ""))), true, nil
+	case "join":
+		items, err := iterate(arg(args, kwargs, 0, "iterable", nil))
+		if err != nil {
+			return nil, true, err
+		}
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = toString(item)
+		}
+		return strings.Join(parts, s), true, nil
+	case "format":
+		// the positional {} placeholders of str.format
+		result := s
+		for _, a := range args {
+			result = strings.Replace(result, "{}", toString(a), 1)
+		}
+		return result, true, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,38 @@
+// Package jinja renders the subset of Jinja2 used by the chat templates of the models,
+// as the tokenizer.chat_template of the GGUF files and the tokenizer_config.json of HuggingFace.
+package jinja
+
+import "strings"
+
+// Template is a parsed template
+type Template struct {
+	nodes []node
+}
+
+// Parse parses the source of a template
+func Parse(src string) (*Template, error) {
+	chunks, err := splitTemplate(src)
+	if err != nil {
+		return nil, err
+	}
+	tp := &templateParser{chunks: chunks}
+	nodes, _, _, err := tp.parseBody()
+	if err != nil {
+		return nil, err
+	}
+	return &Template{nodes: nodes}, nil
+}
+
+// Execute renders the template with the given variables
+func (t *Template) Execute(vars map[string]interface{}) (string, error) {
+	scope := map[string]interface{}{}
+	for k, v := range vars {
+		scope[k] = normalize(v)
+	}
+	s := &state{scopes: []map[string]interface{}{globals(), scope}}
+	out := &strings.Builder{}
+	if err := s.render(t.nodes, out); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,13 @@
+package jinja_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestJinja(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Jinja test suite")
+}
@@ -0,0 +1,249 @@
+package jinja_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	. "github.com/mudler/LocalAI/pkg/templates/jinja"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const llama3Template = `{% set loop_messages = messages %}{% for message in loop_messages %}{% set content = '<|start_header_id|>' + message['role'] + '<|end_header_id|>
+
+'+ message['content'] | trim + '<|eot_id|>' %}{% if loop.index0 == 0 %}{% set content = bos_token + content %}{% endif %}{{ content }}{% endfor %}{% if add_generation_prompt %}{{ '<|start_header_id|>assistant<|end_header_id|>
+
+' }}{% endif %}`
+
+const mistralTemplate = `{{ bos_token }}{% for message in messages %}{% if (message['role'] == 'user') != (loop.index0 % 2 == 0) %}{{ raise_exception('Conversation roles must alternate user/assistant/user/assistant/...') }}{% endif %}{% if message['role'] == 'user' %}{{ '[INST] ' + message['content'] + ' [/INST]' }}{% elif message['role'] == 'assistant' %}{{ message['content'] + eos_token}}{% else %}{{ raise_exception('Only user and assistant roles are supported!') }}{% endif %}{% endfor %}`
+
+const qwenTemplate = `{%- if tools %}
+    {{- '<|im_start|>system\n' }}
+    {%- if messages[0]['role'] == 'system' %}
+        {{- messages[0]['content'] }}
+    {%- else %}
+        {{- 'You are a helpful assistant.' }}
+    {%- endif %}
+    {{- "\n\n# Tools\n\n<tools>" }}
+    {%- for tool in tools %}
+        {{- "\n" }}
+        {{- tool | tojson }}
+    {%- endfor %}
+    {{- "\n</tools><|im_end|>\n" }}
+{%- else %}
+    {%- if messages[0]['role'] == 'system' %}
+        {{- '<|im_start|>system\n' + messages[0]['content'] + '<|im_end|>\n' }}
+    {%- else %}
+        {{- '<|im_start|>system\nYou are a helpful assistant.<|im_end|>\n' }}
+    {%- endif %}
+{%- endif %}
+{%- for message in messages %}
+    {%- if (message.role == "user") or (message.role == "system" and not loop.first) or (message.role == "assistant" and not message.tool_calls) %}
+        {{- '<|im_start|>' + message.role + '\n' + message.content + '<|im_end|>' + '\n' }}
+    {%- elif message.role == "assistant" %}
+        {{- '<|im_start|>' + message.role }}
+        {%- if message.content %}
+            {{- '\n' + message.content }}
+        {%- endif %}
+        {%- for tool_call in message.tool_calls %}
+            {%- if tool_call.function is defined %}
+                {%- set tool_call = tool_call.function %}
+            {%- endif %}
+            {{- '\n<tool_call>\n{"name": "' }}
+            {{- tool_call.name }}
+            {{- '", "arguments": ' }}
+            {{- tool_call.arguments | tojson }}
+            {{- '}\n</tool_call>' }}
+        {%- endfor %}
+        {{- '<|im_end|>\n' }}
+    {%- elif message.role == "tool" %}
+        {%- if (loop.index0 == 0) or (messages[loop.index0 - 1].role != "tool") %}
+            {{- '<|im_start|>user' }}
+        {%- endif %}
+        {{- '\n<tool_response>\n' }}
+        {{- message.content }}
+        {{- '\n</tool_response>' }}
+        {%- if loop.last or (messages[loop.index0 + 1].role != "tool") %}
+            {{- '<|im_end|>\n' }}
+        {%- endif %}
+    {%- endif %}
+{%- endfor %}
+{%- if add_generation_prompt %}
+    {{- '<|im_start|>assistant\n' }}
+{%- endif %}
+`
+
+var _ = Describe("Jinja templates", func() {
+	render := func(src string, vars map[string]interface{}) string {
+		t, err := Parse(src)
+		Expect(err).ToNot(HaveOccurred())
+		out, err := t.Execute(vars)
+		Expect(err).ToNot(HaveOccurred())
+		return out
+	}
+
+	Context("chat templates", func() {
+		It("renders the Llama 3 template", func() {
+			Expect(render(llama3Template, map[string]interface{}{
+				"bos_token":             "<|begin_of_text|>",
+				"add_generation_prompt": true,
+				"messages": []map[string]interface{}{
+					{"role": "system", "content": "You are a bot."},
+					{"role": "user", "content": " Hi! "},
+				},
+			})).To(Equal("<|begin_of_text|><|start_header_id|>system<|end_header_id|>\n\nYou are a bot.<|eot_id|>" +
+				"<|start_header_id|>user<|end_header_id|>\n\nHi!<|eot_id|><|start_header_id|>assistant<|end_header_id|>\n\n"))
+		})
+
+		It("renders the tools and the tool calls of the Qwen template", func() {
+			out := render(qwenTemplate, map[string]interface{}{
+				"add_generation_prompt": true,
+				"tools": []interface{}{
+					map[string]interface{}{"type": "function", "function": map[string]interface{}{
+						"name": "weather", "description": "Get the weather",
+						"parameters": map[string]interface{}{"type": "object", "properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}}},
+					}},
+				},
+				"messages": []map[string]interface{}{
+					{"role": "user", "content": "Weather in Rome?"},
+					{"role": "assistant", "content": "", "tool_calls": []interface{}{
+						map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "weather", "arguments": map[string]interface{}{"city": "Rome"}}},
+					}},
+					{"role": "tool", "content": "sunny"},
+				},
+			})
+			Expect(out).To(Equal(`<|im_start|>system
+You are a helpful assistant.
+
+# Tools
+
+<tools>
+{"function": {"description": "Get the weather", "name": "weather", "parameters": {"properties": {"city": {"type": "string"}}, "type": "object"}}, "type": "function"}
+</tools><|im_end|>
+<|im_start|>user
+Weather in Rome?<|im_end|>
+<|im_start|>assistant
+<tool_call>
+{"name": "weather", "arguments": {"city": "Rome"}}
+</tool_call><|im_end|>
+<|im_start|>user
+<tool_response>
+sunny
+</tool_response><|im_end|>
+<|im_start|>assistant
+`))
+		})
+
+		It("returns the exceptions raised by the templates", func() {
+			t, err := Parse(mistralTemplate)
+			Expect(err).ToNot(HaveOccurred())
+			vars := map[string]interface{}{
+				"bos_token": "<s>", "eos_token": "</s>",
+				"messages": []map[string]interface{}{{"role": "user", "content": "Hi"}, {"role": "assistant", "content": "Hello"}},
+			}
+			Expect(t.Execute(vars)).To(Equal("<s>[INST] Hi [/INST]Hello</s>"))
+
+			vars["messages"] = []map[string]interface{}{{"role": "system", "content": "Hi"}}
+			_, err = t.Execute(vars)
+			var raised *RaiseError
+			Expect(errors.As(err, &raised)).To(BeTrue())
+			Expect(raised.Message).To(Equal("Conversation roles must alternate user/assistant/user/assistant/..."))
+		})
+	})
+
+	Context("language", func() {
+		It("assigns namespace attributes from the loops", func() {
+			Expect(render(`{%- set ns = namespace(found=false, n=0) -%}
+{%- for m in messages if m.role != 'system' -%}
+{%- set ns.n = ns.n + 1 -%}
+{%- if m.role == 'tool' %}{% set ns.found = true %}{% endif -%}
+{%- endfor -%}
+{{ ns.n }} {{ ns.found }}`, map[string]interface{}{
+				"messages": []map[string]interface{}{{"role": "system"}, {"role": "user"}, {"role": "tool"}},
+			})).To(Equal("2 True"))
+		})
+
+		It("exposes the loop variables", func() {
+			Expect(render(`{% for x in items %}{{ loop.index }}/{{ loop.length }}{% if not loop.last %},{% endif %}{% else %}empty{% endfor %}`,
+				map[string]interface{}{"items": []string{"a", "b", "c"}})).To(Equal("1/3,2/3,3/3"))
+			Expect(render(`{% for x in items %}{{ x }}{% else %}empty{% endfor %}`, map[string]interface{}{})).To(Equal("empty"))
+			Expect(render(`{% for k, v in d.items() %}{{ k }}={{ v }};{% endfor %}`,
+				map[string]interface{}{"d": map[string]int{"b": 2, "a": 1}})).To(Equal("a=1;b=2;"))
+		})
+
+		It("applies the whitespace control of the tags", func() {
+			Expect(render("{% if true %}\n  yes\n{% endif %}\n", nil)).To(Equal("  yes\n"))
+			Expect(render("a  {{- 'b' -}}  c", nil)).To(Equal("abc"))
+			Expect(render("{# comment #}x", nil)).To(Equal("x"))
+		})
+
+		It("evaluates filters, tests, methods and macros", func() {
+			Expect(render(`{{ "  Hi  " | trim | upper }}`, nil)).To(Equal("HI"))
+			Expect(render(`{{ [3, 1, 2] | sort | join(",") }}`, nil)).To(Equal("1,2,3"))
+			Expect(render(`{{ x | default("none") }} {{ y is defined }} {{ 4 is divisibleby 2 }}`, nil)).To(Equal("none False True"))
+			Expect(render(`{{ "a,b".split(",") | length }} {{ "Hello"[1:3] }} {{ "x".startswith("x") }}`, nil)).To(Equal("2 el True"))
+			Expect(render(`{{ msgs | selectattr("role", "equalto", "user") | map(attribute="content") | list | tojson }}`,
+				map[string]interface{}{"msgs": []map[string]string{{"role": "user", "content": "a"}, {"role": "bot", "content": "b"}}})).To(Equal(`["a"]`))
+			Expect(render(`{% macro greet(name, suffix="!") %}Hi {{ name }}{{ suffix }}{% endmacro %}{{ greet("you") }}`, nil)).To(Equal("Hi you!"))
+			Expect(render(`{{ 7 // 2 }} {{ 7 / 2 }} {{ 2 ** 3 }} {{ "a" ~ 1 }} {{ 1 if false else 2 }}`, nil)).To(Equal("3 3.5 8 a1 2"))
+		})
+
+		It("keeps the insertion order of the dicts", func() {
+			Expect(render(`{% set d = {"b": 1, "a": 2} %}{% set _ = d.update({"c": 3}) %}{% for k, v in d.items() %}{{ k }}={{ v }};{% endfor %}{{ d | tojson }}`, nil)).
+				To(Equal(`b=1;a=2;c=3;{"b": 1, "a": 2, "c": 3}`))
+			Expect(render(`{{ (dict(x=1) if d.update([["z", 0]]) is none else {}) | tojson }} {{ d.keys() | list | join(",") }}`,
+				map[string]interface{}{"d": json.RawMessage(`{"y": 1, "w": {"q": 1, "p": 2}}`)})).To(Equal(`{"x": 1} y,w,z`))
+			Expect(render(`{{ d.w | tojson }}`, map[string]interface{}{"d": json.RawMessage(`{"w": {"q": 1, "p": 2}}`)})).To(Equal(`{"q": 1, "p": 2}`))
+		})
+
+		It("formats the strings with the format filter", func() {
+			Expect(render(`{{ "%s has %d items, %.2f%%" | format(name, 3, 12.345) }}`, map[string]interface{}{"name": "list"})).
+				To(Equal("list has 3 items, 12.35%"))
+			Expect(render(`{{ "%(role)s: %(content)r" | format(role="user", content="hi") }} {{ "%5s|%-3d|" | format("a", 7) }}`, nil)).
+				To(Equal("user: 'hi'     a|7  |"))
+			t, err := Parse(`{{ "%s %s" | format("a") }}`)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = t.Execute(nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("reports the errors of the rendering", func() {
+			for _, src := range []string{
+				`{{ 'a\nb' | indent(-3) }}`,
+				`{{ [1] | tojson(indent=-1) }}`,
+				`{{ range(0, 10, 0) | list }}`,
+				`{{ 1 // 0 }}`,
+				`{{ "a" | items }}`,
+				`{{ x() }}`,
+			} {
+				t, err := Parse(src)
+				Expect(err).ToNot(HaveOccurred(), src)
+				_, err = t.Execute(nil)
+				Expect(err).To(HaveOccurred(), src)
+			}
+		})
+
+		It("limits the recursion and the iterations", func() {
+			for _, src := range []string{
+				`{% macro f(n) %}{{ f(n) }}{% endmacro %}{{ f(1) }}`,
+				`{% for i in range(1000) %}{% for j in range(1000) %}{% endfor %}{% endfor %}`,
+				`{{ range(1000000000) | length }}`,
+				`{{ "a" * 1000000000 }}`,
+				`{{ ([1] * 1000000000) | length }}`,
+			} {
+				t, err := Parse(src)
+				Expect(err).ToNot(HaveOccurred(), src)
+				_, err = t.Execute(nil)
+				Expect(err).To(HaveOccurred(), src)
+			}
+			Expect(render(`{% macro f(n) %}{% if n > 0 %}{{ f(n - 1) }}{{ n }}{% endif %}{% endmacro %}{{ f(5) }}`, nil)).To(Equal("12345"))
+		})
+
+		It("reports the syntax errors", func() {
+			_, err := Parse("{% if true %}")
+			Expect(err).To(HaveOccurred())
+			_, err = Parse("{{ 1 + }}")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
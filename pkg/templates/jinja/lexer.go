@@ -0,0 +1,306 @@
+package jinja
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type chunkKind int
+
+const (
+	chunkText chunkKind = iota
+	chunkExpr
+	chunkBlock
+	chunkComment
+)
+
+// chunk is a piece of the template source: a text, or the content of a tag
+type chunk struct {
+	kind chunkKind
+	src  string
+	line int
+
+	// whitespace control of the tags
+	trimLeft, trimRight, keepLeft bool
+}
+
+// findTagEnd returns the index of the end delimiter of a tag, skipping the strings of the expressions
+func findTagEnd(src string, from int, end string) int {
+	var quote byte
+	for i := from; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case end != "#}" && (c == '\'' || c == '"'):
+			quote = c
+		case strings.HasPrefix(src[i:], end):
+			return i
+		}
+	}
+	return -1
+}
+
+// splitTemplate splits the source of a template in texts and tags, applying the whitespace control
+// of the tags, and the trim_blocks and lstrip_blocks options of the HuggingFace templates
+func splitTemplate(src string) ([]chunk, error) {
+	chunks := []chunk{}
+	line := 1
+	for i := 0; i < len(src); {
+		start := -1
+		for j := i; j < len(src)-1; j++ {
+			if src[j] == '{' && (src[j+1] == '{' || src[j+1] == '%' || src[j+1] == '#') {
+				start = j
+				break
+			}
+		}
+		if start < 0 {
+			chunks = append(chunks, chunk{kind: chunkText, src: src[i:], line: line})
+			break
+		}
+		if start > i {
+			chunks = append(chunks, chunk{kind: chunkText, src: src[i:start], line: line})
+			line += strings.Count(src[i:start], "\n")
+		}
+
+		c := chunk{line: line}
+		var end string
+		switch src[start+1] {
+		case '{':
+			c.kind, end = chunkExpr, "}}"
+		case '%':
+			c.kind, end = chunkBlock, "%}"
+		default:
+			c.kind, end = chunkComment, "#}"
+		}
+		inner := start + 2
+		if inner < len(src) {
+			switch src[inner] {
+			case '-':
+				c.trimLeft = true
+				inner++
+			case '+':
+				c.keepLeft = true
+				inner++
+			}
+		}
+		stop := findTagEnd(src, inner, end)
+		if stop < 0 {
+			return nil, fmt.Errorf("line %d: unclosed tag", line)
+		}
+		content := src[inner:stop]
+		if strings.HasSuffix(content, "-") {
+			c.trimRight = true
+			content = content[:len(content)-1]
+		} else if strings.HasSuffix(content, "+") && c.kind != chunkExpr {
+			content = content[:len(content)-1]
+		}
+		c.src = strings.TrimSpace(content)
+		line += strings.Count(src[start:stop+2], "\n")
+		i = stop + 2
+
+		// the content of raw blocks is kept as is
+		if c.kind == chunkBlock && c.src == "raw" {
+			chunks = append(chunks, c)
+			endRaw := -1
+			for j := i; j < len(src); j++ {
+				if strings.HasPrefix(src[j:], "{%") {
+					k := findTagEnd(src, j+2, "%}")
+					if k > 0 && strings.Trim(src[j+2:k], "-+ \t\r\n") == "endraw" {
+						endRaw = j
+						break
+					}
+				}
+			}
+			if endRaw < 0 {
+				return nil, fmt.Errorf("line %d: raw block is not closed", line)
+			}
+			chunks = append(chunks, chunk{kind: chunkText, src: src[i:endRaw], line: line})
+			line += strings.Count(src[i:endRaw], "\n")
+			i = endRaw
+			continue
+		}
+		chunks = append(chunks, c)
+	}
+
+	for i := range chunks {
+		if chunks[i].kind != chunkText {
+			continue
+		}
+		orig := chunks[i].src
+		text := orig
+		if i > 0 {
+			prev := chunks[i-1]
+			switch {
+			case prev.trimRight:
+				text = strings.TrimLeftFunc(text, unicode.IsSpace)
+			case prev.kind != chunkExpr && !(prev.kind == chunkBlock && prev.src == "raw"):
+				// trim_blocks
+				if strings.HasPrefix(text, "\r\n") {
+					text = text[2:]
+				} else if strings.HasPrefix(text, "\n") {
+					text = text[1:]
+				}
+			}
+		}
+		if i < len(chunks)-1 {
+			next := chunks[i+1]
+			switch {
+			case next.trimLeft:
+				text = strings.TrimRightFunc(text, unicode.IsSpace)
+			case next.kind != chunkExpr && !next.keepLeft && !(i > 0 && chunks[i-1].kind == chunkBlock && chunks[i-1].src == "raw"):
+				// lstrip_blocks
+				lineStart := strings.LastIndex(text, "\n") + 1
+				if strings.TrimLeft(text[lineStart:], " \t") == "" && (lineStart > 0 || i == 0 || strings.Contains(orig, "\n")) {
+					text = text[:lineStart]
+				}
+			}
+		}
+		chunks[i].src = text
+	}
+	return chunks, nil
+}
+
+type tokenKind int
+
+const (
+	tokName tokenKind = iota
+	tokString
+	tokInt
+	tokFloat
+	tokOp
+	tokEOF
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+}
+
+var operators = []string{"//", "**", "==", "!=", "<=", ">=", "+", "-", "*", "/", "%", "~", "<", ">", "=", "(", ")", "[", "]", "{", "}", ".", ",", ":", "|"}
+
+// tokenize splits the content of a tag in tokens
+func tokenize(src string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokName, val: src[i:j]})
+			i = j
+		case unicode.IsDigit(rune(c)):
+			j := i
+			kind := tokInt
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '_') {
+				j++
+			}
+			if j+1 < len(src) && src[j] == '.' && unicode.IsDigit(rune(src[j+1])) {
+				kind = tokFloat
+				j++
+				for j < len(src) && unicode.IsDigit(rune(src[j])) {
+					j++
+				}
+			}
+			if j < len(src) && (src[j] == 'e' || src[j] == 'E') {
+				k := j + 1
+				if k < len(src) && (src[k] == '+' || src[k] == '-') {
+					k++
+				}
+				if k < len(src) && unicode.IsDigit(rune(src[k])) {
+					kind = tokFloat
+					j = k
+					for j < len(src) && unicode.IsDigit(rune(src[j])) {
+						j++
+					}
+				}
+			}
+			tokens = append(tokens, token{kind: kind, val: strings.ReplaceAll(src[i:j], "_", "")})
+			i = j
+		case c == '\'' || c == '"':
+			s, n, err := unquote(src[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokString, val: s})
+			i += n
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{kind: tokOp, val: op})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+// unquote reads a string literal, returning its value and its length in the source
+func unquote(src string) (string, int, error) {
+	quote := src[0]
+	sb := strings.Builder{}
+	for i := 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == quote:
+			return sb.String(), i + 1, nil
+		case c == '\\' && i+1 < len(src):
+			i++
+			switch src[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case '0':
+				sb.WriteByte(0)
+			case 'u', 'x':
+				size := 4
+				if src[i] == 'x' {
+					size = 2
+				}
+				if i+size >= len(src) {
+					return "", 0, fmt.Errorf("invalid escape sequence in %s", src)
+				}
+				var r rune
+				if _, err := fmt.Sscanf(src[i+1:i+1+size], "%x", &r); err != nil {
+					return "", 0, fmt.Errorf("invalid escape sequence in %s", src)
+				}
+				sb.WriteRune(r)
+				i += size
+			case '\n':
+				// line continuation
+			default:
+				if src[i] != '\\' && src[i] != '\'' && src[i] != '"' {
+					sb.WriteByte('\\')
+				}
+				sb.WriteByte(src[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string %s", src)
+}
@@ -0,0 +1,818 @@
+package jinja
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// statements of the templates
+
+type node interface{}
+
+type textNode struct{ text string }
+
+type outputNode struct{ expr expr }
+
+type ifNode struct {
+	conds  []expr
+	bodies [][]node
+	orelse []node
+}
+
+type forNode struct {
+	targets []string
+	iter    expr
+	cond    expr
+	body    []node
+	orelse  []node
+}
+
+type setNode struct {
+	targets []string
+	// attr is set for the assignments of namespace attributes (ns.attr = value)
+	attr  string
+	value expr
+	// body is the content of the block assignments ({% set x %}...{% endset %})
+	body []node
+}
+
+type macroNode struct {
+	name     string
+	params   []string
+	defaults map[string]expr
+	body     []node
+}
+
+type callBlockNode struct {
+	call expr
+	body []node
+}
+
+type doNode struct{ expr expr }
+
+type breakNode struct{}
+
+type continueNode struct{}
+
+// expressions
+
+type expr interface{}
+
+type literal struct{ value interface{} }
+
+type nameExpr struct{ name string }
+
+type listExpr struct{ items []expr }
+
+type dictExpr struct{ keys, values []expr }
+
+type attrExpr struct {
+	obj  expr
+	name string
+}
+
+type indexExpr struct{ obj, index expr }
+
+type sliceExpr struct{ obj, start, stop, step expr }
+
+type callExpr struct {
+	fn     expr
+	args   []expr
+	kwargs []kwarg
+}
+
+type kwarg struct {
+	name  string
+	value expr
+}
+
+type filterExpr struct {
+	operand expr
+	name    string
+	args    []expr
+	kwargs  []kwarg
+}
+
+type testExpr struct {
+	operand expr
+	name    string
+	args    []expr
+	negate  bool
+}
+
+type unaryExpr struct {
+	op      string
+	operand expr
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+type condExpr struct{ cond, yes, no expr }
+
+// parser reads the expressions of a tag
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	return t.kind == tokOp && slices.Contains(ops, t.val)
+}
+
+func (p *parser) isName(names ...string) bool {
+	t := p.peek()
+	return t.kind == tokName && slices.Contains(names, t.val)
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.isOp(op) {
+		return fmt.Errorf("expected %q, found %q", op, p.peek().val)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) expectName() (string, error) {
+	t := p.next()
+	if t.kind != tokName {
+		return "", fmt.Errorf("expected a name, found %q", t.val)
+	}
+	return t.val, nil
+}
+
+func (p *parser) expectEnd() error {
+	if p.peek().kind != tokEOF {
+		return fmt.Errorf("unexpected %q", p.peek().val)
+	}
+	return nil
+}
+
+// parseTuple parses an expression, or a tuple of expressions separated by commas
+func (p *parser) parseTuple() (expr, error) {
+	e, err := p.parseExpr()
+	if err != nil || !p.isOp(",") {
+		return e, err
+	}
+	items := []expr{e}
+	for p.isOp(",") {
+		p.next()
+		if p.peek().kind == tokEOF || p.isOp(")") {
+			break
+		}
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, e)
+	}
+	return listExpr{items: items}, nil
+}
+
+func (p *parser) parseExpr() (expr, error) {
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isName("if") {
+		return e, nil
+	}
+	p.next()
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	var no expr
+	if p.isName("else") {
+		p.next()
+		if no, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	return condExpr{cond: cond, yes: e, no: no}, nil
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.isName("or") {
+		p.next()
+		var right expr
+		if right, err = p.parseAnd(); err == nil {
+			left = binaryExpr{op: "or", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	for err == nil && p.isName("and") {
+		p.next()
+		var right expr
+		if right, err = p.parseNot(); err == nil {
+			left = binaryExpr{op: "and", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.isName("not") {
+		p.next()
+		e, err := p.parseNot()
+		return unaryExpr{op: "not", operand: e}, err
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (expr, error) {
+	left, err := p.parseMath1()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch {
+		case p.isOp("==", "!=", "<", ">", "<=", ">="):
+			op = p.next().val
+		case p.isName("in"):
+			p.next()
+			op = "in"
+		case p.isName("not") && p.tokens[p.pos+1].kind == tokName && p.tokens[p.pos+1].val == "in":
+			p.pos += 2
+			op = "not in"
+		default:
+			return left, nil
+		}
+		right, err := p.parseMath1()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseMath1() (expr, error) {
+	left, err := p.parseConcat()
+	for err == nil && p.isOp("+", "-") {
+		op := p.next().val
+		var right expr
+		if right, err = p.parseConcat(); err == nil {
+			left = binaryExpr{op: op, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseConcat() (expr, error) {
+	left, err := p.parseMath2()
+	for err == nil && p.isOp("~") {
+		p.next()
+		var right expr
+		if right, err = p.parseMath2(); err == nil {
+			left = binaryExpr{op: "~", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseMath2() (expr, error) {
+	left, err := p.parsePow()
+	for err == nil && p.isOp("*", "/", "//", "%") {
+		op := p.next().val
+		var right expr
+		if right, err = p.parsePow(); err == nil {
+			left = binaryExpr{op: op, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parsePow() (expr, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.isOp("**") {
+		p.next()
+		var right expr
+		if right, err = p.parseUnary(); err == nil {
+			left = binaryExpr{op: "**", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	if p.isOp("-", "+") {
+		op := p.next().val
+		e, err := p.parseUnary()
+		return unaryExpr{op: op, operand: e}, err
+	}
+	e, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if e, err = p.parsePostfix(e); err != nil {
+		return nil, err
+	}
+	if e, err = p.parseFilters(e); err != nil {
+		return nil, err
+	}
+	return p.parseTests(e)
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		s := t.val
+		// adjacent strings are concatenated
+		for p.peek().kind == tokString {
+			s += p.next().val
+		}
+		return literal{value: s}, nil
+	case tokInt:
+		i, err := strconv.Atoi(t.val)
+		return literal{value: i}, err
+	case tokFloat:
+		f, err := strconv.ParseFloat(t.val, 64)
+		return literal{value: f}, err
+	case tokName:
+		switch t.val {
+		case "true", "True":
+			return literal{value: true}, nil
+		case "false", "False":
+			return literal{value: false}, nil
+		case "none", "None":
+			return literal{value: nil}, nil
+		}
+		return nameExpr{name: t.val}, nil
+	case tokOp:
+		switch t.val {
+		case "(":
+			if p.isOp(")") {
+				p.next()
+				return listExpr{}, nil
+			}
+			e, err := p.parseTuple()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expectOp(")")
+		case "[":
+			items, err := p.parseList("]")
+			return listExpr{items: items}, err
+		case "{":
+			d := dictExpr{}
+			for !p.isOp("}") {
+				k, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expectOp(":"); err != nil {
+					return nil, err
+				}
+				v, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				d.keys = append(d.keys, k)
+				d.values = append(d.values, v)
+				if !p.isOp(",") {
+					break
+				}
+				p.next()
+			}
+			return d, p.expectOp("}")
+		}
+	}
+	if t.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q", t.val)
+}
+
+// parseList parses a list of expressions up to the closing delimiter
+func (p *parser) parseList(closing string) ([]expr, error) {
+	items := []expr{}
+	for !p.isOp(closing) {
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, e)
+		if !p.isOp(",") {
+			break
+		}
+		p.next()
+	}
+	return items, p.expectOp(closing)
+}
+
+func (p *parser) parseArgs() ([]expr, []kwarg, error) {
+	args := []expr{}
+	kwargs := []kwarg{}
+	for !p.isOp(")") {
+		if p.peek().kind == tokName && p.tokens[p.pos+1].kind == tokOp && p.tokens[p.pos+1].val == "=" {
+			name := p.next().val
+			p.next()
+			v, err := p.parseExpr()
+			if err != nil {
+				return nil, nil, err
+			}
+			kwargs = append(kwargs, kwarg{name: name, value: v})
+		} else {
+			v, err := p.parseExpr()
+			if err != nil {
+				return nil, nil, err
+			}
+			args = append(args, v)
+		}
+		if !p.isOp(",") {
+			break
+		}
+		p.next()
+	}
+	return args, kwargs, p.expectOp(")")
+}
+
+func (p *parser) parsePostfix(e expr) (expr, error) {
+	for {
+		switch {
+		case p.isOp("."):
+			p.next()
+			t := p.next()
+			if t.kind != tokName && t.kind != tokInt {
+				return nil, fmt.Errorf("expected an attribute, found %q", t.val)
+			}
+			if t.kind == tokInt {
+				i, _ := strconv.Atoi(t.val)
+				e = indexExpr{obj: e, index: literal{value: i}}
+			} else {
+				e = attrExpr{obj: e, name: t.val}
+			}
+		case p.isOp("["):
+			p.next()
+			var parts [3]expr
+			isSlice := false
+			for i := 0; i < 3; i++ {
+				if !p.isOp(":", "]") {
+					v, err := p.parseExpr()
+					if err != nil {
+						return nil, err
+					}
+					parts[i] = v
+				}
+				if !p.isOp(":") || i == 2 {
+					break
+				}
+				p.next()
+				isSlice = true
+			}
+			if err := p.expectOp("]"); err != nil {
+				return nil, err
+			}
+			if isSlice {
+				e = sliceExpr{obj: e, start: parts[0], stop: parts[1], step: parts[2]}
+			} else {
+				e = indexExpr{obj: e, index: parts[0]}
+			}
+		case p.isOp("("):
+			p.next()
+			args, kwargs, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			e = callExpr{fn: e, args: args, kwargs: kwargs}
+		default:
+			return e, nil
+		}
+	}
+}
+
+func (p *parser) parseFilters(e expr) (expr, error) {
+	for p.isOp("|") {
+		p.next()
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		f := filterExpr{operand: e, name: name}
+		if p.isOp("(") {
+			p.next()
+			if f.args, f.kwargs, err = p.parseArgs(); err != nil {
+				return nil, err
+			}
+		}
+		e = f
+	}
+	return e, nil
+}
+
+func (p *parser) parseTests(e expr) (expr, error) {
+	if !p.isName("is") {
+		return e, nil
+	}
+	p.next()
+	t := testExpr{operand: e}
+	if p.isName("not") {
+		p.next()
+		t.negate = true
+	}
+	// tests can be named after keywords or operators
+	tok := p.next()
+	switch tok.kind {
+	case tokName, tokOp:
+		t.name = tok.val
+	default:
+		return nil, fmt.Errorf("expected a test name, found %q", tok.val)
+	}
+	switch {
+	case p.isOp("("):
+		p.next()
+		args, _, err := p.parseArgs()
+		if err != nil {
+			return nil, err
+		}
+		t.args = args
+	case p.peek().kind == tokString || p.peek().kind == tokInt || p.peek().kind == tokFloat || (p.peek().kind == tokName && !p.isName("and", "or", "else", "if", "is", "in", "not")):
+		// tests accept a single argument without parentheses (x is divisibleby 3)
+		arg, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if arg, err = p.parsePostfix(arg); err != nil {
+			return nil, err
+		}
+		t.args = []expr{arg}
+	}
+	return t, nil
+}
+
+// template parser
+
+type templateParser struct {
+	chunks []chunk
+	pos    int
+}
+
+// parseBody parses the statements up to one of the end tags, returning the tag found and the parser of its content
+func (tp *templateParser) parseBody(ends ...string) ([]node, string, *parser, error) {
+	nodes := []node{}
+	for tp.pos < len(tp.chunks) {
+		c := tp.chunks[tp.pos]
+		tp.pos++
+		switch c.kind {
+		case chunkComment:
+		case chunkText:
+			if c.src != "" {
+				nodes = append(nodes, textNode{text: c.src})
+			}
+		case chunkExpr:
+			tokens, err := tokenize(c.src)
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("line %d: %w", c.line, err)
+			}
+			p := &parser{tokens: tokens}
+			e, err := p.parseTuple()
+			if err == nil {
+				err = p.expectEnd()
+			}
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("line %d: %w", c.line, err)
+			}
+			nodes = append(nodes, outputNode{expr: e})
+		case chunkBlock:
+			tokens, err := tokenize(c.src)
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("line %d: %w", c.line, err)
+			}
+			p := &parser{tokens: tokens}
+			keyword, err := p.expectName()
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("line %d: %w", c.line, err)
+			}
+			if slices.Contains(ends, keyword) {
+				return nodes, keyword, p, nil
+			}
+			n, err := tp.parseStatement(keyword, p)
+			if err != nil {
+				return nil, "", nil, fmt.Errorf("line %d: %w", c.line, err)
+			}
+			if n != nil {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	if len(ends) > 0 {
+		return nil, "", nil, fmt.Errorf("missing %s", ends[len(ends)-1])
+	}
+	return nodes, "", nil, nil
+}
+
+func (tp *templateParser) parseStatement(keyword string, p *parser) (node, error) {
+	switch keyword {
+	case "if":
+		n := ifNode{}
+		cond, err := p.parseTuple()
+		if err != nil {
+			return nil, err
+		}
+		for {
+			if err := p.expectEnd(); err != nil {
+				return nil, err
+			}
+			body, end, endParser, err := tp.parseBody("elif", "else", "endif")
+			if err != nil {
+				return nil, err
+			}
+			n.conds = append(n.conds, cond)
+			n.bodies = append(n.bodies, body)
+			p = endParser
+			switch end {
+			case "elif":
+				if cond, err = p.parseTuple(); err != nil {
+					return nil, err
+				}
+			case "else":
+				if err := p.expectEnd(); err != nil {
+					return nil, err
+				}
+				if n.orelse, _, p, err = tp.parseBody("endif"); err != nil {
+					return nil, err
+				}
+				return n, p.expectEnd()
+			default:
+				return n, p.expectEnd()
+			}
+		}
+	case "for":
+		n := forNode{}
+		for {
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			n.targets = append(n.targets, name)
+			if !p.isOp(",") {
+				break
+			}
+			p.next()
+		}
+		if !p.isName("in") {
+			return nil, fmt.Errorf("expected 'in' in for loop")
+		}
+		p.next()
+		iter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		n.iter = iter
+		if p.isName("if") {
+			p.next()
+			if n.cond, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+		}
+		if p.isName("recursive") {
+			return nil, fmt.Errorf("recursive loops are not supported")
+		}
+		if err := p.expectEnd(); err != nil {
+			return nil, err
+		}
+		body, end, p, err := tp.parseBody("else", "endfor")
+		if err != nil {
+			return nil, err
+		}
+		n.body = body
+		if end == "else" {
+			if err := p.expectEnd(); err != nil {
+				return nil, err
+			}
+			if n.orelse, _, p, err = tp.parseBody("endfor"); err != nil {
+				return nil, err
+			}
+		}
+		return n, p.expectEnd()
+	case "set":
+		n := setNode{}
+		for {
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			n.targets = append(n.targets, name)
+			if p.isOp(".") && len(n.targets) == 1 {
+				p.next()
+				if n.attr, err = p.expectName(); err != nil {
+					return nil, err
+				}
+			}
+			if !p.isOp(",") {
+				break
+			}
+			p.next()
+		}
+		if p.peek().kind == tokEOF {
+			body, _, end, err := tp.parseBody("endset")
+			if err != nil {
+				return nil, err
+			}
+			n.body = body
+			return n, end.expectEnd()
+		}
+		if err := p.expectOp("="); err != nil {
+			return nil, err
+		}
+		value, err := p.parseTuple()
+		if err != nil {
+			return nil, err
+		}
+		n.value = value
+		return n, p.expectEnd()
+	case "macro":
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		n := macroNode{name: name, defaults: map[string]expr{}}
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		for !p.isOp(")") {
+			param, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			n.params = append(n.params, param)
+			if p.isOp("=") {
+				p.next()
+				if n.defaults[param], err = p.parseExpr(); err != nil {
+					return nil, err
+				}
+			}
+			if !p.isOp(",") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		if err := p.expectEnd(); err != nil {
+			return nil, err
+		}
+		body, _, end, err := tp.parseBody("endmacro")
+		if err != nil {
+			return nil, err
+		}
+		n.body = body
+		return n, end.expectEnd()
+	case "call":
+		call, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectEnd(); err != nil {
+			return nil, err
+		}
+		body, _, end, err := tp.parseBody("endcall")
+		if err != nil {
+			return nil, err
+		}
+		return callBlockNode{call: call, body: body}, end.expectEnd()
+	case "raw", "generation", "filter":
+		if keyword == "filter" {
+			return nil, fmt.Errorf("filter blocks are not supported")
+		}
+		body, _, end, err := tp.parseBody("end" + keyword)
+		if err != nil {
+			return nil, err
+		}
+		// the body is rendered as is: the generation blocks only mark the assistant messages
+		return ifNode{conds: []expr{literal{value: true}}, bodies: [][]node{body}}, end.expectEnd()
+	case "break":
+		return breakNode{}, p.expectEnd()
+	case "continue":
+		return continueNode{}, p.expectEnd()
+	case "do":
+		e, err := p.parseTuple()
+		if err != nil {
+			return nil, err
+		}
+		return doNode{expr: e}, p.expectEnd()
+	}
+	return nil, fmt.Errorf("unknown tag %q", keyword)
+}
@@ -0,0 +1,584 @@
+package jinja
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// undefined is the value of the variables and attributes which do not exist
+type undefined struct{ name string }
+
+// namespace holds the values which can be assigned from the inner scopes of the loops
+type namespace struct{ attrs map[string]interface{} }
+
+type callable func(args []interface{}, kwargs map[string]interface{}) (interface{}, error)
+
+// dict is the mapping of the templates. It keeps the order in which its keys are inserted, as Python's dicts
+type dict struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newDict() *dict {
+	return &dict{values: map[string]interface{}{}}
+}
+
+// dictFromMap returns a dict of the entries of a map, sorted by key since the maps have no order
+func dictFromMap(m map[string]interface{}) *dict {
+	d := newDict()
+	for _, k := range sortedKeys(m) {
+		d.set(k, m[k])
+	}
+	return d
+}
+
+func (d *dict) get(k string) (interface{}, bool) {
+	v, exists := d.values[k]
+	return v, exists
+}
+
+func (d *dict) set(k string, v interface{}) {
+	if _, exists := d.values[k]; !exists {
+		d.keys = append(d.keys, k)
+	}
+	d.values[k] = v
+}
+
+func (d *dict) len() int {
+	return len(d.keys)
+}
+
+// update sets the entries of a dict or of a list of [key, value] pairs, as Python's dict.update
+func (d *dict) update(other interface{}) error {
+	switch t := other.(type) {
+	case *dict:
+		for _, k := range t.keys {
+			d.set(k, t.values[k])
+		}
+		return nil
+	case []interface{}:
+		for _, item := range t {
+			pair, ok := item.([]interface{})
+			if !ok || len(pair) != 2 {
+				return fmt.Errorf("cannot update a dict with %s", repr(item))
+			}
+			d.set(toString(pair[0]), pair[1])
+		}
+		return nil
+	case nil, undefined:
+		return nil
+	}
+	return fmt.Errorf("cannot update a dict with %s", typeName(other))
+}
+
+// items returns the [key, value] pairs of the dict
+func (d *dict) items() []interface{} {
+	items := make([]interface{}, len(d.keys))
+	for i, k := range d.keys {
+		items[i] = []interface{}{k, d.values[k]}
+	}
+	return items
+}
+
+// normalize converts the values passed to the templates to the types handled by the renderer:
+// nil, bool, int, float64, string, []interface{} and *dict
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil, bool, int, string, undefined, *namespace, callable:
+		return v
+	case *dict:
+		d := newDict()
+		for _, k := range t.keys {
+			d.set(k, normalize(t.values[k]))
+		}
+		return d
+	case float64:
+		// the numbers decoded from JSON are floats: the integers are rendered as such
+		if t == math.Trunc(t) && math.Abs(t) < 1<<53 {
+			return int(t)
+		}
+		return t
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, e := range t {
+			l[i] = normalize(e)
+		}
+		return l
+	case map[string]interface{}:
+		d := newDict()
+		for _, k := range sortedKeys(t) {
+			d.set(k, normalize(t[k]))
+		}
+		return d
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return int(i)
+		}
+		f, _ := t.Float64()
+		return f
+	case json.RawMessage:
+		// the objects keep the order of their keys
+		decoded, err := decodeJSON(json.NewDecoder(strings.NewReader(string(t))))
+		if err != nil {
+			return string(t)
+		}
+		return decoded
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return normalize(rv.Float())
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return normalize(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		l := make([]interface{}, rv.Len())
+		for i := range l {
+			l[i] = normalize(rv.Index(i).Interface())
+		}
+		return l
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = iter.Value().Interface()
+		}
+		return normalize(m)
+	}
+
+	// structs are converted to dicts with their JSON representation, in the order of their fields
+	dat, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	decoded, err := decodeJSON(json.NewDecoder(strings.NewReader(string(dat))))
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return decoded
+}
+
+// decodeJSON decodes a JSON value to the types of the renderer, the objects to dicts in the order of their keys
+func decodeJSON(d *json.Decoder) (interface{}, error) {
+	d.UseNumber()
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '[':
+			l := []interface{}{}
+			for d.More() {
+				e, err := decodeJSON(d)
+				if err != nil {
+					return nil, err
+				}
+				l = append(l, e)
+			}
+			_, err := d.Token()
+			return l, err
+		case '{':
+			m := newDict()
+			for d.More() {
+				k, err := d.Token()
+				if err != nil {
+					return nil, err
+				}
+				e, err := decodeJSON(d)
+				if err != nil {
+					return nil, err
+				}
+				m.set(toString(k), e)
+			}
+			_, err := d.Token()
+			return m, err
+		}
+		return nil, fmt.Errorf("unexpected JSON delimiter %s", t)
+	}
+	return normalize(tok), nil
+}
+
+func truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil, undefined:
+		return false
+	case bool:
+		return t
+	case int:
+		return t != 0
+	case float64:
+		return t != 0
+	case string:
+		return t != ""
+	case []interface{}:
+		return len(t) > 0
+	case *dict:
+		return t.len() > 0
+	}
+	return true
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int, float64, bool:
+		return true
+	}
+	return false
+}
+
+func toFloat(v interface{}) float64 {
+	switch t := v.(type) {
+	case int:
+		return float64(t)
+	case float64:
+		return t
+	case bool:
+		if t {
+			return 1
+		}
+	}
+	return 0
+}
+
+func toInt(v interface{}) int {
+	switch t := v.(type) {
+	case int:
+		return t
+	case float64:
+		return int(t)
+	case bool:
+		if t {
+			return 1
+		}
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(t)); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(t), 64); err == nil {
+			return int(f)
+		}
+	}
+	return 0
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// toString converts a value to a string as Python's str()
+func toString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "None"
+	case undefined:
+		return ""
+	case bool:
+		if t {
+			return "True"
+		}
+		return "False"
+	case int:
+		return strconv.Itoa(t)
+	case float64:
+		return formatFloat(t)
+	case string:
+		return t
+	}
+	return repr(v)
+}
+
+// repr converts a value to a string as Python's repr()
+func repr(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		if strings.Contains(t, "'") && !strings.Contains(t, `"`) {
+			return `"` + t + `"`
+		}
+		r := strings.NewReplacer(`\`, `\\`, "'", `\'`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+		return "'" + r.Replace(t) + "'"
+	case []interface{}:
+		items := make([]string, len(t))
+		for i, e := range t {
+			items[i] = repr(e)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case *dict:
+		items := []string{}
+		for _, k := range t.keys {
+			items = append(items, repr(k)+": "+repr(t.values[k]))
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	case *namespace:
+		return "<Namespace " + repr(dictFromMap(t.attrs)) + ">"
+	case callable:
+		return "<function>"
+	}
+	return toString(v)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// toJSON encodes a value as Python's json.dumps(ensure_ascii=False), as the tojson filter of the HuggingFace templates
+func toJSON(v interface{}, indent string, depth int) string {
+	newline := func(d int) string {
+		if indent == "" {
+			return ""
+		}
+		return "\n" + strings.Repeat(indent, d)
+	}
+	separator := ", "
+	if indent != "" {
+		separator = ","
+	}
+
+	switch t := v.(type) {
+	case nil, undefined:
+		return "null"
+	case bool:
+		if t {
+			return "true"
+		}
+		return "false"
+	case int:
+		return strconv.Itoa(t)
+	case float64:
+		switch {
+		case math.IsInf(t, 1):
+			return "Infinity"
+		case math.IsInf(t, -1):
+			return "-Infinity"
+		case math.IsNaN(t):
+			return "NaN"
+		}
+		return formatFloat(t)
+	case string:
+		return jsonString(t)
+	case []interface{}:
+		if len(t) == 0 {
+			return "[]"
+		}
+		items := make([]string, len(t))
+		for i, e := range t {
+			items[i] = newline(depth+1) + toJSON(e, indent, depth+1)
+		}
+		return "[" + strings.Join(items, separator) + newline(depth) + "]"
+	case *dict:
+		if t.len() == 0 {
+			return "{}"
+		}
+		items := []string{}
+		for _, k := range t.keys {
+			items = append(items, newline(depth+1)+jsonString(k)+": "+toJSON(t.values[k], indent, depth+1))
+		}
+		return "{" + strings.Join(items, separator) + newline(depth) + "}"
+	case *namespace:
+		return toJSON(dictFromMap(t.attrs), indent, depth)
+	}
+	return jsonString(toString(v))
+}
+
+func jsonString(s string) string {
+	sb := strings.Builder{}
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		default:
+			if r < 0x20 || r == utf8.RuneError {
+				fmt.Fprintf(&sb, `\u%04x`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func equal(a, b interface{}) bool {
+	if isNumber(a) && isNumber(b) {
+		return toFloat(a) == toFloat(b)
+	}
+	switch ta := a.(type) {
+	case undefined:
+		_, ok := b.(undefined)
+		return ok
+	case []interface{}:
+		tb, ok := b.([]interface{})
+		if !ok || len(ta) != len(tb) {
+			return false
+		}
+		for i := range ta {
+			if !equal(ta[i], tb[i]) {
+				return false
+			}
+		}
+		return true
+	case *dict:
+		tb, ok := b.(*dict)
+		if !ok || ta.len() != tb.len() {
+			return false
+		}
+		for k, v := range ta.values {
+			if w, exists := tb.values[k]; !exists || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case *namespace:
+		return a == b
+	case callable:
+		return false
+	}
+	return a == b
+}
+
+func compare(a, b interface{}) (int, error) {
+	switch {
+	case isNumber(a) && isNumber(b):
+		fa, fb := toFloat(a), toFloat(b)
+		switch {
+		case fa < fb:
+			return -1, nil
+		case fa > fb:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	sa, oka := a.(string)
+	sb, okb := b.(string)
+	if oka && okb {
+		return strings.Compare(sa, sb), nil
+	}
+	la, oka := a.([]interface{})
+	lb, okb := b.([]interface{})
+	if oka && okb {
+		for i := 0; i < len(la) && i < len(lb); i++ {
+			if c, err := compare(la[i], lb[i]); err != nil || c != 0 {
+				return c, err
+			}
+		}
+		return len(la) - len(lb), nil
+	}
+	return 0, fmt.Errorf("cannot compare %s and %s", typeName(a), typeName(b))
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "none"
+	case undefined:
+		return "undefined"
+	case bool:
+		return "bool"
+	case int:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "str"
+	case []interface{}:
+		return "list"
+	case *dict:
+		return "dict"
+	case *namespace:
+		return "namespace"
+	case callable:
+		return "function"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// iterate returns the items of a value iterated by the loops
+func iterate(v interface{}) ([]interface{}, error) {
+	switch t := v.(type) {
+	case nil, undefined:
+		return nil, nil
+	case []interface{}:
+		return t, nil
+	case *dict:
+		items := make([]interface{}, len(t.keys))
+		for i, k := range t.keys {
+			items[i] = k
+		}
+		return items, nil
+	case string:
+		items := []interface{}{}
+		for _, r := range t {
+			items = append(items, string(r))
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("%s is not iterable", typeName(v))
+}
+
+func length(v interface{}) (int, error) {
+	switch t := v.(type) {
+	case string:
+		return utf8.RuneCountInString(t), nil
+	case []interface{}:
+		return len(t), nil
+	case *dict:
+		return t.len(), nil
+	case undefined:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%s has no length", typeName(v))
+}
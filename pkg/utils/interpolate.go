@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	// variableRegex matches ${VAR}, ${VAR:-default} and ${file:/path}, and their escaped form $${...}
+	variableRegex = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+	// secretVariableRegex matches the names of the environment variables holding secrets
+	secretVariableRegex = regexp.MustCompile(`(?i)(TOKEN|KEY|SECRET|PASSWORD|PASSWD|CREDENTIAL)`)
+
+	secretsMu  sync.RWMutex
+	secrets    = map[string]struct{}{}
+	secretsDir string
+)
+
+// SetSecretsDir sets the directory of the files which can be read by the ${file:...} references.
+// The references are not expanded while it is not set
+func SetSecretsDir(dir string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secretsDir = dir
+}
+
+// secretFile returns the path of the file of a ${file:...} reference, absolute or relative to the secrets directory
+func secretFile(file string) (string, error) {
+	secretsMu.RLock()
+	dir := secretsDir
+	secretsMu.RUnlock()
+	if dir == "" {
+		return "", fmt.Errorf("the secrets directory is not set")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(file) {
+		if file, err = filepath.Rel(dir, file); err != nil {
+			return "", err
+		}
+	}
+	if err := VerifyPath(file, dir); err != nil {
+		return "", fmt.Errorf("the file is not in the secrets directory %s: %w", dir, err)
+	}
+	return filepath.Join(dir, file), nil
+}
+
+// HasVariables returns true if a string references environment variables or files, the escaped $${...} aside
+func HasVariables(s string) bool {
+	for _, match := range variableRegex.FindAllString(s, -1) {
+		if !strings.HasPrefix(match, "$$") {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandVariables replaces the ${VAR} and ${VAR:-default} references with the value of the environment
+// variables, and the ${file:/path} references with the content of the files of the secrets directory,
+// as the secrets mounted by Docker or Kubernetes. $${...} is kept as ${...}.
+// The values of the files, and of the variables whose names look like secrets, are redacted by RedactSecrets
+func ExpandVariables(s string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	return variableRegex.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		expr := match[2 : len(match)-1]
+
+		if file, isFile := strings.CutPrefix(expr, "file:"); isFile {
+			path, err := secretFile(file)
+			if err != nil {
+				log.Warn().Err(err).Str("file", file).Msg("cannot read the file of a variable")
+				return ""
+			}
+			dat, err := os.ReadFile(path)
+			if err != nil {
+				log.Warn().Err(err).Str("file", file).Msg("cannot read the file of a variable")
+				return ""
+			}
+			value := strings.TrimRight(string(dat), "\r\n")
+			addSecret(value)
+			return value
+		}
+
+		name, def, hasDefault := strings.Cut(expr, ":-")
+		value, exists := os.LookupEnv(name)
+		switch {
+		case value == "" && hasDefault:
+			value = def
+		case !exists:
+			log.Warn().Str("variable", name).Msg("environment variable not set, replaced by an empty string")
+		case secretVariableRegex.MatchString(name):
+			addSecret(value)
+		}
+		return value
+	})
+}
+
+// ExpandYAMLVariables expands the variables of the values of a YAML (or JSON) document. The values are
+// replaced after parsing the document, so that they cannot change its structure. The unquoted values
+// are typed after the expansion: `context_size: ${CONTEXT_SIZE:-4096}` is a number
+func ExpandYAMLVariables(dat []byte) ([]byte, error) {
+	if !bytes.Contains(dat, []byte("${")) {
+		return dat, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(dat, &doc); err != nil {
+		return nil, err
+	}
+	expandNode(&doc)
+	return yaml.Marshal(&doc)
+}
+
+func expandNode(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode && strings.Contains(n.Value, "${") {
+		n.Value = ExpandVariables(n.Value)
+		if n.Style == 0 {
+			n.Tag = ""
+		}
+	}
+	for _, c := range n.Content {
+		expandNode(c)
+	}
+}
+
+func addSecret(value string) {
+	// the short values would redact the unrelated occurrences
+	if len(value) < 4 {
+		return
+	}
+	secretsMu.Lock()
+	secrets[value] = struct{}{}
+	secretsMu.Unlock()
+}
+
+// RedactSecrets replaces the values of the secrets expanded by ExpandVariables
+func RedactSecrets(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
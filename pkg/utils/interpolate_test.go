@@ -0,0 +1,77 @@
+package utils_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/LocalAI/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("utils/interpolate tests", func() {
+	var secretsDir, secretFile string
+
+	BeforeEach(func() {
+		os.Setenv("LOCALAI_TEST_HOST", "10.0.0.1")
+		os.Setenv("LOCALAI_TEST_TOKEN", "hf_environment_token")
+		os.Unsetenv("LOCALAI_TEST_UNSET")
+		secretsDir = GinkgoT().TempDir()
+		SetSecretsDir(secretsDir)
+		secretFile = filepath.Join(secretsDir, "secret")
+		Expect(os.WriteFile(secretFile, []byte("file-secret-value\n"), 0600)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.Unsetenv("LOCALAI_TEST_HOST")
+		os.Unsetenv("LOCALAI_TEST_TOKEN")
+		SetSecretsDir("")
+	})
+
+	It("expands the environment variables and the files", func() {
+		Expect(ExpandVariables("grpc://${LOCALAI_TEST_HOST}:50051")).To(Equal("grpc://10.0.0.1:50051"))
+		Expect(ExpandVariables("${LOCALAI_TEST_UNSET:-default}")).To(Equal("default"))
+		Expect(ExpandVariables("${LOCALAI_TEST_UNSET}")).To(Equal(""))
+		Expect(ExpandVariables("key=${file:" + secretFile + "}")).To(Equal("key=file-secret-value"))
+		Expect(ExpandVariables("$${LOCALAI_TEST_HOST}")).To(Equal("${LOCALAI_TEST_HOST}"))
+		Expect(ExpandVariables("${file:secret}")).To(Equal("file-secret-value"))
+	})
+
+	It("only reads the files of the secrets directory", func() {
+		outside := filepath.Join(GinkgoT().TempDir(), "outside")
+		Expect(os.WriteFile(outside, []byte("outside-value"), 0600)).To(Succeed())
+		Expect(ExpandVariables("${file:" + outside + "}")).To(Equal(""))
+		Expect(ExpandVariables("${file:../" + filepath.Base(filepath.Dir(outside)) + "/outside}")).To(Equal(""))
+
+		SetSecretsDir("")
+		Expect(ExpandVariables("${file:" + secretFile + "}")).To(Equal(""))
+	})
+
+	It("detects the references to variables and files", func() {
+		Expect(HasVariables("${HOME}")).To(BeTrue())
+		Expect(HasVariables("a ${file:/etc/passwd}")).To(BeTrue())
+		Expect(HasVariables("$${HOME} ${}")).To(BeTrue())
+		Expect(HasVariables("$${HOME} $HOME")).To(BeFalse())
+	})
+
+	It("redacts the secrets", func() {
+		ExpandVariables("${file:" + secretFile + "} ${LOCALAI_TEST_TOKEN} ${LOCALAI_TEST_HOST}")
+		Expect(RedactSecrets("file-secret-value hf_environment_token 10.0.0.1")).To(Equal("[REDACTED] [REDACTED] 10.0.0.1"))
+	})
+
+	It("expands the values of the YAML documents", func() {
+		os.Setenv("LOCALAI_TEST_VALUE", "a: [b")
+		defer os.Unsetenv("LOCALAI_TEST_VALUE")
+
+		dat, err := ExpandYAMLVariables([]byte("context_size: ${LOCALAI_TEST_UNSET:-4096}\nquoted: \"${LOCALAI_TEST_UNSET:-4096}\"\nvalue: ${LOCALAI_TEST_VALUE}\n"))
+		Expect(err).ToNot(HaveOccurred())
+		doc := map[string]interface{}{}
+		Expect(yaml.Unmarshal(dat, &doc)).To(Succeed())
+		Expect(doc).To(Equal(map[string]interface{}{
+			"context_size": 4096,
+			"quoted":       "4096",
+			"value":        "a: [b",
+		}))
+	})
+})